@@ -0,0 +1,57 @@
+//go:build wasip1
+
+// addheader 是一个示例请求转换插件: 为上游请求添加 X-Plugin 头,并将 /v1/ 路径改写为 /v2/
+//
+// 构建: GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o addheader.wasm ./examples/plugins/addheader
+//
+// 插件约定(详见 internal/plugin):
+//   - 导出 alloc(size) ptr: 为宿主写入的请求JSON分配内存
+//   - 导出 transform(ptr, len) uint64: 读取请求JSON,返回 (结果指针<<32 | 结果长度),结果为修改后的请求JSON
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unsafe"
+)
+
+// request 与宿主交换的请求(JSON)
+type request struct {
+	Method   string              `json:"method"`
+	Path     string              `json:"path"`
+	RawQuery string              `json:"raw_query,omitempty"`
+	Headers  map[string][]string `json:"headers"`
+}
+
+// input/output 保持引用,避免被GC回收(宿主在调用期间读写这些内存)
+var input, output []byte
+
+//go:wasmexport alloc
+func alloc(size uint32) uint32 {
+	input = make([]byte, size)
+	if size == 0 {
+		return 0
+	}
+	return uint32(uintptr(unsafe.Pointer(&input[0])))
+}
+
+//go:wasmexport transform
+func transform(ptr, size uint32) uint64 {
+	var req request
+	if err := json.Unmarshal(input[:size], &req); err != nil {
+		return 0
+	}
+
+	if req.Headers == nil {
+		req.Headers = make(map[string][]string)
+	}
+	req.Headers["X-Plugin"] = []string{"addheader"}
+	if rest, ok := strings.CutPrefix(req.Path, "/v1/"); ok {
+		req.Path = "/v2/" + rest
+	}
+
+	output, _ = json.Marshal(req)
+	return uint64(uintptr(unsafe.Pointer(&output[0])))<<32 | uint64(len(output))
+}
+
+func main() {}
@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"api-proxy/internal/mapping"
+)
+
+// 批量导入模式
+const (
+	importModeMerge   = "merge"   // 新增/更新列出的映射,保留其他映射
+	importModeReplace = "replace" // 删除未列出的映射
+)
+
+// ImportRequest 批量导入请求,mappings 与导出格式相同(也支持前缀到目标的对象)
+type ImportRequest struct {
+	Mode     string          `json:"mode"`
+	Mappings json.RawMessage `json:"mappings" binding:"required"`
+}
+
+// handleExportMappings 导出全部映射及扩展配置(用于备份与迁移)
+// 敏感字段默认脱敏(原样导入时按已存储的值还原),?include_secrets=true 时导出明文
+func (h *Handler) handleExportMappings(c *gin.Context) {
+	entries, err := h.mapper.ExportMappings(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("include_secrets") != "true" {
+		for i := range entries {
+			entries[i].Options = entries[i].Options.Redacted()
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"count":    len(entries),
+		"mappings": entries,
+	})
+}
+
+// handleImportMappings 批量导入映射: 先校验全部条目,任一无效则整体拒绝;
+// 写入在单个事务中完成,版本号只递增一次并只发布一次通知
+func (h *Handler) handleImportMappings(c *gin.Context) {
+	var req ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = importModeMerge
+	}
+	if req.Mode != importModeMerge && req.Mode != importModeReplace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be merge or replace"})
+		return
+	}
+
+	entries, err := mapping.ParseEntries(req.Mappings)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 条目校验失败返回 400,Redis 等存储故障返回 500
+	result, err := h.mapper.ApplyMappings(c.Request.Context(), entries, req.Mode == importModeReplace)
+	var invalid mapping.EntryErrors
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, mappingErrorBody(err))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply mappings: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"mode":    req.Mode,
+		"result":  result,
+		"version": h.mapper.GetVersion(),
+	})
+}
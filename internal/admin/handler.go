@@ -3,46 +3,99 @@ package admin
 import (
 	"context"
 	"crypto/subtle"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"api-proxy/internal/config"
+	"api-proxy/internal/health"
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/middleware"
+	"api-proxy/internal/pages"
+	"api-proxy/internal/stats"
 )
 
 const adminSessionCookie = "api_proxy_admin"
 
 // MappingManager 映射管理器接口
 type MappingManager interface {
-	GetAllMappings() map[string]string
+	GetAllTemplates() map[string]string // 原始目标(环境变量未代入),用于接口输出
 	GetMapping(ctx context.Context, prefix string) (string, error)
-	AddMapping(ctx context.Context, prefix, target string) error
-	UpdateMapping(ctx context.Context, prefix, target string) error
+	AddMapping(ctx context.Context, prefix, target string, opts *mapping.Options) error // opts 与目标一同校验并写入
+	UpdateMapping(ctx context.Context, prefix, target string, opts *mapping.Options) error
+	UpsertMapping(ctx context.Context, prefix, target string, opts *mapping.Options) (bool, error)
 	DeleteMapping(ctx context.Context, prefix string) error
 	ForceReload(ctx context.Context) error
+	BumpVersion(ctx context.Context) (int64, error)
 	Count() int
 	GetPrefixes() []string
 	IsInitialized() bool
 	GetVersion() int64
+	GetAllOptions() map[string]mapping.Options
+	SetMappingOptions(ctx context.Context, prefix string, opts mapping.Options) error
+	Diff(ctx context.Context) (mapping.Diff, error)
+	ExportMappings(ctx context.Context) ([]mapping.Entry, error)
+	ApplyMappings(ctx context.Context, entries []mapping.Entry, replace bool) (mapping.ApplyResult, error)
+}
+
+// TrafficStats 流量统计接口(可选)
+type TrafficStats interface {
+	TopClients(n int) []stats.TopNEntry
+	TopClientPrefixes(n int) []stats.TopNEntry
+	TopAPIKeys(n int) []stats.TopNEntry
+	GetSLOStats() map[string]stats.SLOStats
+}
+
+// UpstreamHealth 多目标映射的主动健康检查结果(可选)
+type UpstreamHealth interface {
+	Status() []health.TargetHealth
+}
+
+// SLOStatus 映射延迟目标及当前达标情况
+type SLOStatus struct {
+	mapping.SLO
+	stats.SLOStats
+	Meeting bool `json:"meeting"` // 达标率是否满足目标(未设置目标时恒为true)
 }
 
 // Handler 管理接口处理器（DIP原则：依赖注入）
 type Handler struct {
 	mapper     MappingManager
 	adminToken string
+	traffic    TrafficStats   // 可选,未设置时相关接口返回503
+	upstreams  UpstreamHealth // 可选,未设置时健康检查接口返回503
+
+	maintenance MaintenanceManager // 可选,未设置时维护模式接口返回503
+	basePath    string             // 部署路径前缀(如 /proxy-service),用于页面链接与Cookie路径
+	pprof       bool               // 是否挂载 /debug/pprof 性能分析接口(ENABLE_PPROF)
 }
 
 // NewHandler 创建管理接口处理器
 func NewHandler(mapper MappingManager) *Handler {
 	return &Handler{
 		mapper:     mapper,
-		adminToken: os.Getenv("ADMIN_TOKEN"), // 初始化时读取，避免每次请求都读取
+		adminToken: config.String("ADMIN_TOKEN", ""), // 初始化时读取，避免每次请求都读取
+		basePath:   middleware.NormalizeBasePath(config.String("BASE_PATH", "")),
+		pprof:      config.Bool("ENABLE_PPROF", false), // 默认关闭,避免暴露运行时信息
 	}
 }
 
+// SetTrafficStats 注入客户端流量统计(统计功能禁用时可不设置)
+func (h *Handler) SetTrafficStats(traffic TrafficStats) {
+	h.traffic = traffic
+}
+
+// SetUpstreamHealth 注入主动健康检查结果(健康检查禁用时可不设置)
+func (h *Handler) SetUpstreamHealth(upstreams UpstreamHealth) {
+	h.upstreams = upstreams
+}
+
 // authMiddleware Token认证中间件
 func (h *Handler) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -69,20 +122,56 @@ func (h *Handler) authMiddleware() gin.HandlerFunc {
 
 // handleGetAllMappings 获取所有API映射
 func (h *Handler) handleGetAllMappings(c *gin.Context) {
-	mappings := h.mapper.GetAllMappings()
+	mappings := h.mapper.GetAllTemplates()
+	options := make(map[string]mapping.Options)
+	for prefix, opts := range h.mapper.GetAllOptions() {
+		options[prefix] = opts.Redacted()
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
 		"count":    len(mappings),
 		"mappings": mappings,
+		"options":  options,
+		"slo":      h.sloStatus(options),
 		"version":  h.mapper.GetVersion(),
 	})
 }
 
+// redactedOptions 返回脱敏后的扩展配置(用于响应回显)
+func redactedOptions(opts *mapping.Options) *mapping.Options {
+	if opts == nil {
+		return nil
+	}
+	redacted := opts.Redacted()
+	return &redacted
+}
+
+// sloStatus 汇总配置了延迟目标的映射的达标情况
+func (h *Handler) sloStatus(options map[string]mapping.Options) map[string]SLOStatus {
+	result := make(map[string]SLOStatus)
+	var current map[string]stats.SLOStats
+	if h.traffic != nil {
+		current = h.traffic.GetSLOStats()
+	}
+
+	for prefix, opts := range options {
+		if opts.SLO == nil {
+			continue
+		}
+		status := SLOStatus{SLO: *opts.SLO, SLOStats: current[prefix], Meeting: true}
+		if opts.SLO.Target > 0 && status.Total > 0 {
+			status.Meeting = status.Compliance >= opts.SLO.Target
+		}
+		result[prefix] = status
+	}
+	return result
+}
+
 // handleGetPublicMappings 返回所有映射(公开访问,只读)
 // 用于前端页面动态加载端点列表
 func (h *Handler) handleGetPublicMappings(c *gin.Context) {
-	mappings := h.mapper.GetAllMappings()
+	mappings := h.mapper.GetAllTemplates()
 
 	// 转换为前端需要的格式: {"/prefix": "https://target"}
 	publicMappings := make(map[string]string)
@@ -99,11 +188,13 @@ func (h *Handler) handleGetPublicMappings(c *gin.Context) {
 
 // MappingRequest 映射请求体
 type MappingRequest struct {
-	Prefix string `json:"prefix" binding:"required"`
-	Target string `json:"target" binding:"required"`
+	Prefix  string           `json:"prefix" binding:"required"`
+	Target  string           `json:"target" binding:"required"`
+	Options *mapping.Options `json:"options,omitempty"` // 可选扩展配置
 }
 
-// handleAddMapping 添加新映射
+// handleAddMapping 添加新映射,前缀已存在时返回错误
+// ?upsert=true 时不存在则创建、存在则更新(未携带options时保持原有扩展配置)
 func (h *Handler) handleAddMapping(c *gin.Context) {
 	var req MappingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -113,20 +204,33 @@ func (h *Handler) handleAddMapping(c *gin.Context) {
 		return
 	}
 
+	// 扩展配置与目标一同校验,全部通过后在同一事务中写入
 	ctx := c.Request.Context()
-	if err := h.mapper.AddMapping(ctx, req.Prefix, req.Target); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	created := true
+	var err error
+	if c.Query("upsert") == "true" {
+		created, err = h.mapper.UpsertMapping(ctx, req.Prefix, req.Target, req.Options)
+	} else {
+		err = h.mapper.AddMapping(ctx, req.Prefix, req.Target, req.Options)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, mappingErrorBody(err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	status, action := http.StatusCreated, "added"
+	if !created {
+		status, action = http.StatusOK, "updated"
+	}
+
+	c.JSON(status, gin.H{
 		"success": true,
-		"message": "Mapping added successfully",
+		"created": created,
+		"message": "Mapping " + action + " successfully",
 		"mapping": gin.H{
-			"prefix": req.Prefix,
-			"target": req.Target,
+			"prefix":  req.Prefix,
+			"target":  req.Target,
+			"options": redactedOptions(req.Options),
 		},
 	})
 }
@@ -140,7 +244,8 @@ func (h *Handler) handleUpdateMapping(c *gin.Context) {
 	}
 
 	var req struct {
-		Target string `json:"target" binding:"required"`
+		Target  string           `json:"target" binding:"required"`
+		Options *mapping.Options `json:"options,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -150,11 +255,9 @@ func (h *Handler) handleUpdateMapping(c *gin.Context) {
 		return
 	}
 
-	ctx := c.Request.Context()
-	if err := h.mapper.UpdateMapping(ctx, prefix, req.Target); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+	// 未携带options时保持原有扩展配置不变
+	if err := h.mapper.UpdateMapping(c.Request.Context(), prefix, req.Target, req.Options); err != nil {
+		c.JSON(http.StatusBadRequest, mappingErrorBody(err))
 		return
 	}
 
@@ -162,8 +265,9 @@ func (h *Handler) handleUpdateMapping(c *gin.Context) {
 		"success": true,
 		"message": "Mapping updated successfully",
 		"mapping": gin.H{
-			"prefix": prefix,
-			"target": req.Target,
+			"prefix":  prefix,
+			"target":  req.Target,
+			"options": redactedOptions(req.Options),
 		},
 	})
 }
@@ -209,9 +313,121 @@ func (h *Handler) handleForceReload(c *gin.Context) {
 	})
 }
 
+// handleUpstreamHealth 返回多目标映射各目标的健康状态
+func (h *Handler) handleUpstreamHealth(c *gin.Context) {
+	if h.upstreams == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Health checking is disabled"})
+		return
+	}
+
+	targets := h.upstreams.Status()
+	unhealthy := 0
+	for _, target := range targets {
+		if !target.Healthy {
+			unhealthy++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"targets":   targets,
+		"unhealthy": unhealthy,
+	})
+}
+
+// handleTopClients 返回请求最多的客户端(用于发现扫描器/滥用)
+func (h *Handler) handleTopClients(c *gin.Context) {
+	if h.traffic == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Statistics are disabled"})
+		return
+	}
+
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"clients":  h.traffic.TopClients(limit),
+		"prefixes": h.traffic.TopClientPrefixes(limit),
+	})
+}
+
+// handleTopAPIKeys 返回请求最多的API Key(仅摘要,用于按客户统计用量)
+func (h *Handler) handleTopAPIKeys(c *gin.Context) {
+	if h.traffic == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Statistics are disabled"})
+		return
+	}
+
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"keys":    h.traffic.TopAPIKeys(limit),
+	})
+}
+
+// handleEffectiveConfig 返回当前生效的环境变量配置(敏感值已脱敏)
+func (h *Handler) handleEffectiveConfig(c *gin.Context) {
+	settings := config.Effective()
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"count":   len(settings),
+		"config":  settings,
+	})
+}
+
+// parseLimit 解析 limit 查询参数(默认20),非法时直接返回400
+func parseLimit(c *gin.Context) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return 20, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return 0, false
+	}
+	return n, true
+}
+
+// handleMappingsDiff 比较本实例缓存与Redis中的映射(排查实例同步问题)
+func (h *Handler) handleMappingsDiff(c *gin.Context) {
+	diff, err := h.mapper.Diff(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"in_sync": diff.InSync(),
+		"diff":    diff,
+	})
+}
+
+// handleBumpVersion 递增映射版本号并通知所有实例重新加载(不修改映射,用于排查多实例不同步)
+func (h *Handler) handleBumpVersion(c *gin.Context) {
+	version, err := h.mapper.BumpVersion(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to bump version: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "All instances notified to reload",
+		"version": version,
+	})
+}
+
 // handleAdminPage 管理页面
 func (h *Handler) handleAdminPage(c *gin.Context) {
-	c.File("web/templates/admin.html")
+	pages.Serve(c, "web/templates/admin.html", h.basePath)
 }
 
 // handleAdminLogin 验证Token（用于前端登录）
@@ -271,11 +487,50 @@ func (h *Handler) SetupRoutes(r *gin.Engine) {
 	adminAPI.Use(h.authMiddleware())
 	{
 		adminAPI.GET("", h.handleGetAllMappings)           // 获取所有映射
+		adminAPI.GET("/search", h.handleSearchMappings)    // 按相关度搜索映射
+		adminAPI.GET("/health", h.handleUpstreamHealth)    // 多目标映射的目标健康状态
+		adminAPI.GET("/export", h.handleExportMappings)    // 批量导出映射
+		adminAPI.POST("/import", h.handleImportMappings)   // 批量导入映射(全部成功或全部拒绝)
 		adminAPI.POST("", h.handleAddMapping)              // 添加映射
 		adminAPI.PUT("/*prefix", h.handleUpdateMapping)    // 更新映射
 		adminAPI.DELETE("/*prefix", h.handleDeleteMapping) // 删除映射
 		adminAPI.POST("/reload", h.handleForceReload)      // 强制重载映射
 	}
+
+	// 运维API (需要Token认证)
+	opsAPI := r.Group("/api/admin")
+	opsAPI.Use(h.authMiddleware())
+	{
+		opsAPI.GET("/clients", h.handleTopClients)         // 高频客户端
+		opsAPI.GET("/keys", h.handleTopAPIKeys)            // 按API Key统计用量
+		opsAPI.GET("/mappings/diff", h.handleMappingsDiff) // 本地缓存与Redis差异
+		opsAPI.POST("/mappings/bump", h.handleBumpVersion) // 递增版本号,通知所有实例重载
+		opsAPI.GET("/config", h.handleEffectiveConfig)     // 当前生效的配置(敏感值脱敏)
+
+		opsAPI.GET("/maintenance", h.handleGetMaintenance)              // 维护模式状态
+		opsAPI.PUT("/maintenance", h.handleSetMaintenance)              // 开启全局维护
+		opsAPI.DELETE("/maintenance", h.handleClearMaintenance)         // 关闭全局维护
+		opsAPI.PUT("/maintenance/*prefix", h.handleSetMaintenance)      // 开启前缀维护
+		opsAPI.DELETE("/maintenance/*prefix", h.handleClearMaintenance) // 关闭前缀维护
+	}
+
+	// 性能分析 (可选,需要Token认证)
+	h.setupPprofRoutes(r)
+}
+
+// mappingErrorBody 构造映射写入失败的响应体,校验错误在 errors 中逐项列出
+func mappingErrorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+
+	var multi interface{ Unwrap() []error }
+	if errors.As(err, &multi) {
+		problems := make([]string, 0, len(multi.Unwrap()))
+		for _, problem := range multi.Unwrap() {
+			problems = append(problems, problem.Error())
+		}
+		body["errors"] = problems
+	}
+	return body
 }
 
 func extractPrefixParam(c *gin.Context) (string, error) {
@@ -283,6 +538,10 @@ func extractPrefixParam(c *gin.Context) (string, error) {
 	if prefix == "" {
 		return "", fmt.Errorf("prefix parameter is required")
 	}
+	// 正则映射前缀以 ~ 开头,去掉路由参数自带的 /
+	if trimmed := strings.TrimPrefix(prefix, "/"); mapping.IsPattern(trimmed) {
+		return trimmed, nil
+	}
 	if !strings.HasPrefix(prefix, "/") {
 		prefix = "/" + prefix
 	}
@@ -294,7 +553,7 @@ func (h *Handler) setSessionCookie(c *gin.Context) {
 	cookie := &http.Cookie{
 		Name:     adminSessionCookie,
 		Value:    value,
-		Path:     "/",
+		Path:     h.basePath + "/",
 		HttpOnly: true,
 		Secure:   c.Request.TLS != nil,
 		SameSite: http.SameSiteStrictMode,
@@ -308,7 +567,7 @@ func (h *Handler) clearSessionCookie(c *gin.Context) {
 	cookie := &http.Cookie{
 		Name:     adminSessionCookie,
 		Value:    "",
-		Path:     "/",
+		Path:     h.basePath + "/",
 		HttpOnly: true,
 		Secure:   c.Request.TLS != nil,
 		SameSite: http.SameSiteStrictMode,
@@ -4,25 +4,42 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+
+	"api-proxy/internal/config"
+	"api-proxy/internal/health"
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/stats"
 )
 
 // MockMappingManager 用于测试的模拟映射管理器
 type MockMappingManager struct {
 	mappings map[string]string
+	options  map[string]mapping.Options
 	version  int64
+	remote   map[string]string // Diff 比较用的"Redis"映射
+	writeErr error             // 非nil时 Add/Update/Upsert/ApplyMappings 返回该错误
 }
 
 func (m *MockMappingManager) GetAllMappings() map[string]string {
 	return m.mappings
 }
 
+func (m *MockMappingManager) GetAllTemplates() map[string]string {
+	return m.mappings
+}
+
 func (m *MockMappingManager) GetMapping(ctx context.Context, prefix string) (string, error) {
 	if target, ok := m.mappings[prefix]; ok {
 		return target, nil
@@ -30,14 +47,45 @@ func (m *MockMappingManager) GetMapping(ctx context.Context, prefix string) (str
 	return "", nil
 }
 
-func (m *MockMappingManager) AddMapping(ctx context.Context, prefix, target string) error {
-	m.mappings[prefix] = target
-	m.version++
-	return nil
+func (m *MockMappingManager) AddMapping(ctx context.Context, prefix, target string, opts *mapping.Options) error {
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	return m.put(prefix, target, opts)
+}
+
+func (m *MockMappingManager) UpdateMapping(ctx context.Context, prefix, target string, opts *mapping.Options) error {
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	return m.put(prefix, target, opts)
 }
 
-func (m *MockMappingManager) UpdateMapping(ctx context.Context, prefix, target string) error {
+func (m *MockMappingManager) UpsertMapping(ctx context.Context, prefix, target string, opts *mapping.Options) (bool, error) {
+	if m.writeErr != nil {
+		return false, m.writeErr
+	}
+	_, exists := m.mappings[prefix]
+	if err := m.put(prefix, target, opts); err != nil {
+		return false, err
+	}
+	return !exists, nil
+}
+
+// put 与真实实现一致: 先校验扩展配置,目标与扩展配置一次写入,版本号只递增一次
+func (m *MockMappingManager) put(prefix, target string, opts *mapping.Options) error {
+	if opts != nil {
+		if err := opts.Validate(); err != nil {
+			return err
+		}
+	}
 	m.mappings[prefix] = target
+	if opts != nil {
+		if m.options == nil {
+			m.options = make(map[string]mapping.Options)
+		}
+		m.options[prefix] = *opts
+	}
 	m.version++
 	return nil
 }
@@ -53,6 +101,11 @@ func (m *MockMappingManager) ForceReload(ctx context.Context) error {
 	return nil
 }
 
+func (m *MockMappingManager) BumpVersion(ctx context.Context) (int64, error) {
+	m.version++
+	return m.version, nil
+}
+
 func (m *MockMappingManager) Count() int {
 	return len(m.mappings)
 }
@@ -73,6 +126,88 @@ func (m *MockMappingManager) GetVersion() int64 {
 	return m.version
 }
 
+func (m *MockMappingManager) Diff(ctx context.Context) (mapping.Diff, error) {
+	diff := mapping.Compare(m.mappings, m.remote)
+	diff.LocalVersion = m.version
+	diff.RemoteVersion = m.version
+	return diff, nil
+}
+
+func (m *MockMappingManager) GetAllOptions() map[string]mapping.Options {
+	return m.options
+}
+
+func (m *MockMappingManager) ExportMappings(ctx context.Context) ([]mapping.Entry, error) {
+	entries := make([]mapping.Entry, 0, len(m.mappings))
+	for prefix, target := range m.mappings {
+		entries = append(entries, mapping.Entry{Prefix: prefix, Target: target, Options: m.options[prefix]})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Prefix < entries[j].Prefix })
+	return entries, nil
+}
+
+// ApplyMappings 与存储实现一致: 先校验全部条目,任一无效则不做任何修改;有变化时版本只递增一次
+func (m *MockMappingManager) ApplyMappings(ctx context.Context, entries []mapping.Entry, replace bool) (mapping.ApplyResult, error) {
+	var result mapping.ApplyResult
+	if m.writeErr != nil {
+		return result, m.writeErr
+	}
+	var problems mapping.EntryErrors
+	for _, e := range entries {
+		if u, err := url.Parse(e.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("%s: invalid target %q", e.Prefix, e.Target))
+		}
+		if err := e.Options.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", e.Prefix, err))
+		}
+	}
+	if len(problems) > 0 {
+		return result, problems
+	}
+
+	if m.options == nil {
+		m.options = make(map[string]mapping.Options)
+	}
+	wanted := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		wanted[e.Prefix] = true
+		target, exists := m.mappings[e.Prefix]
+		switch {
+		case !exists:
+			result.Added++
+		case target != e.Target || !reflect.DeepEqual(m.options[e.Prefix], e.Options):
+			result.Updated++
+		default:
+			result.Unchanged++
+			continue
+		}
+		m.mappings[e.Prefix] = e.Target
+		m.options[e.Prefix] = e.Options
+	}
+	if replace {
+		for prefix := range m.mappings {
+			if !wanted[prefix] {
+				delete(m.mappings, prefix)
+				delete(m.options, prefix)
+				result.Removed++
+			}
+		}
+	}
+	if result.Changed() {
+		m.version++
+	}
+	return result, nil
+}
+
+func (m *MockMappingManager) SetMappingOptions(ctx context.Context, prefix string, opts mapping.Options) error {
+	if m.options == nil {
+		m.options = make(map[string]mapping.Options)
+	}
+	m.options[prefix] = opts
+	m.version++
+	return nil
+}
+
 func setupTestRouter(handler *Handler) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
@@ -213,6 +348,42 @@ func TestHandler_AddMapping(t *testing.T) {
 	}
 }
 
+func TestHandler_AddMapping_Upsert(t *testing.T) {
+	mapper := &MockMappingManager{
+		mappings: make(map[string]string),
+	}
+
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(mapper)
+	r := setupTestRouter(handler)
+
+	upsert := func(target string) (int, map[string]any) {
+		body, _ := json.Marshal(map[string]string{"prefix": "/api", "target": target})
+		req, _ := http.NewRequest("POST", "/api/mappings?upsert=true", bytes.NewBuffer(body))
+		addAuthCookie(req)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var response map[string]any
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	// 不存在时创建
+	if code, response := upsert("http://old.example.com"); code != http.StatusCreated || response["created"] != true {
+		t.Fatalf("expected 201 created, got %d %v", code, response)
+	}
+	// 存在时更新
+	if code, response := upsert("http://new.example.com"); code != http.StatusOK || response["created"] != false {
+		t.Fatalf("expected 200 updated, got %d %v", code, response)
+	}
+	if mapper.mappings["/api"] != "http://new.example.com" {
+		t.Errorf("mapping not updated, got %q", mapper.mappings["/api"])
+	}
+}
+
 func TestHandler_UpdateMapping(t *testing.T) {
 	mapper := &MockMappingManager{
 		mappings: map[string]string{
@@ -502,6 +673,24 @@ func TestHandler_AdminLogin_InvalidToken(t *testing.T) {
 	}
 }
 
+func TestHandler_AdminLoginBasePath(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-token")
+	t.Setenv("BASE_PATH", "proxy-service/")
+
+	handler := NewHandler(&MockMappingManager{mappings: make(map[string]string)})
+	r := setupTestRouter(handler)
+
+	req, _ := http.NewRequest("POST", "/api/admin/login", strings.NewReader(`{"token":"test-token"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Path != "/proxy-service/" {
+		t.Fatalf("expected session cookie scoped to base path, got %+v", cookies)
+	}
+}
+
 func TestHandler_AdminLogout(t *testing.T) {
 	mapper := &MockMappingManager{
 		mappings: make(map[string]string),
@@ -556,3 +745,621 @@ func TestHandler_AddMapping_InvalidJSON(t *testing.T) {
 		t.Errorf("expected status 400, got %d", w.Code)
 	}
 }
+
+func TestHandler_MappingOptions(t *testing.T) {
+	mapper := &MockMappingManager{
+		mappings: make(map[string]string),
+	}
+
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(mapper)
+	r := setupTestRouter(handler)
+
+	// 添加带扩展配置的映射
+	body := []byte(`{"prefix":"/bin","target":"http://bin.example.com","options":{"content_type":"application/json"}}`)
+	req, _ := http.NewRequest("POST", "/api/mappings", bytes.NewBuffer(body))
+	addAuthCookie(req)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if mapper.options["/bin"].ContentType != "application/json" {
+		t.Fatalf("options not stored, got %+v", mapper.options["/bin"])
+	}
+
+	// 列表中应包含扩展配置
+	req, _ = http.NewRequest("GET", "/api/mappings", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response struct {
+		Options map[string]mapping.Options `json:"options"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Options["/bin"].ContentType != "application/json" {
+		t.Errorf("expected options in listing, got %+v", response.Options)
+	}
+
+	// 客户端证书私钥在列表中脱敏
+	mapper.options["/mtls"] = mapping.Options{ClientCert: &mapping.ClientCert{CertPEM: "cert", KeyPEM: "secret-key"}}
+	req, _ = http.NewRequest("GET", "/api/mappings", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), "secret-key") {
+		t.Errorf("client key should be masked in listing: %s", w.Body.String())
+	}
+	if mapper.options["/mtls"].ClientCert.KeyPEM != "secret-key" {
+		t.Error("listing must not modify stored options")
+	}
+
+	// 无效的扩展配置应被拒绝且不写入映射
+	body = []byte(`{"prefix":"/bad","target":"http://bad.example.com","options":{"content_type":"???"}}`)
+	req, _ = http.NewRequest("POST", "/api/mappings", bytes.NewBuffer(body))
+	addAuthCookie(req)
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid options, got %d", w.Code)
+	}
+	if _, exists := mapper.mappings["/bad"]; exists {
+		t.Error("mapping should not be added when options are invalid")
+	}
+
+	// 更新时一并写入扩展配置,版本号只递增一次
+	version := mapper.version
+	body = []byte(`{"target":"http://bin2.example.com","options":{"content_type":"text/plain"}}`)
+	req, _ = http.NewRequest("PUT", "/api/mappings/bin", bytes.NewBuffer(body))
+	addAuthCookie(req)
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if mapper.options["/bin"].ContentType != "text/plain" || mapper.version != version+1 {
+		t.Errorf("expected options written with a single version bump, got %+v (version %d -> %d)", mapper.options["/bin"], version, mapper.version)
+	}
+}
+
+func TestHandler_DeleteMapping_Pattern(t *testing.T) {
+	pattern := "~^/t/(?P<tenant>[^/]+)/api"
+	mapper := &MockMappingManager{
+		mappings: map[string]string{
+			pattern: "https://backend.com/$tenant",
+		},
+	}
+
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(mapper)
+	r := setupTestRouter(handler)
+
+	req, _ := http.NewRequest("DELETE", "/api/mappings/"+url.PathEscape(pattern), nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	if _, exists := mapper.mappings[pattern]; exists {
+		t.Fatal("pattern mapping should be deleted")
+	}
+}
+
+// MockTrafficStats 用于测试的客户端流量统计
+type MockTrafficStats struct {
+	clients []stats.TopNEntry
+	keys    []stats.TopNEntry
+	slo     map[string]stats.SLOStats
+}
+
+func (m *MockTrafficStats) TopAPIKeys(n int) []stats.TopNEntry {
+	return m.keys
+}
+
+func (m *MockTrafficStats) GetSLOStats() map[string]stats.SLOStats {
+	return m.slo
+}
+
+func (m *MockTrafficStats) TopClients(n int) []stats.TopNEntry {
+	if n < len(m.clients) {
+		return m.clients[:n]
+	}
+	return m.clients
+}
+
+func (m *MockTrafficStats) TopClientPrefixes(n int) []stats.TopNEntry {
+	return nil
+}
+
+func TestHandler_TopClients(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(&MockMappingManager{mappings: map[string]string{}})
+	r := setupTestRouter(handler)
+
+	// 未注入统计时返回503
+	req, _ := http.NewRequest("GET", "/api/admin/clients", nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without traffic stats, got %d", w.Code)
+	}
+
+	handler.SetTrafficStats(&MockTrafficStats{clients: []stats.TopNEntry{
+		{Key: "10.0.0.1", Count: 100},
+		{Key: "10.0.0.2", Count: 3},
+	}})
+
+	// 需要认证
+	req, _ = http.NewRequest("GET", "/api/admin/clients", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without auth, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/admin/clients?limit=1", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Clients []stats.TopNEntry `json:"clients"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if len(response.Clients) != 1 || response.Clients[0].Key != "10.0.0.1" {
+		t.Errorf("unexpected clients: %+v", response.Clients)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/admin/clients?limit=abc", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid limit, got %d", w.Code)
+	}
+}
+
+func TestHandler_SLOStatusInListing(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/a": "http://a.example.com", "/b": "http://b.example.com"},
+		options: map[string]mapping.Options{
+			"/a": {SLO: &mapping.SLO{LatencyMs: 2000, Target: 0.95}},
+			"/b": {SLO: &mapping.SLO{LatencyMs: 500, Target: 0.99}},
+		},
+	}
+	handler := NewHandler(mapper)
+	handler.SetTrafficStats(&MockTrafficStats{slo: map[string]stats.SLOStats{
+		"/a": {Total: 100, Met: 97, Compliance: 0.97},
+		"/b": {Total: 100, Met: 90, Compliance: 0.90},
+	}})
+	r := setupTestRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/api/mappings", nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response struct {
+		SLO map[string]SLOStatus `json:"slo"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	a, b := response.SLO["/a"], response.SLO["/b"]
+	if a.LatencyMs != 2000 || a.Compliance != 0.97 || !a.Meeting {
+		t.Errorf("unexpected /a status: %+v", a)
+	}
+	if b.Compliance != 0.90 || b.Meeting {
+		t.Errorf("unexpected /b status: %+v", b)
+	}
+}
+
+func TestHandler_TopAPIKeys(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(&MockMappingManager{mappings: map[string]string{}})
+	handler.SetTrafficStats(&MockTrafficStats{keys: []stats.TopNEntry{
+		{Key: stats.HashAPIKey("secret"), Count: 5},
+	}})
+	r := setupTestRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/api/admin/keys?limit=5", nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), `"secret"`) || !strings.Contains(w.Body.String(), stats.HashAPIKey("secret")) {
+		t.Fatalf("expected hashed key in response, got %s", w.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/api/admin/keys?limit=0", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid limit, got %d", w.Code)
+	}
+}
+
+func TestHandler_MappingsDiff(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(&MockMappingManager{
+		mappings: map[string]string{"/a": "http://a.example.com", "/old": "http://old.example.com"},
+		remote:   map[string]string{"/a": "http://a2.example.com", "/new": "http://new.example.com"},
+	})
+	r := setupTestRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/api/admin/mappings/diff", nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		InSync bool         `json:"in_sync"`
+		Diff   mapping.Diff `json:"diff"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.InSync {
+		t.Error("expected out-of-sync result")
+	}
+	if _, ok := response.Diff.Added["/new"]; !ok {
+		t.Errorf("expected /new as added, got %+v", response.Diff)
+	}
+	if _, ok := response.Diff.Removed["/old"]; !ok {
+		t.Errorf("expected /old as removed, got %+v", response.Diff)
+	}
+	if response.Diff.Changed["/a"].Remote != "http://a2.example.com" {
+		t.Errorf("expected /a as changed, got %+v", response.Diff)
+	}
+}
+
+func TestHandler_AddMapping_ListsAllValidationErrors(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(&MockMappingManager{
+		mappings: map[string]string{},
+		writeErr: errors.Join(errors.New("prefix must start with /"), errors.New("target URL must use http or https scheme")),
+	})
+	r := setupTestRouter(handler)
+
+	body := []byte(`{"prefix":"bad","target":"ftp://x"}`)
+	req, _ := http.NewRequest("POST", "/api/mappings", bytes.NewBuffer(body))
+	addAuthCookie(req)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	var response struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Errors) != 2 || response.Errors[0] != "prefix must start with /" {
+		t.Fatalf("expected both validation errors listed, got %v", response.Errors)
+	}
+}
+
+func TestHandler_EffectiveConfig(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "test-token")
+	t.Setenv("STREAM_IDLE_TIMEOUT", "45s")
+
+	handler := NewHandler(&MockMappingManager{mappings: make(map[string]string)})
+	config.Duration("STREAM_IDLE_TIMEOUT", 0) // 模拟组件初始化时读取
+	r := setupTestRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/api/admin/config", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without auth, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/admin/config", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Config []config.Setting `json:"config"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	settings := make(map[string]config.Setting)
+	for _, s := range response.Config {
+		settings[s.Name] = s
+	}
+	if s := settings["STREAM_IDLE_TIMEOUT"]; s.Value != "45s" || s.Source != config.SourceEnv {
+		t.Errorf("expected overridden idle timeout, got %+v", s)
+	}
+	if s := settings["ADMIN_TOKEN"]; s.Value != config.Mask {
+		t.Errorf("expected admin token to be masked, got %+v", s)
+	}
+	if strings.Contains(w.Body.String(), "test-token") {
+		t.Error("response must not contain secret values")
+	}
+}
+
+func TestHandler_BumpVersion(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": "http://a.example.com"}, version: 7}
+	r := setupTestRouter(NewHandler(mapper))
+
+	req, _ := http.NewRequest("POST", "/api/admin/mappings/bump", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("POST", "/api/admin/mappings/bump", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Version int64 `json:"version"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Version != 8 {
+		t.Errorf("expected version 8, got %d", response.Version)
+	}
+	if mapper.mappings["/api"] != "http://a.example.com" {
+		t.Error("bump must not change mappings")
+	}
+}
+
+func TestHandler_ImportMappings(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/keep": "http://keep.example.com", "/old": "http://old.example.com"},
+		version:  3,
+	}
+	r := setupTestRouter(NewHandler(mapper))
+
+	importMappings := func(body string, auth bool) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/mappings/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if auth {
+			addAuthCookie(req)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := importMappings(`{"mappings":{}}`, false); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", w.Code)
+	}
+
+	// 合并模式: 新增/更新列出的映射,版本只递增一次
+	w := importMappings(`{"mappings":[
+		{"prefix":"/old","target":"http://new-old.example.com"},
+		{"prefix":"/new","target":"http://new.example.com","options":{"timeout_ms":500}}
+	]}`, true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Mode    string              `json:"mode"`
+		Result  mapping.ApplyResult `json:"result"`
+		Version int64               `json:"version"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Mode != "merge" || response.Result != (mapping.ApplyResult{Added: 1, Updated: 1}) || response.Version != 4 {
+		t.Errorf("unexpected merge response: %s", w.Body.String())
+	}
+	if len(mapper.mappings) != 3 || mapper.mappings["/old"] != "http://new-old.example.com" || mapper.options["/new"].TimeoutMs != 500 {
+		t.Errorf("unexpected mappings after merge: %v %v", mapper.mappings, mapper.options)
+	}
+
+	// 部分条目无效: 整体拒绝,不做任何修改
+	w = importMappings(`{"mode":"replace","mappings":[
+		{"prefix":"/keep","target":"http://changed.example.com"},
+		{"prefix":"/broken","target":"ftp://broken.example.com"}
+	]}`, true)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid entry, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "/broken") {
+		t.Errorf("expected error to name the invalid entry, got %s", w.Body.String())
+	}
+	if len(mapper.mappings) != 3 || mapper.mappings["/keep"] != "http://keep.example.com" || mapper.version != 4 {
+		t.Errorf("invalid import must change nothing, got %v (version %d)", mapper.mappings, mapper.version)
+	}
+
+	// 替换模式: 删除未列出的映射
+	w = importMappings(`{"mode":"replace","mappings":{"/keep":"http://keep.example.com"}}`, true)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || response.Result.Removed != 2 || len(mapper.mappings) != 1 || mapper.version != 5 {
+		t.Errorf("unexpected replace result: %d %s (mappings %v)", w.Code, w.Body.String(), mapper.mappings)
+	}
+
+	if w := importMappings(`{"mode":"upsert","mappings":{}}`, true); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown mode, got %d", w.Code)
+	}
+
+	// 存储故障不是请求错误
+	mapper.writeErr = errors.New("redis: connection refused")
+	if w := importMappings(`{"mappings":{"/keep":"http://keep.example.com"}}`, true); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 for storage failure, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandler_ExportMappings_RoundTrip(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	source := &MockMappingManager{
+		mappings: map[string]string{"/b": "http://b.example.com", "/a": "http://a.example.com"},
+		options:  map[string]mapping.Options{"/a": {TimeoutMs: 1500}},
+	}
+	req, _ := http.NewRequest("GET", "/api/mappings/export", nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	setupTestRouter(NewHandler(source)).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var exported struct {
+		Count    int             `json:"count"`
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &exported)
+	if exported.Count != 2 {
+		t.Fatalf("expected 2 exported mappings, got %s", w.Body.String())
+	}
+
+	// 导出内容原样导入另一实例
+	target := &MockMappingManager{mappings: map[string]string{"/stale": "http://stale.example.com"}}
+	body, _ := json.Marshal(gin.H{"mode": "replace", "mappings": exported.Mappings})
+	req, _ = http.NewRequest("POST", "/api/mappings/import", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	setupTestRouter(NewHandler(target)).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected import of export to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if !reflect.DeepEqual(target.mappings, source.mappings) || target.options["/a"].TimeoutMs != 1500 {
+		t.Errorf("round-trip mismatch: %v %v", target.mappings, target.options)
+	}
+}
+
+func TestHandler_ExportMappings_Redacted(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	router := setupTestRouter(NewHandler(&MockMappingManager{
+		mappings: map[string]string{"/token": "http://token.example.com"},
+		options: map[string]mapping.Options{"/token": {
+			TokenRefresh: &mapping.TokenRefresh{URL: "https://auth.example.com/token", ClientSecret: "s3cret"},
+		}},
+	}))
+
+	tests := []struct {
+		path   string
+		secret bool
+	}{
+		{"/api/mappings/export", false},
+		{"/api/mappings/export?include_secrets=true", true},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.path, nil)
+		addAuthCookie(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", tt.path, w.Code)
+		}
+		if got := strings.Contains(w.Body.String(), "s3cret"); got != tt.secret {
+			t.Errorf("%s: secret exported = %v, want %v: %s", tt.path, got, tt.secret, w.Body.String())
+		}
+	}
+}
+
+// MockUpstreamHealth 用于测试的健康检查结果
+type MockUpstreamHealth []health.TargetHealth
+
+func (m MockUpstreamHealth) Status() []health.TargetHealth {
+	return m
+}
+
+func TestHandler_UpstreamHealth(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(&MockMappingManager{mappings: map[string]string{}})
+	r := setupTestRouter(handler)
+
+	// 未启用健康检查时返回503
+	req, _ := http.NewRequest("GET", "/api/mappings/health", nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without health checker, got %d", w.Code)
+	}
+
+	handler.SetUpstreamHealth(MockUpstreamHealth{
+		{Prefix: "/api", Target: "http://a.example", Healthy: true},
+		{Prefix: "/api", Target: "http://b.example", ConsecutiveFailures: 3, StatusCode: 500},
+	})
+
+	req, _ = http.NewRequest("GET", "/api/mappings/health", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without auth, got %d", w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/mappings/health", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Targets   []health.TargetHealth `json:"targets"`
+		Unhealthy int                   `json:"unhealthy"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if len(response.Targets) != 2 || response.Unhealthy != 1 || response.Targets[1].Healthy {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+}
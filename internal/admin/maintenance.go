@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"api-proxy/internal/mapping"
+)
+
+// MaintenanceManager 维护模式管理接口(可选)
+type MaintenanceManager interface {
+	SetMaintenance(ctx context.Context, prefix string, maint mapping.Maintenance) error
+	ClearMaintenance(ctx context.Context, prefix string) error
+	GetAllMaintenance() map[string]mapping.Maintenance
+}
+
+// SetMaintenance 注入维护模式管理器(未设置时相关接口返回503)
+func (h *Handler) SetMaintenance(maintenance MaintenanceManager) {
+	h.maintenance = maintenance
+}
+
+// handleGetMaintenance 返回所有开启中的维护配置("*" 表示全局)
+func (h *Handler) handleGetMaintenance(c *gin.Context) {
+	if !h.requireMaintenance(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"maintenance": h.maintenance.GetAllMaintenance(),
+	})
+}
+
+// handleSetMaintenance 开启维护模式(无前缀参数时为全局),请求体可选
+func (h *Handler) handleSetMaintenance(c *gin.Context) {
+	if !h.requireMaintenance(c) {
+		return
+	}
+	prefix, ok := maintenancePrefix(c)
+	if !ok {
+		return
+	}
+
+	var maint mapping.Maintenance
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&maint); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+	}
+	if err := maint.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.maintenance.SetMaintenance(c.Request.Context(), prefix, maint); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"message":     "Maintenance mode enabled",
+		"prefix":      maintenanceScope(prefix),
+		"maintenance": maint,
+	})
+}
+
+// handleClearMaintenance 关闭维护模式(无前缀参数时为全局)
+func (h *Handler) handleClearMaintenance(c *gin.Context) {
+	if !h.requireMaintenance(c) {
+		return
+	}
+	prefix, ok := maintenancePrefix(c)
+	if !ok {
+		return
+	}
+
+	if err := h.maintenance.ClearMaintenance(c.Request.Context(), prefix); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Maintenance mode disabled",
+		"prefix":  maintenanceScope(prefix),
+	})
+}
+
+// requireMaintenance 维护模式管理器未设置时返回503
+func (h *Handler) requireMaintenance(c *gin.Context) bool {
+	if h.maintenance == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Maintenance mode is not available"})
+		return false
+	}
+	return true
+}
+
+// maintenancePrefix 解析路由中的前缀参数,空表示全局
+func maintenancePrefix(c *gin.Context) (string, bool) {
+	if strings.Trim(c.Param("prefix"), "/ ") == "" {
+		return "", true
+	}
+	prefix, err := extractPrefixParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return prefix, true
+}
+
+// maintenanceScope 返回响应中展示的维护范围
+func maintenanceScope(prefix string) string {
+	if prefix == "" {
+		return mapping.MaintenanceGlobal
+	}
+	return prefix
+}
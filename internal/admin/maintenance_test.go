@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+// MockMaintenance 维护模式管理器的内存实现
+type MockMaintenance struct {
+	prefixes map[string]bool
+	entries  map[string]mapping.Maintenance
+}
+
+func (m *MockMaintenance) SetMaintenance(ctx context.Context, prefix string, maint mapping.Maintenance) error {
+	if prefix == "" {
+		prefix = mapping.MaintenanceGlobal
+	} else if !m.prefixes[prefix] {
+		return fmt.Errorf("mapping not found for prefix: %s", prefix)
+	}
+	m.entries[prefix] = maint
+	return nil
+}
+
+func (m *MockMaintenance) ClearMaintenance(ctx context.Context, prefix string) error {
+	if prefix == "" {
+		prefix = mapping.MaintenanceGlobal
+	}
+	delete(m.entries, prefix)
+	return nil
+}
+
+func (m *MockMaintenance) GetAllMaintenance() map[string]mapping.Maintenance {
+	return m.entries
+}
+
+func TestHandler_Maintenance(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(&MockMappingManager{mappings: map[string]string{}})
+	r := setupTestRouter(handler)
+
+	do := func(method, path, body string, auth bool) *httptest.ResponseRecorder {
+		t.Helper()
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if auth {
+			addAuthCookie(req)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 未注入时返回503
+	if w := do("GET", "/api/admin/maintenance", "", true); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 without maintenance manager, got %d", w.Code)
+	}
+
+	maint := &MockMaintenance{prefixes: map[string]bool{"/api": true}, entries: map[string]mapping.Maintenance{}}
+	handler.SetMaintenance(maint)
+
+	// 需要认证
+	if w := do("PUT", "/api/admin/maintenance", "", false); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without auth, got %d", w.Code)
+	}
+
+	// 全局维护,请求体可省略
+	if w := do("PUT", "/api/admin/maintenance", "", true); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := maint.entries[mapping.MaintenanceGlobal]; !ok {
+		t.Fatal("expected global maintenance enabled")
+	}
+
+	// 前缀维护
+	w := do("PUT", "/api/admin/maintenance/api", `{"message":"<h1>down</h1>","content_type":"text/html","retry_after":60}`, true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := maint.entries["/api"]; got.Message != "<h1>down</h1>" || got.RetryAfter != 60 {
+		t.Errorf("unexpected maintenance: %+v", got)
+	}
+	if w := do("PUT", "/api/admin/maintenance/missing", "", true); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown prefix, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/admin/maintenance/api", `{"retry_after":-1}`, true); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid config, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/admin/maintenance", "", true)
+	var resp struct {
+		Maintenance map[string]mapping.Maintenance `json:"maintenance"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Maintenance) != 2 {
+		t.Errorf("expected 2 entries, got %v", resp.Maintenance)
+	}
+
+	// 关闭
+	if w := do("DELETE", "/api/admin/maintenance/api", "", true); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if w := do("DELETE", "/api/admin/maintenance", "", true); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if len(maint.entries) != 0 {
+		t.Errorf("expected maintenance cleared, got %v", maint.entries)
+	}
+}
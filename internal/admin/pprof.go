@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupPprofRoutes 注册 net/http/pprof 性能分析接口(需要Token认证),仅在 ENABLE_PPROF=true 时挂载
+func (h *Handler) setupPprofRoutes(r *gin.Engine) {
+	if !h.pprof {
+		return
+	}
+	debugAPI := r.Group("/debug/pprof")
+	debugAPI.Use(h.authMiddleware())
+	{
+		debugAPI.GET("/*name", handlePprof)
+		debugAPI.POST("/symbol", gin.WrapF(pprof.Symbol))
+	}
+}
+
+// handlePprof 按路径分发 pprof 接口: cmdline/profile/symbol/trace 使用专用处理器,
+// 其余(索引页及 heap、goroutine 等命名 profile)交给 pprof.Index
+func handlePprof(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("name"), "/") {
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHandler_PprofDisabledByDefault(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	router := setupTestRouter(NewHandler(&MockMappingManager{mappings: map[string]string{}}))
+	req := httptest.NewRequest("GET", "/debug/pprof/", nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected pprof to be unmounted by default, got %d", w.Code)
+	}
+}
+
+func TestHandler_PprofRequiresAuth(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	os.Setenv("ENABLE_PPROF", "true")
+	defer os.Unsetenv("ADMIN_TOKEN")
+	defer os.Unsetenv("ENABLE_PPROF")
+
+	router := setupTestRouter(NewHandler(&MockMappingManager{mappings: map[string]string{}}))
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without token: expected 401, got %d", path, w.Code)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/debug/pprof/", "goroutine"},
+		{"/debug/pprof/goroutine?debug=1", "goroutine profile"},
+		{"/debug/pprof/cmdline", os.Args[0]},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		addAuthCookie(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s: expected 200 containing %q, got %d", tt.path, tt.want, w.Code)
+		}
+	}
+}
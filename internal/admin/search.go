@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchResult 映射搜索结果
+type SearchResult struct {
+	Prefix string `json:"prefix"`
+	Target string `json:"target"`
+	Score  int    `json:"score"`
+}
+
+// handleSearchMappings 按相关度搜索映射(前缀、目标主机)
+func (h *Handler) handleSearchMappings(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query parameter q is required"})
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+
+	results := searchMappings(h.mapper.GetAllTemplates(), query)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"query":   query,
+		"count":   len(results),
+		"results": results,
+	})
+}
+
+// searchMappings 对所有映射评分并按相关度排序(得分相同时前缀短者优先)
+func searchMappings(mappings map[string]string, query string) []SearchResult {
+	query = strings.ToLower(query)
+	results := make([]SearchResult, 0)
+	for prefix, target := range mappings {
+		if score := scoreMapping(query, prefix, target); score > 0 {
+			results = append(results, SearchResult{Prefix: prefix, Target: target, Score: score})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if len(results[i].Prefix) != len(results[j].Prefix) {
+			return len(results[i].Prefix) < len(results[j].Prefix)
+		}
+		return results[i].Prefix < results[j].Prefix
+	})
+	return results
+}
+
+// scoreMapping 计算相关度: 精确 > 开头匹配 > 包含 > 模糊(子序列),前缀权重高于目标主机
+func scoreMapping(query, prefix, target string) int {
+	name := strings.TrimPrefix(strings.ToLower(prefix), "/")
+	q := strings.TrimPrefix(query, "/")
+
+	host := ""
+	if parsed, err := url.Parse(target); err == nil {
+		host = strings.ToLower(parsed.Hostname())
+	}
+
+	switch {
+	case name == q:
+		return 100
+	case strings.HasPrefix(name, q):
+		return 90
+	case host == q:
+		return 80
+	case strings.HasPrefix(host, q):
+		return 70
+	case strings.Contains(name, q):
+		return 60
+	case strings.Contains(host, q):
+		return 50
+	case strings.Contains(strings.ToLower(target), q):
+		return 40
+	}
+
+	// 模糊匹配: 查询字符按顺序出现,间隔越小得分越高
+	if score := fuzzyScore(q, name); score > 0 {
+		return 10 + score
+	}
+	return fuzzyScore(q, host)
+}
+
+// fuzzyScore 子序列匹配得分(0表示不匹配,最高20)
+func fuzzyScore(query, text string) int {
+	if query == "" || text == "" {
+		return 0
+	}
+	gaps, pos := 0, 0
+	for _, r := range query {
+		idx := strings.IndexRune(text[pos:], r)
+		if idx < 0 {
+			return 0
+		}
+		gaps += idx
+		pos += idx + len(string(r))
+	}
+	return max(20-gaps, 1)
+}
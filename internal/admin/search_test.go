@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSearchMappings_Ranking(t *testing.T) {
+	mappings := map[string]string{
+		"/openai":        "https://api.openai.com",
+		"/openai-proxy":  "https://proxy.example.com",
+		"/chat":          "https://openai.azure.com",
+		"/gpt":           "https://gateway.example.com/openai/v1",
+		"/o-p-e-n-a-i":   "https://misc.example.com",
+		"/unrelated":     "https://unrelated.example.com",
+		"/my-openai-api": "https://my.example.com",
+	}
+
+	results := searchMappings(mappings, "openai")
+
+	want := []string{
+		"/openai",        // 精确匹配前缀
+		"/openai-proxy",  // 前缀开头匹配
+		"/chat",          // 目标主机开头匹配
+		"/my-openai-api", // 前缀包含
+		"/gpt",           // 目标URL包含
+		"/o-p-e-n-a-i",   // 模糊匹配
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), results)
+	}
+	for i, prefix := range want {
+		if results[i].Prefix != prefix {
+			t.Fatalf("rank %d: expected %s, got %s (%+v)", i, prefix, results[i].Prefix, results)
+		}
+	}
+}
+
+func TestHandler_SearchMappings(t *testing.T) {
+	os.Setenv("ADMIN_TOKEN", "test-token")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	handler := NewHandler(&MockMappingManager{mappings: map[string]string{
+		"/api":    "https://api.example.com",
+		"/api-v2": "https://v2.example.com",
+		"/other":  "https://other.example.com",
+	}})
+	r := setupTestRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/api/mappings/search?q=api&limit=1", nil)
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Results []SearchResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Results) != 1 || response.Results[0].Prefix != "/api" {
+		t.Fatalf("expected top result /api, got %+v", response.Results)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/mappings/search", nil)
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 without query, got %d", w.Code)
+	}
+}
@@ -0,0 +1,165 @@
+// Package analytics 将请求元数据异步导出到外部分析系统(不包含请求/响应体)
+package analytics
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event 单个请求的元数据
+type Event struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Endpoint      string    `json:"endpoint"`
+	Method        string    `json:"method"`
+	Status        int       `json:"status"`
+	LatencyMs     int64     `json:"latency_ms"`
+	RequestBytes  int64     `json:"request_bytes"`
+	ResponseBytes int64     `json:"response_bytes"`
+
+	Upstream *UpstreamTiming `json:"upstream,omitempty"` // 上游各阶段耗时(未发出上游请求时为空)
+}
+
+// UpstreamTiming 上游请求各阶段完成时相对开始发送的累计耗时(毫秒)
+// 复用连接时没有 DNS/连接/TLS 阶段,对应字段为0;因此有值时满足 dns <= connect <= tls <= ttfb <= total
+type UpstreamTiming struct {
+	DNSMs     float64 `json:"dns_ms,omitempty"`
+	ConnectMs float64 `json:"connect_ms,omitempty"`
+	TLSMs     float64 `json:"tls_ms,omitempty"`
+	TTFBMs    float64 `json:"ttfb_ms,omitempty"` // 收到响应首字节
+	TotalMs   float64 `json:"total_ms"`          // 响应体转发完成
+}
+
+// Sink 事件的最终目的地(webhook、Kafka等),按批次调用
+type Sink interface {
+	Send(ctx context.Context, events []Event) error
+}
+
+// Stats 导出计数
+type Stats struct {
+	Sent    int64 `json:"sent"`    // 已成功发送的事件数
+	Dropped int64 `json:"dropped"` // 缓冲区满时丢弃的事件数
+	Failed  int64 `json:"failed"`  // 发送失败的事件数
+}
+
+// Exporter 异步批量导出器
+// Export 从不阻塞请求路径:缓冲区满时直接丢弃并计数
+type Exporter struct {
+	sink      Sink
+	events    chan Event
+	batchSize int
+	interval  time.Duration
+	timeout   time.Duration
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+
+	closeOnce sync.Once
+	stopChan  chan struct{}
+	done      chan struct{}
+}
+
+// NewExporter 创建导出器并启动后台发送协程
+// 达到 batchSize 或每隔 interval 发送一批
+func NewExporter(sink Sink, bufferSize, batchSize int, interval time.Duration) *Exporter {
+	if bufferSize <= 0 {
+		bufferSize = 1000
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	e := &Exporter{
+		sink:      sink,
+		events:    make(chan Event, bufferSize),
+		batchSize: batchSize,
+		interval:  interval,
+		timeout:   10 * time.Second,
+		stopChan:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export 提交事件(非阻塞)
+func (e *Exporter) Export(event Event) {
+	select {
+	case e.events <- event:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Stats 返回导出计数
+func (e *Exporter) Stats() Stats {
+	return Stats{
+		Sent:    e.sent.Load(),
+		Dropped: e.dropped.Load(),
+		Failed:  e.failed.Load(),
+	}
+}
+
+// Close 停止导出,发送缓冲区中剩余的事件
+func (e *Exporter) Close() {
+	e.closeOnce.Do(func() {
+		close(e.stopChan)
+		<-e.done
+	})
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, e.batchSize)
+	for {
+		select {
+		case event := <-e.events:
+			batch = append(batch, event)
+			if len(batch) >= e.batchSize {
+				batch = e.flush(batch)
+			}
+		case <-ticker.C:
+			batch = e.flush(batch)
+		case <-e.stopChan:
+			// 排空缓冲区后退出
+			for {
+				select {
+				case event := <-e.events:
+					batch = append(batch, event)
+					if len(batch) >= e.batchSize {
+						batch = e.flush(batch)
+					}
+				default:
+					e.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush 发送一批事件,返回可复用的空批次
+func (e *Exporter) flush(batch []Event) []Event {
+	if len(batch) == 0 {
+		return batch
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	if err := e.sink.Send(ctx, batch); err != nil {
+		e.failed.Add(int64(len(batch)))
+		log.Printf("⚠️  分析事件发送失败 (%d 条): %v", len(batch), err)
+	} else {
+		e.sent.Add(int64(len(batch)))
+	}
+	return batch[:0]
+}
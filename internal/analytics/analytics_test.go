@@ -0,0 +1,106 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink 记录收到的批次,block 非nil时阻塞直到关闭
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]Event
+	block   chan struct{}
+}
+
+func (s *recordingSink) Send(ctx context.Context, events []Event) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	s.batches = append(s.batches, append([]Event(nil), events...))
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *recordingSink) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sizes := make([]int, len(s.batches))
+	for i, b := range s.batches {
+		sizes[i] = len(b)
+	}
+	return sizes
+}
+
+func TestExporter_Batches(t *testing.T) {
+	sink := &recordingSink{}
+	e := NewExporter(sink, 100, 3, time.Hour)
+
+	for i := range 7 {
+		e.Export(Event{Endpoint: "/api", Status: 200 + i})
+	}
+	// 满批立即发送,剩余事件在关闭时发送
+	e.Close()
+
+	sizes := sink.batchSizes()
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+		t.Fatalf("expected batches [3 3 1], got %v", sizes)
+	}
+	if stats := e.Stats(); stats.Sent != 7 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestExporter_FlushInterval(t *testing.T) {
+	sink := &recordingSink{}
+	e := NewExporter(sink, 100, 100, 20*time.Millisecond)
+	defer e.Close()
+
+	e.Export(Event{Endpoint: "/api"})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sink.batchSizes()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("partial batch was not flushed on interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestExporter_DropOnOverflow(t *testing.T) {
+	sink := &recordingSink{block: make(chan struct{})}
+	e := NewExporter(sink, 2, 1, time.Hour)
+
+	// 首个事件进入阻塞的发送,随后缓冲区容纳2个,其余丢弃
+	e.Export(Event{Endpoint: "/first"})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(e.events) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("first event was not picked up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for range 10 {
+			e.Export(Event{Endpoint: "/api"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Export blocked while the sink was stalled")
+	}
+
+	if dropped := e.Stats().Dropped; dropped != 8 {
+		t.Errorf("expected 8 dropped events, got %d", dropped)
+	}
+	close(sink.block)
+	e.Close()
+	if sent := e.Stats().Sent; sent != 3 {
+		t.Errorf("expected 3 sent events, got %d", sent)
+	}
+}
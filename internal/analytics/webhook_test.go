@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookSink_DeliversBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var events []Event
+		if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, events)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	e := NewExporter(NewWebhookSink(server.URL), 100, 2, time.Hour)
+	e.Export(Event{Endpoint: "/a", Method: "GET", Status: 200, LatencyMs: 12, RequestBytes: 0, ResponseBytes: 34})
+	e.Export(Event{Endpoint: "/b", Method: "POST", Status: 502})
+	e.Export(Event{Endpoint: "/c", Method: "GET", Status: 404})
+	e.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("expected 2 batches (2+1 events), got %v", batches)
+	}
+	if got := batches[0][0]; got.Endpoint != "/a" || got.ResponseBytes != 34 || got.LatencyMs != 12 {
+		t.Errorf("unexpected event payload: %+v", got)
+	}
+}
+
+func TestWebhookSink_FailureCounted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	e := NewExporter(NewWebhookSink(server.URL), 100, 10, time.Hour)
+	e.Export(Event{Endpoint: "/a"})
+	e.Close()
+
+	if stats := e.Stats(); stats.Failed != 1 || stats.Sent != 0 {
+		t.Errorf("expected 1 failed event, got %+v", stats)
+	}
+}
@@ -0,0 +1,114 @@
+// Package certs 提供可热更新的TLS证书加载
+package certs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader 证书热加载器
+// 通过 tls.Config.GetCertificate 为每次握手提供当前证书,轮换证书无需重启
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	cert    atomic.Pointer[tls.Certificate]
+	modTime atomic.Int64 // 已加载文件的最新修改时间(UnixNano)
+
+	stopOnce sync.Once
+	stopChan chan struct{}
+}
+
+// NewReloader 加载证书并创建热加载器
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		stopChan: make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 重新读取证书文件,失败时保留当前证书
+func (r *Reloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	r.cert.Store(&cert)
+	r.modTime.Store(modTime)
+	return nil
+}
+
+// GetCertificate 返回当前证书(用于 tls.Config.GetCertificate)
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// TLSConfig 返回使用热加载证书的TLS配置
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// Watch 按间隔检查证书文件修改时间,变化时自动重载
+func (r *Reloader) Watch(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.reloadIfChanged()
+			case <-r.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止文件监视
+func (r *Reloader) Stop() {
+	r.stopOnce.Do(func() { close(r.stopChan) })
+}
+
+func (r *Reloader) reloadIfChanged() {
+	modTime, err := r.latestModTime()
+	if err != nil || modTime == r.modTime.Load() {
+		return
+	}
+	if err := r.Reload(); err != nil {
+		log.Printf("⚠️  证书重载失败,继续使用旧证书: %v", err)
+		return
+	}
+	log.Println("🔐 证书文件已更新,已重新加载")
+}
+
+func (r *Reloader) latestModTime() (int64, error) {
+	var latest int64
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return 0, err
+		}
+		latest = max(latest, info.ModTime().UnixNano())
+	}
+	return latest, nil
+}
@@ -0,0 +1,116 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned 生成指定序列号的自签名证书并写入文件
+func writeSelfSigned(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func currentSerial(t *testing.T, r *Reloader) int64 {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil || cert == nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeSelfSigned(t, certFile, keyFile, 1)
+
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	if got := currentSerial(t, r); got != 1 {
+		t.Fatalf("expected serial 1, got %d", got)
+	}
+
+	// 替换证书后重载
+	writeSelfSigned(t, certFile, keyFile, 2)
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := currentSerial(t, r); got != 2 {
+		t.Fatalf("expected serial 2 after swap, got %d", got)
+	}
+
+	// 损坏的证书不影响当前证书
+	os.WriteFile(certFile, []byte("garbage"), 0o600)
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected error for invalid certificate")
+	}
+	if got := currentSerial(t, r); got != 2 {
+		t.Fatalf("failed reload should keep serial 2, got %d", got)
+	}
+}
+
+func TestReloader_WatchFileChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeSelfSigned(t, certFile, keyFile, 1)
+
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	r.Watch(10 * time.Millisecond)
+	defer r.Stop()
+
+	writeSelfSigned(t, certFile, keyFile, 3)
+	// 确保修改时间变化(部分文件系统时间精度较低)
+	future := time.Now().Add(time.Second)
+	os.Chtimes(certFile, future, future)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for currentSerial(t, r) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher did not pick up the new certificate")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
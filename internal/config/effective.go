@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 配置来源
+const (
+	SourceEnv     = "env"     // 来自环境变量
+	SourceDefault = "default" // 未设置,使用默认值
+	SourceInvalid = "invalid" // 已设置但无法解析,回退默认值
+)
+
+// Mask 敏感配置的脱敏占位符
+const Mask = "***"
+
+// Setting 一项已生效的配置
+type Setting struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Default string `json:"default"`
+	Source  string `json:"source"`
+}
+
+// registry 记录各组件通过本包读取过的配置(名称 -> 生效值)
+var registry = struct {
+	sync.RWMutex
+	settings map[string]Setting
+}{settings: make(map[string]Setting)}
+
+// record 记录一次配置读取结果
+func record(name string, value, def any, source string) {
+	s := Setting{Name: name, Value: format(value), Default: format(def), Source: source}
+	registry.Lock()
+	registry.settings[name] = s
+	registry.Unlock()
+}
+
+func format(v any) string {
+	if items, ok := v.([]string); ok {
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// Effective 返回已读取的全部配置(按名称排序,敏感值已脱敏)
+// 配置在各组件初始化时读取,启动完成后即为进程当前生效的配置
+func Effective() []Setting {
+	registry.RLock()
+	settings := make([]Setting, 0, len(registry.settings))
+	for _, s := range registry.settings {
+		settings = append(settings, s)
+	}
+	registry.RUnlock()
+
+	for i := range settings {
+		settings[i].Value = maskValue(settings[i].Name, settings[i].Value)
+		settings[i].Default = maskValue(settings[i].Name, settings[i].Default)
+	}
+	slices.SortFunc(settings, func(a, b Setting) int { return strings.Compare(a.Name, b.Name) })
+	return settings
+}
+
+// sensitiveWords 名称中包含这些片段的配置视为敏感(按 "_" 分段匹配)
+var sensitiveWords = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIALS"}
+
+// IsSensitive 判断配置名是否为敏感项
+func IsSensitive(name string) bool {
+	for part := range strings.SplitSeq(strings.ToUpper(name), "_") {
+		if slices.Contains(sensitiveWords, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHeaderSetting 判断配置是否为 "名称: 值" 形式的请求头列表(如 UPSTREAM_HEADERS)
+func isHeaderSetting(name string) bool {
+	for part := range strings.SplitSeq(strings.ToUpper(name), "_") {
+		if part == "HEADERS" {
+			return true
+		}
+	}
+	return false
+}
+
+// maskValue 脱敏敏感配置;请求头列表中的头部值及URL中的用户信息与查询参数值总是脱敏
+func maskValue(name, value string) string {
+	if value == "" {
+		return value
+	}
+	if IsSensitive(name) {
+		return Mask
+	}
+	headers := isHeaderSetting(name)
+	if !headers && !strings.Contains(value, "://") {
+		return value
+	}
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = maskItem(item, headers)
+	}
+	return strings.Join(items, ",")
+}
+
+// maskItem 脱敏列表配置中的一项
+func maskItem(item string, header bool) string {
+	if header {
+		if name, _, ok := strings.Cut(item, ":"); ok {
+			return name + ": " + Mask
+		}
+	}
+	if strings.Contains(item, "://") {
+		if u, err := url.Parse(strings.TrimSpace(item)); err == nil {
+			return maskURL(u)
+		}
+	}
+	return item
+}
+
+// maskURL 脱敏URL中的用户信息(如 redis://:pass@host)及查询参数值(如 ?token=...)
+func maskURL(u *url.URL) string {
+	masked := u.User != nil
+	u.User = nil
+	if u.RawQuery != "" {
+		query := u.Query()
+		keys := slices.Sorted(maps.Keys(query))
+		for i, key := range keys {
+			keys[i] = url.QueryEscape(key) + "=" + Mask
+		}
+		u.RawQuery = strings.Join(keys, "&")
+	}
+	if masked {
+		// 占位符直接拼接(url.User 会转义 *)
+		return strings.Replace(u.String(), "://", "://"+Mask+"@", 1)
+	}
+	return u.String()
+}
@@ -0,0 +1,115 @@
+// Package config 提供环境变量读取辅助函数
+// 解析失败时记录警告并回退默认值,避免单个配置错误影响启动
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String 读取字符串配置(未设置时返回默认值)
+func String(name, def string) string {
+	if value, ok := lookup(name); ok {
+		record(name, value, def, SourceEnv)
+		return value
+	}
+	record(name, def, def, SourceDefault)
+	return def
+}
+
+// Int 读取整数配置
+func Int(name string, def int) int {
+	value, ok := lookup(name)
+	if !ok {
+		record(name, def, def, SourceDefault)
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %d", name, value, def)
+		record(name, def, def, SourceInvalid)
+		return def
+	}
+	record(name, n, def, SourceEnv)
+	return n
+}
+
+// Float 读取浮点数配置
+func Float(name string, def float64) float64 {
+	value, ok := lookup(name)
+	if !ok {
+		record(name, def, def, SourceDefault)
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %g", name, value, def)
+		record(name, def, def, SourceInvalid)
+		return def
+	}
+	record(name, f, def, SourceEnv)
+	return f
+}
+
+// Bool 读取布尔配置(支持 true/false/1/0 等 strconv.ParseBool 格式)
+func Bool(name string, def bool) bool {
+	value, ok := lookup(name)
+	if !ok {
+		record(name, def, def, SourceDefault)
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %t", name, value, def)
+		record(name, def, def, SourceInvalid)
+		return def
+	}
+	record(name, b, def, SourceEnv)
+	return b
+}
+
+// Duration 读取时长配置(如 "30s"、"500ms")
+func Duration(name string, def time.Duration) time.Duration {
+	value, ok := lookup(name)
+	if !ok {
+		record(name, def, def, SourceDefault)
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️  Invalid %s=%q, using default %s", name, value, def)
+		record(name, def, def, SourceInvalid)
+		return def
+	}
+	record(name, d, def, SourceEnv)
+	return d
+}
+
+// List 读取逗号分隔的列表配置(忽略空项)
+func List(name string) []string {
+	value, ok := lookup(name)
+	if !ok {
+		record(name, "", "", SourceDefault)
+		return nil
+	}
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	record(name, items, "", SourceEnv)
+	return items
+}
+
+func lookup(name string) (string, bool) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", false
+	}
+	value = strings.TrimSpace(value)
+	return value, value != ""
+}
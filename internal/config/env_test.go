@@ -0,0 +1,42 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnvHelpers(t *testing.T) {
+	t.Setenv("CFG_INT", "42")
+	t.Setenv("CFG_BAD_INT", "abc")
+	t.Setenv("CFG_BOOL", "true")
+	t.Setenv("CFG_DURATION", "1500ms")
+	t.Setenv("CFG_FLOAT", "0.25")
+	t.Setenv("CFG_LIST", " a, ,b ,c")
+	t.Setenv("CFG_BLANK", "   ")
+
+	if got := Int("CFG_INT", 1); got != 42 {
+		t.Errorf("Int: expected 42, got %d", got)
+	}
+	if got := Int("CFG_BAD_INT", 7); got != 7 {
+		t.Errorf("Int with invalid value: expected default 7, got %d", got)
+	}
+	if got := Int("CFG_MISSING", 3); got != 3 {
+		t.Errorf("Int missing: expected default 3, got %d", got)
+	}
+	if !Bool("CFG_BOOL", false) {
+		t.Error("Bool: expected true")
+	}
+	if got := Duration("CFG_DURATION", time.Second); got != 1500*time.Millisecond {
+		t.Errorf("Duration: expected 1.5s, got %s", got)
+	}
+	if got := Float("CFG_FLOAT", 1); got != 0.25 {
+		t.Errorf("Float: expected 0.25, got %g", got)
+	}
+	if got := List("CFG_LIST"); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("List: expected [a b c], got %v", got)
+	}
+	if got := String("CFG_BLANK", "def"); got != "def" {
+		t.Errorf("String blank: expected default, got %q", got)
+	}
+}
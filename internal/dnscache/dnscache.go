@@ -0,0 +1,181 @@
+// Package dnscache 为上游拨号提供带TTL的DNS缓存,减少高QPS下的解析延迟和解析器压力
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Resolver DNS解析接口(*net.Resolver 满足该接口)
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Cache DNS缓存
+// 过期后由首个请求重新解析(并发请求合并为一次),解析失败时继续使用旧结果
+type Cache struct {
+	resolver Resolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*entry
+	inflight map[string]*lookup
+}
+
+type entry struct {
+	addrs   []string
+	expires time.Time
+	next    atomic.Uint32 // 轮询起点(多条A记录时轮换)
+}
+
+type lookup struct {
+	done  chan struct{}
+	entry *entry
+	err   error
+}
+
+// New 创建DNS缓存,resolver 为nil时使用 net.DefaultResolver
+func New(resolver Resolver, ttl time.Duration) *Cache {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &Cache{
+		resolver: resolver,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*entry),
+		inflight: make(map[string]*lookup),
+	}
+}
+
+// Lookup 返回主机的IP地址,每次调用按轮询顺序排列
+func (c *Cache) Lookup(ctx context.Context, host string) ([]string, error) {
+	e, err := c.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	n := len(e.addrs)
+	start := int(e.next.Add(1)-1) % n
+	addrs := make([]string, 0, n)
+	addrs = append(addrs, e.addrs[start:]...)
+	return append(addrs, e.addrs[:start]...), nil
+}
+
+// resolve 返回未过期的缓存,否则重新解析(同一主机的并发解析合并)
+func (c *Cache) resolve(ctx context.Context, host string) (*entry, error) {
+	c.mu.Lock()
+	cached := c.entries[host]
+	if cached != nil && c.now().Before(cached.expires) {
+		c.mu.Unlock()
+		return cached, nil
+	}
+	call, running := c.inflight[host]
+	if !running {
+		call = &lookup{done: make(chan struct{})}
+		c.inflight[host] = call
+	}
+	c.mu.Unlock()
+
+	if running {
+		select {
+		case <-call.done:
+			return call.entry, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	call.entry, call.err = c.lookup(ctx, host, cached)
+
+	c.mu.Lock()
+	if call.err == nil {
+		c.entries[host] = call.entry
+	}
+	delete(c.inflight, host)
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.entry, call.err
+}
+
+// lookup 执行实际解析;失败且有旧结果时沿用旧结果,避免解析器故障导致上游不可用
+func (c *Cache) lookup(ctx context.Context, host string, stale *entry) (*entry, error) {
+	ips, err := c.resolver.LookupIPAddr(ctx, host)
+	if err == nil && len(ips) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		if stale != nil {
+			log.Printf("⚠️  DNS刷新失败,继续使用缓存结果 %s: %v", host, err)
+			return stale, nil
+		}
+		return nil, err
+	}
+
+	e := &entry{
+		addrs:   make([]string, len(ips)),
+		expires: c.now().Add(c.ttl),
+	}
+	for i, ip := range ips {
+		e.addrs[i] = ip.String()
+	}
+	if stale != nil {
+		// 保持轮询位置连续
+		e.next.Store(stale.next.Load())
+	}
+	return e, nil
+}
+
+// dnsDoneInfo 将缓存解析结果转换为 httptrace 回调参数
+func dnsDoneInfo(addrs []string, err error) httptrace.DNSDoneInfo {
+	info := httptrace.DNSDoneInfo{Err: err}
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			info.Addrs = append(info.Addrs, net.IPAddr{IP: ip})
+		}
+	}
+	return info
+}
+
+// DialContext 返回使用缓存解析结果的拨号函数
+// 依次尝试各个地址(起点轮换),目标为IP时直接拨号
+func (c *Cache) DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, address)
+		}
+
+		// 解析不经过标准解析器,由此触发 httptrace 的 DNS 回调(上游耗时统计)
+		trace := httptrace.ContextClientTrace(ctx)
+		if trace != nil && trace.DNSStart != nil {
+			trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+		}
+		addrs, err := c.Lookup(ctx, host)
+		if trace != nil && trace.DNSDone != nil {
+			trace.DNSDone(dnsDoneInfo(addrs, err))
+		}
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, ip := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
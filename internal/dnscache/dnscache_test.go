@@ -0,0 +1,185 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeResolver 返回预设地址并记录解析次数
+type fakeResolver struct {
+	mu      sync.Mutex
+	addrs   map[string][]string
+	err     error
+	lookups atomic.Int32
+}
+
+func (r *fakeResolver) set(host string, addrs ...string) {
+	r.mu.Lock()
+	r.addrs[host] = addrs
+	r.mu.Unlock()
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.lookups.Add(1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	var ips []net.IPAddr
+	for _, addr := range r.addrs[host] {
+		ips = append(ips, net.IPAddr{IP: net.ParseIP(addr)})
+	}
+	return ips, nil
+}
+
+// testClock 可手动推进的时钟
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestCache(ttl time.Duration) (*Cache, *fakeResolver, *testClock) {
+	resolver := &fakeResolver{addrs: make(map[string][]string)}
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	c := New(resolver, ttl)
+	c.now = clock.Now
+	return c, resolver, clock
+}
+
+func TestCache_CachesUntilTTL(t *testing.T) {
+	c, resolver, clock := newTestCache(time.Minute)
+	resolver.set("api.example.com", "203.0.113.1")
+	ctx := context.Background()
+
+	for range 5 {
+		addrs, err := c.Lookup(ctx, "api.example.com")
+		if err != nil || len(addrs) != 1 || addrs[0] != "203.0.113.1" {
+			t.Fatalf("unexpected lookup result %v %v", addrs, err)
+		}
+	}
+	if n := resolver.lookups.Load(); n != 1 {
+		t.Fatalf("expected 1 resolver lookup within TTL, got %d", n)
+	}
+
+	// TTL 过期后重新解析并使用新结果
+	resolver.set("api.example.com", "203.0.113.2")
+	clock.now = clock.now.Add(time.Minute)
+	addrs, err := c.Lookup(ctx, "api.example.com")
+	if err != nil || addrs[0] != "203.0.113.2" {
+		t.Fatalf("expected refreshed address, got %v %v", addrs, err)
+	}
+	if n := resolver.lookups.Load(); n != 2 {
+		t.Fatalf("expected refresh after TTL, got %d lookups", n)
+	}
+}
+
+func TestCache_RotatesRecords(t *testing.T) {
+	c, resolver, _ := newTestCache(time.Minute)
+	resolver.set("api.example.com", "203.0.113.1", "203.0.113.2", "203.0.113.3")
+	ctx := context.Background()
+
+	var firsts []string
+	for range 4 {
+		addrs, err := c.Lookup(ctx, "api.example.com")
+		if err != nil || len(addrs) != 3 {
+			t.Fatalf("unexpected lookup result %v %v", addrs, err)
+		}
+		firsts = append(firsts, addrs[0])
+	}
+	want := []string{"203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.1"}
+	for i := range want {
+		if firsts[i] != want[i] {
+			t.Fatalf("expected rotation %v, got %v", want, firsts)
+		}
+	}
+}
+
+func TestCache_StaleOnRefreshFailure(t *testing.T) {
+	c, resolver, clock := newTestCache(time.Minute)
+	resolver.set("api.example.com", "203.0.113.1")
+	ctx := context.Background()
+
+	if _, err := c.Lookup(ctx, "api.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	resolver.err = errors.New("resolver down")
+	clock.now = clock.now.Add(2 * time.Minute)
+	addrs, err := c.Lookup(ctx, "api.example.com")
+	if err != nil || addrs[0] != "203.0.113.1" {
+		t.Fatalf("expected stale address on refresh failure, got %v %v", addrs, err)
+	}
+
+	if _, err := c.Lookup(ctx, "unknown.example.com"); err == nil {
+		t.Fatal("expected error for unresolvable host without cache")
+	}
+}
+
+func TestCache_DialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	c, resolver, _ := newTestCache(time.Minute)
+	// 第一个地址不可达时尝试下一个
+	resolver.set("upstream.test", "127.0.0.2", "127.0.0.1")
+	client := &http.Client{Transport: &http.Transport{
+		DialContext:       c.DialContext(&net.Dialer{Timeout: time.Second}),
+		DisableKeepAlives: true,
+	}}
+
+	for range 3 {
+		resp, err := client.Get("http://upstream.test:" + port + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	if n := resolver.lookups.Load(); n != 1 {
+		t.Errorf("expected cached resolution across dials, got %d lookups", n)
+	}
+}
+
+func TestCache_DialContextTracesDNS(t *testing.T) {
+	c, resolver, _ := newTestCache(time.Minute)
+	resolver.set("upstream.test", "127.0.0.1")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	var started, done []string
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) { started = append(started, info.Host) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			for _, addr := range info.Addrs {
+				done = append(done, addr.String())
+			}
+		},
+	})
+	// 命中缓存的解析同样触发 DNS 回调
+	for range 2 {
+		conn, err := c.DialContext(&net.Dialer{Timeout: time.Second})(ctx, "tcp", net.JoinHostPort("upstream.test", port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if len(started) != 2 || started[0] != "upstream.test" || len(done) != 2 || done[0] != "127.0.0.1" {
+		t.Errorf("expected DNS trace on every dial, got start=%v done=%v", started, done)
+	}
+}
@@ -0,0 +1,259 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// 主动健康检查默认值
+const (
+	DefaultCheckPath      = "/"
+	DefaultCheckThreshold = 3
+	DefaultCheckTimeout   = 2 * time.Second
+)
+
+// OptionsSource 映射扩展配置来源(依赖倒置)
+type OptionsSource interface {
+	GetAllOptions() map[string]mapping.Options
+}
+
+// CheckerConfig 主动健康检查配置
+type CheckerConfig struct {
+	Interval  time.Duration // 探测间隔
+	Path      string        // 探测路径(空表示 DefaultCheckPath)
+	Threshold int           // 连续失败多少次后标记为不健康(0表示 DefaultCheckThreshold)
+	Timeout   time.Duration // 单次探测超时(0表示 DefaultCheckTimeout)
+}
+
+// TargetHealth 多目标映射中单个目标的健康状态
+type TargetHealth struct {
+	Prefix              string    `json:"prefix"`
+	Target              string    `json:"target"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	StatusCode          int       `json:"status_code,omitempty"`
+	Error               string    `json:"error,omitempty"`
+	CheckedAt           time.Time `json:"checked_at"`
+}
+
+// targetState 目标的探测状态(按目标URL记录,多个映射共用同一目标时共享)
+type targetState struct {
+	healthy    bool
+	failures   int
+	statusCode int
+	err        string
+	checkedAt  time.Time
+}
+
+// Checker 周期探测多目标映射(upstreams)的各个目标: 连续失败达到阈值后标记为不健康,
+// 一次成功即恢复。5xx 响应和连接失败视为失败,其他状态码视为健康
+type Checker struct {
+	source    OptionsSource
+	client    *http.Client
+	interval  time.Duration
+	path      string
+	threshold int
+	timeout   time.Duration
+
+	mu      sync.RWMutex
+	targets map[string]*targetState
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewChecker 创建主动健康检查器,Interval<=0 时返回 nil(禁用)
+func NewChecker(source OptionsSource, client *http.Client, cfg CheckerConfig) *Checker {
+	if cfg.Interval <= 0 {
+		return nil
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultCheckPath
+	}
+	if !strings.HasPrefix(cfg.Path, "/") {
+		cfg.Path = "/" + cfg.Path
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultCheckThreshold
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultCheckTimeout
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Checker{
+		source:    source,
+		client:    client,
+		interval:  cfg.Interval,
+		path:      cfg.Path,
+		threshold: cfg.Threshold,
+		timeout:   cfg.Timeout,
+		targets:   make(map[string]*targetState),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Healthy 判断目标是否健康,尚未探测过的目标视为健康
+func (c *Checker) Healthy(target string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state, ok := c.targets[target]
+	return !ok || state.healthy
+}
+
+// Start 立即探测一次,之后按间隔周期探测
+func (c *Checker) Start() {
+	c.CheckNow(context.Background())
+	c.wg.Go(func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.CheckNow(context.Background())
+			case <-c.stopChan:
+				return
+			}
+		}
+	})
+}
+
+// Stop 停止周期探测(可重复调用)
+func (c *Checker) Stop() {
+	c.stopOnce.Do(func() { close(c.stopChan) })
+	c.wg.Wait()
+}
+
+// CheckNow 并发探测当前所有多目标映射的目标,并清理已不再使用的目标
+func (c *Checker) CheckNow(ctx context.Context) {
+	targets := make(map[string]bool)
+	for _, opts := range c.source.GetAllOptions() {
+		for _, upstream := range opts.Upstreams {
+			targets[upstream.URL] = true
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeConcurrency)
+	for target := range targets {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			c.probe(ctx, target)
+		})
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for target := range c.targets {
+		if !targets[target] {
+			delete(c.targets, target)
+		}
+	}
+}
+
+// probe 探测单个目标并更新其状态
+func (c *Checker) probe(ctx context.Context, target string) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	status, err := c.get(ctx, target)
+	failed := err != nil || status >= http.StatusInternalServerError
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.targets[target]
+	if !ok {
+		state = &targetState{healthy: true}
+		c.targets[target] = state
+	}
+	state.statusCode, state.err, state.checkedAt = status, "", time.Now()
+	if err != nil {
+		state.err = err.Error()
+	}
+	if !failed {
+		if !state.healthy {
+			log.Printf("💚 上游恢复健康: %s", redactedURL(target))
+		}
+		state.healthy, state.failures = true, 0
+		return
+	}
+	state.failures++
+	if state.healthy && state.failures >= c.threshold {
+		state.healthy = false
+		log.Printf("💔 上游连续 %d 次探测失败,暂停转发: %s", state.failures, redactedURL(target))
+	}
+}
+
+// get 以 GET 请求探测目标的检查路径并返回状态码
+func (c *Checker) get(ctx context.Context, target string) (int, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return 0, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + c.path
+	u.RawQuery = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return 0, urlErr.Err
+		}
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Status 返回各多目标映射的目标健康状态(按前缀、目标排序)
+func (c *Checker) Status() []TargetHealth {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := []TargetHealth{}
+	for prefix, opts := range c.source.GetAllOptions() {
+		for _, upstream := range opts.Upstreams {
+			entry := TargetHealth{Prefix: prefix, Target: upstream.URL, Healthy: true}
+			if state, ok := c.targets[upstream.URL]; ok {
+				entry.Healthy = state.healthy
+				entry.ConsecutiveFailures = state.failures
+				entry.StatusCode = state.statusCode
+				entry.Error = state.err
+				entry.CheckedAt = state.checkedAt
+			}
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Prefix != result[j].Prefix {
+			return result[i].Prefix < result[j].Prefix
+		}
+		return result[i].Target < result[j].Target
+	})
+	return result
+}
+
+// redactedURL 隐藏目标URL中的密码后用于日志
+func redactedURL(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	return u.Redacted()
+}
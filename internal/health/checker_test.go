@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// staticOptions 固定的映射配置来源
+type staticOptions map[string]mapping.Options
+
+func (s staticOptions) GetAllOptions() map[string]mapping.Options {
+	return s
+}
+
+func TestChecker_MarksFailingTargetAndRecovers(t *testing.T) {
+	var failing atomic.Bool
+	var paths atomic.Value
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths.Store(r.URL.Path)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer flaky.Close()
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound) // 非5xx视为健康
+	}))
+	defer stable.Close()
+
+	checker := NewChecker(staticOptions{
+		"/api": {Upstreams: []mapping.Upstream{{URL: flaky.URL + "/v1/"}, {URL: stable.URL}}},
+	}, nil, CheckerConfig{Interval: time.Hour, Path: "healthz", Threshold: 2})
+
+	ctx := context.Background()
+	checker.CheckNow(ctx)
+	if got := paths.Load(); got != "/v1/healthz" {
+		t.Errorf("expected probe path /v1/healthz, got %v", got)
+	}
+
+	failing.Store(true)
+	checker.CheckNow(ctx)
+	if !checker.Healthy(flaky.URL + "/v1/") {
+		t.Fatal("target should stay healthy below the failure threshold")
+	}
+	checker.CheckNow(ctx)
+	if checker.Healthy(flaky.URL + "/v1/") {
+		t.Fatal("target should be unhealthy after reaching the failure threshold")
+	}
+	if !checker.Healthy(stable.URL) {
+		t.Error("target answering 404 should be healthy")
+	}
+
+	status := checker.Status()
+	if len(status) != 2 {
+		t.Fatalf("expected 2 targets, got %+v", status)
+	}
+	for _, target := range status {
+		if target.Target == flaky.URL+"/v1/" && (target.Healthy || target.ConsecutiveFailures != 2 || target.StatusCode != 500) {
+			t.Errorf("unexpected status for failing target: %+v", target)
+		}
+	}
+
+	// 一次成功即恢复
+	failing.Store(false)
+	checker.CheckNow(ctx)
+	if !checker.Healthy(flaky.URL + "/v1/") {
+		t.Error("target should be healthy again after a successful probe")
+	}
+}
+
+func TestChecker_UnknownTargetsAreHealthy(t *testing.T) {
+	if NewChecker(staticOptions{}, nil, CheckerConfig{}) != nil {
+		t.Fatal("expected nil checker when interval is not set")
+	}
+
+	options := staticOptions{"/api": {Upstreams: []mapping.Upstream{{URL: "http://127.0.0.1:1"}}}}
+	checker := NewChecker(options, nil, CheckerConfig{Interval: time.Hour, Threshold: 1, Timeout: 200 * time.Millisecond})
+	if !checker.Healthy("http://127.0.0.1:1") {
+		t.Fatal("targets not yet probed should be healthy")
+	}
+	checker.CheckNow(context.Background())
+	if checker.Healthy("http://127.0.0.1:1") {
+		t.Fatal("unreachable target should be unhealthy")
+	}
+
+	// 目标从配置中移除后清理其状态
+	delete(options, "/api")
+	checker.CheckNow(context.Background())
+	if !checker.Healthy("http://127.0.0.1:1") || len(checker.Status()) != 0 {
+		t.Error("removed targets should be forgotten")
+	}
+}
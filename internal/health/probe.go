@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// probeConcurrency 同时进行的目标探测数
+const probeConcurrency = 16
+
+// TargetProbe 单个映射目标的探测结果
+type TargetProbe struct {
+	Prefix     string `json:"prefix"`
+	Target     string `json:"target"`
+	StatusCode int    `json:"status_code,omitempty"` // 可达时的上游状态码
+	Error      string `json:"error,omitempty"`       // 不可达原因
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ProbeSummary 目标可达性汇总(各列表按前缀排序)
+type ProbeSummary struct {
+	Reachable   []TargetProbe `json:"reachable"`
+	Unreachable []TargetProbe `json:"unreachable"`
+	Skipped     []string      `json:"skipped"` // 正则映射的目标为模板,无法直接探测
+}
+
+// String 返回一行汇总
+func (s ProbeSummary) String() string {
+	return fmt.Sprintf("%d reachable, %d unreachable, %d skipped",
+		len(s.Reachable), len(s.Unreachable), len(s.Skipped))
+}
+
+// ProbeTargets 并发以 HEAD 请求探测映射目标,收到任何HTTP响应(含4xx/5xx)即视为可达
+// timeout 为单个目标的探测超时
+func ProbeTargets(ctx context.Context, client *http.Client, mappings map[string]string, timeout time.Duration) ProbeSummary {
+	var (
+		summary ProbeSummary
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, probeConcurrency)
+	)
+	for prefix, target := range mappings {
+		if mapping.IsPattern(prefix) {
+			summary.Skipped = append(summary.Skipped, prefix)
+			continue
+		}
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := probeTarget(ctx, client, prefix, target, timeout)
+			mu.Lock()
+			defer mu.Unlock()
+			if result.Error != "" {
+				summary.Unreachable = append(summary.Unreachable, result)
+			} else {
+				summary.Reachable = append(summary.Reachable, result)
+			}
+		})
+	}
+	wg.Wait()
+
+	byPrefix := func(list []TargetProbe) func(i, j int) bool {
+		return func(i, j int) bool { return list[i].Prefix < list[j].Prefix }
+	}
+	sort.Slice(summary.Reachable, byPrefix(summary.Reachable))
+	sort.Slice(summary.Unreachable, byPrefix(summary.Unreachable))
+	sort.Strings(summary.Skipped)
+	return summary
+}
+
+// probeTarget 探测单个目标
+func probeTarget(ctx context.Context, client *http.Client, prefix, target string, timeout time.Duration) TargetProbe {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	status, err := headStatus(ctx, client, target)
+	result := TargetProbe{
+		Prefix:     prefix,
+		Target:     target,
+		StatusCode: status,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// headStatus 发送 HEAD 请求并返回状态码
+func headStatus(ctx context.Context, client *http.Client, target string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// 去掉 url.Error 中的完整URL(可能含凭据),由调用方按需脱敏输出
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return 0, urlErr.Err
+		}
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeTargets(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("expected HEAD probe, got %s", r.Method)
+		}
+	}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	// 已关闭的监听地址: 连接被拒绝
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := "http://" + listener.Addr().String()
+	listener.Close()
+
+	// 不响应的目标: 探测超时
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hanging.Close()
+
+	summary := ProbeTargets(context.Background(), http.DefaultClient, map[string]string{
+		"/ok":                   ok.URL,
+		"/failing":              failing.URL,
+		"/closed":               closedAddr,
+		"/hanging":              hanging.URL,
+		"/invalid":              "://bad",
+		"~^/t/(?P<tenant>\\w+)": "https://example.com/$tenant",
+	}, 200*time.Millisecond)
+
+	if len(summary.Reachable) != 2 || summary.Reachable[0].Prefix != "/failing" || summary.Reachable[1].Prefix != "/ok" {
+		t.Fatalf("unexpected reachable targets: %+v", summary.Reachable)
+	}
+	if summary.Reachable[0].StatusCode != http.StatusServiceUnavailable || summary.Reachable[1].StatusCode != http.StatusOK {
+		t.Errorf("unexpected status codes: %+v", summary.Reachable)
+	}
+
+	var unreachable []string
+	for _, probe := range summary.Unreachable {
+		unreachable = append(unreachable, probe.Prefix)
+		if probe.Error == "" {
+			t.Errorf("expected error for %s", probe.Prefix)
+		}
+	}
+	if len(unreachable) != 3 || unreachable[0] != "/closed" || unreachable[1] != "/hanging" || unreachable[2] != "/invalid" {
+		t.Errorf("unexpected unreachable targets: %v", unreachable)
+	}
+	if len(summary.Skipped) != 1 {
+		t.Errorf("expected pattern mapping to be skipped, got %v", summary.Skipped)
+	}
+	if got := summary.String(); got != "2 reachable, 3 unreachable, 1 skipped" {
+		t.Errorf("String() = %q", got)
+	}
+}
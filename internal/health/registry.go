@@ -0,0 +1,193 @@
+// Package health 提供可扩展的依赖健康检查(用于 /readyz)
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckFunc 单项检查,返回nil表示健康
+type CheckFunc func(ctx context.Context) error
+
+// Result 单项检查结果
+type Result struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"` // ok / fail
+	Error      string `json:"-"`      // 失败详情只写日志,不对外暴露(可能包含内部地址)
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report 聚合检查结果
+type Report struct {
+	Status string   `json:"status"` // ready / unready
+	Checks []Result `json:"checks"`
+}
+
+// Ready 是否全部检查通过
+func (r Report) Ready() bool {
+	return r.Status == "ready"
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Registry 健康检查注册表(并发安全)
+type Registry struct {
+	timeout time.Duration
+
+	mu       sync.RWMutex
+	checks   []check
+	disabled map[string]bool
+}
+
+// NewRegistry 创建注册表,timeout 为单项检查超时
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &Registry{
+		timeout:  timeout,
+		disabled: make(map[string]bool),
+	}
+}
+
+// Register 注册检查项(同名覆盖)
+func (r *Registry) Register(name string, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.checks {
+		if r.checks[i].name == name {
+			r.checks[i].fn = fn
+			return
+		}
+	}
+	r.checks = append(r.checks, check{name: name, fn: fn})
+}
+
+// SetEnabled 启用/禁用检查项(禁用的检查不参与聚合)
+func (r *Registry) SetEnabled(name string, enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if enabled {
+		delete(r.disabled, name)
+	} else {
+		r.disabled[name] = true
+	}
+}
+
+// Run 并发执行所有启用的检查并聚合结果
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make([]check, 0, len(r.checks))
+	for _, c := range r.checks {
+		if !r.disabled[c.name] {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.runOne(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: "ready", Checks: results}
+	if slices.ContainsFunc(results, func(res Result) bool { return res.Status != "ok" }) {
+		report.Status = "unready"
+	}
+	return report
+}
+
+func (r *Registry) runOne(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := safeCall(ctx, c.fn)
+	result := Result{
+		Name:       c.name,
+		Status:     "ok",
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// safeCall 执行检查,panic 视为失败
+func safeCall(ctx context.Context, fn CheckFunc) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("check panicked: %v", rec)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Handler 返回 /readyz 处理函数(全部通过200,否则503);响应只含各项状态,失败详情写入日志
+func (r *Registry) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := r.Run(c.Request.Context())
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+			for _, result := range report.Checks {
+				if result.Status != "ok" {
+					log.Printf("⚠️  就绪检查失败 [%s]: %s", result.Name, result.Error)
+				}
+			}
+		}
+		c.JSON(status, report)
+	}
+}
+
+// ErrNoMappings 没有任何映射
+var ErrNoMappings = errors.New("no mappings configured")
+
+// MappingsCheck 至少存在一个映射
+func MappingsCheck(count func() int) CheckFunc {
+	return func(ctx context.Context) error {
+		if count() == 0 {
+			return ErrNoMappings
+		}
+		return nil
+	}
+}
+
+// HTTPCheck 探测上游URL,5xx或连接失败视为不健康
+func HTTPCheck(client *http.Client, url string) CheckFunc {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("upstream returned %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegistry_AggregatesFailures(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register("redis", func(ctx context.Context) error { return nil })
+	registry.Register("mappings", MappingsCheck(func() int { return 0 }))
+
+	report := registry.Run(context.Background())
+	if report.Ready() {
+		t.Fatal("expected unready when a check fails")
+	}
+
+	var failed []string
+	for _, result := range report.Checks {
+		if result.Status == "fail" {
+			failed = append(failed, result.Name)
+			if result.Error != ErrNoMappings.Error() {
+				t.Errorf("expected error %q, got %q", ErrNoMappings, result.Error)
+			}
+		}
+	}
+	if len(failed) != 1 || failed[0] != "mappings" {
+		t.Fatalf("expected only mappings to fail, got %v", failed)
+	}
+
+	// 禁用失败项后恢复就绪
+	registry.SetEnabled("mappings", false)
+	report = registry.Run(context.Background())
+	if !report.Ready() || len(report.Checks) != 1 {
+		t.Fatalf("expected ready with one check after disabling, got %+v", report)
+	}
+}
+
+func TestRegistry_TimeoutAndPanic(t *testing.T) {
+	registry := NewRegistry(20 * time.Millisecond)
+	registry.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	registry.Register("panics", func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	report := registry.Run(context.Background())
+	for _, result := range report.Checks {
+		if result.Status != "fail" {
+			t.Errorf("check %s should fail, got %+v", result.Name, result)
+		}
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := NewRegistry(time.Second)
+	registry.Register("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	r := gin.New()
+	r.GET("/readyz", registry.Handler())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Status != "unready" || report.Checks[0].Name != "redis" || report.Checks[0].Status != "fail" {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	// 失败详情只写日志
+	if strings.Contains(w.Body.String(), "connection refused") {
+		t.Errorf("readiness response should not expose check errors: %s", w.Body.String())
+	}
+
+	registry.Register("redis", func(ctx context.Context) error { return nil })
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 after recovery, got %d", w.Code)
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	status := http.StatusOK
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer upstream.Close()
+
+	check := HTTPCheck(upstream.Client(), upstream.URL)
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("expected healthy upstream, got %v", err)
+	}
+
+	status = http.StatusBadGateway
+	if err := check(context.Background()); err == nil {
+		t.Fatal("expected 5xx upstream to fail")
+	}
+}
@@ -0,0 +1,129 @@
+// Package importer 从远程URL导入映射配置(GitOps场景),支持周期刷新
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/schedule"
+)
+
+// maxBodyBytes 远程映射文件的大小上限
+const maxBodyBytes = 10 << 20
+
+// Applier 映射批量写入接口(依赖倒置)
+type Applier interface {
+	ApplyMappings(ctx context.Context, entries []mapping.Entry, replace bool) (mapping.ApplyResult, error)
+}
+
+// Importer 远程映射导入器(非并发安全,Sync 需串行调用)
+type Importer struct {
+	url     string
+	display string // 日志中使用的URL(隐藏凭据)
+	replace bool
+	applier Applier
+	client  *http.Client
+
+	// 上次成功应用的内容(仅在同步协程中访问)
+	etag string
+	hash [sha256.Size]byte
+}
+
+// New 创建导入器; replace 为 true 时删除远程列表中不存在的映射
+func New(rawURL string, applier Applier, replace bool) *Importer {
+	display := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		display = u.Redacted()
+	}
+	return &Importer{
+		url:     rawURL,
+		display: display,
+		replace: replace,
+		applier: applier,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Sync 拉取并应用一次远程映射,内容未变化时跳过
+func (i *Importer) Sync(ctx context.Context) (mapping.ApplyResult, error) {
+	var result mapping.ApplyResult
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, i.url, nil)
+	if err != nil {
+		return result, err
+	}
+	if i.etag != "" {
+		req.Header.Set("If-None-Match", i.etag)
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("fetch mappings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return result, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("fetch mappings: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
+	if err != nil {
+		return result, fmt.Errorf("read mappings: %w", err)
+	}
+	if len(data) > maxBodyBytes {
+		return result, fmt.Errorf("mappings file exceeds %d bytes", maxBodyBytes)
+	}
+
+	hash := sha256.Sum256(data)
+	if hash == i.hash {
+		return result, nil
+	}
+
+	entries, err := mapping.ParseEntries(data)
+	if err != nil {
+		return result, fmt.Errorf("parse mappings: %w", err)
+	}
+	result, err = i.applier.ApplyMappings(ctx, entries, i.replace)
+	if err != nil {
+		return result, fmt.Errorf("apply mappings: %w", err)
+	}
+
+	i.hash = hash
+	i.etag = resp.Header.Get("ETag")
+	return result, nil
+}
+
+// Run 周期同步,直到 stop 关闭;失败仅记录日志,保留当前映射
+// leader 非nil时仅在本实例为领导者时同步(多实例部署避免重复写入)
+func (i *Importer) Run(stop <-chan struct{}, interval, jitter time.Duration, leader func() bool) {
+	schedule.Every(stop, interval, jitter, func() {
+		if leader == nil || leader() {
+			i.SyncAndLog()
+		}
+	})
+}
+
+// SyncAndLog 同步一次并记录结果,失败不影响当前映射
+func (i *Importer) SyncAndLog() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	result, err := i.Sync(ctx)
+	if err != nil {
+		log.Printf("⚠️  远程映射导入失败 (%s): %v", i.display, err)
+		return
+	}
+	if result.Changed() {
+		log.Printf("📥 已导入远程映射: added=%d updated=%d removed=%d", result.Added, result.Updated, result.Removed)
+	}
+}
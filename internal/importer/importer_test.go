@@ -0,0 +1,131 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+// fakeApplier 记录应用的映射(替换语义与存储层一致)
+type fakeApplier struct {
+	mappings map[string]string
+	calls    int
+	err      error
+}
+
+func (f *fakeApplier) ApplyMappings(ctx context.Context, entries []mapping.Entry, replace bool) (mapping.ApplyResult, error) {
+	f.calls++
+	if f.err != nil {
+		return mapping.ApplyResult{}, f.err
+	}
+	if replace {
+		f.mappings = make(map[string]string)
+	}
+	for _, e := range entries {
+		f.mappings[e.Prefix] = e.Target
+	}
+	return mapping.ApplyResult{Added: len(entries)}, nil
+}
+
+// mappingsServer 提供可修改内容的映射文件
+type mappingsServer struct {
+	mu   sync.Mutex
+	body string
+	etag string
+}
+
+func (s *mappingsServer) set(body, etag string) {
+	s.mu.Lock()
+	s.body, s.etag = body, etag
+	s.mu.Unlock()
+}
+
+func (s *mappingsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.etag != "" {
+		if r.Header.Get("If-None-Match") == s.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+	}
+	w.Write([]byte(s.body))
+}
+
+func TestImporter_SyncAndRefresh(t *testing.T) {
+	src := &mappingsServer{}
+	src.set(`{"/a":"https://a.example.com"}`, "")
+	server := httptest.NewServer(src)
+	defer server.Close()
+
+	applier := &fakeApplier{mappings: map[string]string{"/old": "https://old.example.com"}}
+	imp := New(server.URL, applier, true)
+	ctx := context.Background()
+
+	if _, err := imp.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(applier.mappings) != 1 || applier.mappings["/a"] != "https://a.example.com" {
+		t.Fatalf("expected remote mappings applied, got %v", applier.mappings)
+	}
+
+	// 内容未变化时不重复应用
+	if _, err := imp.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if applier.calls != 1 {
+		t.Fatalf("unchanged content should not be re-applied, got %d calls", applier.calls)
+	}
+
+	// 内容变化后刷新
+	src.set(`[{"prefix":"/b","target":"https://b.example.com"}]`, `"v2"`)
+	if _, err := imp.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if applier.mappings["/b"] != "https://b.example.com" || applier.calls != 2 {
+		t.Fatalf("expected refreshed mappings, got %v (calls=%d)", applier.mappings, applier.calls)
+	}
+
+	// ETag 未变化时服务端返回 304
+	if _, err := imp.Sync(ctx); err != nil || applier.calls != 2 {
+		t.Fatalf("expected 304 to skip apply, got calls=%d err=%v", applier.calls, err)
+	}
+}
+
+func TestImporter_Errors(t *testing.T) {
+	src := &mappingsServer{}
+	server := httptest.NewServer(src)
+	defer server.Close()
+	ctx := context.Background()
+
+	src.set(`not json`, "")
+	applier := &fakeApplier{mappings: map[string]string{"/keep": "https://keep.example.com"}}
+	imp := New(server.URL, applier, false)
+	if _, err := imp.Sync(ctx); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if applier.calls != 0 || applier.mappings["/keep"] == "" {
+		t.Fatal("parse errors must leave mappings untouched")
+	}
+
+	// 应用失败后内容未记录,下次仍会重试
+	src.set(`{"/a":"https://a.example.com"}`, "")
+	applier.err = errors.New("validation failed")
+	if _, err := imp.Sync(ctx); err == nil {
+		t.Fatal("expected apply error")
+	}
+	applier.err = nil
+	if _, err := imp.Sync(ctx); err != nil || applier.mappings["/a"] == "" {
+		t.Fatalf("expected retry after failed apply, got %v", err)
+	}
+
+	if _, err := New("http://127.0.0.1:1/mappings.json", applier, false).Sync(ctx); err == nil {
+		t.Fatal("expected fetch error")
+	}
+}
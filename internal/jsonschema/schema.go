@@ -0,0 +1,354 @@
+// Package jsonschema 实现 JSON Schema 的常用子集,用于校验请求体
+//
+// 支持的关键字: type、enum、const、properties、required、additionalProperties、
+// items、minItems、maxItems、minLength、maxLength、pattern、minimum、maximum、
+// exclusiveMinimum、exclusiveMaximum。未识别的关键字(如 $schema、title、description)被忽略。
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxErrors 单次校验最多返回的错误数
+const maxErrors = 20
+
+// Schema 编译后的 schema(并发安全,可复用)
+type Schema struct {
+	types            []string
+	enum             []any
+	constValue       *any
+	properties       map[string]*Schema
+	required         []string
+	additional       *Schema // additionalProperties 为 schema 时
+	noAdditional     bool    // additionalProperties: false
+	items            *Schema
+	minItems         *int
+	maxItems         *int
+	minLength        *int
+	maxLength        *int
+	pattern          *regexp.Regexp
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	acceptNone       bool // schema 为 false
+}
+
+// ValidationError 单个校验失败(Path 为 JSON Pointer,根为空字符串)
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// validTypes JSON Schema 的基本类型
+var validTypes = []string{"null", "boolean", "object", "array", "number", "integer", "string"}
+
+// Compile 解析 schema 文档
+func Compile(data []byte) (*Schema, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid schema JSON: %w", err)
+	}
+	return compile(doc, "")
+}
+
+func compile(doc any, path string) (*Schema, error) {
+	switch v := doc.(type) {
+	case bool:
+		return &Schema{acceptNone: !v}, nil
+	case map[string]any:
+		return compileObject(v, path)
+	default:
+		return nil, fmt.Errorf("schema%s must be an object or boolean", at(path))
+	}
+}
+
+func compileObject(doc map[string]any, path string) (*Schema, error) {
+	s := &Schema{}
+	var err error
+
+	switch t := doc["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("schema%s: type must be a string or array of strings", at(path))
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("schema%s: type must be a string or array of strings", at(path))
+	}
+	for _, name := range s.types {
+		if !slices.Contains(validTypes, name) {
+			return nil, fmt.Errorf("schema%s: unknown type %q", at(path), name)
+		}
+	}
+
+	if raw, ok := doc["enum"]; ok {
+		values, ok := raw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("schema%s: enum must be an array", at(path))
+		}
+		s.enum = values
+	}
+	if raw, ok := doc["const"]; ok {
+		s.constValue = &raw
+	}
+
+	if raw, ok := doc["properties"]; ok {
+		props, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("schema%s: properties must be an object", at(path))
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, sub := range props {
+			if s.properties[name], err = compile(sub, path+"/properties/"+escape(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if raw, ok := doc["required"]; ok {
+		names, ok := raw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("schema%s: required must be an array", at(path))
+		}
+		for _, item := range names {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("schema%s: required must contain strings", at(path))
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	switch raw := doc["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		s.noAdditional = !raw
+	default:
+		if s.additional, err = compile(raw, path+"/additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if raw, ok := doc["items"]; ok {
+		if s.items, err = compile(raw, path+"/items"); err != nil {
+			return nil, err
+		}
+	}
+
+	for keyword, dst := range map[string]**int{
+		"minItems": &s.minItems, "maxItems": &s.maxItems,
+		"minLength": &s.minLength, "maxLength": &s.maxLength,
+	} {
+		if *dst, err = intKeyword(doc, keyword, path); err != nil {
+			return nil, err
+		}
+	}
+	for keyword, dst := range map[string]**float64{
+		"minimum": &s.minimum, "maximum": &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMinimum, "exclusiveMaximum": &s.exclusiveMaximum,
+	} {
+		if *dst, err = numberKeyword(doc, keyword, path); err != nil {
+			return nil, err
+		}
+	}
+	if raw, ok := doc["pattern"]; ok {
+		expr, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("schema%s: pattern must be a string", at(path))
+		}
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("schema%s: invalid pattern: %w", at(path), err)
+		}
+	}
+	return s, nil
+}
+
+func intKeyword(doc map[string]any, keyword, path string) (*int, error) {
+	raw, ok := doc[keyword]
+	if !ok {
+		return nil, nil
+	}
+	n, ok := raw.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("schema%s: %s must be a non-negative integer", at(path), keyword)
+	}
+	v := int(n)
+	return &v, nil
+}
+
+func numberKeyword(doc map[string]any, keyword, path string) (*float64, error) {
+	raw, ok := doc[keyword]
+	if !ok {
+		return nil, nil
+	}
+	n, ok := raw.(float64)
+	if !ok {
+		return nil, fmt.Errorf("schema%s: %s must be a number", at(path), keyword)
+	}
+	return &n, nil
+}
+
+// ValidateJSON 解析并校验 JSON 文档,返回全部(最多 maxErrors 个)校验错误
+// 文档不是合法JSON时返回 error
+func (s *Schema) ValidateJSON(data []byte) ([]ValidationError, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+	var errs []ValidationError
+	s.validate(value, "", &errs)
+	return errs, nil
+}
+
+func (s *Schema) validate(value any, path string, errs *[]ValidationError) {
+	fail := func(format string, args ...any) {
+		if len(*errs) < maxErrors {
+			*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	if s.acceptNone {
+		fail("value is not allowed")
+		return
+	}
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(value, t) }) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), typeOf(value))
+		return
+	}
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(v any) bool { return reflect.DeepEqual(v, value) }) {
+		fail("value is not one of the allowed values")
+	}
+	if s.constValue != nil && !reflect.DeepEqual(*s.constValue, value) {
+		fail("value does not match the expected constant")
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names) // 错误顺序稳定
+		for _, name := range names {
+			child := path + "/" + escape(name)
+			if sub, ok := s.properties[name]; ok {
+				sub.validate(v[name], child, errs)
+			} else if s.noAdditional {
+				fail("unexpected property %q", name)
+			} else if s.additional != nil {
+				s.additional.validate(v[name], child, errs)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("expected at least %d items, got %d", *s.minItems, len(v))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("expected at most %d items, got %d", *s.maxItems, len(v))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, path+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			fail("expected at least %d characters, got %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("expected at most %d characters, got %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("does not match pattern %q", s.pattern.String())
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("must be <= %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+			fail("must be > %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+			fail("must be < %v", *s.exclusiveMaximum)
+		}
+	}
+}
+
+func hasType(value any, t string) bool {
+	switch t {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return typeOf(value) == t
+	}
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	default:
+		return "unknown"
+	}
+}
+
+// escape 按 JSON Pointer 规则转义属性名
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func at(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " at " + path
+}
@@ -0,0 +1,93 @@
+package jsonschema
+
+import (
+	"testing"
+)
+
+const userSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 10},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"nickname": {"type": ["string", "null"]}
+	}
+}`
+
+func TestSchema_ValidateJSON(t *testing.T) {
+	schema, err := Compile([]byte(userSchema))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		doc  string
+		want []ValidationError
+	}{
+		{"valid", `{"name":"amy","age":30,"role":"admin","tags":["a"],"nickname":null}`, nil},
+		{"missingRequired", `{"name":"amy"}`, []ValidationError{{"", `missing required property "age"`}}},
+		{"wrongType", `{"name":"amy","age":"30"}`, []ValidationError{{"/age", "expected integer, got string"}}},
+		{"notInteger", `{"name":"amy","age":1.5}`, []ValidationError{{"/age", "expected integer, got number"}}},
+		{"range", `{"name":"amy","age":150}`, []ValidationError{{"/age", "must be < 150"}}},
+		{"additional", `{"name":"amy","age":1,"admin":true}`, []ValidationError{{"", `unexpected property "admin"`}}},
+		{"stringRules", `{"name":"","age":1,"email":"nope"}`, []ValidationError{
+			{"/email", `does not match pattern "^[^@]+@[^@]+$"`},
+			{"/name", "expected at least 1 characters, got 0"},
+		}},
+		{"enum", `{"name":"amy","age":1,"role":"root"}`, []ValidationError{{"/role", "value is not one of the allowed values"}}},
+		{"arrayItems", `{"name":"amy","age":1,"tags":["a",2,"c"]}`, []ValidationError{
+			{"/tags", "expected at most 2 items, got 3"},
+			{"/tags/1", "expected string, got number"},
+		}},
+		{"rootType", `[1]`, []ValidationError{{"", "expected object, got array"}}},
+	}
+	for _, tt := range tests {
+		got, err := schema.ValidateJSON([]byte(tt.doc))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: error %d = %v, want %v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+
+	for _, doc := range []string{`{"name":`, `{"name":"a","age":1} {}`, ``} {
+		if _, err := schema.ValidateJSON([]byte(doc)); err == nil {
+			t.Errorf("expected invalid JSON error for %q", doc)
+		}
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, doc := range []string{
+		`not json`,
+		`"string"`,
+		`{"type":"text"}`,
+		`{"pattern":"("}`,
+		`{"minLength":-1}`,
+		`{"properties":{"a":{"type":1}}}`,
+		`{"required":"a"}`,
+	} {
+		if _, err := Compile([]byte(doc)); err == nil {
+			t.Errorf("expected compile error for %s", doc)
+		}
+	}
+	if s, err := Compile([]byte(`false`)); err != nil {
+		t.Fatal(err)
+	} else if errs, _ := s.ValidateJSON([]byte(`{}`)); len(errs) != 1 {
+		t.Errorf("false schema should reject everything, got %v", errs)
+	}
+}
@@ -0,0 +1,83 @@
+package mapping
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+)
+
+// MaskedSecret 接口输出中替代敏感内容的占位符
+// 更新配置时原样提交该值表示保留已存储的内容
+const MaskedSecret = "******"
+
+// ClientCert 上游 mTLS 客户端证书(client_cert)
+// 证书与私钥可引用本地文件,也可以 PEM 文本直接存储在Redis中(二选一)
+type ClientCert struct {
+	CertFile string `json:"cert_file,omitempty"` // 证书文件路径(PEM)
+	KeyFile  string `json:"key_file,omitempty"`  // 私钥文件路径(PEM)
+	CertPEM  string `json:"cert_pem,omitempty"`  // 证书内容(PEM)
+	KeyPEM   string `json:"key_pem,omitempty"`   // 私钥内容(PEM),接口输出时脱敏
+}
+
+// inline 判断证书是否以 PEM 文本存储
+func (c ClientCert) inline() bool {
+	return c.CertPEM != "" || c.KeyPEM != ""
+}
+
+// Validate 校验证书配置并确认证书与私钥可以加载
+// 私钥为脱敏占位符时仅校验结构,由存储层替换为已保存的私钥后再加载
+func (c ClientCert) Validate() error {
+	files := c.CertFile != "" || c.KeyFile != ""
+	switch {
+	case files && c.inline():
+		return fmt.Errorf("client_cert: use either cert_file/key_file or cert_pem/key_pem, not both")
+	case files && (c.CertFile == "" || c.KeyFile == ""):
+		return fmt.Errorf("client_cert: cert_file and key_file must be set together")
+	case c.inline() && (c.CertPEM == "" || c.KeyPEM == ""):
+		return fmt.Errorf("client_cert: cert_pem and key_pem must be set together")
+	case !files && !c.inline():
+		return fmt.Errorf("client_cert: certificate and key are required")
+	}
+	if c.KeyPEM == MaskedSecret {
+		return nil
+	}
+	if _, err := c.Load(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Load 加载证书与私钥
+func (c ClientCert) Load() (tls.Certificate, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+	if c.inline() {
+		cert, err = tls.X509KeyPair([]byte(c.CertPEM), []byte(c.KeyPEM))
+	} else {
+		cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	}
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("client_cert: load key pair: %w", err)
+	}
+	return cert, nil
+}
+
+// Identity 返回证书配置的标识(用于隔离连接池,不包含私钥明文)
+func (c ClientCert) Identity() string {
+	if !c.inline() {
+		return "file:" + c.CertFile + ":" + c.KeyFile
+	}
+	sum := sha256.Sum256([]byte(c.CertPEM + "\x00" + c.KeyPEM))
+	return "pem:" + hex.EncodeToString(sum[:8])
+}
+
+// Redacted 返回私钥内容脱敏后的副本
+func (c ClientCert) Redacted() *ClientCert {
+	if c.KeyPEM != "" {
+		c.KeyPEM = MaskedSecret
+	}
+	return &c
+}
@@ -0,0 +1,102 @@
+package mapping
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selfSignedPEM 生成测试用的自签名证书与私钥
+func selfSignedPEM(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM
+}
+
+func TestClientCert_Load(t *testing.T) {
+	certPEM, keyPEM := selfSignedPEM(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, []byte(certPEM), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, []byte(keyPEM), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cc := range map[string]ClientCert{
+		"pem":  {CertPEM: certPEM, KeyPEM: keyPEM},
+		"file": {CertFile: certFile, KeyFile: keyFile},
+	} {
+		if err := cc.Validate(); err != nil {
+			t.Errorf("%s: Validate() error = %v", name, err)
+		}
+		cert, err := cc.Load()
+		if err != nil {
+			t.Fatalf("%s: Load() error = %v", name, err)
+		}
+		if len(cert.Certificate) != 1 {
+			t.Errorf("%s: expected 1 certificate, got %d", name, len(cert.Certificate))
+		}
+	}
+
+	otherCert, _ := selfSignedPEM(t)
+	mismatched := ClientCert{CertPEM: otherCert, KeyPEM: keyPEM}
+	if err := mismatched.Validate(); err == nil {
+		t.Error("expected mismatched key pair to fail validation")
+	}
+}
+
+func TestClientCert_IdentityAndRedacted(t *testing.T) {
+	certPEM, keyPEM := selfSignedPEM(t)
+	cc := ClientCert{CertPEM: certPEM, KeyPEM: keyPEM}
+
+	id := cc.Identity()
+	if !strings.HasPrefix(id, "pem:") || strings.Contains(id, "PRIVATE") {
+		t.Errorf("unexpected identity %q", id)
+	}
+	rotated := ClientCert{CertPEM: certPEM, KeyPEM: keyPEM + "\n"}
+	if rotated.Identity() == id {
+		t.Error("expected identity to change with key content")
+	}
+	if got := (ClientCert{CertFile: "a.crt", KeyFile: "a.key"}).Identity(); got != "file:a.crt:a.key" {
+		t.Errorf("file identity = %q", got)
+	}
+
+	redacted := Options{ClientCert: &cc}.Redacted()
+	if redacted.ClientCert.KeyPEM != MaskedSecret || redacted.ClientCert.CertPEM != certPEM {
+		t.Errorf("unexpected redacted cert: %+v", redacted.ClientCert)
+	}
+	if cc.KeyPEM != keyPEM {
+		t.Error("Redacted must not modify the original")
+	}
+}
@@ -0,0 +1,47 @@
+package mapping
+
+// Diff 本地缓存与Redis中映射的差异(用于排查多实例同步问题)
+type Diff struct {
+	Added   map[string]string `json:"added"`   // Redis中存在但本地缓存缺失
+	Removed map[string]string `json:"removed"` // 本地缓存存在但Redis中已删除
+	Changed map[string]Change `json:"changed"` // 两边目标不一致
+
+	LocalVersion  int64 `json:"local_version"`
+	RemoteVersion int64 `json:"remote_version"`
+	VersionSkew   int64 `json:"version_skew"` // RemoteVersion - LocalVersion
+}
+
+// Change 同一前缀在两边的目标
+type Change struct {
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+// InSync 本地缓存是否与Redis一致
+func (d Diff) InSync() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && d.VersionSkew == 0
+}
+
+// Compare 比较本地与远端映射
+func Compare(local, remote map[string]string) Diff {
+	diff := Diff{
+		Added:   make(map[string]string),
+		Removed: make(map[string]string),
+		Changed: make(map[string]Change),
+	}
+	for prefix, target := range remote {
+		current, ok := local[prefix]
+		switch {
+		case !ok:
+			diff.Added[prefix] = target
+		case current != target:
+			diff.Changed[prefix] = Change{Local: current, Remote: target}
+		}
+	}
+	for prefix, target := range local {
+		if _, ok := remote[prefix]; !ok {
+			diff.Removed[prefix] = target
+		}
+	}
+	return diff
+}
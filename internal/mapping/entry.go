@@ -0,0 +1,70 @@
+package mapping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Entry 批量导入/导出的映射条目
+type Entry struct {
+	Prefix  string  `json:"prefix"`
+	Target  string  `json:"target"`
+	Options Options `json:"options,omitzero"`
+}
+
+// ApplyResult 批量应用映射的结果统计
+type ApplyResult struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Changed 是否有映射发生变化
+func (r ApplyResult) Changed() bool {
+	return r.Added+r.Updated+r.Removed > 0
+}
+
+// EntryErrors 批量应用映射时条目未通过校验的全部问题(可用 errors.As 与存储故障区分)
+type EntryErrors []error
+
+func (e EntryErrors) Error() string {
+	return errors.Join(e...).Error()
+}
+
+// Unwrap 支持 errors.Is/As 匹配任一问题
+func (e EntryErrors) Unwrap() []error {
+	return e
+}
+
+// ParseEntries 解析映射列表,支持两种格式:
+//   - 条目数组: [{"prefix":"/api","target":"https://...","options":{...}}]
+//   - 前缀到目标的对象: {"/api":"https://..."}
+func ParseEntries(data []byte) ([]Entry, error) {
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err == nil {
+		return entries, checkDuplicates(entries)
+	}
+
+	var targets map[string]string
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, errors.New("mappings must be a JSON array of {prefix,target} or an object of prefix -> target")
+	}
+	entries = make([]Entry, 0, len(targets))
+	for prefix, target := range targets {
+		entries = append(entries, Entry{Prefix: prefix, Target: target})
+	}
+	return entries, nil
+}
+
+func checkDuplicates(entries []Entry) error {
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if seen[e.Prefix] {
+			return fmt.Errorf("duplicate prefix %q", e.Prefix)
+		}
+		seen[e.Prefix] = true
+	}
+	return nil
+}
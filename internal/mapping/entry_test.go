@@ -0,0 +1,22 @@
+package mapping
+
+import "testing"
+
+func TestParseEntries(t *testing.T) {
+	entries, err := ParseEntries([]byte(`[{"prefix":"/a","target":"https://a.example.com","options":{"verify_gzip":true}}]`))
+	if err != nil || len(entries) != 1 || entries[0].Prefix != "/a" || !entries[0].Options.VerifyGzip {
+		t.Fatalf("unexpected array parse result: %+v, %v", entries, err)
+	}
+
+	entries, err = ParseEntries([]byte(`{"/b":"https://b.example.com"}`))
+	if err != nil || len(entries) != 1 || entries[0].Target != "https://b.example.com" {
+		t.Fatalf("unexpected object parse result: %+v, %v", entries, err)
+	}
+
+	if _, err := ParseEntries([]byte(`[{"prefix":"/a","target":"x"},{"prefix":"/a","target":"y"}]`)); err == nil {
+		t.Error("expected duplicate prefix error")
+	}
+	if _, err := ParseEntries([]byte(`"nope"`)); err == nil {
+		t.Error("expected format error")
+	}
+}
@@ -0,0 +1,51 @@
+package mapping
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TargetEnvPrefix 目标中可引用的环境变量名前缀,其他变量(如 ADMIN_TOKEN、REDIS_PASSWORD)不可引用
+const TargetEnvPrefix = "APIPROXY_TARGET_"
+
+// envVarRe 目标中的环境变量引用 ${NAME}
+var envVarRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// InterpolateEnv 将目标中的 ${NAME} 替换为 lookup 返回的环境变量值
+// 变量名须以 TargetEnvPrefix 开头,引用其他变量或变量未设置时返回错误
+// 正则映射目标中与捕获组同名的 ${name} 保留,由 ExpandTarget 在请求时代入
+func InterpolateEnv(prefix, target string, lookup func(string) (string, bool)) (string, error) {
+	if !envVarRe.MatchString(target) {
+		return target, nil
+	}
+	groups := make(map[string]bool)
+	if IsPattern(prefix) {
+		if re, err := CompilePattern(prefix); err == nil {
+			for _, name := range re.SubexpNames() {
+				groups[name] = name != ""
+			}
+		}
+	}
+
+	var problem error
+	result := envVarRe.ReplaceAllStringFunc(target, func(ref string) string {
+		name := envVarRe.FindStringSubmatch(ref)[1]
+		if groups[name] || problem != nil {
+			return ref
+		}
+		if !strings.HasPrefix(name, TargetEnvPrefix) {
+			problem = fmt.Errorf("target references environment variable %s, only %s* variables are allowed", name, TargetEnvPrefix)
+			return ref
+		}
+		value, ok := lookup(name)
+		if !ok {
+			problem = fmt.Errorf("target references unset environment variable %s", name)
+		}
+		return value
+	})
+	if problem != nil {
+		return "", problem
+	}
+	return result, nil
+}
@@ -0,0 +1,35 @@
+package mapping
+
+import "testing"
+
+func TestInterpolateEnv(t *testing.T) {
+	env := map[string]string{"APIPROXY_TARGET_REGION": "eu-west-1", "APIPROXY_TARGET_EMPTY": "", "ADMIN_TOKEN": "secret"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		prefix, target, want string
+		wantErr              bool
+	}{
+		{"/api", "https://${APIPROXY_TARGET_REGION}.api.example.com/v1", "https://eu-west-1.api.example.com/v1", false},
+		{"/api", "https://api.example.com", "https://api.example.com", false},
+		{"/api", "https://${APIPROXY_TARGET_EMPTY}.example.com", "https://.example.com", false},
+		{"/api", "https://${APIPROXY_TARGET_MISSING}.example.com", "", true},
+		// 前缀之外的变量(即使已设置)不可引用,避免泄露密钥
+		{"/api", "https://api.example.com/?t=${ADMIN_TOKEN}", "", true},
+		// 正则映射的同名捕获组保留到请求时代入,$1 等不是环境变量引用
+		{"~^/t/(?P<tenant>\\w+)", "https://${APIPROXY_TARGET_REGION}.example.com/${tenant}/$1", "https://eu-west-1.example.com/${tenant}/$1", false},
+	}
+	for _, tt := range tests {
+		got, err := InterpolateEnv(tt.prefix, tt.target, lookup)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("InterpolateEnv(%q, %q) = %q, %v; want %q, error=%v", tt.prefix, tt.target, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if _, err := InterpolateEnv("/api", "https://${APIPROXY_TARGET_MISSING}.example.com", lookup); err == nil || err.Error() != "target references unset environment variable APIPROXY_TARGET_MISSING" {
+		t.Errorf("expected clear error naming the variable, got %v", err)
+	}
+}
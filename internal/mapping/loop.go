@@ -0,0 +1,77 @@
+package mapping
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// SelfAddresses 代理自身对外公布的地址,用于检测指向代理自身的回环映射
+// 条目形如 "proxy.example.com:8000" 或 "proxy.example.com"(不带端口时匹配任意端口)
+type SelfAddresses struct {
+	entries map[string]bool
+}
+
+// NewSelfAddresses 创建自身地址集合,忽略空项
+func NewSelfAddresses(addrs []string) *SelfAddresses {
+	s := &SelfAddresses{entries: make(map[string]bool, len(addrs))}
+	for _, addr := range addrs {
+		if addr = strings.ToLower(strings.TrimSpace(addr)); addr != "" {
+			s.entries[addr] = true
+		}
+	}
+	return s
+}
+
+// Len 返回地址条目数
+func (s *SelfAddresses) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.entries)
+}
+
+// Matches 判断目标URL是否指向代理自身(nil集合不匹配任何目标)
+func (s *SelfAddresses) Matches(target string) bool {
+	if s.Len() == 0 {
+		return false
+	}
+	host, port, ok := targetHostPort(target)
+	if !ok {
+		return false
+	}
+	return s.entries[host] || s.entries[net.JoinHostPort(host, port)]
+}
+
+// IsSelfHost 判断目标URL是否指向客户端访问代理时使用的 Host
+// Host 带端口时需端口一致,否则仅比较主机名
+func IsSelfHost(target, requestHost string) bool {
+	if requestHost == "" {
+		return false
+	}
+	host, port, ok := targetHostPort(target)
+	if !ok {
+		return false
+	}
+	requestHost = strings.ToLower(requestHost)
+	if h, p, err := net.SplitHostPort(requestHost); err == nil {
+		return h == host && p == port
+	}
+	return strings.Trim(requestHost, "[]") == host
+}
+
+// targetHostPort 解析目标URL的主机名和端口(未显式指定时取协议默认端口)
+func targetHostPort(target string) (host, port string, ok bool) {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	host, port = strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return host, port, true
+}
@@ -0,0 +1,48 @@
+package mapping
+
+import "testing"
+
+func TestSelfAddresses_Matches(t *testing.T) {
+	self := NewSelfAddresses([]string{"Proxy.Example.com:8000", "edge.example.com", "[2001:db8::1]:443", " "})
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"http://proxy.example.com:8000/api", true},
+		{"http://PROXY.example.com:8000", true},
+		{"http://proxy.example.com/api", false}, // 默认端口80
+		{"https://edge.example.com/v1", true},   // 不带端口的条目匹配任意端口
+		{"http://edge.example.com:9000", true},
+		{"https://[2001:db8::1]/x", true},
+		{"https://api.example.com", false},
+		{"::bad", false},
+	}
+	for _, tt := range tests {
+		if got := self.Matches(tt.target); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	var none *SelfAddresses
+	if none.Matches("http://proxy.example.com:8000") {
+		t.Error("nil self addresses should match nothing")
+	}
+}
+
+func TestIsSelfHost(t *testing.T) {
+	tests := []struct {
+		target, host string
+		want         bool
+	}{
+		{"https://proxy.example.com/api", "proxy.example.com", true},
+		{"http://proxy.example.com:8000/api", "proxy.example.com:8000", true},
+		{"http://proxy.example.com:9000/api", "proxy.example.com:8000", false},
+		{"https://api.example.com", "proxy.example.com", false},
+		{"https://proxy.example.com", "", false},
+	}
+	for _, tt := range tests {
+		if got := IsSelfHost(tt.target, tt.host); got != tt.want {
+			t.Errorf("IsSelfHost(%q, %q) = %v, want %v", tt.target, tt.host, got, tt.want)
+		}
+	}
+}
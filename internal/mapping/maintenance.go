@@ -0,0 +1,44 @@
+package mapping
+
+import (
+	"fmt"
+	"mime"
+)
+
+// MaintenanceGlobal 全局维护模式在存储中使用的键
+const MaintenanceGlobal = "*"
+
+// DefaultMaintenanceMessage 未配置维护页面时返回的内容
+const DefaultMaintenanceMessage = "Service is temporarily unavailable for maintenance"
+
+// Maintenance 维护模式配置(开启期间对应前缀返回 503,映射保持不变)
+type Maintenance struct {
+	Message     string `json:"message,omitempty"`      // 响应内容(如维护页面HTML)
+	ContentType string `json:"content_type,omitempty"` // 响应 Content-Type,默认 text/plain
+	RetryAfter  int    `json:"retry_after,omitempty"`  // Retry-After 秒数,0表示不设置
+}
+
+// Validate 校验维护模式配置
+func (m Maintenance) Validate() error {
+	if m.RetryAfter < 0 {
+		return fmt.Errorf("retry_after cannot be negative")
+	}
+	if m.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(m.ContentType); err != nil {
+			return fmt.Errorf("invalid content_type %q: %w", m.ContentType, err)
+		}
+	}
+	return nil
+}
+
+// Body 返回维护响应内容及其 Content-Type
+func (m Maintenance) Body() (contentType, body string) {
+	contentType, body = m.ContentType, m.Message
+	if body == "" {
+		body = DefaultMaintenanceMessage
+	}
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return contentType, body
+}
@@ -0,0 +1,34 @@
+package mapping
+
+import "testing"
+
+func TestMaintenance_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		maint   Maintenance
+		wantErr bool
+	}{
+		{"zero", Maintenance{}, false},
+		{"full", Maintenance{Message: "<h1>down</h1>", ContentType: "text/html; charset=utf-8", RetryAfter: 60}, false},
+		{"negative retry", Maintenance{RetryAfter: -1}, true},
+		{"bad content type", Maintenance{ContentType: "text/html; ;"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.maint.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaintenance_Body(t *testing.T) {
+	contentType, body := Maintenance{}.Body()
+	if contentType != "text/plain; charset=utf-8" || body != DefaultMaintenanceMessage {
+		t.Errorf("unexpected defaults: %q %q", contentType, body)
+	}
+	contentType, body = Maintenance{Message: "x", ContentType: "text/html"}.Body()
+	if contentType != "text/html" || body != "x" {
+		t.Errorf("unexpected body: %q %q", contentType, body)
+	}
+}
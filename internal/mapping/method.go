@@ -0,0 +1,33 @@
+package mapping
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// validateMethodRewrite 校验请求方法改写(如 POST->PUT): 方法须为 token 且不涉及 CONNECT
+func validateMethodRewrite(rewrite map[string]string) error {
+	for from, to := range rewrite {
+		for _, method := range []string{from, to} {
+			if method == "" || strings.IndexFunc(method, func(r rune) bool { return !isTokenRune(r) }) >= 0 {
+				return fmt.Errorf("method_rewrite: invalid method %q", method)
+			}
+			if method == http.MethodConnect {
+				return fmt.Errorf("method_rewrite: %s cannot be rewritten", http.MethodConnect)
+			}
+		}
+		if from == to {
+			return fmt.Errorf("method_rewrite: %s is rewritten to itself", from)
+		}
+	}
+	return nil
+}
+
+// UpstreamMethod 返回转发到上游使用的请求方法(未配置改写时保持原方法)
+func (o Options) UpstreamMethod(method string) string {
+	if to, ok := o.MethodRewrite[method]; ok {
+		return to
+	}
+	return method
+}
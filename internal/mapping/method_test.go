@@ -0,0 +1,17 @@
+package mapping
+
+import "testing"
+
+func TestOptions_UpstreamMethod(t *testing.T) {
+	opts := Options{MethodRewrite: map[string]string{"POST": "PUT"}}
+	if got := opts.UpstreamMethod("POST"); got != "PUT" {
+		t.Errorf("expected PUT, got %s", got)
+	}
+	// 方法区分大小写
+	if got := opts.UpstreamMethod("post"); got != "post" {
+		t.Errorf("expected post unchanged, got %s", got)
+	}
+	if got := (Options{}).UpstreamMethod("GET"); got != "GET" {
+		t.Errorf("expected GET unchanged, got %s", got)
+	}
+}
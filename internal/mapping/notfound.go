@@ -0,0 +1,61 @@
+package mapping
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 上游返回404时的处理方式
+const (
+	NotFoundPassthrough = "passthrough" // 原样返回上游响应(默认)
+	NotFoundFallback    = "fallback"    // 转发到备用目标
+	NotFoundMessage     = "message"     // 返回自定义内容
+)
+
+// NotFoundAction 上游返回404时的处理配置(on_upstream_404)
+type NotFoundAction struct {
+	Action      string `json:"action"`                 // passthrough / fallback / message
+	Target      string `json:"target,omitempty"`       // fallback: 备用目标URL,剩余路径与查询参数原样拼接
+	Message     string `json:"message,omitempty"`      // message: 响应内容
+	ContentType string `json:"content_type,omitempty"` // message: 响应 Content-Type,默认 text/plain
+}
+
+// Validate 校验404处理配置
+func (a NotFoundAction) Validate() error {
+	switch a.Action {
+	case NotFoundPassthrough, NotFoundMessage:
+	case NotFoundFallback:
+		u, err := url.Parse(a.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("on_upstream_404.target must be an http(s) URL, got %q", a.Target)
+		}
+	default:
+		return fmt.Errorf("invalid on_upstream_404.action %q: expected %s, %s or %s",
+			a.Action, NotFoundPassthrough, NotFoundFallback, NotFoundMessage)
+	}
+	if a.ContentType != "" {
+		if err := validateContentType(a.ContentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FallbackURL 返回备用目标的完整URL
+func (a NotFoundAction) FallbackURL(rest, rawQuery string) string {
+	target := strings.TrimSuffix(a.Target, "/") + rest
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+	return target
+}
+
+// Body 返回自定义404响应内容及其 Content-Type
+func (a NotFoundAction) Body() (contentType, body string) {
+	contentType = a.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return contentType, a.Message
+}
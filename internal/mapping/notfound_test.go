@@ -0,0 +1,19 @@
+package mapping
+
+import "testing"
+
+func TestNotFoundAction_FallbackURL(t *testing.T) {
+	action := NotFoundAction{Action: NotFoundFallback, Target: "https://backup.example.com/v1/"}
+	tests := []struct {
+		rest, query, want string
+	}{
+		{"/items/1", "", "https://backup.example.com/v1/items/1"},
+		{"/items", "page=2", "https://backup.example.com/v1/items?page=2"},
+		{"", "", "https://backup.example.com/v1"},
+	}
+	for _, tt := range tests {
+		if got := action.FallbackURL(tt.rest, tt.query); got != tt.want {
+			t.Errorf("FallbackURL(%q, %q) = %q, want %q", tt.rest, tt.query, got, tt.want)
+		}
+	}
+}
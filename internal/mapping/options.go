@@ -0,0 +1,324 @@
+// Package mapping 定义API映射的扩展配置(与存储、代理实现解耦)
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"strings"
+	"time"
+
+	"api-proxy/internal/jsonschema"
+)
+
+// Options 映射的可选扩展配置
+// 零值表示完全透明转发,所有字段均为按需开启
+type Options struct {
+	// ContentType 强制覆盖上游响应的 Content-Type
+	ContentType string `json:"content_type,omitempty"`
+
+	// ContentTypeMap 将上游返回的 Content-Type 映射为修正值
+	// 键可以是完整值(如 "application/octet-stream; charset=utf-8")或仅媒体类型
+	ContentTypeMap map[string]string `json:"content_type_map,omitempty"`
+
+	// IdempotencyTTL 幂等键去重窗口(秒),0表示不启用
+	// 窗口内携带相同 Idempotency-Key 的请求直接返回首次响应
+	IdempotencyTTL int `json:"idempotency_ttl,omitempty"`
+
+	// VerifyGzip 流式校验 gzip 响应完整性(截断/损坏时记录指标,不影响转发)
+	VerifyGzip bool `json:"verify_gzip,omitempty"`
+
+	// SLO 延迟目标(如 95% 的请求在 2s 内完成),用于统计达标率
+	SLO *SLO `json:"slo,omitempty"`
+
+	// DialAddress 覆盖上游拨号地址(host:port),Host 头和 TLS SNI 仍使用目标URL的主机名
+	// 用于绕过DNS直连CDN的指定节点进行测试
+	DialAddress string `json:"dial_address,omitempty"`
+
+	// DisableKeepAlive 对该目标禁用连接复用(使用独立连接池,每个请求新建连接)
+	DisableKeepAlive bool `json:"disable_keep_alive,omitempty"`
+
+	// RetryOnStatus 触发重试的上游状态码(如 [502, 503]),未设置(nil)时使用全局默认,
+	// 显式设置为 [] 表示该映射不按状态码重试;仅对无请求体的幂等请求生效,重试次数取全局配置
+	RetryOnStatus []int `json:"retry_on_status,omitzero"`
+
+	// TimeoutMs 上游请求超时(毫秒,含响应体传输),0表示使用默认的30秒保护性超时
+	// 客户端设置了更早的截止时间时以客户端为准
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+
+	// RequestHeaders 附加到上游请求的头部,覆盖客户端传入值和全局默认头部
+	RequestHeaders map[string]string `json:"request_headers,omitempty"`
+
+	// MethodRewrite 转发前改写请求方法(如 {"POST": "PUT"}),请求体和请求头保持不变
+	// 方法区分大小写;连接错误重试按改写后的方法判断是否安全
+	MethodRewrite map[string]string `json:"method_rewrite,omitempty"`
+
+	// StatusMap 将上游返回的状态码改写后再返回给客户端(如 {"418": 503}),响应头和响应体保持不变
+	// 熔断、404处理等仍按上游原始状态码判断,统计与导出记录改写后的状态码
+	StatusMap map[int]int `json:"status_map,omitempty"`
+
+	// DailyQuota 每个API Key每天(UTC)的请求配额,0表示不限制
+	// 超出后返回 429,未携带API Key的请求不计入配额
+	DailyQuota int64 `json:"daily_quota,omitempty"`
+
+	// GRPCWeb 将 gRPC-Web(二进制模式)请求转换为 gRPC 转发,响应 trailers 编码为 gRPC-Web 帧
+	// 上游使用 HTTP/2(http:// 目标使用 h2c)
+	GRPCWeb bool `json:"grpc_web,omitempty"`
+
+	// OnUpstream404 上游返回404时的处理: 原样返回、转发到备用目标(仅无请求体的请求)或返回自定义内容
+	OnUpstream404 *NotFoundAction `json:"on_upstream_404,omitempty"`
+
+	// ClientCert 连接上游时出示的 mTLS 客户端证书(使用独立连接池)
+	ClientCert *ClientCert `json:"client_cert,omitempty"`
+
+	// DisableStats 不记录该映射的请求统计(用于超高QPS端点,全局 ENABLE_STATS 仍为总开关)
+	DisableStats bool `json:"disable_stats,omitempty"`
+
+	// TokenRefresh 由代理获取并注入上游访问令牌,上游返回401时刷新令牌后重试一次(仅无请求体的请求)
+	TokenRefresh *TokenRefresh `json:"token_refresh,omitempty"`
+
+	// Upstreams 多目标映射: 设置后请求按 Strategy 在这些目标间分配,映射本身的目标不再使用
+	Upstreams []Upstream `json:"upstreams,omitempty"`
+
+	// Strategy 多目标选择策略: random(默认,按权重随机)、wrr(平滑加权轮询)、round_robin、consistent_hash
+	Strategy string `json:"strategy,omitempty"`
+
+	// HashKey 一致性哈希的键来源: path(默认,请求路径)或 header:<名称>
+	HashKey string `json:"hash_key,omitempty"`
+
+	// AuditLog 将该映射的请求元数据(不含请求/响应体)写入 Redis Stream,供外部消费者短期审计
+	AuditLog bool `json:"audit_log,omitempty"`
+
+	// RewriteCookies 改写上游 Set-Cookie: Domain 改为代理主机,Path 挂到映射前缀之下
+	// 用于代理完整站点时让 Cookie 作用于代理域名
+	RewriteCookies bool `json:"rewrite_cookies,omitempty"`
+
+	// ForwardPrefix 在上游请求头(默认 X-Proxy-Prefix)中携带匹配的映射前缀,覆盖客户端传入值
+	ForwardPrefix bool `json:"forward_prefix,omitempty"`
+
+	// RequestSchema 校验JSON请求体的 JSON Schema(常用关键字子集),不符合时返回 400 及校验详情
+	// 仅校验 Content-Type 为JSON的请求,其他请求保持流式转发
+	RequestSchema json.RawMessage `json:"request_schema,omitempty"`
+
+	// Plugin 转发前由 WASM 插件转换请求(路径、查询参数、请求头),插件在沙箱中限时运行
+	// 插件失败时拒绝请求(返回 502),不会以未转换的请求访问上游
+	Plugin *Plugin `json:"plugin,omitempty"`
+}
+
+// SLO 映射的延迟服务目标
+type SLO struct {
+	LatencyMs int     `json:"latency_ms"`       // 延迟阈值(毫秒)
+	Target    float64 `json:"target,omitempty"` // 目标达标率(0~1),仅用于展示是否达标
+}
+
+// Threshold 返回延迟阈值
+func (s SLO) Threshold() time.Duration {
+	return time.Duration(s.LatencyMs) * time.Millisecond
+}
+
+// IsZero 判断是否未配置任何扩展选项
+func (o Options) IsZero() bool {
+	return o.ContentType == "" && len(o.ContentTypeMap) == 0 &&
+		o.IdempotencyTTL == 0 && !o.VerifyGzip && o.SLO == nil &&
+		o.DialAddress == "" && !o.DisableKeepAlive && o.RetryOnStatus == nil &&
+		o.TimeoutMs == 0 && len(o.RequestHeaders) == 0 && len(o.MethodRewrite) == 0 && len(o.StatusMap) == 0 && o.DailyQuota == 0 &&
+		!o.GRPCWeb && o.OnUpstream404 == nil && o.ClientCert == nil &&
+		!o.DisableStats && o.TokenRefresh == nil && len(o.Upstreams) == 0 &&
+		o.Strategy == "" && o.HashKey == "" && !o.AuditLog && !o.RewriteCookies && len(o.RequestSchema) == 0 &&
+		o.Plugin == nil && !o.ForwardPrefix
+}
+
+// Redacted 返回敏感字段脱敏后的副本(用于接口输出),请求头只保留名称
+func (o Options) Redacted() Options {
+	if len(o.RequestHeaders) > 0 {
+		headers := make(map[string]string, len(o.RequestHeaders))
+		for name := range o.RequestHeaders {
+			headers[name] = MaskedSecret
+		}
+		o.RequestHeaders = headers
+	}
+	if o.ClientCert != nil {
+		o.ClientCert = o.ClientCert.Redacted()
+	}
+	if o.TokenRefresh != nil {
+		o.TokenRefresh = o.TokenRefresh.Redacted()
+	}
+	return o
+}
+
+// IdempotencyWindow 返回幂等去重窗口,0表示不启用
+func (o Options) IdempotencyWindow() time.Duration {
+	return time.Duration(o.IdempotencyTTL) * time.Second
+}
+
+// RequestTimeout 返回映射的上游请求超时,0表示未配置
+func (o Options) RequestTimeout() time.Duration {
+	return time.Duration(o.TimeoutMs) * time.Millisecond
+}
+
+// Validate 校验扩展配置
+func (o Options) Validate() error {
+	if o.ContentType != "" {
+		if err := validateContentType(o.ContentType); err != nil {
+			return err
+		}
+	}
+	for from, to := range o.ContentTypeMap {
+		if strings.TrimSpace(from) == "" {
+			return fmt.Errorf("content_type_map key cannot be empty")
+		}
+		if err := validateContentType(to); err != nil {
+			return err
+		}
+	}
+	if o.IdempotencyTTL < 0 {
+		return fmt.Errorf("idempotency_ttl cannot be negative")
+	}
+	if o.TimeoutMs < 0 {
+		return fmt.Errorf("timeout_ms cannot be negative")
+	}
+	if o.DailyQuota < 0 {
+		return fmt.Errorf("daily_quota cannot be negative")
+	}
+	if o.DialAddress != "" {
+		if _, port, err := net.SplitHostPort(o.DialAddress); err != nil || port == "" {
+			return fmt.Errorf("invalid dial_address %q: expected host:port", o.DialAddress)
+		}
+	}
+	for _, code := range o.RetryOnStatus {
+		if err := ValidateRetryStatus(code); err != nil {
+			return err
+		}
+	}
+	for name, value := range o.RequestHeaders {
+		if err := ValidateRequestHeader(name, value); err != nil {
+			return err
+		}
+	}
+	if err := validateMethodRewrite(o.MethodRewrite); err != nil {
+		return err
+	}
+	if err := validateStatusMap(o.StatusMap); err != nil {
+		return err
+	}
+	if o.OnUpstream404 != nil {
+		if err := o.OnUpstream404.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.ClientCert != nil {
+		if err := o.ClientCert.Validate(); err != nil {
+			return err
+		}
+	}
+	if len(o.RequestSchema) > 0 {
+		if _, err := jsonschema.Compile(o.RequestSchema); err != nil {
+			return fmt.Errorf("request_schema: %w", err)
+		}
+	}
+	if o.Plugin != nil {
+		if err := o.Plugin.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateUpstreams(o.Upstreams, o.Strategy); err != nil {
+		return err
+	}
+	if err := validateHashKey(o.HashKey); err != nil {
+		return err
+	}
+	if o.TokenRefresh != nil {
+		if err := o.TokenRefresh.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.SLO != nil {
+		if o.SLO.LatencyMs <= 0 {
+			return fmt.Errorf("slo.latency_ms must be positive")
+		}
+		if o.SLO.Target < 0 || o.SLO.Target > 1 {
+			return fmt.Errorf("slo.target must be between 0 and 1")
+		}
+	}
+	return nil
+}
+
+// ResolveContentType 根据配置计算响应 Content-Type
+// 返回值为空表示保持上游原值
+func (o Options) ResolveContentType(upstream string) string {
+	if o.ContentType != "" {
+		return o.ContentType
+	}
+	if len(o.ContentTypeMap) == 0 {
+		return ""
+	}
+
+	if corrected, ok := o.ContentTypeMap[upstream]; ok {
+		return corrected
+	}
+
+	// 退化为按媒体类型匹配(忽略参数和大小写)
+	mediaType, _, err := mime.ParseMediaType(upstream)
+	if err != nil {
+		return ""
+	}
+	for from, to := range o.ContentTypeMap {
+		if strings.EqualFold(strings.TrimSpace(from), mediaType) {
+			return to
+		}
+	}
+	return ""
+}
+
+// ValidateRetryStatus 校验重试状态码(仅允许 4xx/5xx)
+func ValidateRetryStatus(code int) error {
+	if code < 400 || code > 599 {
+		return fmt.Errorf("invalid retry status %d: must be between 400 and 599", code)
+	}
+	return nil
+}
+
+func validateContentType(value string) error {
+	if _, _, err := mime.ParseMediaType(value); err != nil {
+		return fmt.Errorf("invalid content type %q: %w", value, err)
+	}
+	return nil
+}
+
+// reservedRequestHeaders 不允许通过配置设置的请求头(逐跳头部及由传输层控制的头部)
+var reservedRequestHeaders = map[string]bool{
+	"connection":          true,
+	"content-length":      true,
+	"host":                true,
+	"keep-alive":          true,
+	"proxy-authorization": true,
+	"proxy-connection":    true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+	"upgrade":             true,
+}
+
+// ValidateRequestHeader 校验配置的上游请求头
+func ValidateRequestHeader(name, value string) error {
+	if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isTokenRune(r) }) >= 0 {
+		return fmt.Errorf("invalid request header name %q", name)
+	}
+	if reservedRequestHeaders[strings.ToLower(name)] {
+		return fmt.Errorf("request header %q cannot be configured", name)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("invalid value for request header %q", name)
+	}
+	return nil
+}
+
+// isTokenRune 判断字符是否可用于头部名称(RFC 7230 token)
+func isTokenRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
@@ -0,0 +1,146 @@
+package mapping
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"zero", Options{}, false},
+		{"contentType", Options{ContentType: "application/json; charset=utf-8"}, false},
+		{"invalidContentType", Options{ContentType: "not a type"}, true},
+		{"map", Options{ContentTypeMap: map[string]string{"application/octet-stream": "application/json"}}, false},
+		{"mapEmptyKey", Options{ContentTypeMap: map[string]string{" ": "application/json"}}, true},
+		{"mapInvalidValue", Options{ContentTypeMap: map[string]string{"text/plain": "???"}}, true},
+		{"idempotency", Options{IdempotencyTTL: 60}, false},
+		{"negativeIdempotency", Options{IdempotencyTTL: -1}, true},
+		{"slo", Options{SLO: &SLO{LatencyMs: 2000, Target: 0.95}}, false},
+		{"sloZeroLatency", Options{SLO: &SLO{Target: 0.95}}, true},
+		{"dialAddress", Options{DialAddress: "203.0.113.10:443"}, false},
+		{"dialAddressNoPort", Options{DialAddress: "203.0.113.10"}, true},
+		{"retryOnStatus", Options{RetryOnStatus: []int{502, 503}}, false},
+		{"retryOnSuccessStatus", Options{RetryOnStatus: []int{200}}, true},
+		{"timeout", Options{TimeoutMs: 5000}, false},
+		{"negativeTimeout", Options{TimeoutMs: -1}, true},
+		{"dailyQuota", Options{DailyQuota: 1000}, false},
+		{"negativeDailyQuota", Options{DailyQuota: -1}, true},
+		{"grpcWeb", Options{GRPCWeb: true}, false},
+		{"notFoundFallback", Options{OnUpstream404: &NotFoundAction{Action: NotFoundFallback, Target: "https://backup.example.com"}}, false},
+		{"notFoundFallbackNoTarget", Options{OnUpstream404: &NotFoundAction{Action: NotFoundFallback}}, true},
+		{"notFoundMessage", Options{OnUpstream404: &NotFoundAction{Action: NotFoundMessage, Message: "gone", ContentType: "text/html"}}, false},
+		{"notFoundBadContentType", Options{OnUpstream404: &NotFoundAction{Action: NotFoundMessage, ContentType: "???"}}, true},
+		{"notFoundBadAction", Options{OnUpstream404: &NotFoundAction{Action: "redirect"}}, true},
+		{"disableStats", Options{DisableStats: true}, false},
+		{"auditLog", Options{AuditLog: true}, false},
+		{"rewriteCookies", Options{RewriteCookies: true}, false},
+		{"forwardPrefix", Options{ForwardPrefix: true}, false},
+		{"requestSchema", Options{RequestSchema: []byte(`{"type":"object","required":["id"]}`)}, false},
+		{"requestSchemaInvalid", Options{RequestSchema: []byte(`{"type":"text"}`)}, true},
+		{"pluginNoPath", Options{Plugin: &Plugin{}}, true},
+		{"pluginMissingFile", Options{Plugin: &Plugin{Path: "/nonexistent/plugin.wasm"}}, true},
+		{"upstreams", Options{Upstreams: []Upstream{{URL: "https://a.example.com", Weight: 3}, {URL: "https://b.example.com"}}, Strategy: StrategyWRR}, false},
+		{"upstreamsBadURL", Options{Upstreams: []Upstream{{URL: "a.example.com"}}}, true},
+		{"upstreamsNegativeWeight", Options{Upstreams: []Upstream{{URL: "https://a.example.com", Weight: -1}}}, true},
+		{"upstreamsMaxWeight", Options{Upstreams: []Upstream{{URL: "https://a.example.com", Weight: MaxUpstreamWeight}}}, false},
+		{"upstreamsWeightTooLarge", Options{Upstreams: []Upstream{{URL: "https://a.example.com", Weight: MaxUpstreamWeight + 1}}}, true},
+		{"badStrategy", Options{Strategy: "least_conn"}, true},
+		{"consistentHash", Options{Strategy: StrategyHash, HashKey: "header:X-User-ID"}, false},
+		{"hashKeyPath", Options{Strategy: StrategyHash, HashKey: HashKeyPath}, false},
+		{"hashKeyUnknown", Options{Strategy: StrategyHash, HashKey: "cookie:session"}, true},
+		{"hashKeyBadHeader", Options{Strategy: StrategyHash, HashKey: "header:Bad Header"}, true},
+		{"tokenRefresh", Options{TokenRefresh: &TokenRefresh{URL: "https://auth.example.com/token", ClientID: "id", ClientSecret: "secret"}}, false},
+		{"tokenRefreshNoURL", Options{TokenRefresh: &TokenRefresh{ClientID: "id"}}, true},
+		{"tokenRefreshBadHeader", Options{TokenRefresh: &TokenRefresh{URL: "https://auth.example.com/token", Header: "Bad Header"}}, true},
+		{"clientCertMasked", Options{ClientCert: &ClientCert{CertPEM: "cert", KeyPEM: MaskedSecret}}, false},
+		{"clientCertEmpty", Options{ClientCert: &ClientCert{}}, true},
+		{"clientCertMissingKey", Options{ClientCert: &ClientCert{CertFile: "client.crt"}}, true},
+		{"clientCertMixed", Options{ClientCert: &ClientCert{CertFile: "client.crt", KeyPEM: "key"}}, true},
+		{"clientCertMissingFile", Options{ClientCert: &ClientCert{CertFile: "/nonexistent/client.crt", KeyFile: "/nonexistent/client.key"}}, true},
+		{"methodRewrite", Options{MethodRewrite: map[string]string{"POST": "PUT", "GET": "PROPFIND"}}, false},
+		{"methodRewriteBadMethod", Options{MethodRewrite: map[string]string{"POST": "P UT"}}, true},
+		{"methodRewriteEmpty", Options{MethodRewrite: map[string]string{"": "PUT"}}, true},
+		{"methodRewriteConnect", Options{MethodRewrite: map[string]string{"POST": "CONNECT"}}, true},
+		{"methodRewriteSelf", Options{MethodRewrite: map[string]string{"PUT": "PUT"}}, true},
+		{"statusMap", Options{StatusMap: map[int]int{418: 503, 200: 502}}, false},
+		{"statusMapOutOfRange", Options{StatusMap: map[int]int{418: 600}}, true},
+		{"statusMapInformational", Options{StatusMap: map[int]int{101: 200}}, true},
+		{"statusMapNoBody", Options{StatusMap: map[int]int{500: 204}}, true},
+		{"statusMapSelf", Options{StatusMap: map[int]int{503: 503}}, true},
+		{"requestHeaders", Options{RequestHeaders: map[string]string{"X-Proxy-Source": "edge"}}, false},
+		{"requestHeaderBadName", Options{RequestHeaders: map[string]string{"X Bad": "v"}}, true},
+		{"requestHeaderReserved", Options{RequestHeaders: map[string]string{"Host": "evil.example.com"}}, true},
+		{"requestHeaderNewline", Options{RequestHeaders: map[string]string{"X-A": "v\r\nX-B: injected"}}, true},
+		{"sloBadTarget", Options{SLO: &SLO{LatencyMs: 100, Target: 95}}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: expected error=%v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestOptions_ResolveContentType(t *testing.T) {
+	forced := Options{ContentType: "application/json"}
+	if got := forced.ResolveContentType("application/octet-stream"); got != "application/json" {
+		t.Fatalf("expected forced content type, got %q", got)
+	}
+
+	mapped := Options{ContentTypeMap: map[string]string{"Application/Octet-Stream": "application/json"}}
+	if got := mapped.ResolveContentType("application/octet-stream; charset=binary"); got != "application/json" {
+		t.Fatalf("expected mapped content type by media type, got %q", got)
+	}
+	if got := mapped.ResolveContentType("text/html"); got != "" {
+		t.Fatalf("expected unmapped content type to pass through, got %q", got)
+	}
+
+	if got := (Options{}).ResolveContentType("text/plain"); got != "" {
+		t.Fatalf("expected zero options to keep upstream value, got %q", got)
+	}
+}
+
+func TestOptions_RedactedRequestHeaders(t *testing.T) {
+	opts := Options{RequestHeaders: map[string]string{"Authorization": "Bearer secret", "X-Tenant": "acme"}}
+	redacted := opts.Redacted()
+	for name, value := range redacted.RequestHeaders {
+		if value != MaskedSecret {
+			t.Errorf("request header %s should be masked, got %q", name, value)
+		}
+	}
+	if len(redacted.RequestHeaders) != 2 {
+		t.Errorf("header names should be kept, got %v", redacted.RequestHeaders)
+	}
+	if opts.RequestHeaders["Authorization"] != "Bearer secret" {
+		t.Error("Redacted must not modify the original")
+	}
+}
+
+func TestOptions_RetryOnStatusOptOut(t *testing.T) {
+	// 显式的 [] 与未设置不同: 序列化后仍保留,表示不按状态码重试
+	data, err := json.Marshal(Options{RetryOnStatus: []int{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"retry_on_status":[]}` {
+		t.Fatalf("empty retry_on_status should be kept, got %s", data)
+	}
+	var opts Options
+	if err := json.Unmarshal(data, &opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts.RetryOnStatus == nil || opts.IsZero() {
+		t.Errorf("explicit empty retry_on_status should survive a round trip, got %+v", opts)
+	}
+
+	data, _ = json.Marshal(Options{TimeoutMs: 1000})
+	if strings.Contains(string(data), "retry_on_status") {
+		t.Errorf("unset retry_on_status should be omitted, got %s", data)
+	}
+}
@@ -0,0 +1,243 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PatternMarker 正则映射前缀标识,如 "~^/t/(?P<tenant>[^/]+)/api"
+const PatternMarker = "~"
+
+// 目标模板变量: $name / ${name} / $1
+var templateVarRe = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+// ErrTargetExpansion 代入捕获组后的目标不安全(捕获组来自客户端路径,不允许改变协议、主机后缀或路径层级)
+var ErrTargetExpansion = errors.New("unsafe target expansion")
+
+// 代入主机标签的捕获值
+var hostLabelRe = regexp.MustCompile(`^[a-z0-9-]{1,63}$`)
+
+// 主机标签中除变量外的字面部分
+var hostLiteralRe = regexp.MustCompile(`^[a-z0-9-]*$`)
+
+// minHostSuffixLabels 模板主机中变量之后至少需要的字面标签数(如 backend.com),避免客户端选择任意域名
+const minHostSuffixLabels = 2
+
+// 已编译正则缓存(映射数量有限,无需淘汰)
+var patternCache sync.Map // map[string]*regexp.Regexp
+
+// IsPattern 判断前缀是否为正则映射
+func IsPattern(prefix string) bool {
+	return strings.HasPrefix(prefix, PatternMarker)
+}
+
+// CompilePattern 编译正则映射前缀(结果会被缓存)
+func CompilePattern(prefix string) (*regexp.Regexp, error) {
+	if cached, ok := patternCache.Load(prefix); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	expr := strings.TrimPrefix(prefix, PatternMarker)
+	if !strings.HasPrefix(expr, "^") {
+		return nil, fmt.Errorf("pattern must be anchored with ^: %s", prefix)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", prefix, err)
+	}
+
+	patternCache.Store(prefix, re)
+	return re, nil
+}
+
+// MatchPattern 匹配路径,返回匹配结束位置
+// 与普通前缀相同,要求在路径段边界结束
+func MatchPattern(prefix, path string) (int, bool) {
+	re, err := CompilePattern(prefix)
+	if err != nil {
+		return 0, false
+	}
+	loc := re.FindStringIndex(path)
+	if loc == nil || loc[0] != 0 {
+		return 0, false
+	}
+	end := loc[1]
+	if end == len(path) || path[end] == '/' || (end > 0 && path[end-1] == '/') {
+		return end, true
+	}
+	return 0, false
+}
+
+// ExpandTarget 将正则捕获组代入目标模板
+// 非正则映射或未匹配时原样返回目标;代入值不安全时返回 ErrTargetExpansion:
+// 主机中的值须为单个小写标签,路径中的值不能为 . / .. 或包含分隔符,代入后的主机不能解析到私有地址
+func ExpandTarget(prefix, target, path string) (string, error) {
+	if !IsPattern(prefix) || !strings.Contains(target, "$") {
+		return target, nil
+	}
+	re, err := CompilePattern(prefix)
+	if err != nil {
+		return target, nil
+	}
+	match := re.FindStringSubmatchIndex(path)
+	if match == nil {
+		return target, nil
+	}
+
+	hostStart, hostEnd := hostBounds(target)
+	var b strings.Builder
+	last, hostTemplated := 0, false
+	for _, loc := range templateVarRe.FindAllStringIndex(target, -1) {
+		value := string(re.ExpandString(nil, target[loc[0]:loc[1]], path, match))
+		inHost := loc[0] >= hostStart && loc[1] <= hostEnd
+		switch {
+		case inHost && !hostLabelRe.MatchString(value):
+			return "", fmt.Errorf("%w: %q is not a valid host label", ErrTargetExpansion, value)
+		case !inHost && unsafeSegment(value):
+			return "", fmt.Errorf("%w: %q is not a valid path segment", ErrTargetExpansion, value)
+		}
+		hostTemplated = hostTemplated || inHost
+		b.WriteString(target[last:loc[0]])
+		b.WriteString(value)
+		last = loc[1]
+	}
+	b.WriteString(target[last:])
+	expanded := b.String()
+
+	// 协议与端口不变,主机保持模板的字面后缀(保存时已校验模板,此处再次确认以防绕过)
+	want, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrTargetExpansion, err)
+	}
+	got, err := url.Parse(expanded)
+	if err != nil || got.Scheme != want.Scheme || got.User.String() != want.User.String() || got.Port() != want.Port() {
+		return "", ErrTargetExpansion
+	}
+	if !hostTemplated {
+		if got.Host != want.Host {
+			return "", ErrTargetExpansion
+		}
+		return expanded, nil
+	}
+	if suffix := hostSuffix(want.Hostname()); !strings.HasSuffix(got.Hostname(), "."+suffix) {
+		return "", ErrTargetExpansion
+	}
+	if ip := ResolvePrivateIP(got.Hostname()); ip != nil {
+		return "", fmt.Errorf("%w: %s resolves to private address %s", ErrTargetExpansion, got.Hostname(), ip)
+	}
+	return expanded, nil
+}
+
+// unsafeSegment 判断代入路径或查询的值是否会改变路径层级(含编码形式)
+func unsafeSegment(value string) bool {
+	if value == "." || value == ".." || strings.ContainsAny(value, "/\\?#") {
+		return true
+	}
+	lower := strings.ToLower(value)
+	return strings.Contains(lower, "%2e") || strings.Contains(lower, "%2f") || strings.Contains(lower, "%5c")
+}
+
+// hostBounds 返回目标中主机名的起止位置(不含用户信息与端口)
+func hostBounds(target string) (int, int) {
+	start := 0
+	if i := strings.Index(target, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	end := authorityEnd(target)
+	if i := strings.LastIndex(target[start:end], "@"); i >= 0 {
+		start += i + 1
+	}
+	if i := strings.LastIndex(target[start:end], ":"); i >= 0 {
+		end = start + i
+	}
+	return start, end
+}
+
+// hostSuffix 返回模板主机中最后一个变量之后的字面后缀(如 "$tenant.backend.com" 返回 "backend.com")
+func hostSuffix(host string) string {
+	labels := strings.Split(host, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		if strings.Contains(labels[i], "$") {
+			return strings.Join(labels[i+1:], ".")
+		}
+	}
+	return host
+}
+
+// authorityEnd 返回目标中协议与主机部分的结束位置(其后为路径)
+func authorityEnd(target string) int {
+	start := 0
+	if i := strings.Index(target, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.IndexAny(target[start:], "/?#"); i >= 0 {
+		return start + i
+	}
+	return len(target)
+}
+
+// ValidateTemplate 校验目标模板中的变量均有对应的捕获组
+// 变量只能出现在路径、查询或主机标签中;主机中的变量之后须有固定的字面后缀,协议、用户信息和端口不能包含变量
+func ValidateTemplate(prefix, target string) error {
+	if !IsPattern(prefix) {
+		return nil
+	}
+
+	re, err := CompilePattern(prefix)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			names[name] = true
+		}
+	}
+
+	hostStart, hostEnd := hostBounds(target)
+	authEnd := authorityEnd(target)
+	hostTemplated := false
+	for _, loc := range templateVarRe.FindAllStringSubmatchIndex(target, -1) {
+		switch {
+		case loc[0] >= hostStart && loc[1] <= hostEnd:
+			hostTemplated = true
+		case loc[0] < authEnd:
+			return errors.New("target template can only reference captures in the host labels or the path, not the scheme, userinfo or port")
+		}
+		name := target[max(loc[2], loc[4]):max(loc[3], loc[5])]
+		if n, err := strconv.Atoi(name); err == nil {
+			if n > re.NumSubexp() {
+				return fmt.Errorf("target references $%s but pattern has %d groups", name, re.NumSubexp())
+			}
+			continue
+		}
+		if !names[name] {
+			return fmt.Errorf("target references $%s but pattern has no such named group", name)
+		}
+	}
+	if hostTemplated {
+		return validateHostTemplate(target[hostStart:hostEnd])
+	}
+	return nil
+}
+
+// validateHostTemplate 校验主机模板: 变量只能构成完整或部分标签,且其后有固定的字面后缀
+func validateHostTemplate(host string) error {
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if !hostLiteralRe.MatchString(templateVarRe.ReplaceAllString(label, "")) {
+			return fmt.Errorf("host template label %q can only combine captures with lowercase letters, digits and hyphens", label)
+		}
+	}
+	suffix := hostSuffix(host)
+	if suffix == "" || len(strings.Split(suffix, ".")) < minHostSuffixLabels || strings.Contains(suffix, "$") {
+		return fmt.Errorf("host template %q must end with a fixed domain of at least %d labels", host, minHostSuffixLabels)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package mapping
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// wasmMagic WebAssembly 二进制模块的文件头
+var wasmMagic = []byte{0x00, 0x61, 0x73, 0x6d}
+
+// Plugin 转发前转换请求(路径、查询参数、请求头)的 WASM 插件
+type Plugin struct {
+	Path      string `json:"path"`                 // 插件文件路径(代理所在主机),文件更新后自动重新加载
+	TimeoutMs int    `json:"timeout_ms,omitempty"` // 单次转换超时(毫秒),0表示使用全局默认值
+}
+
+// Validate 校验插件配置(文件须可读且为 wasm 模块)
+func (p Plugin) Validate() error {
+	if p.Path == "" {
+		return fmt.Errorf("plugin.path is required")
+	}
+	if p.TimeoutMs < 0 {
+		return fmt.Errorf("plugin.timeout_ms cannot be negative")
+	}
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return fmt.Errorf("plugin.path: %w", err)
+	}
+	defer f.Close()
+	header := make([]byte, len(wasmMagic))
+	if _, err := io.ReadFull(f, header); err != nil || !bytes.Equal(header, wasmMagic) {
+		return fmt.Errorf("plugin.path %q is not a WebAssembly module", p.Path)
+	}
+	return nil
+}
+
+// Timeout 返回单次转换超时,0表示未配置
+func (p Plugin) Timeout() time.Duration {
+	return time.Duration(p.TimeoutMs) * time.Millisecond
+}
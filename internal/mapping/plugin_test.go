@@ -0,0 +1,33 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlugin_Validate(t *testing.T) {
+	dir := t.TempDir()
+	wasm := filepath.Join(dir, "plugin.wasm")
+	os.WriteFile(wasm, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, 0o644)
+	text := filepath.Join(dir, "plugin.txt")
+	os.WriteFile(text, []byte("not a module"), 0o644)
+	short := filepath.Join(dir, "short.wasm")
+	os.WriteFile(short, []byte{0x00}, 0o644)
+
+	tests := []struct {
+		name    string
+		plugin  Plugin
+		wantErr bool
+	}{
+		{"valid", Plugin{Path: wasm, TimeoutMs: 50}, false},
+		{"notWasm", Plugin{Path: text}, true},
+		{"truncated", Plugin{Path: short}, true},
+		{"negativeTimeout", Plugin{Path: wasm, TimeoutMs: -1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.plugin.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
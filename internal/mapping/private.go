@@ -0,0 +1,25 @@
+package mapping
+
+import "net"
+
+// lookupIP 解析主机名(测试时替换)
+var lookupIP = net.LookupIP
+
+// IsPrivateIP 检查IP是否为私有地址(含回环、链路本地及未指定地址)
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// ResolvePrivateIP 返回主机解析到的第一个私有地址,没有时返回 nil(解析失败不视为私有)
+func ResolvePrivateIP(host string) net.IP {
+	ips, err := lookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if IsPrivateIP(ip) {
+			return ip
+		}
+	}
+	return nil
+}
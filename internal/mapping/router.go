@@ -0,0 +1,143 @@
+package mapping
+
+import (
+	"sort"
+	"strings"
+)
+
+// Router 按路径段组织的前缀树,返回与线性扫描(按长度降序、同长按字典序)一致的匹配结果
+// 普通前缀的查找耗时与路径长度成正比,与映射数量无关;正则映射仍逐个匹配(通常数量很少)
+// 构建后只读,可并发使用
+type Router struct {
+	root     routerNode
+	patterns []string // 正则映射前缀(按线性扫描顺序排序)
+}
+
+type routerNode struct {
+	children map[string]*routerNode
+	exact    string // 在此结束的前缀(如 /api): 路径在此结束或后接 / 时匹配
+	slash    string // 在此结束且以 / 结尾的前缀(如 /api/): 路径后接 / 时匹配
+}
+
+// NewRouter 由映射前缀构建路由树(忽略不以 / 开头的普通前缀,它们不会匹配任何路径)
+func NewRouter(prefixes []string) *Router {
+	r := &Router{}
+	for _, prefix := range prefixes {
+		switch {
+		case IsPattern(prefix):
+			r.patterns = append(r.patterns, prefix)
+		case strings.HasPrefix(prefix, "/"):
+			r.insert(prefix)
+		}
+	}
+	sort.Slice(r.patterns, func(i, j int) bool {
+		return prefixBefore(r.patterns[i], r.patterns[j])
+	})
+	return r
+}
+
+// insert 将普通前缀按 / 分段插入(末尾的 / 记为 slash 前缀)
+func (r *Router) insert(prefix string) {
+	rest, trailingSlash := strings.CutSuffix(strings.TrimPrefix(prefix, "/"), "/")
+	if prefix == "/" {
+		rest, trailingSlash = "", true
+	}
+
+	node := &r.root
+	if rest != "" || !trailingSlash {
+		for segment := range strings.SplitSeq(rest, "/") {
+			child := node.children[segment]
+			if child == nil {
+				if node.children == nil {
+					node.children = make(map[string]*routerNode)
+				}
+				child = &routerNode{}
+				node.children[segment] = child
+			}
+			node = child
+		}
+	}
+	if trailingSlash {
+		node.slash = prefix
+	} else {
+		node.exact = prefix
+	}
+}
+
+// Match 返回匹配 path 的前缀(多个匹配时取线性扫描中排在最前的)
+func (r *Router) Match(path string) (string, bool) {
+	best := r.matchLiteral(path)
+
+	// 排在最佳普通前缀之前的正则映射优先
+	for _, pattern := range r.patterns {
+		if best != "" && !prefixBefore(pattern, best) {
+			break
+		}
+		if _, ok := MatchPattern(pattern, path); ok {
+			return pattern, true
+		}
+	}
+	return best, best != ""
+}
+
+// matchLiteral 沿路径段下行,返回最长的匹配前缀
+// 请求路径总以 / 开头;更深的节点对应更长的前缀,同一节点上 slash 前缀比 exact 前缀长一个字符
+func (r *Router) matchLiteral(path string) string {
+	rest, ok := strings.CutPrefix(path, "/")
+	if !ok {
+		return ""
+	}
+
+	var best string
+	node := &r.root
+	more := true // 当前节点之后是否还有路径段(即后接 /)
+	for {
+		if node.exact != "" {
+			best = node.exact
+		}
+		if node.slash != "" && more {
+			best = node.slash
+		}
+		if !more {
+			return best
+		}
+
+		var segment string
+		segment, rest, more = strings.Cut(rest, "/")
+		if node = node.children[segment]; node == nil {
+			return best
+		}
+	}
+}
+
+// prefixBefore 线性扫描的前缀顺序: 长度降序,同长按字典序
+func prefixBefore(a, b string) bool {
+	if len(a) == len(b) {
+		return a < b
+	}
+	return len(a) > len(b)
+}
+
+// MatchesPrefix 判断 path 是否匹配单个前缀(线性扫描的匹配规则)
+func MatchesPrefix(path, prefix string) bool {
+	if prefix == "" {
+		return false
+	}
+	if IsPattern(prefix) {
+		_, ok := MatchPattern(prefix, path)
+		return ok
+	}
+	if prefix == "/" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	if len(path) == len(prefix) {
+		return true
+	}
+	if strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	return path[len(prefix)] == '/'
+}
@@ -0,0 +1,131 @@
+package mapping
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+// linearMatch 按长度降序逐个匹配前缀(路由树需与其结果一致)
+func linearMatch(path string, prefixes []string) (string, bool) {
+	sorted := append([]string(nil), prefixes...)
+	sort.Slice(sorted, func(i, j int) bool { return prefixBefore(sorted[i], sorted[j]) })
+	for _, prefix := range sorted {
+		if MatchesPrefix(path, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+func TestMatchesPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		prefix  string
+		expects bool
+	}{
+		{"exact", "/api", "/api", true},
+		{"nested", "/api/v1", "/api", true},
+		{"boundary", "/api2", "/api", false},
+		{"trailingSlash", "/api/v1", "/api/", true},
+		{"root", "/anything", "/", true},
+		{"noMatch", "/foo", "/bar", false},
+		{"pattern", "/t/acme/api/x", "~^/t/(?P<tenant>[^/]+)/api", true},
+		{"patternBoundary", "/t/acme/apix", "~^/t/(?P<tenant>[^/]+)/api", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchesPrefix(tt.path, tt.prefix); got != tt.expects {
+			t.Fatalf("%s: expected %v got %v", tt.name, tt.expects, got)
+		}
+	}
+}
+
+func TestRouter_PrefersLongest(t *testing.T) {
+	router := NewRouter([]string{"/openai", "/openai/v1"})
+	if match, ok := router.Match("/openai/v1/chat"); !ok || match != "/openai/v1" {
+		t.Fatalf("expected /openai/v1, got %q", match)
+	}
+}
+
+func TestRouter_MatchesLinearScan(t *testing.T) {
+	prefixes := []string{
+		"/", "/api", "/api/", "/api/v1", "/api/v1/", "/api2", "/a//b", "/a/", "/x/y/z",
+		"/openai", "/openai/v1", "/empty/", "nolead",
+		"~^/t/(?P<tenant>[^/]+)/api", "~^/api/v1/items", "~^/o",
+	}
+	paths := []string{
+		"/", "/api", "/api/", "/api/v1", "/api/v1/", "/api/v1/chat", "/api/v2", "/api2", "/api2/x", "/api3",
+		"/a", "/a/", "/a//b", "/a//b/c", "/a/b", "/x/y", "/x/y/z", "/x/y/z/", "/x/y/zz",
+		"/openai/v1/chat", "/openai/v10", "/empty", "/empty/", "/t/acme/api/x", "/t/acme/apix",
+		"/api/v1/items/1", "/o", "/other",
+	}
+
+	check := func(t *testing.T, prefixes []string) {
+		t.Helper()
+		router := NewRouter(prefixes)
+		for _, path := range paths {
+			want, wantOK := linearMatch(path, prefixes)
+			got, gotOK := router.Match(path)
+			if got != want || gotOK != wantOK {
+				t.Errorf("prefixes %v path %q: router=%q,%v linear=%q,%v", prefixes, path, got, gotOK, want, wantOK)
+			}
+		}
+	}
+
+	check(t, prefixes)
+	check(t, nil)
+
+	// 随机子集覆盖不同的前缀组合
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 200 {
+		var subset []string
+		for _, prefix := range prefixes {
+			if rng.IntN(2) == 0 {
+				subset = append(subset, prefix)
+			}
+		}
+		check(t, subset)
+	}
+}
+
+// benchmarkPrefixes 生成 n 个两级前缀(/svcN/vM)
+func benchmarkPrefixes(n int) []string {
+	prefixes := make([]string, 0, n)
+	for i := range n {
+		prefixes = append(prefixes, fmt.Sprintf("/svc%d/v%d", i/4, i%4))
+	}
+	return prefixes
+}
+
+const benchmarkPath = "/svc1100/v2/chat/completions"
+
+func BenchmarkRouter_Match5k(b *testing.B) {
+	router := NewRouter(benchmarkPrefixes(5000))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, ok := router.Match(benchmarkPath); !ok {
+			b.Fatal("expected match")
+		}
+	}
+}
+
+func BenchmarkLinearScan_Match5k(b *testing.B) {
+	prefixes := benchmarkPrefixes(5000)
+	sort.Slice(prefixes, func(i, j int) bool { return prefixBefore(prefixes[i], prefixes[j]) })
+	b.ReportAllocs()
+	for b.Loop() {
+		found := false
+		for _, prefix := range prefixes {
+			if MatchesPrefix(benchmarkPath, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			b.Fatal("expected match")
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package mapping
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// validateStatusMap 校验状态码改写(如 418->503): 两侧均须为 200~599,
+// 且目标状态码允许响应体(不能为 204/304,否则上游响应体无法写出)
+func validateStatusMap(statusMap map[int]int) error {
+	for from, to := range statusMap {
+		for _, code := range []int{from, to} {
+			if code < 200 || code > 599 {
+				return fmt.Errorf("status_map: invalid status %d: must be between 200 and 599", code)
+			}
+		}
+		if to == http.StatusNoContent || to == http.StatusNotModified {
+			return fmt.Errorf("status_map: cannot rewrite to %d (response body not allowed)", to)
+		}
+		if from == to {
+			return fmt.Errorf("status_map: %d is rewritten to itself", from)
+		}
+	}
+	return nil
+}
+
+// ClientStatus 返回上游状态码改写后返回给客户端的状态码,未配置改写时返回 false
+func (o Options) ClientStatus(upstream int) (int, bool) {
+	to, ok := o.StatusMap[upstream]
+	return to, ok
+}
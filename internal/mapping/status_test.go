@@ -0,0 +1,16 @@
+package mapping
+
+import "testing"
+
+func TestOptions_ClientStatus(t *testing.T) {
+	opts := Options{StatusMap: map[int]int{418: 503}}
+	if got, ok := opts.ClientStatus(418); !ok || got != 503 {
+		t.Errorf("expected 418 remapped to 503, got %d %v", got, ok)
+	}
+	if got, ok := opts.ClientStatus(500); ok {
+		t.Errorf("expected 500 unchanged, got %d", got)
+	}
+	if _, ok := (Options{}).ClientStatus(418); ok {
+		t.Error("expected no remap without status_map")
+	}
+}
@@ -0,0 +1,55 @@
+package mapping
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// TokenRefresh 代理注入的上游访问令牌配置(token_refresh)
+// 令牌通过 OAuth2 client_credentials 方式从刷新端点获取并缓存,上游返回401时刷新后重试一次
+type TokenRefresh struct {
+	URL          string `json:"url"`                     // 令牌端点
+	ClientID     string `json:"client_id,omitempty"`     // 客户端ID(HTTP Basic 认证)
+	ClientSecret string `json:"client_secret,omitempty"` // 客户端密钥,接口输出时脱敏
+	Scope        string `json:"scope,omitempty"`         // 申请的权限范围
+	Header       string `json:"header,omitempty"`        // 注入令牌的请求头,默认 Authorization(值为 Bearer <token>)
+}
+
+// Validate 校验令牌刷新配置
+func (t TokenRefresh) Validate() error {
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("token_refresh.url must be an http(s) URL, got %q", t.URL)
+	}
+	if t.Header != "" {
+		if err := ValidateRequestHeader(t.Header, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HeaderName 返回注入令牌的请求头名称
+func (t TokenRefresh) HeaderName() string {
+	if t.Header == "" {
+		return "Authorization"
+	}
+	return http.CanonicalHeaderKey(t.Header)
+}
+
+// HeaderValue 返回令牌对应的请求头值(Authorization 使用 Bearer 方案)
+func (t TokenRefresh) HeaderValue(token string) string {
+	if t.HeaderName() == "Authorization" {
+		return "Bearer " + token
+	}
+	return token
+}
+
+// Redacted 返回客户端密钥脱敏后的副本
+func (t TokenRefresh) Redacted() *TokenRefresh {
+	if t.ClientSecret != "" {
+		t.ClientSecret = MaskedSecret
+	}
+	return &t
+}
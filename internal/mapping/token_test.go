@@ -0,0 +1,26 @@
+package mapping
+
+import "testing"
+
+func TestTokenRefresh_Header(t *testing.T) {
+	bearer := TokenRefresh{URL: "https://auth.example.com/token"}
+	if bearer.HeaderName() != "Authorization" || bearer.HeaderValue("abc") != "Bearer abc" {
+		t.Errorf("unexpected default header: %s: %s", bearer.HeaderName(), bearer.HeaderValue("abc"))
+	}
+
+	custom := TokenRefresh{URL: "https://auth.example.com/token", Header: "x-api-token"}
+	if custom.HeaderName() != "X-Api-Token" || custom.HeaderValue("abc") != "abc" {
+		t.Errorf("unexpected custom header: %s: %s", custom.HeaderName(), custom.HeaderValue("abc"))
+	}
+}
+
+func TestTokenRefresh_Redacted(t *testing.T) {
+	cfg := TokenRefresh{URL: "https://auth.example.com/token", ClientID: "id", ClientSecret: "secret"}
+	redacted := Options{TokenRefresh: &cfg}.Redacted()
+	if redacted.TokenRefresh.ClientSecret != MaskedSecret || redacted.TokenRefresh.ClientID != "id" {
+		t.Errorf("unexpected redacted config: %+v", redacted.TokenRefresh)
+	}
+	if cfg.ClientSecret != "secret" {
+		t.Error("Redacted must not modify the original")
+	}
+}
@@ -0,0 +1,78 @@
+package mapping
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 多目标映射的选择策略
+const (
+	StrategyRandom     = "random"          // 按权重随机选择(默认)
+	StrategyWRR        = "wrr"             // 平滑加权轮询(确定性顺序,分布均匀)
+	StrategyRoundRobin = "round_robin"     // 忽略权重依次轮询
+	StrategyHash       = "consistent_hash" // 一致性哈希: 相同键固定命中同一目标,增删目标时仅少量键迁移
+)
+
+// 一致性哈希的键来源
+const (
+	HashKeyPath         = "path"    // 请求路径(默认)
+	HashKeyHeaderPrefix = "header:" // header:<名称>,请求头缺失时退化为路径
+)
+
+// MaxUpstreamWeight 单个目标的最大权重(避免权重前缀和溢出及一致性哈希环过大)
+const MaxUpstreamWeight = 1000
+
+// Upstream 多目标映射中的一个目标
+type Upstream struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight,omitempty"` // 权重,0按1处理,最大 MaxUpstreamWeight
+}
+
+// EffectiveWeight 返回实际使用的权重(校验前已存储的超限权重按 MaxUpstreamWeight 处理)
+func (u Upstream) EffectiveWeight() int {
+	if u.Weight <= 0 {
+		return 1
+	}
+	return min(u.Weight, MaxUpstreamWeight)
+}
+
+// HashKeyHeader 返回 header:<名称> 形式的键来源中的请求头名称,其他来源返回空
+func HashKeyHeader(hashKey string) string {
+	name, _ := strings.CutPrefix(hashKey, HashKeyHeaderPrefix)
+	if name == hashKey {
+		return ""
+	}
+	return name
+}
+
+// validateHashKey 校验一致性哈希的键来源
+func validateHashKey(hashKey string) error {
+	if hashKey == "" || hashKey == HashKeyPath {
+		return nil
+	}
+	if name := HashKeyHeader(hashKey); name != "" {
+		return ValidateRequestHeader(name, "")
+	}
+	return fmt.Errorf("invalid hash_key %q: expected %s or %s<name>", hashKey, HashKeyPath, HashKeyHeaderPrefix)
+}
+
+// validateUpstreams 校验多目标配置
+func validateUpstreams(upstreams []Upstream, strategy string) error {
+	switch strategy {
+	case "", StrategyRandom, StrategyWRR, StrategyRoundRobin, StrategyHash:
+	default:
+		return fmt.Errorf("invalid strategy %q: expected %s, %s, %s or %s",
+			strategy, StrategyRandom, StrategyWRR, StrategyRoundRobin, StrategyHash)
+	}
+	for _, upstream := range upstreams {
+		u, err := url.Parse(upstream.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("upstreams: url must be an http(s) URL, got %q", upstream.URL)
+		}
+		if upstream.Weight < 0 || upstream.Weight > MaxUpstreamWeight {
+			return fmt.Errorf("upstreams: weight must be between 0 and %d for %q", MaxUpstreamWeight, upstream.URL)
+		}
+	}
+	return nil
+}
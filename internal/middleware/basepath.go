@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// NormalizeBasePath 规范化部署路径前缀: 补全开头的 "/",去掉结尾的 "/"
+// 空值或 "/" 表示部署在根路径,返回空字符串
+func NormalizeBasePath(base string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		return ""
+	}
+	if !strings.HasPrefix(base, "/") {
+		base = "/" + base
+	}
+	return base
+}
+
+// StripBasePath 在路由前去掉请求路径中的部署前缀(如反向代理挂载在 /proxy-service/ 下)
+// 前缀之外的请求返回 404;base 为空时直接返回 next
+func StripBasePath(base string, next http.Handler) http.Handler {
+	base = NormalizeBasePath(base)
+	if base == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := trimBase(r.URL.Path, base)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = path
+		if r.URL.RawPath != "" {
+			if raw, ok := trimBase(r.URL.RawPath, base); ok {
+				r2.URL.RawPath = raw
+			} else {
+				r2.URL.RawPath = ""
+			}
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// trimBase 去掉路径前缀,"/base" 与 "/base/" 均映射为 "/"
+func trimBase(path, base string) (string, bool) {
+	if path == base {
+		return "/", true
+	}
+	if rest, ok := strings.CutPrefix(path, base); ok && strings.HasPrefix(rest, "/") {
+		return rest, true
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":                "",
+		"/":               "",
+		"proxy-service":   "/proxy-service",
+		"/proxy-service/": "/proxy-service",
+		"/a/b/":           "/a/b",
+	}
+	for in, want := range tests {
+		if got := NormalizeBasePath(in); got != want {
+			t.Errorf("NormalizeBasePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStripBasePath(t *testing.T) {
+	var gotPath, gotRaw string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotRaw = r.URL.Path, r.URL.RawPath
+	})
+	h := StripBasePath("/proxy-service/", next)
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantPath string
+	}{
+		{"/proxy-service/stats", http.StatusOK, "/stats"},
+		{"/proxy-service/", http.StatusOK, "/"},
+		{"/proxy-service", http.StatusOK, "/"},
+		{"/proxy-serviceX/stats", http.StatusNotFound, ""},
+		{"/stats", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		gotPath = ""
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.wantCode || gotPath != tt.wantPath {
+			t.Errorf("%s: got code=%d path=%q, want code=%d path=%q", tt.path, w.Code, gotPath, tt.wantCode, tt.wantPath)
+		}
+	}
+
+	// 编码路径同步去掉前缀
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/proxy-service/api/a%2Fb", nil))
+	if gotPath != "/api/a/b" || gotRaw != "/api/a%2Fb" {
+		t.Fatalf("unexpected escaped path: path=%q raw=%q", gotPath, gotRaw)
+	}
+}
+
+func TestStripBasePath_Root(t *testing.T) {
+	var gotPath string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { gotPath = r.URL.Path })
+
+	w := httptest.NewRecorder()
+	StripBasePath("/", next).ServeHTTP(w, httptest.NewRequest("GET", "/stats", nil))
+	if w.Code != http.StatusOK || gotPath != "/stats" {
+		t.Fatalf("root deployment should pass through, got code=%d path=%q", w.Code, gotPath)
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"api-proxy/internal/stats"
+)
+
+// ClientIP 将 c.ClientIP()(仅信任 TRUSTED_PROXIES 转发的 X-Forwarded-For/X-Real-IP)记录到请求上下文,
+// 供只持有 *http.Request 的组件(按 API Key 分桶、配额、SSE 连接数限制等)通过 stats.ClientIP 读取
+func ClientIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = stats.WithClientIP(c.Request, c.ClientIP())
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"api-proxy/internal/stats"
+)
+
+func TestClientIP_TrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	r.Use(ClientIP())
+	r.GET("/api", func(c *gin.Context) {
+		c.String(http.StatusOK, stats.ClientIP(c.Request))
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{"trustedProxy", "10.0.0.1:5000", "198.51.100.7"},
+		{"untrustedPeer", "192.0.2.1:5000", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Body.String() != tt.want {
+			t.Errorf("%s: client IP = %q, want %q", tt.name, w.Body.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Drainer 跟踪进行中的代理请求,关闭时等待其完成(优雅排空)
+type Drainer struct {
+	inFlight atomic.Int64
+	draining atomic.Bool
+	started  atomic.Int64  // 排空开始时间(UnixNano)
+	duration atomic.Int64  // 排空耗时(纳秒),0表示尚未结束
+	idle     chan struct{} // 排空期间计数归零时通知
+}
+
+// DrainStats 排空状态(用于 /stats)
+type DrainStats struct {
+	Draining   bool  `json:"draining"`
+	InFlight   int64 `json:"in_flight"`
+	ElapsedMs  int64 `json:"elapsed_ms,omitempty"`  // 排空已进行的时间
+	DurationMs int64 `json:"duration_ms,omitempty"` // 排空结束时的总耗时
+}
+
+// NewDrainer 创建排空跟踪器
+func NewDrainer() *Drainer {
+	return &Drainer{idle: make(chan struct{}, 1)}
+}
+
+// Handler 统计进行中的请求
+func (d *Drainer) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d.inFlight.Add(1)
+		defer d.done()
+		c.Next()
+	}
+}
+
+// done 请求结束;排空期间计数归零时唤醒 Drain
+func (d *Drainer) done() {
+	if d.inFlight.Add(-1) == 0 && d.draining.Load() {
+		select {
+		case d.idle <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// InFlight 返回进行中的请求数
+func (d *Drainer) InFlight() int64 {
+	return d.inFlight.Load()
+}
+
+// Draining 是否处于排空状态
+func (d *Drainer) Draining() bool {
+	return d.draining.Load()
+}
+
+// Drain 进入排空状态并等待进行中的请求完成,每 interval 输出一次进度
+// ctx 结束时放弃等待,返回时记录排空耗时并输出剩余请求数
+func (d *Drainer) Drain(ctx context.Context, interval time.Duration) DrainStats {
+	start := time.Now()
+	d.started.Store(start.UnixNano())
+	d.draining.Store(true)
+	log.Printf("⏳ Draining %d in-flight requests", d.inFlight.Load())
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+wait:
+	for d.inFlight.Load() > 0 {
+		select {
+		case <-d.idle:
+		case <-tick:
+			log.Printf("⏳ Draining: %d requests in flight (%s elapsed)",
+				d.inFlight.Load(), time.Since(start).Round(time.Millisecond))
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	elapsed := time.Since(start)
+	d.duration.Store(max(int64(elapsed), 1))
+	if remaining := d.inFlight.Load(); remaining > 0 {
+		log.Printf("⚠️  Drain timed out after %s with %d requests in flight", elapsed.Round(time.Millisecond), remaining)
+	} else {
+		log.Printf("✅ Drain completed in %s", elapsed.Round(time.Millisecond))
+	}
+	return d.Stats()
+}
+
+// Stats 返回当前排空状态
+func (d *Drainer) Stats() DrainStats {
+	stats := DrainStats{
+		Draining: d.draining.Load(),
+		InFlight: d.inFlight.Load(),
+	}
+	if !stats.Draining {
+		return stats
+	}
+	if duration := d.duration.Load(); duration > 0 {
+		stats.DurationMs = time.Duration(duration).Milliseconds()
+	} else {
+		stats.ElapsedMs = time.Since(time.Unix(0, d.started.Load())).Milliseconds()
+	}
+	return stats
+}
+
+// Duration 返回排空总耗时,未结束时返回0
+func (d *Drainer) Duration() time.Duration {
+	return time.Duration(d.duration.Load())
+}
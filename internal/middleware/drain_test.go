@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDrainer_Drain(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	drainer := NewDrainer()
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+
+	r := gin.New()
+	r.Use(drainer.Handler())
+	r.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Go(func() {
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		})
+	}
+	for range 3 {
+		<-started
+	}
+	if drainer.InFlight() != 3 || drainer.Draining() {
+		t.Fatalf("expected 3 in flight before drain, got %d", drainer.InFlight())
+	}
+
+	done := make(chan DrainStats)
+	go func() { done <- drainer.Drain(context.Background(), 5*time.Millisecond) }()
+
+	// 排空期间逐个完成,计数递减至0
+	for remaining := int64(3); remaining > 0; remaining-- {
+		deadline := time.Now().Add(time.Second)
+		for !drainer.Draining() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		stats := drainer.Stats()
+		if !stats.Draining || stats.InFlight != remaining || stats.DurationMs != 0 {
+			t.Fatalf("expected draining with %d in flight, got %+v", remaining, stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+		release <- struct{}{}
+		for drainer.InFlight() == remaining {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	wg.Wait()
+
+	stats := <-done
+	if stats.InFlight != 0 || !stats.Draining {
+		t.Fatalf("expected drain to complete with 0 in flight, got %+v", stats)
+	}
+	if drainer.Duration() < 30*time.Millisecond || stats.DurationMs < 30 {
+		t.Errorf("expected drain duration recorded, got %v (%+v)", drainer.Duration(), stats)
+	}
+}
+
+func TestDrainer_DrainIdle(t *testing.T) {
+	drainer := NewDrainer()
+	stats := drainer.Drain(context.Background(), time.Second)
+	if stats.InFlight != 0 || drainer.Duration() <= 0 {
+		t.Fatalf("expected immediate drain with duration recorded, got %+v", stats)
+	}
+}
+
+func TestDrainer_DrainTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	drainer := NewDrainer()
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	r := gin.New()
+	r.Use(drainer.Handler())
+	r.GET("/stuck", func(c *gin.Context) {
+		close(started)
+		<-release
+	})
+	go r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/stuck", nil))
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	stats := drainer.Drain(ctx, 0)
+	if stats.InFlight != 1 || stats.DurationMs < 20 {
+		t.Fatalf("expected timeout with 1 request remaining, got %+v", stats)
+	}
+}
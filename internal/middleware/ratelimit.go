@@ -1,29 +1,136 @@
 package middleware
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
 
+// 代理自身的限流响应头: 使用独立前缀,避免与上游返回的 X-RateLimit-* 互相覆盖
+const (
+	HeaderRateLimitLimit     = "X-Proxy-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-Proxy-RateLimit-Remaining"
+)
+
 // RateLimiter 全局速率限制器（简单实现）
 type RateLimiter struct {
 	limiter *rate.Limiter
 }
 
-// NewRateLimiter 创建速率限制器
+// NewRateLimiter 创建速率限制器(突发容量为每秒请求数的2倍)
 // requestsPerSecond: 每秒允许的请求数
 func NewRateLimiter(requestsPerSecond int) *RateLimiter {
+	return NewRateLimiterWithBurst(requestsPerSecond, requestsPerSecond*2)
+}
+
+// NewRateLimiterWithBurst 创建指定突发容量的速率限制器
+// burst: 可立即通过的最大请求数,小于1时按1处理(无突发,严格按速率放行)
+func NewRateLimiterWithBurst(requestsPerSecond, burst int) *RateLimiter {
 	return &RateLimiter{
-		limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), requestsPerSecond*2),
+		limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), max(burst, 1)),
 	}
 }
 
 // Middleware 返回速率限制中间件
+// 响应头 X-Proxy-RateLimit-Limit 为突发容量,X-Proxy-RateLimit-Remaining 为放行后剩余的令牌数;
+// 被限流时按令牌补充所需时间输出 Retry-After(向上取整,至少1秒)
 func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !rl.limiter.Allow() {
+		now := time.Now()
+		reservation := rl.limiter.ReserveN(now, 1)
+		c.Header(HeaderRateLimitLimit, strconv.Itoa(rl.limiter.Burst()))
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			// 仅用于计算等待时间,归还预占的令牌
+			reservation.CancelAt(now)
+			c.Header(HeaderRateLimitRemaining, "0")
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Rate limit exceeded",
+			})
+			c.Abort()
+			return
+		}
+		c.Header(HeaderRateLimitRemaining, strconv.Itoa(max(int(rl.limiter.TokensAt(now)), 0)))
+		c.Next()
+	}
+}
+
+// keyedIdleTTL 分桶限流器的闲置回收时间
+const keyedIdleTTL = 10 * time.Minute
+
+// KeyedRateLimiter 按客户端分桶的速率限制器(如 API Key 摘要或客户端IP)
+type KeyedRateLimiter struct {
+	rps   rate.Limit
+	burst int
+	key   func(*http.Request) string
+
+	mu        sync.Mutex
+	buckets   map[string]*keyedBucket
+	lastSweep time.Time
+}
+
+type keyedBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewKeyedRateLimiter 创建分桶速率限制器,key 返回请求所属的桶(nil 表示按客户端IP)
+// burst 小于1时按1处理
+func NewKeyedRateLimiter(requestsPerSecond, burst int, key func(*http.Request) string) *KeyedRateLimiter {
+	return &KeyedRateLimiter{
+		rps:     rate.Limit(requestsPerSecond),
+		burst:   max(burst, 1),
+		key:     key,
+		buckets: make(map[string]*keyedBucket),
+	}
+}
+
+// NewPerIPRateLimiter 创建按客户端IP分桶的速率限制器
+// 客户端IP取 c.ClientIP(): 仅信任 TRUSTED_PROXIES 转发的 X-Forwarded-For/X-Real-IP,否则为连接地址
+func NewPerIPRateLimiter(requestsPerSecond, burst int) *KeyedRateLimiter {
+	return NewKeyedRateLimiter(requestsPerSecond, burst, nil)
+}
+
+// bucketKey 返回请求所属的桶
+func (kl *KeyedRateLimiter) bucketKey(c *gin.Context) string {
+	if kl.key == nil {
+		return c.ClientIP()
+	}
+	return kl.key(c.Request)
+}
+
+// allow 判断桶内是否还有令牌,并顺带回收闲置的桶
+func (kl *KeyedRateLimiter) allow(key string, now time.Time) bool {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	if now.Sub(kl.lastSweep) > keyedIdleTTL {
+		for k, b := range kl.buckets {
+			if now.Sub(b.lastSeen) > keyedIdleTTL {
+				delete(kl.buckets, k)
+			}
+		}
+		kl.lastSweep = now
+	}
+
+	b, ok := kl.buckets[key]
+	if !ok {
+		b = &keyedBucket{limiter: rate.NewLimiter(kl.rps, kl.burst)}
+		kl.buckets[key] = b
+	}
+	b.lastSeen = now
+	return b.limiter.AllowN(now, 1)
+}
+
+// Middleware 返回分桶速率限制中间件
+func (kl *KeyedRateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !kl.allow(kl.bucketKey(c), time.Now()) {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error": "Rate limit exceeded",
 			})
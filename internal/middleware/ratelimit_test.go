@@ -3,9 +3,12 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
 )
 
 func TestNewRateLimiter(t *testing.T) {
@@ -50,3 +53,177 @@ func TestRateLimiter_Middleware(t *testing.T) {
 		t.Errorf("third request should be rate limited, got status %d", w3.Code)
 	}
 }
+
+func TestRateLimiter_Headers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 每10秒补充1个令牌,突发容量2
+	limiter := &RateLimiter{limiter: rate.NewLimiter(rate.Every(10*time.Second), 2)}
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	router.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i, remaining := range []string{"1", "0"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d should pass, got status %d", i+1, w.Code)
+		}
+		if got := w.Header().Get(HeaderRateLimitLimit); got != "2" {
+			t.Errorf("request %d: expected X-Proxy-RateLimit-Limit 2, got %q", i+1, got)
+		}
+		if got := w.Header().Get(HeaderRateLimitRemaining); got != remaining {
+			t.Errorf("request %d: expected X-Proxy-RateLimit-Remaining %s, got %q", i+1, remaining, got)
+		}
+		if w.Header().Get("Retry-After") != "" {
+			t.Errorf("request %d: Retry-After should only be set when throttled", i+1)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request should be rate limited, got status %d", w.Code)
+	}
+	if w.Header().Get(HeaderRateLimitLimit) != "2" || w.Header().Get(HeaderRateLimitRemaining) != "0" {
+		t.Errorf("unexpected rate limit headers on 429: %v", w.Header())
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 10 {
+		t.Errorf("expected Retry-After between 1 and 10 seconds, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// 被拒绝的请求不消耗令牌: 预占已归还,等待时间不会累加
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+	if got, _ := strconv.Atoi(w.Header().Get("Retry-After")); got > retryAfter {
+		t.Errorf("rejected requests should not push Retry-After out, got %d after %d", got, retryAfter)
+	}
+}
+
+func TestNewRateLimiterWithBurst(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		burst   int
+		allowed int
+	}{
+		{burst: 5, allowed: 5},
+		{burst: 1, allowed: 1},
+		{burst: 0, allowed: 1}, // 无突发: 仅放行一个请求
+	}
+	for _, tt := range tests {
+		router := gin.New()
+		router.Use(NewRateLimiterWithBurst(1, tt.burst).Middleware())
+		router.GET("/test", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		passed := 0
+		for range tt.allowed + 3 {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+			if w.Code == http.StatusOK {
+				passed++
+			}
+		}
+		if passed != tt.allowed {
+			t.Errorf("burst=%d: expected %d immediate requests, got %d", tt.burst, tt.allowed, passed)
+		}
+	}
+}
+
+func TestKeyedRateLimiter_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := NewKeyedRateLimiter(1, 1, func(r *http.Request) string { return r.Header.Get("X-API-Key") })
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(200, gin.H{"ok": true})
+	})
+
+	do := func(key string) int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("a"); code != http.StatusOK {
+		t.Fatalf("first request for key a should pass, got %d", code)
+	}
+	if code := do("a"); code != http.StatusTooManyRequests {
+		t.Errorf("second request for key a should be limited, got %d", code)
+	}
+	// 各桶独立计数
+	if code := do("b"); code != http.StatusOK {
+		t.Errorf("key b should have its own bucket, got %d", code)
+	}
+}
+
+func TestKeyedRateLimiter_SweepsIdleBuckets(t *testing.T) {
+	limiter := NewKeyedRateLimiter(1, 1, nil)
+	now := time.Now()
+	limiter.allow("a", now)
+	limiter.allow("b", now.Add(keyedIdleTTL+time.Second))
+	if _, ok := limiter.buckets["a"]; ok || len(limiter.buckets) != 1 {
+		t.Errorf("expected idle bucket to be swept, got %d buckets", len(limiter.buckets))
+	}
+}
+
+func TestPerIPRateLimiter_IndependentClients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := NewPerIPRateLimiter(1, 3)
+	router := gin.New()
+	if err := router.SetTrustedProxies([]string{"192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	router.Use(limiter.Middleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 经受信任代理转发的两个客户端交替压测: 各自消耗突发容量后被限流,互不影响
+	var limitedA, limitedB int
+	for range 10 {
+		if do("192.0.2.1:1234", "203.0.113.10") == http.StatusTooManyRequests {
+			limitedA++
+		}
+		if do("192.0.2.1:1234", "203.0.113.20") == http.StatusTooManyRequests {
+			limitedB++
+		}
+	}
+	if limitedA != 7 || limitedB != 7 {
+		t.Errorf("expected each client to be limited after its burst of 3, got %d and %d", limitedA, limitedB)
+	}
+
+	// 受限客户端不影响新客户端
+	if code := do("192.0.2.1:1234", "203.0.113.30"); code != http.StatusOK {
+		t.Errorf("a fresh client should not be limited, got %d", code)
+	}
+
+	// 非受信任来源的 X-Forwarded-For 被忽略,按连接地址分桶(无法伪造新IP绕过限流)
+	for i := range 5 {
+		want := http.StatusOK
+		if i >= 3 {
+			want = http.StatusTooManyRequests
+		}
+		if code := do("198.51.100.7:5555", "203.0.113."+strconv.Itoa(100+i)); code != want {
+			t.Errorf("request %d from untrusted source: got %d, want %d", i, code, want)
+		}
+	}
+}
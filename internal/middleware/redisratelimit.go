@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyRateLimitPrefix 分布式限流令牌桶(apiproxy:ratelimit:<桶>,Hash: tokens/ts)
+const KeyRateLimitPrefix = "apiproxy:ratelimit:"
+
+// redisRateLimitTimeout 单次限流判断访问Redis的超时
+const redisRateLimitTimeout = 100 * time.Millisecond
+
+// tokenBucketScript 令牌桶: 按Redis服务器时间补充令牌(避免实例间时钟偏差),
+// 返回 {是否放行, 剩余令牌, 需等待毫秒数};桶在补满所需时间后过期
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), wait}`)
+
+// RedisRateLimiter 基于Redis令牌桶的分布式速率限制器: 多实例共享同一Redis时共用配额
+// Redis 不可用时放行请求(限流故障不影响转发)
+type RedisRateLimiter struct {
+	client *redis.Client
+	rps    int
+	burst  int
+	key    func(*http.Request) string
+}
+
+// NewRedisRateLimiter 创建分布式速率限制器,默认按客户端IP分桶(可通过 SetKey 改为按 API Key 等)
+// burst 小于1时按1处理
+func NewRedisRateLimiter(client *redis.Client, requestsPerSecond, burst int) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client: client,
+		rps:    requestsPerSecond,
+		burst:  max(burst, 1),
+	}
+}
+
+// SetKey 设置请求所属的桶(nil 表示按客户端IP)
+func (rl *RedisRateLimiter) SetKey(key func(*http.Request) string) {
+	rl.key = key
+}
+
+// bucketKey 返回请求所属的桶
+func (rl *RedisRateLimiter) bucketKey(c *gin.Context) string {
+	if rl.key == nil {
+		return c.ClientIP()
+	}
+	return rl.key(c.Request)
+}
+
+// allow 消耗桶内一个令牌,返回是否放行及需等待的时间
+func (rl *RedisRateLimiter) allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisRateLimitTimeout)
+	defer cancel()
+	result, err := tokenBucketScript.Run(ctx, rl.client, []string{KeyRateLimitPrefix + key}, rl.rps, rl.burst).Int64Slice()
+	if err != nil {
+		return true, 0, err
+	}
+	return result[0] == 1, time.Duration(result[2]) * time.Millisecond, nil
+}
+
+// Middleware 返回分布式速率限制中间件
+func (rl *RedisRateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, wait, err := rl.allow(c.Request.Context(), rl.bucketKey(c))
+		if err != nil {
+			log.Printf("⚠️  分布式限流失败,放行请求: %v", err)
+		}
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(max(int((wait+time.Second-1)/time.Second), 1)))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Rate limit exceeded",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisRateLimiter_SharedAcrossInstances(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+
+	// 两个实例各自连接同一Redis
+	var routers []*gin.Engine
+	for range 2 {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		defer client.Close()
+		router := gin.New()
+		router.Use(NewRedisRateLimiter(client, 1, 3).Middleware())
+		router.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+		routers = append(routers, router)
+	}
+
+	// 两个实例合计只放行突发容量内的请求
+	allowed := 0
+	var last *httptest.ResponseRecorder
+	for i := range 6 {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "198.51.100.7:1234"
+		last = httptest.NewRecorder()
+		routers[i%2].ServeHTTP(last, req)
+		if last.Code == http.StatusOK {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("expected 3 requests allowed across instances, got %d", allowed)
+	}
+	if last.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the shared budget is exhausted, got %d", last.Code)
+	}
+	if retryAfter, err := strconv.Atoi(last.Header().Get("Retry-After")); err != nil || retryAfter < 1 {
+		t.Errorf("expected Retry-After on 429, got %q", last.Header().Get("Retry-After"))
+	}
+
+	// 其他客户端使用独立的桶
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "198.51.100.8:1234"
+	w := httptest.NewRecorder()
+	routers[0].ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("other client should not be limited, got %d", w.Code)
+	}
+	if !mr.Exists(KeyRateLimitPrefix + "198.51.100.8") {
+		t.Error("expected bucket keyed by client IP")
+	}
+}
+
+func TestRedisRateLimiter_CustomKeyAndFailOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	limiter := NewRedisRateLimiter(client, 1, 0)
+	limiter.SetKey(func(r *http.Request) string { return "key:" + r.Header.Get("X-API-Key") })
+	router := gin.New()
+	router.Use(limiter.Middleware())
+	router.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func() int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-API-Key", "abc")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if send() != http.StatusOK || send() != http.StatusTooManyRequests {
+		t.Fatal("expected burst of 1 per API key")
+	}
+	if !mr.Exists(KeyRateLimitPrefix + "key:abc") {
+		t.Error("expected bucket keyed by custom key")
+	}
+
+	// Redis 不可用时放行
+	mr.Close()
+	if code := send(); code != http.StatusOK {
+		t.Errorf("expected fail-open when Redis is unavailable, got %d", code)
+	}
+}
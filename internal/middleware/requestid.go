@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRequestIDHeader 默认的请求ID头
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// RequestIDKey 请求ID在 gin.Context 中的键(访问日志通过 LogFormatterParams.Keys 读取)
+const RequestIDKey = "request_id"
+
+// maxRequestIDLength 客户端传入的请求ID长度上限,超出或含不可见字符时重新生成
+const maxRequestIDLength = 128
+
+// RequestID 请求ID中间件: 沿用客户端在 header 中传入的ID,缺失时生成新ID;
+// 写回请求头(随请求转发到上游)并在响应头中回显。header 为空时使用 DefaultRequestIDHeader
+func RequestID(header string) gin.HandlerFunc {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	header = http.CanonicalHeaderKey(header)
+	return func(c *gin.Context) {
+		id := c.GetHeader(header)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Request.Header.Set(header, id)
+		c.Header(header, id)
+		c.Set(RequestIDKey, id)
+		c.Next()
+	}
+}
+
+// GetRequestID 返回当前请求的ID(未启用中间件时为空)
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// validRequestID 判断客户端传入的ID是否可用(非空、长度受限、仅可见ASCII字符)
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID 生成32位十六进制随机ID
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestID_ConfiguredHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 上游记录收到的请求ID,转发时沿用中间件写回的请求头
+	var forwarded, defaultForwarded string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("X-Correlation-ID")
+		defaultForwarded = r.Header.Get(DefaultRequestIDHeader)
+	}))
+	defer upstream.Close()
+
+	r := gin.New()
+	r.Use(RequestID("x-correlation-id"))
+	r.GET("/api", func(c *gin.Context) {
+		req, _ := http.NewRequest("GET", upstream.URL, nil)
+		req.Header = c.Request.Header.Clone()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("upstream request failed: %v", err)
+			return
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		c.String(http.StatusOK, GetRequestID(c))
+	})
+
+	// 沿用客户端传入的ID
+	req := httptest.NewRequest("GET", "/api", nil)
+	req.Header.Set("X-Correlation-ID", "abc-123")
+	req.Header.Set(DefaultRequestIDHeader, "ignored")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("X-Correlation-ID"); got != "abc-123" {
+		t.Errorf("expected response echo abc-123, got %q", got)
+	}
+	if w.Header().Get(DefaultRequestIDHeader) != "" {
+		t.Error("default header should not be emitted when another name is configured")
+	}
+	if forwarded != "abc-123" || w.Body.String() != "abc-123" {
+		t.Errorf("expected abc-123 forwarded and in context, got %q / %q", forwarded, w.Body.String())
+	}
+	if defaultForwarded != "ignored" {
+		t.Errorf("unrelated headers should pass through unchanged, got %q", defaultForwarded)
+	}
+
+	// 缺失或不合法时生成新ID
+	for _, incoming := range []string{"", "bad id", strings.Repeat("x", maxRequestIDLength+1)} {
+		req = httptest.NewRequest("GET", "/api", nil)
+		if incoming != "" {
+			req.Header.Set("X-Correlation-ID", incoming)
+		}
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		id := w.Header().Get("X-Correlation-ID")
+		if len(id) != 32 || id == incoming || forwarded != id {
+			t.Errorf("incoming %q: expected generated id forwarded upstream, got %q (forwarded %q)", incoming, id, forwarded)
+		}
+	}
+}
+
+func TestRequestID_DefaultHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(""))
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if len(w.Header().Get(DefaultRequestIDHeader)) != 32 {
+		t.Errorf("expected generated %s, got %v", DefaultRequestIDHeader, w.Header())
+	}
+}
@@ -0,0 +1,95 @@
+package pages
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorData 错误页模板可用的字段
+type ErrorData struct {
+	Status     int    // 状态码
+	StatusText string // 状态码说明(如 Bad Gateway)
+	Message    string // 错误信息
+	Path       string // 请求路径
+}
+
+// ErrorPages 按状态码加载的HTML错误页模板(目录中的 404.html、502.html、503.html 等)
+type ErrorPages struct {
+	templates map[int]*template.Template
+}
+
+// LoadErrorPages 从目录加载错误页模板,文件名为状态码(4xx/5xx)加 .html,其余文件忽略
+func LoadErrorPages(dir string) (*ErrorPages, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := &ErrorPages{templates: make(map[int]*template.Template)}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".html")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		status, err := strconv.Atoi(name)
+		if err != nil || status < 400 || status > 599 {
+			continue
+		}
+		tmpl, err := template.ParseFiles(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("parse error page %s: %w", entry.Name(), err)
+		}
+		pages.templates[status] = tmpl
+	}
+	return pages, nil
+}
+
+// Len 返回已加载的模板数量
+func (e *ErrorPages) Len() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.templates)
+}
+
+// Render 输出错误响应: 浏览器客户端(Accept 优先 text/html)且存在对应模板时输出HTML,否则输出JSON
+// body 中的 "error" 字段作为模板的 Message
+func (e *ErrorPages) Render(c *gin.Context, status int, body gin.H) {
+	if page, ok := e.render(c, status, body); ok {
+		c.Data(status, "text/html; charset=utf-8", page)
+		return
+	}
+	c.JSON(status, body)
+}
+
+// render 渲染HTML错误页,不需要或渲染失败时返回false(回退为JSON)
+func (e *ErrorPages) render(c *gin.Context, status int, body gin.H) ([]byte, bool) {
+	if e == nil {
+		return nil, false
+	}
+	tmpl, ok := e.templates[status]
+	if !ok || c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		return nil, false
+	}
+
+	message, _ := body["error"].(string)
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, ErrorData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Message:    message,
+		Path:       c.Request.URL.Path,
+	})
+	if err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
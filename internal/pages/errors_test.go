@@ -0,0 +1,99 @@
+package pages
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func writeErrorPages(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadErrorPages(t *testing.T) {
+	dir := writeErrorPages(t, map[string]string{
+		"404.html":   `<h1>{{.Status}}</h1>`,
+		"502.html":   `<h1>{{.StatusText}}</h1>`,
+		"index.html": `ignored`,
+		"200.html":   `ignored`,
+		"503.txt":    `ignored`,
+	})
+	pages, err := LoadErrorPages(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages.Len() != 2 {
+		t.Errorf("expected 2 templates, got %d", pages.Len())
+	}
+
+	if _, err := LoadErrorPages(writeErrorPages(t, map[string]string{"503.html": `{{.Status`})); err == nil {
+		t.Error("expected parse error for invalid template")
+	}
+	if _, err := LoadErrorPages(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
+
+func TestErrorPages_Render(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pages, err := LoadErrorPages(writeErrorPages(t, map[string]string{
+		"502.html": `<h1>{{.Status}} {{.StatusText}}</h1><p>{{.Message}}</p><p>{{.Path}}</p>`,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(pages *ErrorPages, status int, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/x", nil)
+		if accept != "" {
+			c.Request.Header.Set("Accept", accept)
+		}
+		pages.Render(c, status, gin.H{"error": "upstream <failed>"})
+		return w
+	}
+
+	// 浏览器: HTML模板(内容转义)
+	w := render(pages, http.StatusBadGateway, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	if w.Code != http.StatusBadGateway || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected HTML 502, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	want := `<h1>502 Bad Gateway</h1><p>upstream &lt;failed&gt;</p><p>/api/x</p>`
+	if w.Body.String() != want {
+		t.Errorf("unexpected page:\n got %s\nwant %s", w.Body.String(), want)
+	}
+
+	// API客户端、无Accept、无对应模板、未配置模板: JSON
+	for _, tc := range []struct {
+		name   string
+		pages  *ErrorPages
+		status int
+		accept string
+	}{
+		{"json", pages, http.StatusBadGateway, "application/json"},
+		{"any", pages, http.StatusBadGateway, "*/*"},
+		{"none", pages, http.StatusBadGateway, ""},
+		{"noTemplate", pages, http.StatusNotFound, "text/html"},
+		{"nilPages", nil, http.StatusBadGateway, "text/html"},
+	} {
+		w := render(tc.pages, tc.status, tc.accept)
+		var body map[string]string
+		if w.Code != tc.status || json.Unmarshal(w.Body.Bytes(), &body) != nil || body["error"] != "upstream <failed>" {
+			t.Errorf("%s: expected JSON %d, got %d %s", tc.name, tc.status, w.Code, w.Body.String())
+		}
+	}
+}
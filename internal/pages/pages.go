@@ -0,0 +1,30 @@
+// Package pages 输出内置HTML页面,支持部署在路径前缀下
+package pages
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// baseTag 页面模板中的基准地址标签,页面内链接与请求均使用相对路径
+const baseTag = `<base href="/">`
+
+// Serve 输出页面,basePath 非空时将基准地址替换为部署前缀
+func Serve(c *gin.Context, file, basePath string) {
+	if basePath == "" {
+		c.File(file)
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	tag := `<base href="` + html.EscapeString(basePath) + `/">`
+	data = bytes.Replace(data, []byte(baseTag), []byte(tag), 1)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+}
@@ -0,0 +1,39 @@
+package pages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	file := filepath.Join(t.TempDir(), "index.html")
+	page := `<html><head><base href="/"><link href="static/css/styles.css"></head></html>`
+	if err := os.WriteFile(file, []byte(page), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		basePath string
+		want     string
+	}{
+		{"", `<base href="/">`},
+		{"/proxy-service", `<base href="/proxy-service/">`},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.GET("/", func(c *gin.Context) { Serve(c, file, tt.basePath) })
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("basePath=%q: expected %s, got %d %s", tt.basePath, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
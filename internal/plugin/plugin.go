@@ -0,0 +1,245 @@
+// Package plugin 基于 WebAssembly 的请求转换插件(wazero 运行时,纯Go实现)
+//
+// 插件为 WASI reactor 模块(如 Go 的 GOOS=wasip1 -buildmode=c-shared),需导出:
+//   - alloc(size i32) i32: 分配 size 字节供宿主写入请求JSON,返回其地址
+//   - transform(ptr i32, len i32) i64: 读取请求JSON并返回 (结果地址<<32 | 结果长度),
+//     结果为修改后的请求JSON;长度为0表示插件拒绝处理
+//
+// 插件在沙箱中运行: 无文件系统、网络、环境变量和命令行参数,时钟与随机数为确定性实现,
+// 内存受上限约束,单次调用超时后模块被强制终止。
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	// DefaultTimeout 单次转换的默认超时
+	DefaultTimeout = 100 * time.Millisecond
+	// DefaultMemoryLimitMB 单个插件实例的默认内存上限
+	DefaultMemoryLimitMB = 64
+
+	maxIdleInstances = 8  // 每个插件保留的空闲实例数
+	pageSize         = 16 // 每MB的 wasm 页数(64KiB/页)
+)
+
+// ErrRejected 插件返回空结果
+var ErrRejected = errors.New("plugin rejected the request")
+
+// Request 与插件交换的请求(JSON)
+// Path 为转发到目标的路径部分(映射前缀之后),插件可修改 Path、RawQuery 和 Headers
+type Request struct {
+	Method   string              `json:"method"`
+	Path     string              `json:"path"`
+	RawQuery string              `json:"raw_query,omitempty"`
+	Headers  map[string][]string `json:"headers"`
+}
+
+// Config 运行时配置
+type Config struct {
+	DefaultTimeout time.Duration // 映射未指定超时时使用(0表示 DefaultTimeout)
+	MemoryLimitMB  int           // 单个实例的内存上限(0表示 DefaultMemoryLimitMB)
+}
+
+// Runtime 插件运行时: 按文件缓存编译结果并复用实例(并发安全)
+type Runtime struct {
+	runtime        wazero.Runtime
+	defaultTimeout time.Duration
+
+	mu      sync.Mutex
+	modules map[string]*module // path -> 已编译模块
+}
+
+// module 已编译的插件及其空闲实例(文件变化时整体替换)
+type module struct {
+	compiled wazero.CompiledModule
+	modTime  time.Time
+	size     int64
+
+	mu      sync.Mutex
+	idle    []api.Module
+	retired bool
+}
+
+// NewRuntime 创建插件运行时
+func NewRuntime(ctx context.Context, cfg Config) *Runtime {
+	if cfg.DefaultTimeout <= 0 {
+		cfg.DefaultTimeout = DefaultTimeout
+	}
+	if cfg.MemoryLimitMB <= 0 {
+		cfg.MemoryLimitMB = DefaultMemoryLimitMB
+	}
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(cfg.MemoryLimitMB*pageSize)))
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+	return &Runtime{
+		runtime:        runtime,
+		defaultTimeout: cfg.DefaultTimeout,
+		modules:        make(map[string]*module),
+	}
+}
+
+// Close 释放全部插件
+func (rt *Runtime) Close(ctx context.Context) error {
+	return rt.runtime.Close(ctx)
+}
+
+// Transform 以 path 指定的插件转换请求,timeout 为0时使用默认超时
+// 插件文件更新后自动重新加载
+func (rt *Runtime) Transform(ctx context.Context, path string, timeout time.Duration, req *Request) error {
+	mod, err := rt.load(ctx, path)
+	if err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		timeout = rt.defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	instance, err := rt.acquire(ctx, mod)
+	if err != nil {
+		return err
+	}
+	defer mod.release(ctx, instance)
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	output, err := call(ctx, instance, input)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("plugin timed out after %v", timeout)
+		}
+		return err
+	}
+	var result Request
+	if err := json.Unmarshal(output, &result); err != nil {
+		return fmt.Errorf("plugin returned invalid JSON: %w", err)
+	}
+	*req = result
+	return nil
+}
+
+// load 返回插件的编译结果,首次使用或文件变化时(重新)编译
+func (rt *Runtime) load(ctx context.Context, path string) (*module, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if cached, ok := rt.modules[path]; ok {
+		if cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			return cached, nil
+		}
+		cached.retire(ctx)
+		delete(rt.modules, path)
+	}
+
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := rt.runtime.CompileModule(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("compile plugin: %w", err)
+	}
+	for _, name := range []string{"alloc", "transform"} {
+		if _, ok := compiled.ExportedFunctions()[name]; !ok {
+			compiled.Close(ctx)
+			return nil, fmt.Errorf("plugin does not export %q", name)
+		}
+	}
+	mod := &module{compiled: compiled, modTime: info.ModTime(), size: info.Size()}
+	rt.modules[path] = mod
+	return mod, nil
+}
+
+// acquire 取出空闲实例,没有时新建(reactor 模块在 _initialize 中完成初始化)
+func (rt *Runtime) acquire(ctx context.Context, mod *module) (api.Module, error) {
+	mod.mu.Lock()
+	if n := len(mod.idle); n > 0 {
+		instance := mod.idle[n-1]
+		mod.idle = mod.idle[:n-1]
+		mod.mu.Unlock()
+		return instance, nil
+	}
+	mod.mu.Unlock()
+
+	// 不挂载文件系统、不传入参数和环境变量;名称留空以允许同一模块多个实例
+	instance, err := rt.runtime.InstantiateModule(ctx, mod.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, fmt.Errorf("instantiate plugin: %w", err)
+	}
+	return instance, nil
+}
+
+// release 归还实例;已终止(超时或异常)、空闲已满或插件已替换时关闭实例
+func (mod *module) release(ctx context.Context, instance api.Module) {
+	if instance.IsClosed() {
+		return
+	}
+	mod.mu.Lock()
+	if !mod.retired && len(mod.idle) < maxIdleInstances {
+		mod.idle = append(mod.idle, instance)
+		mod.mu.Unlock()
+		return
+	}
+	mod.mu.Unlock()
+	instance.Close(context.WithoutCancel(ctx))
+}
+
+// retire 关闭空闲实例和编译结果(使用中的实例归还时关闭)
+func (mod *module) retire(ctx context.Context) {
+	mod.mu.Lock()
+	idle := mod.idle
+	mod.idle, mod.retired = nil, true
+	mod.mu.Unlock()
+	for _, instance := range idle {
+		instance.Close(ctx)
+	}
+	mod.compiled.Close(ctx)
+}
+
+// call 将输入写入插件内存并调用 transform,返回结果的副本
+func call(ctx context.Context, instance api.Module, input []byte) ([]byte, error) {
+	results, err := instance.ExportedFunction("alloc").Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("plugin alloc: %w", err)
+	}
+	ptr := uint32(results[0])
+	if !instance.Memory().Write(ptr, input) {
+		return nil, errors.New("plugin alloc returned an out-of-range pointer")
+	}
+
+	results, err = instance.ExportedFunction("transform").Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("plugin transform: %w", err)
+	}
+	outPtr, outLen := uint32(results[0]>>32), uint32(results[0])
+	if outLen == 0 {
+		return nil, ErrRejected
+	}
+	output, ok := instance.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, errors.New("plugin returned an out-of-range result")
+	}
+	// Read 返回的是实例内存的视图,实例复用前复制
+	return append([]byte(nil), output...), nil
+}
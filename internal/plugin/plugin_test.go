@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildExample 编译 examples/plugins/addheader 为 wasm(输出到测试临时目录,测试结束后自动清理)
+func buildExample(t *testing.T) string {
+	t.Helper()
+	goBin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(goBin); err != nil {
+		t.Skipf("cannot build example plugin: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "addheader.wasm")
+	cmd := exec.Command(goBin, "build", "-buildmode=c-shared", "-o", path, "../../examples/plugins/addheader")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build example plugin: %s", out)
+	}
+	return path
+}
+
+// rawModule 手写的最小插件: alloc 返回0,transform 函数体由调用方提供(无局部变量)
+func rawModule(transformBody ...byte) []byte {
+	module := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		// type: (i32)->i32, (i32,i32)->i64
+		0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e,
+		// function: alloc=type0, transform=type1
+		0x03, 0x03, 0x02, 0x00, 0x01,
+		// memory: 1 page
+		0x05, 0x03, 0x01, 0x00, 0x01,
+		// export: memory, alloc, transform
+		0x07, 0x1e, 0x03,
+		0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+		0x05, 'a', 'l', 'l', 'o', 'c', 0x00, 0x00,
+		0x09, 't', 'r', 'a', 'n', 's', 'f', 'o', 'r', 'm', 0x00, 0x01,
+	}
+	body := append([]byte{0x00}, transformBody...)
+	code := []byte{0x02, 0x04, 0x00, 0x41, 0x00, 0x0b, byte(len(body))}
+	code = append(code, body...)
+	module = append(module, 0x0a, byte(len(code)))
+	return append(module, code...)
+}
+
+func writeModule(t *testing.T, code []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin.wasm")
+	if err := os.WriteFile(path, code, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRuntime_TransformExample(t *testing.T) {
+	path := buildExample(t)
+	ctx := context.Background()
+	rt := NewRuntime(ctx, Config{DefaultTimeout: 5 * time.Second})
+	defer rt.Close(ctx)
+
+	// 多次调用验证实例复用
+	for i := 0; i < 3; i++ {
+		req := &Request{
+			Method:   "GET",
+			Path:     "/v1/models",
+			RawQuery: "a=1",
+			Headers:  map[string][]string{"Accept": {"application/json"}},
+		}
+		if err := rt.Transform(ctx, path, 0, req); err != nil {
+			t.Fatalf("Transform: %v", err)
+		}
+		if req.Path != "/v2/models" {
+			t.Errorf("path = %q, want /v2/models", req.Path)
+		}
+		if got := req.Headers["X-Plugin"]; len(got) != 1 || got[0] != "addheader" {
+			t.Errorf("X-Plugin = %v", got)
+		}
+		if got := req.Headers["Accept"]; len(got) != 1 || got[0] != "application/json" {
+			t.Errorf("Accept = %v, want preserved", got)
+		}
+		if req.RawQuery != "a=1" || req.Method != "GET" {
+			t.Errorf("unexpected request %+v", req)
+		}
+	}
+}
+
+func TestRuntime_Timeout(t *testing.T) {
+	// transform: loop { br 0 }; unreachable
+	path := writeModule(t, rawModule(0x03, 0x40, 0x0c, 0x00, 0x0b, 0x00, 0x0b))
+	ctx := context.Background()
+	rt := NewRuntime(ctx, Config{})
+	defer rt.Close(ctx)
+
+	start := time.Now()
+	err := rt.Transform(ctx, path, 50*time.Millisecond, &Request{Path: "/"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout took %v", elapsed)
+	}
+}
+
+func TestRuntime_Rejected(t *testing.T) {
+	// transform: i64.const 0
+	path := writeModule(t, rawModule(0x42, 0x00, 0x0b))
+	ctx := context.Background()
+	rt := NewRuntime(ctx, Config{})
+	defer rt.Close(ctx)
+
+	if err := rt.Transform(ctx, path, 0, &Request{Path: "/"}); !errors.Is(err, ErrRejected) {
+		t.Fatalf("err = %v, want ErrRejected", err)
+	}
+}
+
+func TestRuntime_InvalidModule(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRuntime(ctx, Config{})
+	defer rt.Close(ctx)
+
+	if err := rt.Transform(ctx, writeModule(t, []byte("not wasm")), 0, &Request{}); err == nil {
+		t.Error("expected compile error")
+	}
+	if err := rt.Transform(ctx, filepath.Join(t.TempDir(), "missing.wasm"), 0, &Request{}); err == nil {
+		t.Error("expected error for missing file")
+	}
+	// 缺少 transform 导出
+	noTransform := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	if err := rt.Transform(ctx, writeModule(t, noTransform), 0, &Request{}); err == nil || !strings.Contains(err.Error(), "does not export") {
+		t.Errorf("err = %v, want missing export", err)
+	}
+}
+
+func TestRuntime_ReloadsChangedFile(t *testing.T) {
+	ctx := context.Background()
+	rt := NewRuntime(ctx, Config{DefaultTimeout: time.Second})
+	defer rt.Close(ctx)
+
+	path := writeModule(t, rawModule(0x42, 0x00, 0x0b))
+	if err := rt.Transform(ctx, path, 0, &Request{}); !errors.Is(err, ErrRejected) {
+		t.Fatalf("err = %v, want ErrRejected", err)
+	}
+
+	// 替换为返回非法结果的插件: transform 返回 (0<<32 | 1),内存0处为0字节
+	if err := os.WriteFile(path, rawModule(0x42, 0x01, 0x0b), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(path, future, future)
+	err := rt.Transform(ctx, path, 0, &Request{})
+	if err == nil || errors.Is(err, ErrRejected) || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Fatalf("err = %v, want reloaded plugin's invalid JSON error", err)
+	}
+}
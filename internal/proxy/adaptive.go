@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// EventTimeoutTightened 目标变慢,有效超时收紧到下限
+const EventTimeoutTightened = "timeout_tightened"
+
+// EventTimeoutRestored 目标恢复,有效超时恢复为配置值
+const EventTimeoutRestored = "timeout_restored"
+
+// adaptiveTimeout 按目标(映射前缀,多目标映射为前缀加所选目标)跟踪最近的上游响应耗时(到响应头),p95 超过 slow 时将该目标的有效超时收紧到 floor,
+// 快速失败以减轻慢目标的负载;收紧期间最近请求的 p95 低于 floor(至少95%在收紧后的超时内完成)时恢复
+type adaptiveTimeout struct {
+	slow   time.Duration
+	floor  time.Duration
+	window int
+
+	mu      sync.Mutex
+	targets map[string]*latencyWindow
+}
+
+// latencyWindow 目标最近的响应耗时(环形缓冲)
+type latencyWindow struct {
+	samples   []time.Duration
+	next      int
+	tightened bool
+}
+
+// newAdaptiveTimeout 创建自适应超时(slow<=0 时返回nil,表示禁用)
+// floor 不小于 slow 时无法收紧,同样视为禁用
+func newAdaptiveTimeout(slow, floor time.Duration, window int) *adaptiveTimeout {
+	if slow <= 0 || floor <= 0 || floor >= slow {
+		return nil
+	}
+	return &adaptiveTimeout{
+		slow:    slow,
+		floor:   floor,
+		window:  max(window, 1),
+		targets: make(map[string]*latencyWindow),
+	}
+}
+
+// limit 返回目标当前的超时上限(0表示不限制,使用配置的超时)
+func (a *adaptiveTimeout) limit(target string) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if w := a.targets[target]; w != nil && w.tightened {
+		return a.floor
+	}
+	return 0
+}
+
+// observe 记录一次耗时,收紧或恢复时返回对应事件
+// 状态切换后清空窗口,下一次判断只基于切换后的请求
+func (a *adaptiveTimeout) observe(target string, latency time.Duration) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	w := a.targets[target]
+	if w == nil {
+		w = &latencyWindow{samples: make([]time.Duration, 0, a.window)}
+		a.targets[target] = w
+	}
+	if len(w.samples) < a.window {
+		w.samples = append(w.samples, latency)
+	} else {
+		w.samples[w.next] = latency
+	}
+	w.next = (w.next + 1) % a.window
+	if len(w.samples) < a.window {
+		return ""
+	}
+
+	p95 := percentile95(w.samples)
+	switch {
+	case !w.tightened && p95 > a.slow:
+		w.tightened = true
+	case w.tightened && p95 < a.floor:
+		w.tightened = false
+	default:
+		return ""
+	}
+	w.samples, w.next = w.samples[:0], 0
+	if w.tightened {
+		return EventTimeoutTightened
+	}
+	return EventTimeoutRestored
+}
+
+// percentile95 返回样本的第95百分位(最近秩)
+func percentile95(samples []time.Duration) time.Duration {
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	rank := (len(sorted)*95 + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeout_TightenAndRestore(t *testing.T) {
+	a := newAdaptiveTimeout(time.Second, 200*time.Millisecond, 4)
+
+	for range 3 {
+		if event := a.observe("t", 2*time.Second); event != "" {
+			t.Fatalf("should not change before the window is full, got %q", event)
+		}
+	}
+	if event := a.observe("t", 2*time.Second); event != EventTimeoutTightened {
+		t.Fatalf("expected tightening once p95 exceeds the threshold, got %q", event)
+	}
+	if got := a.limit("t"); got != 200*time.Millisecond {
+		t.Fatalf("expected limit to shrink to the floor, got %v", got)
+	}
+	if got := a.limit("other"); got != 0 {
+		t.Errorf("other targets should be unaffected, got %v", got)
+	}
+
+	// 仍有请求超时(按收紧后的超时计入)时保持收紧
+	for _, latency := range []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond, 200 * time.Millisecond} {
+		a.observe("t", latency)
+	}
+	if a.limit("t") == 0 {
+		t.Fatal("should stay tightened while requests still hit the floor")
+	}
+	for range 4 {
+		a.observe("t", 10*time.Millisecond)
+	}
+	if got := a.limit("t"); got != 0 {
+		t.Fatalf("expected limit to be restored after recovery, got %v", got)
+	}
+}
+
+func TestNewAdaptiveTimeout_Disabled(t *testing.T) {
+	if newAdaptiveTimeout(0, time.Second, 10) != nil {
+		t.Error("zero threshold should disable adaptive timeouts")
+	}
+	if newAdaptiveTimeout(time.Second, time.Second, 10) != nil {
+		t.Error("floor not below the threshold should disable adaptive timeouts")
+	}
+}
+
+func TestTransparentProxy_AdaptiveTimeout(t *testing.T) {
+	var delay atomic.Int64
+	delay.Store(int64(80 * time.Millisecond))
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Duration(delay.Load())):
+			w.Write([]byte("ok"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	collector := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, collector)
+	proxy.adaptive = newAdaptiveTimeout(50*time.Millisecond, 30*time.Millisecond, 3)
+
+	do := func() error {
+		req := httptest.NewRequest("GET", "http://proxy.example/api/x", nil)
+		return proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/x")
+	}
+
+	// 目标变慢: 窗口填满后有效超时收紧,后续慢请求快速失败
+	for range 3 {
+		if err := do(); err != nil {
+			t.Fatalf("slow requests should succeed before tightening, got %v", err)
+		}
+	}
+	if got := proxy.adaptive.limit("/api"); got != 30*time.Millisecond {
+		t.Fatalf("expected effective timeout to shrink, got %v", got)
+	}
+	if err := do(); !errors.Is(err, ErrUpstreamTimeout) {
+		t.Fatalf("expected slow request to fail fast with 504, got %v", err)
+	}
+
+	// 目标恢复: 快速请求填满窗口后超时恢复
+	delay.Store(0)
+	for range 3 {
+		if err := do(); err != nil {
+			t.Fatalf("fast requests should succeed, got %v", err)
+		}
+	}
+	if got := proxy.adaptive.limit("/api"); got != 0 {
+		t.Fatalf("expected effective timeout to be restored, got %v", got)
+	}
+	if !slices.Contains(collector.events, EventTimeoutTightened) || !slices.Contains(collector.events, EventTimeoutRestored) {
+		t.Errorf("expected tighten and restore events, got %v", collector.events)
+	}
+}
+
+func TestTransparentProxy_AdaptiveTimeoutKeyedByMapping(t *testing.T) {
+	pattern := "~^/t/(?P<tenant>[^/]+)/api"
+	mapper := &MockMappingManager{mappings: map[string]string{pattern: "https://backend.com/tenants/$tenant"}}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.adaptive = newAdaptiveTimeout(50*time.Millisecond, 30*time.Millisecond, 3)
+	proxy.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: http.NoBody}, nil
+	})}
+
+	// 客户端可控的捕获值不能产生新的跟踪状态
+	for i := range 20 {
+		req := httptest.NewRequest("GET", fmt.Sprintf("http://localhost/t/tenant%d/api/x", i), nil)
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, pattern, "/x"); err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+	}
+	if n := len(proxy.adaptive.targets); n != 1 {
+		t.Errorf("expected latency tracked once per mapping, got %d entries", n)
+	}
+}
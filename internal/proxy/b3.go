@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+)
+
+// B3 (Zipkin) 传播头
+const (
+	B3TraceIDHeader      = "X-B3-TraceId"
+	B3SpanIDHeader       = "X-B3-SpanId"
+	B3ParentSpanIDHeader = "X-B3-ParentSpanId"
+	B3SampledHeader      = "X-B3-Sampled"
+	B3FlagsHeader        = "X-B3-Flags"
+)
+
+// propagateB3 为上游请求创建子 span: 沿用 TraceId,以客户端 SpanId 作为父 span 并生成新 SpanId
+// 客户端未携带有效 TraceId 时开始新的 trace;Sampled/Flags 采样决策原样传递
+// 仅处理 X-B3-* 头部,不影响其他追踪头(如 traceparent)的透传
+func propagateB3(dst, src http.Header) {
+	traceID := src.Get(B3TraceIDHeader)
+	parentID := src.Get(B3SpanIDHeader)
+	if !validB3ID(traceID, 16, 32) || !validB3ID(parentID, 16) {
+		traceID, parentID = newB3ID()+newB3ID(), ""
+	}
+
+	dst.Set(B3TraceIDHeader, traceID)
+	dst.Set(B3SpanIDHeader, newB3ID())
+	if parentID != "" {
+		dst.Set(B3ParentSpanIDHeader, parentID)
+	} else {
+		dst.Del(B3ParentSpanIDHeader)
+	}
+}
+
+// validB3ID 判断是否为指定长度的小写十六进制ID(且不全为0)
+func validB3ID(id string, lengths ...int) bool {
+	valid := false
+	for _, n := range lengths {
+		valid = valid || len(id) == n
+	}
+	if !valid {
+		return false
+	}
+	zero := true
+	for _, c := range id {
+		switch {
+		case c == '0':
+		case c >= '1' && c <= '9', c >= 'a' && c <= 'f':
+			zero = false
+		default:
+			return false
+		}
+	}
+	return !zero
+}
+
+// newB3ID 生成64位的非零 span ID(16位十六进制)
+func newB3ID() string {
+	return fmt.Sprintf("%016x", rand.Uint64N(^uint64(0))+1)
+}
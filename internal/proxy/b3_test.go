@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransparentProxy_B3Propagation(t *testing.T) {
+	var upstream http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	proxy := NewTransparentProxy(&MockMappingManager{mappings: map[string]string{"/api": backend.URL}}, nil)
+	do := func(header http.Header) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://localhost/api/x", nil)
+		req.Header = header
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	incoming := http.Header{}
+	incoming.Set(B3TraceIDHeader, "463ac35c9f6413ad48485a3953bb6124")
+	incoming.Set(B3SpanIDHeader, "a2fb4a1d1a96d312")
+	incoming.Set(B3SampledHeader, "1")
+	incoming.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	// 未启用时原样透传
+	do(incoming.Clone())
+	if upstream.Get(B3SpanIDHeader) != "a2fb4a1d1a96d312" || upstream.Get(B3ParentSpanIDHeader) != "" {
+		t.Fatalf("expected B3 headers untouched when disabled, got %v", upstream)
+	}
+
+	// 启用后创建子 span
+	proxy.traceB3 = true
+	do(incoming.Clone())
+	if upstream.Get(B3TraceIDHeader) != "463ac35c9f6413ad48485a3953bb6124" {
+		t.Errorf("expected trace id preserved, got %q", upstream.Get(B3TraceIDHeader))
+	}
+	if upstream.Get(B3ParentSpanIDHeader) != "a2fb4a1d1a96d312" {
+		t.Errorf("expected client span as parent, got %q", upstream.Get(B3ParentSpanIDHeader))
+	}
+	spanID := upstream.Get(B3SpanIDHeader)
+	if !validB3ID(spanID, 16) || spanID == "a2fb4a1d1a96d312" {
+		t.Errorf("expected new span id, got %q", spanID)
+	}
+	if upstream.Get(B3SampledHeader) != "1" {
+		t.Errorf("expected sampling decision forwarded, got %q", upstream.Get(B3SampledHeader))
+	}
+	if upstream.Get("Traceparent") != incoming.Get("Traceparent") {
+		t.Errorf("expected W3C traceparent untouched, got %q", upstream.Get("Traceparent"))
+	}
+
+	// 未携带或携带无效 TraceId 时开始新的 trace
+	invalid := http.Header{}
+	invalid.Set(B3TraceIDHeader, "not-hex")
+	invalid.Set(B3SpanIDHeader, "a2fb4a1d1a96d312")
+	invalid.Set(B3ParentSpanIDHeader, "a2fb4a1d1a96d312")
+	for _, header := range []http.Header{{}, invalid} {
+		do(header)
+		if !validB3ID(upstream.Get(B3TraceIDHeader), 32) || !validB3ID(upstream.Get(B3SpanIDHeader), 16) {
+			t.Errorf("expected new trace, got %v", upstream)
+		}
+		if upstream.Get(B3ParentSpanIDHeader) != "" {
+			t.Errorf("root span should not have a parent, got %q", upstream.Get(B3ParentSpanIDHeader))
+		}
+	}
+}
+
+func TestValidB3ID(t *testing.T) {
+	tests := []struct {
+		id      string
+		lengths []int
+		want    bool
+	}{
+		{"a2fb4a1d1a96d312", []int{16}, true},
+		{"463ac35c9f6413ad48485a3953bb6124", []int{16, 32}, true},
+		{"463ac35c9f6413ad", []int{16, 32}, true},
+		{"0000000000000000", []int{16}, false},
+		{"A2FB4A1D1A96D312", []int{16}, false},
+		{"a2fb4a1d1a96d31", []int{16}, false},
+		{"", []int{16}, false},
+	}
+	for _, tt := range tests {
+		if got := validB3ID(tt.id, tt.lengths...); got != tt.want {
+			t.Errorf("validB3ID(%q, %v) = %v, want %v", tt.id, tt.lengths, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"api-proxy/internal/mapping"
+)
+
+// EventNoHealthyUpstream 多目标映射的全部目标均不健康而拒绝的请求
+const EventNoHealthyUpstream = "no_healthy_upstream"
+
+// ErrNoHealthyUpstream 多目标映射的全部目标均未通过主动健康检查
+var ErrNoHealthyUpstream = errors.New("no healthy upstream available")
+
+// HealthSource 目标健康状态查询接口(依赖倒置)
+type HealthSource interface {
+	Healthy(target string) bool
+}
+
+// SetHealthSource 设置主动健康检查结果来源(nil表示不跳过任何目标)
+func (p *TransparentProxy) SetHealthSource(source HealthSource) {
+	p.health = source
+}
+
+// selector 多目标映射的目标选择器,返回目标下标(并发安全)
+// key 为请求的哈希键,仅一致性哈希使用
+type selector interface {
+	next(key string) int
+}
+
+// newSelector 按策略创建选择器
+func newSelector(strategy string, upstreams []mapping.Upstream) selector {
+	weights := make([]int, len(upstreams))
+	for i, u := range upstreams {
+		weights[i] = u.EffectiveWeight()
+	}
+	switch strategy {
+	case mapping.StrategyWRR:
+		return newSmoothWRR(weights)
+	case mapping.StrategyRoundRobin:
+		return &roundRobin{n: uint64(len(weights))}
+	case mapping.StrategyHash:
+		return newHashRing(upstreams, weights)
+	default:
+		return newWeightedRandom(weights)
+	}
+}
+
+// smoothWRR 平滑加权轮询(nginx 算法): 每轮各目标累加自身权重,选出当前值最大者并减去总权重
+// 权重 {5,1,1} 的选择顺序为 a a b a c a a,高权重目标不会连续集中出现
+type smoothWRR struct {
+	mu      sync.Mutex
+	weights []int
+	current []int
+	total   int
+}
+
+func newSmoothWRR(weights []int) *smoothWRR {
+	s := &smoothWRR{weights: weights, current: make([]int, len(weights))}
+	for _, w := range weights {
+		s.total += w
+	}
+	return s
+}
+
+func (s *smoothWRR) next(string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best := 0
+	for i, w := range s.weights {
+		s.current[i] += w
+		if s.current[i] > s.current[best] {
+			best = i
+		}
+	}
+	s.current[best] -= s.total
+	return best
+}
+
+// roundRobin 忽略权重依次轮询
+type roundRobin struct {
+	n       uint64
+	counter atomic.Uint64
+}
+
+func (r *roundRobin) next(string) int {
+	return int((r.counter.Add(1) - 1) % r.n)
+}
+
+// weightedRandom 按权重随机选择
+type weightedRandom struct {
+	cumulative []int // 权重前缀和
+}
+
+func newWeightedRandom(weights []int) *weightedRandom {
+	cumulative := make([]int, len(weights))
+	sum := 0
+	for i, w := range weights {
+		sum += w
+		cumulative[i] = sum
+	}
+	return &weightedRandom{cumulative: cumulative}
+}
+
+func (w *weightedRandom) next(string) int {
+	n := rand.IntN(w.cumulative[len(w.cumulative)-1])
+	for i, bound := range w.cumulative {
+		if n < bound {
+			return i
+		}
+	}
+	return len(w.cumulative) - 1
+}
+
+// hashRingReplicas 每单位权重在哈希环上的虚拟节点数(越多分布越均匀)
+const hashRingReplicas = 160
+
+// maxHashRingNodes 哈希环的虚拟节点总数上限,总权重较大时按比例减少每单位权重的节点数
+const maxHashRingNodes = 1 << 16
+
+// hashRing 一致性哈希环: 虚拟节点按目标URL计算位置,增删目标只影响相邻区间的键
+type hashRing struct {
+	points  []uint64 // 升序的虚拟节点位置
+	targets []int    // 与 points 对应的目标下标
+}
+
+func newHashRing(upstreams []mapping.Upstream, weights []int) *hashRing {
+	type node struct {
+		point  uint64
+		target int
+	}
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	replicas := hashRingReplicas
+	if total*replicas > maxHashRingNodes {
+		replicas = max(1, maxHashRingNodes/total)
+	}
+
+	nodes := make([]node, 0, total*replicas)
+	for i, u := range upstreams {
+		for replica := range weights[i] * replicas {
+			nodes = append(nodes, node{hashString(u.URL + "#" + strconv.Itoa(replica)), i})
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].point < nodes[j].point })
+
+	ring := &hashRing{points: make([]uint64, len(nodes)), targets: make([]int, len(nodes))}
+	for i, n := range nodes {
+		ring.points[i], ring.targets[i] = n.point, n.target
+	}
+	return ring
+}
+
+// next 返回键在环上顺时针方向的第一个虚拟节点对应的目标
+func (h *hashRing) next(key string) int {
+	point := hashString(key)
+	i := sort.Search(len(h.points), func(i int) bool { return h.points[i] >= point })
+	if i == len(h.points) {
+		i = 0
+	}
+	return h.targets[i]
+}
+
+// nextHealthy 从键的位置顺时针查找第一个健康目标,只有原本落在不健康目标上的键会迁移(到环上的下一个健康目标)
+// 没有健康目标时返回 false
+func (h *hashRing) nextHealthy(key string, healthy func(target int) bool) (int, bool) {
+	point := hashString(key)
+	start := sort.Search(len(h.points), func(i int) bool { return h.points[i] >= point })
+	checked := make(map[int]bool)
+	for n := range len(h.points) {
+		target := h.targets[(start+n)%len(h.points)]
+		ok, seen := checked[target]
+		if !seen {
+			ok = healthy(target)
+			checked[target] = ok
+		}
+		if ok {
+			return target, true
+		}
+	}
+	return 0, false
+}
+
+// hashString FNV-1a 64位哈希,末尾再做一次混合使相近字符串的位置分散
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	return x
+}
+
+// hashKey 返回请求的一致性哈希键(header:<名称> 的请求头缺失时使用路径)
+func hashKey(r *http.Request, opts mapping.Options) string {
+	if name := mapping.HashKeyHeader(opts.HashKey); name != "" {
+		if value := r.Header.Get(name); value != "" {
+			return value
+		}
+	}
+	return r.URL.Path
+}
+
+// balancerEntry 映射的选择器及其对应的配置(配置变化时重建,轮询状态随之重置)
+type balancerEntry struct {
+	signature string
+	selector  selector
+}
+
+// selectUpstream 为多目标映射选择本次请求的目标,未配置多目标时返回 false
+// 选中的目标不健康时在健康目标中按权重重新选择,全部不健康时返回 502 错误
+func (p *TransparentProxy) selectUpstream(r *http.Request, prefix string, opts mapping.Options) (string, bool, error) {
+	if len(opts.Upstreams) == 0 {
+		return "", false, nil
+	}
+	signature := fmt.Sprint(opts.Strategy, opts.Upstreams)
+	cached, ok := p.balancers.Load(prefix)
+	if !ok || cached.(*balancerEntry).signature != signature {
+		entry := &balancerEntry{signature: signature, selector: newSelector(opts.Strategy, opts.Upstreams)}
+		if ok {
+			// 配置已更新: 替换旧选择器(并发请求可能各自创建,保留任意一个即可)
+			p.balancers.Store(prefix, entry)
+			cached = entry
+		} else {
+			cached, _ = p.balancers.LoadOrStore(prefix, entry)
+		}
+	}
+	var key string
+	if opts.Strategy == mapping.StrategyHash {
+		key = hashKey(r, opts)
+	}
+	sel := cached.(*balancerEntry).selector
+	chosen := sel.next(key)
+	if p.health == nil || p.health.Healthy(opts.Upstreams[chosen].URL) {
+		return opts.Upstreams[chosen].URL, true, nil
+	}
+	var (
+		i       int
+		healthy bool
+	)
+	if ring, ok := sel.(*hashRing); ok {
+		i, healthy = ring.nextHealthy(key, func(target int) bool { return p.health.Healthy(opts.Upstreams[target].URL) })
+	} else {
+		i, healthy = pickHealthy(opts.Upstreams, opts.Strategy, p.health)
+	}
+	if healthy {
+		return opts.Upstreams[i].URL, true, nil
+	}
+	return "", true, &Error{StatusCode: http.StatusBadGateway, Err: ErrNoHealthyUpstream}
+}
+
+// pickHealthy 按权重在健康目标中随机选择(轮询策略视各目标权重相同),避免不健康目标的流量全部落到其后一个目标
+// 一致性哈希不经过此处,由 hashRing.nextHealthy 沿环查找;没有健康目标时返回 false
+func pickHealthy(upstreams []mapping.Upstream, strategy string, health HealthSource) (int, bool) {
+	healthy := make([]int, 0, len(upstreams))
+	cumulative := make([]int, 0, len(upstreams))
+	total := 0
+	for i, u := range upstreams {
+		if !health.Healthy(u.URL) {
+			continue
+		}
+		weight := u.EffectiveWeight()
+		if strategy == mapping.StrategyRoundRobin {
+			weight = 1
+		}
+		total += weight
+		healthy = append(healthy, i)
+		cumulative = append(cumulative, total)
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	i := sort.SearchInts(cumulative, rand.IntN(total)+1)
+	return healthy[i], true
+}
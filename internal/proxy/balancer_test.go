@@ -0,0 +1,379 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestSmoothWRR_Sequence(t *testing.T) {
+	tests := []struct {
+		weights []int
+		want    []int
+	}{
+		{[]int{5, 1, 1}, []int{0, 0, 1, 0, 2, 0, 0}},
+		{[]int{2, 1}, []int{0, 1, 0}},
+		{[]int{1, 1, 1}, []int{0, 1, 2}},
+		{[]int{3, 2}, []int{0, 1, 0, 1, 0}},
+	}
+	for _, tt := range tests {
+		s := newSmoothWRR(tt.weights)
+		// 连续两个周期的顺序一致
+		for round := 0; round < 2; round++ {
+			got := make([]int, len(tt.want))
+			for i := range got {
+				got[i] = s.next("")
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("weights %v round %d: got %v, want %v", tt.weights, round, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestRoundRobinAndWeightedRandom(t *testing.T) {
+	rr := newSelector(mapping.StrategyRoundRobin, []mapping.Upstream{{Weight: 5}, {Weight: 1}, {Weight: 1}})
+	var got []int
+	for range 6 {
+		got = append(got, rr.next(""))
+	}
+	if !slices.Equal(got, []int{0, 1, 2, 0, 1, 2}) {
+		t.Errorf("round robin should ignore weights, got %v", got)
+	}
+
+	random := newSelector(mapping.StrategyRandom, []mapping.Upstream{{Weight: 3}, {Weight: 1}, {Weight: 0}})
+	counts := make([]int, 3)
+	for range 10000 {
+		counts[random.next("")]++
+	}
+	// 期望比例 3:1:1(权重0按1处理)
+	if counts[0] < 5000 || counts[0] > 7000 || counts[1] < 1400 || counts[2] < 1400 {
+		t.Errorf("unexpected weighted random distribution: %v", counts)
+	}
+}
+
+func TestTransparentProxy_MultiTargetWRR(t *testing.T) {
+	var hits []string
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+		}))
+	}
+	a, b := newBackend("a"), newBackend("b")
+	defer a.Close()
+	defer b.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": "http://unused.invalid"},
+		options: map[string]mapping.Options{"/api": {
+			Strategy:  mapping.StrategyWRR,
+			Upstreams: []mapping.Upstream{{URL: a.URL, Weight: 2}, {URL: b.URL, Weight: 1}},
+		}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	send := func(n int) {
+		for range n {
+			w := httptest.NewRecorder()
+			if err := proxy.ProxyRequest(w, httptest.NewRequest("GET", "http://localhost/api/x", nil), "/api", "/x"); err != nil {
+				t.Fatalf("ProxyRequest failed: %v", err)
+			}
+		}
+	}
+	send(6)
+	if want := []string{"a", "b", "a", "a", "b", "a"}; !slices.Equal(hits, want) {
+		t.Errorf("got %v, want %v", hits, want)
+	}
+
+	// 配置变化后按新权重重新开始
+	hits = nil
+	mapper.options["/api"] = mapping.Options{
+		Strategy:  mapping.StrategyWRR,
+		Upstreams: []mapping.Upstream{{URL: a.URL, Weight: 1}, {URL: b.URL, Weight: 2}},
+	}
+	send(3)
+	if want := []string{"b", "a", "b"}; !slices.Equal(hits, want) {
+		t.Errorf("after reconfiguration got %v, want %v", hits, want)
+	}
+}
+
+func TestHashRing_StableAcrossTargetChanges(t *testing.T) {
+	upstreams := []mapping.Upstream{
+		{URL: "http://a.internal"}, {URL: "http://b.internal"},
+		{URL: "http://c.internal"}, {URL: "http://d.internal"},
+	}
+	ring := newSelector(mapping.StrategyHash, upstreams)
+
+	const keys = 10000
+	before := make([]string, keys)
+	counts := map[string]int{}
+	for i := range keys {
+		key := "/v1/items/" + strconv.Itoa(i)
+		before[i] = upstreams[ring.next(key)].URL
+		counts[before[i]]++
+		// 同一键始终命中同一目标
+		if again := upstreams[ring.next(key)].URL; again != before[i] {
+			t.Fatalf("key %q moved from %s to %s", key, before[i], again)
+		}
+	}
+	for _, u := range upstreams {
+		if counts[u.URL] < keys/4*7/10 {
+			t.Errorf("uneven distribution: %v", counts)
+			break
+		}
+	}
+
+	// 新增目标: 只有约 1/5 的键迁移,且只迁移到新目标
+	added := append(slices.Clone(upstreams), mapping.Upstream{URL: "http://e.internal"})
+	grown := newSelector(mapping.StrategyHash, added)
+	moved := 0
+	for i := range keys {
+		after := added[grown.next("/v1/items/"+strconv.Itoa(i))].URL
+		if after != before[i] {
+			moved++
+			if after != "http://e.internal" {
+				t.Fatalf("key %d moved between existing targets: %s -> %s", i, before[i], after)
+			}
+		}
+	}
+	if moved < keys/10 || moved > keys*3/10 {
+		t.Errorf("adding a target remapped %d of %d keys, want about 20%%", moved, keys)
+	}
+
+	// 移除目标: 只有原本在该目标上的键迁移
+	removed := []mapping.Upstream{upstreams[0], upstreams[1], upstreams[3]}
+	shrunk := newSelector(mapping.StrategyHash, removed)
+	for i := range keys {
+		key := "/v1/items/" + strconv.Itoa(i)
+		if before[i] != "http://c.internal" && removed[shrunk.next(key)].URL != before[i] {
+			t.Fatalf("key %q moved although its target %s remains", key, before[i])
+		}
+	}
+}
+
+func TestHashRing_NextHealthyMovesOnlyUnhealthyKeys(t *testing.T) {
+	upstreams := []mapping.Upstream{
+		{URL: "http://a.internal"}, {URL: "http://b.internal"},
+		{URL: "http://c.internal"}, {URL: "http://d.internal"},
+	}
+	ring := newHashRing(upstreams, []int{1, 1, 1, 1})
+	const down = 2
+	healthy := func(target int) bool { return target != down }
+
+	const keys = 10000
+	spread := map[int]int{}
+	for i := range keys {
+		key := "/v1/items/" + strconv.Itoa(i)
+		before := ring.next(key)
+		after, ok := ring.nextHealthy(key, healthy)
+		if !ok {
+			t.Fatal("expected a healthy upstream")
+		}
+		if before != down && after != before {
+			t.Fatalf("key %q moved from healthy target %d to %d", key, before, after)
+		}
+		if after == down {
+			t.Fatalf("key %q routed to unhealthy target", key)
+		}
+		if before == down {
+			spread[after]++
+			// 同一键稳定落在同一健康目标
+			if again, _ := ring.nextHealthy(key, healthy); again != after {
+				t.Fatalf("key %q fallback not stable: %d and %d", key, after, again)
+			}
+		}
+	}
+	// 不健康目标的键分散到其余目标(虚拟节点交错),而不是全部落到同一个目标
+	if len(spread) != len(upstreams)-1 {
+		t.Errorf("expected keys of the unhealthy target to spread over the others, got %v", spread)
+	}
+
+	if _, ok := ring.nextHealthy("key", func(int) bool { return false }); ok {
+		t.Error("expected no healthy upstream")
+	}
+}
+
+func TestHashRing_BoundsVirtualNodes(t *testing.T) {
+	upstreams := []mapping.Upstream{
+		{URL: "http://a.internal", Weight: mapping.MaxUpstreamWeight},
+		{URL: "http://b.internal", Weight: mapping.MaxUpstreamWeight},
+	}
+	ring := newSelector(mapping.StrategyHash, upstreams).(*hashRing)
+	if len(ring.points) > maxHashRingNodes {
+		t.Fatalf("ring has %d virtual nodes, want at most %d", len(ring.points), maxHashRingNodes)
+	}
+
+	// 超出上限的权重在校验时拒绝
+	oversized := mapping.Options{
+		Strategy:  mapping.StrategyHash,
+		Upstreams: []mapping.Upstream{{URL: "http://a.internal", Weight: 10000000}},
+	}
+	if err := oversized.Validate(); err == nil {
+		t.Error("expected oversized weight to be rejected")
+	}
+}
+
+func TestTransparentProxy_ConsistentHashByHeader(t *testing.T) {
+	hits := map[string]string{}
+	var last string
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			last = name
+		}))
+	}
+	a, b, c := newBackend("a"), newBackend("b"), newBackend("c")
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": "http://unused.invalid"},
+		options: map[string]mapping.Options{"/api": {
+			Strategy:  mapping.StrategyHash,
+			HashKey:   "header:X-User-ID",
+			Upstreams: []mapping.Upstream{{URL: a.URL}, {URL: b.URL}, {URL: c.URL}},
+		}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	// 同一用户无论请求路径如何都命中同一目标
+	for round := range 3 {
+		for user := range 20 {
+			req := httptest.NewRequest("GET", "http://localhost/api/x/"+strconv.Itoa(round), nil)
+			req.Header.Set("X-User-ID", "user-"+strconv.Itoa(user))
+			if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/x/"+strconv.Itoa(round)); err != nil {
+				t.Fatalf("ProxyRequest failed: %v", err)
+			}
+			id := req.Header.Get("X-User-ID")
+			if prev, ok := hits[id]; ok && prev != last {
+				t.Fatalf("user %s moved from %s to %s", id, prev, last)
+			}
+			hits[id] = last
+		}
+	}
+	used := map[string]bool{}
+	for _, target := range hits {
+		used[target] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("20 users should spread over several targets, got %v", used)
+	}
+}
+
+// fakeHealth 目标健康状态的内存实现
+type fakeHealth struct {
+	mu        sync.Mutex
+	unhealthy map[string]bool
+}
+
+func (f *fakeHealth) Healthy(target string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.unhealthy[target]
+}
+
+func (f *fakeHealth) set(target string, unhealthy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unhealthy[target] = unhealthy
+}
+
+func TestTransparentProxy_SkipsUnhealthyUpstreams(t *testing.T) {
+	var hits []string
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+		}))
+	}
+	a, b := newBackend("a"), newBackend("b")
+	defer a.Close()
+	defer b.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": "http://unused.invalid"},
+		options: map[string]mapping.Options{"/api": {
+			Strategy:  mapping.StrategyRoundRobin,
+			Upstreams: []mapping.Upstream{{URL: a.URL}, {URL: b.URL}},
+		}},
+	}
+	collector := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, collector)
+	health := &fakeHealth{unhealthy: map[string]bool{}}
+	proxy.SetHealthSource(health)
+
+	send := func(n int) error {
+		for range n {
+			if err := proxy.ProxyRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost/api/x", nil), "/api", "/x"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	health.set(a.URL, true)
+	if err := send(4); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if want := []string{"b", "b", "b", "b"}; !slices.Equal(hits, want) {
+		t.Errorf("unhealthy target should be skipped, got %v", hits)
+	}
+
+	// 全部不健康时返回 502
+	health.set(b.URL, true)
+	var proxyErr *Error
+	if err := send(1); !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusBadGateway || !errors.Is(err, ErrNoHealthyUpstream) {
+		t.Fatalf("expected 502 no healthy upstream, got %v", err)
+	}
+	if !slices.Contains(collector.events, EventNoHealthyUpstream) {
+		t.Errorf("expected %s event, got %v", EventNoHealthyUpstream, collector.events)
+	}
+
+	// 恢复后重新参与轮询
+	hits = nil
+	health.set(a.URL, false)
+	health.set(b.URL, false)
+	if err := send(2); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	slices.Sort(hits)
+	if !slices.Equal(hits, []string{"a", "b"}) {
+		t.Errorf("recovered targets should be selected again, got %v", hits)
+	}
+}
+
+func TestPickHealthy_RespectsWeights(t *testing.T) {
+	upstreams := []mapping.Upstream{
+		{URL: "http://a", Weight: 10},
+		{URL: "http://b", Weight: 1},
+		{URL: "http://c", Weight: 10},
+	}
+	health := &fakeHealth{unhealthy: map[string]bool{"http://a": true}}
+
+	// 不健康目标的流量按权重分配给其余目标,而不是全部落到下一个目标
+	counts := make(map[string]int)
+	for range 1100 {
+		i, ok := pickHealthy(upstreams, mapping.StrategyWRR, health)
+		if !ok {
+			t.Fatal("expected a healthy upstream")
+		}
+		counts[upstreams[i].URL]++
+	}
+	if counts["http://a"] != 0 {
+		t.Fatalf("unhealthy upstream must not be selected, got %v", counts)
+	}
+	if counts["http://c"] < 3*counts["http://b"] {
+		t.Errorf("fallback should follow weights (b:c = 1:10), got %v", counts)
+	}
+
+	health.set("http://b", true)
+	health.set("http://c", true)
+	if _, ok := pickHealthy(upstreams, mapping.StrategyWRR, health); ok {
+		t.Error("expected no healthy upstream")
+	}
+}
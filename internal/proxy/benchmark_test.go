@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"api-proxy/internal/mapping"
 )
 
 // mockMappingManager 模拟映射管理器
@@ -25,6 +27,10 @@ func (m mockMappingManager) GetPrefixes() []string {
 	return []string{"test"}
 }
 
+func (m mockMappingManager) GetOptions(prefix string) mapping.Options {
+	return mapping.Options{}
+}
+
 // BenchmarkTransparentProxy 透明代理性能基准测试
 func BenchmarkTransparentProxy(b *testing.B) {
 	// 创建后端服务器
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 目标熔断中,请求被快速拒绝
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// Error 携带客户端响应语义的代理错误
+type Error struct {
+	StatusCode int           // 返回给客户端的状态码
+	RetryAfter time.Duration // >0 时输出 Retry-After 头
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %v", e.StatusCode, http.StatusText(e.StatusCode), e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfterSeconds 返回 Retry-After 头的秒数(向上取整,至少1秒)
+func (e *Error) RetryAfterSeconds() int {
+	if e.RetryAfter <= 0 {
+		return 0
+	}
+	return max(int(math.Ceil(e.RetryAfter.Seconds())), 1)
+}
+
+// circuitBreaker 按映射(多目标映射按映射与所选目标)统计连续失败,达到阈值后在冷却期内快速失败
+// 冷却结束后只放行一个试探请求(半开),其余请求继续快速失败;试探失败立即重新熔断,
+// 试探在一个冷却期内未报告结果时允许新的试探(避免试探请求丢失后永久熔断)
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	states map[string]*breakerState
+}
+
+type breakerState struct {
+	failures   int
+	openUntil  time.Time
+	trialUntil time.Time // 半开试探的租期,期间拒绝其他请求
+}
+
+// trialRetryAfter 半开试探进行中时建议客户端等待的时间上限
+const trialRetryAfter = time.Second
+
+// newCircuitBreaker 创建熔断器(threshold<=0 时返回nil,表示禁用)
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 || cooldown <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		states:    make(map[string]*breakerState),
+	}
+}
+
+// Allow 判断目标是否可以请求,熔断时返回剩余冷却时间
+func (b *circuitBreaker) Allow(target string) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.states[target]
+	if state == nil {
+		return 0, true
+	}
+	now := b.now()
+	if remaining := state.openUntil.Sub(now); remaining > 0 {
+		return remaining, false
+	}
+	if state.failures < b.threshold {
+		return 0, true
+	}
+	// 半开: 已有试探在进行中时拒绝,否则放行本请求作为试探
+	if remaining := state.trialUntil.Sub(now); remaining > 0 {
+		return min(remaining, trialRetryAfter), false
+	}
+	state.trialUntil = now.Add(b.cooldown)
+	return 0, true
+}
+
+// Success 记录成功,重置失败计数
+func (b *circuitBreaker) Success(target string) {
+	b.mu.Lock()
+	delete(b.states, target)
+	b.mu.Unlock()
+}
+
+// Failure 记录失败,达到阈值时打开熔断
+func (b *circuitBreaker) Failure(target string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.states[target]
+	if state == nil {
+		state = &breakerState{}
+		b.states[target] = state
+	}
+	state.failures++
+	if state.failures >= b.threshold {
+		state.openUntil = b.now().Add(b.cooldown)
+	}
+}
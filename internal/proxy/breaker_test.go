@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestCircuitBreaker_OpenAndRecover(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	breaker := newCircuitBreaker(2, 10*time.Second)
+	breaker.now = clock.Now
+
+	breaker.Failure("a")
+	if _, ok := breaker.Allow("a"); !ok {
+		t.Fatal("breaker should stay closed below threshold")
+	}
+
+	breaker.Failure("a")
+	remaining, ok := breaker.Allow("a")
+	if ok || remaining != 10*time.Second {
+		t.Fatalf("expected open breaker with 10s remaining, got ok=%v remaining=%s", ok, remaining)
+	}
+
+	// 其他目标不受影响
+	if _, ok := breaker.Allow("b"); !ok {
+		t.Fatal("other targets should not be affected")
+	}
+
+	clock.Advance(4 * time.Second)
+	if remaining, _ := breaker.Allow("a"); remaining != 6*time.Second {
+		t.Fatalf("expected 6s remaining, got %s", remaining)
+	}
+
+	// 冷却结束后放行试探请求,成功则关闭
+	clock.Advance(6 * time.Second)
+	if _, ok := breaker.Allow("a"); !ok {
+		t.Fatal("breaker should allow a trial request after cooldown")
+	}
+	breaker.Success("a")
+	breaker.Failure("a")
+	if _, ok := breaker.Allow("a"); !ok {
+		t.Fatal("breaker should be reset after success")
+	}
+}
+
+func TestCircuitBreaker_HalfOpenAdmitsSingleTrial(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	breaker := newCircuitBreaker(1, 10*time.Second)
+	breaker.now = clock.Now
+
+	breaker.Failure("a")
+	clock.Advance(10 * time.Second)
+
+	// 冷却结束后只放行一个试探请求
+	if _, ok := breaker.Allow("a"); !ok {
+		t.Fatal("breaker should allow a trial request after cooldown")
+	}
+	if remaining, ok := breaker.Allow("a"); ok || remaining != time.Second {
+		t.Fatalf("concurrent requests should be rejected while the trial is in flight, got ok=%v remaining=%s", ok, remaining)
+	}
+
+	// 试探失败立即重新熔断
+	breaker.Failure("a")
+	if remaining, ok := breaker.Allow("a"); ok || remaining != 10*time.Second {
+		t.Fatalf("failed trial should reopen the breaker, got ok=%v remaining=%s", ok, remaining)
+	}
+
+	// 试探未报告结果时,租期结束后允许新的试探
+	clock.Advance(10 * time.Second)
+	if _, ok := breaker.Allow("a"); !ok {
+		t.Fatal("breaker should allow a trial request after cooldown")
+	}
+	clock.Advance(10 * time.Second)
+	if _, ok := breaker.Allow("a"); !ok {
+		t.Fatal("breaker should allow a new trial once the previous one expired")
+	}
+	breaker.Success("a")
+	if _, ok := breaker.Allow("a"); !ok {
+		t.Fatal("successful trial should close the breaker")
+	}
+	if _, ok := breaker.Allow("a"); !ok {
+		t.Fatal("closed breaker should admit every request")
+	}
+}
+
+func TestNewCircuitBreaker_Disabled(t *testing.T) {
+	if newCircuitBreaker(0, time.Second) != nil {
+		t.Error("threshold 0 should disable the breaker")
+	}
+}
+
+func TestTransparentProxy_CircuitOpenRetryAfter(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/test": backend.URL},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	proxy.breaker = newCircuitBreaker(2, 30*time.Second)
+	proxy.breaker.now = clock.Now
+
+	// 连续失败打开熔断
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://localhost/test/x", nil)
+		if err := proxy.ProxyRequest(w, req, "/test", "/x"); err != nil {
+			t.Fatalf("upstream 5xx should be forwarded, got error %v", err)
+		}
+	}
+
+	retryAfter := func() int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://localhost/test/x", nil)
+		err := proxy.ProxyRequest(w, req, "/test", "/x")
+
+		var proxyErr *Error
+		if !errors.As(err, &proxyErr) {
+			t.Fatalf("expected *Error, got %v", err)
+		}
+		if proxyErr.StatusCode != http.StatusServiceUnavailable || !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected 503 circuit open error, got %v", err)
+		}
+		return proxyErr.RetryAfterSeconds()
+	}
+
+	if got := retryAfter(); got != 30 {
+		t.Fatalf("expected Retry-After 30, got %d", got)
+	}
+
+	clock.Advance(12 * time.Second)
+	if got := retryAfter(); got != 18 {
+		t.Fatalf("expected Retry-After to decrease to 18, got %d", got)
+	}
+
+	clock.Advance(17*time.Second + 500*time.Millisecond)
+	if got := retryAfter(); got != 1 {
+		t.Fatalf("expected Retry-After to round up to 1, got %d", got)
+	}
+}
+
+func TestTransparentProxy_CircuitBreakerPatternMapping(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer backend.Close()
+
+	pattern := "~^/t/(?P<tenant>[^/]+)/api"
+	mapper := &MockMappingManager{
+		mappings: map[string]string{pattern: backend.URL + "/$tenant"},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.breaker = newCircuitBreaker(2, 30*time.Second)
+
+	send := func(tenant string) error {
+		path := "/t/" + tenant + "/api"
+		req := httptest.NewRequest("GET", "http://localhost"+path, nil)
+		return proxy.ProxyRequest(httptest.NewRecorder(), req, pattern, "")
+	}
+
+	// 不同路径展开为不同目标,失败仍计入同一映射的熔断状态
+	for _, tenant := range []string{"a", "b"} {
+		if err := send(tenant); err != nil {
+			t.Fatalf("upstream 5xx should be forwarded, got error %v", err)
+		}
+	}
+	if err := send("c"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected circuit open across expanded targets, got %v", err)
+	}
+	if n := len(proxy.breaker.states); n != 1 {
+		t.Errorf("expected a single breaker state for the mapping, got %d", n)
+	}
+}
+
+func TestTransparentProxy_CircuitBreakerIgnoresClientCancel(t *testing.T) {
+	received := make(chan struct{}, 2)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/test": backend.URL},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.breaker = newCircuitBreaker(1, 30*time.Second)
+
+	// 客户端在上游响应前断开
+	for range 2 {
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("GET", "http://localhost/test/x", nil).WithContext(ctx)
+		go func() {
+			<-received
+			cancel()
+		}()
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/test", "/x"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected client cancellation, got %v", err)
+		}
+	}
+
+	if _, ok := proxy.breaker.Allow("/test"); !ok {
+		t.Fatal("client cancellations must not open the breaker")
+	}
+}
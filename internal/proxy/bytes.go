@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// countingBody 统计客户端请求体实际转发的字节数(不缓存内容)
+// 重试时共享同一请求体,计数为从客户端读取的总字节数
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// countRequestBody 为有请求体的请求挂载字节计数,无请求体时返回nil
+func countRequestBody(r *http.Request) *countingBody {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	cb := &countingBody{ReadCloser: r.Body}
+	r.Body = cb
+	return cb
+}
+
+// Bytes 返回已读取的字节数(nil安全)
+func (c *countingBody) Bytes() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.n.Load()
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-proxy/internal/stats"
+)
+
+func TestTransparentProxy_RecordSizes(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(strings.Repeat("x", 2048)))
+	}))
+	defer backend.Close()
+
+	mockStats := &MockStatsCollector{}
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	req := httptest.NewRequest("POST", "http://localhost/api/upload", strings.NewReader(strings.Repeat("a", 300)))
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/upload"); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest("GET", "http://localhost/api/download", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/download"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mockStats.requestBytes) != 2 || mockStats.requestBytes[0] != 300 || mockStats.requestBytes[1] != 0 {
+		t.Fatalf("unexpected request sizes: %v", mockStats.requestBytes)
+	}
+	if mockStats.responseBytes[0] != 2048 || mockStats.responseBytes[1] != 2048 {
+		t.Fatalf("unexpected response sizes: %v", mockStats.responseBytes)
+	}
+}
+
+func TestTransparentProxy_AverageRequestSize(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer backend.Close()
+
+	collector := stats.NewCollector(nil)
+	mapper := &MockMappingManager{mappings: map[string]string{"/upload": backend.URL, "/other": backend.URL}}
+	proxy := NewTransparentProxy(mapper, collector)
+
+	// 流式上传(无 Content-Length),按实际转发的字节计数
+	for _, size := range []int{100, 300, 800} {
+		body := io.MultiReader(strings.NewReader(strings.Repeat("a", size)))
+		req := httptest.NewRequest("POST", "http://localhost/upload/file", body)
+		req.ContentLength = -1
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/upload", "/file"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest("GET", "http://localhost/other/x", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/other", "/x"); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := collector.GetRequestSizeStats()
+	if got := sizes["/upload"]; got.Count != 3 || got.TotalBytes != 1200 || got.AvgBytes != 400 {
+		t.Errorf("unexpected upload size stats: %+v", got)
+	}
+	if got := sizes["/other"]; got.Count != 1 || got.AvgBytes != 0 {
+		t.Errorf("unexpected size stats for bodyless requests: %+v", got)
+	}
+}
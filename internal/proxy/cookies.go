@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"api-proxy/internal/mapping"
+)
+
+// rewriteSetCookies 将上游 Set-Cookie 的 Domain 改写为代理主机、Path 改写到映射前缀之下
+// 代理主机为IP地址时删除 Domain(浏览器不接受IP作为Domain,删除后为仅限当前主机的Cookie)
+// 正则映射没有固定前缀,Path 保持不变
+func rewriteSetCookies(header http.Header, r *http.Request, prefix string) {
+	cookies := header.Values("Set-Cookie")
+	if len(cookies) == 0 {
+		return
+	}
+	host := cookieHost(r.Host)
+	if mapping.IsPattern(prefix) {
+		prefix = ""
+	}
+	rewritten := make([]string, len(cookies))
+	for i, cookie := range cookies {
+		rewritten[i] = rewriteSetCookie(cookie, host, prefix)
+	}
+	header["Set-Cookie"] = rewritten
+}
+
+// rewriteSetCookie 改写单个 Set-Cookie 值,其余属性原样保留
+func rewriteSetCookie(value, host, prefix string) string {
+	parts := strings.Split(value, ";")
+	out := parts[:1]
+	for _, part := range parts[1:] {
+		name, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "domain":
+			if host == "" {
+				continue
+			}
+			part = " Domain=" + host
+		case "path":
+			if prefix != "" && strings.HasPrefix(strings.TrimSpace(val), "/") {
+				part = " Path=" + joinCookiePath(prefix, strings.TrimSpace(val))
+			}
+		}
+		out = append(out, part)
+	}
+	return strings.Join(out, ";")
+}
+
+// cookieHost 返回可用作 Cookie Domain 的代理主机名(IP地址返回空)
+func cookieHost(hostport string) string {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "" || net.ParseIP(host) != nil {
+		return ""
+	}
+	return host
+}
+
+// joinCookiePath 将上游路径挂到映射前缀之下(/ -> /app, /login -> /app/login)
+func joinCookiePath(prefix, path string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return path
+	}
+	if path == "/" {
+		return prefix
+	}
+	return prefix + path
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestRewriteSetCookie(t *testing.T) {
+	tests := []struct {
+		name, value, host, prefix, want string
+	}{
+		{"domainAndPath", "sid=abc; Domain=backend.example.com; Path=/; HttpOnly", "proxy.example.com", "/app",
+			"sid=abc; Domain=proxy.example.com; Path=/app; HttpOnly"},
+		{"nestedPath", "t=1; path=/login; Secure", "proxy.example.com", "/app/",
+			"t=1; Path=/app/login; Secure"},
+		{"ipHostDropsDomain", "sid=abc; Domain=.backend.example.com; Path=/", "", "/app",
+			"sid=abc; Path=/app"},
+		{"rootPrefix", "sid=abc; Path=/x", "proxy.example.com", "/", "sid=abc; Path=/x"},
+		{"noAttributes", "sid=abc", "proxy.example.com", "/app", "sid=abc"},
+	}
+	for _, tt := range tests {
+		if got := rewriteSetCookie(tt.value, tt.host, tt.prefix); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := cookieHost("127.0.0.1:8000"); got != "" {
+		t.Errorf("expected empty host for IP, got %q", got)
+	}
+	if got := cookieHost("proxy.example.com:8443"); got != "proxy.example.com" {
+		t.Errorf("cookieHost() = %q", got)
+	}
+}
+
+func TestTransparentProxy_RewriteCookies(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "session=abc; Domain=backend.example.com; Path=/; HttpOnly")
+		w.Header().Add("Set-Cookie", "theme=dark; Domain=backend.example.com; Path=/settings")
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/site": backend.URL, "/raw": backend.URL},
+		options:  map[string]mapping.Options{"/site": {RewriteCookies: true}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://proxy.example.com/site/", nil)
+	if err := proxy.ProxyRequest(w, req, "/site", "/"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"session=abc; Domain=proxy.example.com; Path=/site; HttpOnly",
+		"theme=dark; Domain=proxy.example.com; Path=/site/settings",
+	}
+	if got := w.Result().Header.Values("Set-Cookie"); !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// 未开启的映射原样转发
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://proxy.example.com/raw/", nil)
+	if err := proxy.ProxyRequest(w, req, "/raw", "/"); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Result().Header.Values("Set-Cookie"); got[0] != "session=abc; Domain=backend.example.com; Path=/; HttpOnly" {
+		t.Errorf("expected untouched cookie, got %q", got)
+	}
+}
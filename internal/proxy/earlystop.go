@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// EventEarlyResponse 上游在请求体上传完成前返回错误,代理停止读取客户端请求体
+const EventEarlyResponse = "early_response"
+
+// earlyStopBody 可提前结束的请求体: 上游已给出最终错误响应时不再读取客户端剩余的请求体
+// 上游请求体以 chunked 编码发送,提前结束表现为正常的 EOF,上游连接仍可复用
+type earlyStopBody struct {
+	io.ReadCloser
+	stopped atomic.Bool
+	eof     atomic.Bool
+}
+
+// stopOnEarlyResponse 为有请求体的请求挂载提前结束能力,无请求体时返回nil
+func stopOnEarlyResponse(r *http.Request) *earlyStopBody {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	b := &earlyStopBody{ReadCloser: r.Body}
+	r.Body = b
+	return b
+}
+
+func (b *earlyStopBody) Read(p []byte) (int, error) {
+	if b.stopped.Load() {
+		return 0, io.EOF
+	}
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.eof.Store(true)
+	}
+	return n, err
+}
+
+// stop 请求体尚未读完时停止读取并返回 true(nil安全)
+func (b *earlyStopBody) stop() bool {
+	if b == nil || b.eof.Load() {
+		return false
+	}
+	return !b.stopped.Swap(true)
+}
@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// endlessBody 持续上传的客户端请求体,记录被读取的字节数(上限 limit)
+type endlessBody struct {
+	read  atomic.Int64
+	limit int64
+}
+
+func (b *endlessBody) Read(p []byte) (int, error) {
+	if b.read.Load() >= b.limit {
+		return 0, io.EOF
+	}
+	time.Sleep(time.Millisecond)
+	n := min(int64(len(p)), b.limit-b.read.Load())
+	b.read.Add(n)
+	return int(n), nil
+}
+
+func (b *endlessBody) Close() error { return nil }
+
+// earlyRejectBackend 读完请求头即返回 413,之后继续读取请求体直至结束(部分服务器会如此处理)
+// 通过 done 报告上游是否收到了正常结束的请求体
+func earlyRejectBackend(t *testing.T) (string, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			done <- err
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 413 Request Entity Too Large\r\nContent-Length: 9\r\n\r\ntoo large")
+		_, err = io.Copy(io.Discard, req.Body)
+		done <- err
+	}()
+	return "http://" + ln.Addr().String(), done
+}
+
+func TestTransparentProxy_StopsBodyOnEarlyResponse(t *testing.T) {
+	target, upstreamDone := earlyRejectBackend(t)
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/upload": target},
+		options:  map[string]mapping.Options{},
+	}
+	mockStats := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	const total = 1 << 30
+	body := &endlessBody{limit: total}
+	req := httptest.NewRequest("POST", "http://localhost/upload/file", nil)
+	req.Body = body
+
+	w := httptest.NewRecorder()
+	if err := proxy.ProxyRequest(w, req, "/upload", "/file"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if w.Code != http.StatusRequestEntityTooLarge || w.Body.String() != "too large" {
+		t.Errorf("expected upstream 413 response, got %d %q", w.Code, w.Body.String())
+	}
+	if len(mockStats.events) != 1 || mockStats.events[0] != EventEarlyResponse {
+		t.Errorf("expected early_response event, got %v", mockStats.events)
+	}
+
+	// 上游收到正常结束的(截断的)请求体,而非连接被中断
+	select {
+	case err := <-upstreamDone:
+		if err != nil {
+			t.Errorf("upstream should see a cleanly terminated body, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxy kept streaming the request body after the upstream rejected it")
+	}
+	read := body.read.Load()
+	time.Sleep(50 * time.Millisecond)
+	if after := body.read.Load(); after != read || read >= total {
+		t.Errorf("client body should no longer be consumed: %d then %d bytes", read, after)
+	}
+}
+
+func TestEarlyStopBody_NotAfterEOF(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://localhost/", nil)
+	req.Body = io.NopCloser(&endlessBody{limit: 10})
+	b := stopOnEarlyResponse(req)
+	io.Copy(io.Discard, req.Body)
+	if b.stop() {
+		t.Error("fully read body should not be reported as stopped early")
+	}
+
+	var nilBody *earlyStopBody
+	if nilBody.stop() {
+		t.Error("nil body should not stop")
+	}
+}
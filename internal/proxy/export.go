@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"api-proxy/internal/analytics"
+	"api-proxy/internal/mapping"
+)
+
+// RequestExporter 请求元数据导出接口(依赖倒置),实现必须非阻塞
+type RequestExporter interface {
+	Export(event analytics.Event)
+}
+
+// SetExporter 设置请求元数据导出器(nil表示禁用)
+func (p *TransparentProxy) SetExporter(exporter RequestExporter) {
+	p.exporter = exporter
+}
+
+// SetAuditLog 设置开启 audit_log 的映射使用的审计日志导出器(nil表示禁用)
+func (p *TransparentProxy) SetAuditLog(auditLog RequestExporter) {
+	p.auditLog = auditLog
+}
+
+// exporting 判断请求的元数据是否需要导出
+func (p *TransparentProxy) exporting(opts mapping.Options) bool {
+	return p.exporter != nil || (opts.AuditLog && p.auditLog != nil)
+}
+
+// exportRequest 导出一次请求的元数据(不包含请求/响应体),timing 为上游各阶段耗时(可为nil)
+// 开启 audit_log 的映射同时写入审计日志;请求上下文带有耗时接收位置时(访问日志)同时写入
+func (p *TransparentProxy) exportRequest(r *http.Request, prefix string, opts mapping.Options, status int, start time.Time, requestBytes, responseBytes int64, timing *upstreamTiming) {
+	end := time.Now()
+	if slot := timingRecorder(r.Context()); slot != nil && timing != nil {
+		slot.Store(timing.report(end))
+	}
+	if !p.exporting(opts) {
+		return
+	}
+	event := analytics.Event{
+		Timestamp:     start,
+		Endpoint:      prefix,
+		Method:        r.Method,
+		Status:        status,
+		LatencyMs:     end.Sub(start).Milliseconds(),
+		RequestBytes:  requestBytes,
+		ResponseBytes: responseBytes,
+		Upstream:      timing.report(end),
+	}
+	if p.exporter != nil {
+		p.exporter.Export(event)
+	}
+	if opts.AuditLog && p.auditLog != nil {
+		p.auditLog.Export(event)
+	}
+}
+
+// errorStatus 返回错误对应的响应状态码(与调用方写出的错误响应一致)
+func errorStatus(err error) int {
+	var proxyErr *Error
+	if errors.As(err, &proxyErr) {
+		return proxyErr.StatusCode
+	}
+	return http.StatusInternalServerError
+}
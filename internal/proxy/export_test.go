@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-proxy/internal/analytics"
+	"api-proxy/internal/mapping"
+)
+
+// recordingExporter 记录导出的事件
+type recordingExporter struct {
+	events []analytics.Event
+}
+
+func (e *recordingExporter) Export(event analytics.Event) {
+	e.events = append(e.events, event)
+}
+
+func TestTransparentProxy_ExportRequest(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{
+		"/api":  backend.URL,
+		"/down": "http://127.0.0.1:1",
+	}}
+	exporter := &recordingExporter{}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.SetExporter(exporter)
+
+	req := httptest.NewRequest("POST", "http://localhost/api/items", strings.NewReader("payload"))
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/items"); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest("GET", "http://localhost/down/x", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/down", "/x"); err == nil {
+		t.Fatal("expected upstream error")
+	}
+
+	if len(exporter.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(exporter.events))
+	}
+	got := exporter.events[0]
+	if got.Endpoint != "/api" || got.Method != "POST" || got.Status != http.StatusCreated ||
+		got.RequestBytes != 7 || got.ResponseBytes != 7 || got.Timestamp.IsZero() {
+		t.Errorf("unexpected event: %+v", got)
+	}
+	if failed := exporter.events[1]; failed.Endpoint != "/down" || failed.Status != http.StatusInternalServerError {
+		t.Errorf("unexpected failure event: %+v", failed)
+	}
+}
+
+func TestTransparentProxy_AuditLogOnlyForFlaggedMappings(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/audited": backend.URL, "/plain": backend.URL},
+		options:  map[string]mapping.Options{"/audited": {AuditLog: true}},
+	}
+	auditLog := &recordingExporter{}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.SetAuditLog(auditLog)
+
+	for _, prefix := range []string{"/audited", "/plain"} {
+		req := httptest.NewRequest("GET", "http://localhost"+prefix+"/x", nil)
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, prefix, "/x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(auditLog.events) != 1 || auditLog.events[0].Endpoint != "/audited" || auditLog.events[0].Status != http.StatusOK {
+		t.Errorf("expected a single audit event for /audited, got %+v", auditLog.events)
+	}
+}
+
+func TestTransparentProxy_ExportUpstreamTiming(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("head"))
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("tail"))
+	}))
+	defer backend.Close()
+
+	// 使用主机名以经过 DNS 解析阶段
+	target := strings.Replace(backend.URL, "127.0.0.1", "localhost", 1)
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": target}}
+	exporter := &recordingExporter{}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.SetExporter(exporter)
+
+	req := httptest.NewRequest("GET", "http://proxy.example/api/slow", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/slow"); err != nil {
+		t.Fatal(err)
+	}
+	if len(exporter.events) != 1 || exporter.events[0].Upstream == nil {
+		t.Fatalf("expected an event with upstream timing, got %+v", exporter.events)
+	}
+	timing := exporter.events[0].Upstream
+	if timing.ConnectMs <= 0 || timing.TTFBMs <= 0 {
+		t.Fatalf("expected connect and TTFB timings on a new connection, got %+v", timing)
+	}
+	if timing.DNSMs > timing.ConnectMs || timing.ConnectMs > timing.TTFBMs || timing.TTFBMs > timing.TotalMs {
+		t.Errorf("expected dns <= connect <= ttfb <= total, got %+v", timing)
+	}
+	if timing.TTFBMs < 20 || timing.TotalMs-timing.TTFBMs < 20 {
+		t.Errorf("expected TTFB to include upstream processing and total to include the body, got %+v", timing)
+	}
+	if timing.TLSMs != 0 {
+		t.Errorf("plain HTTP should not record TLS, got %+v", timing)
+	}
+}
+
+func TestTransparentProxy_TimingRecorder(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	// 未启用导出时,请求上下文带有接收位置也记录耗时(访问日志)
+	proxy := NewTransparentProxy(&MockMappingManager{mappings: map[string]string{"/api": backend.URL}}, nil)
+	ctx, upstreamTiming := WithTimingRecorder(context.Background())
+	req := httptest.NewRequest("GET", "http://proxy.example/api/x", nil).WithContext(ctx)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/x"); err != nil {
+		t.Fatal(err)
+	}
+	timing := upstreamTiming()
+	if timing == nil || timing.TTFBMs <= 0 || timing.TotalMs < timing.TTFBMs {
+		t.Errorf("expected upstream timing for the access log, got %+v", timing)
+	}
+
+	// 未发出上游请求时为 nil
+	ctx, upstreamTiming = WithTimingRecorder(context.Background())
+	req = httptest.NewRequest("GET", "http://proxy.example/missing/x", nil).WithContext(ctx)
+	proxy.ProxyRequest(httptest.NewRecorder(), req, "/missing", "/x")
+	if timing := upstreamTiming(); timing != nil {
+		t.Errorf("expected no timing without an upstream request, got %+v", timing)
+	}
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+)
+
+// forwardedHeaders 向上游传递客户端访问代理时使用的协议和端口
+// 用于上游生成绝对URL(链接、重定向);默认关闭以保持完全透明
+type forwardedHeaders struct {
+	enabled bool
+	proto   string // 覆盖值(TLS在前置负载均衡终结时配置)
+	port    string // 覆盖值
+}
+
+// apply 设置 X-Forwarded-Proto / X-Forwarded-Port(覆盖客户端传入的值)
+func (f forwardedHeaders) apply(dst http.Header, r *http.Request) {
+	if !f.enabled {
+		return
+	}
+
+	proto := f.proto
+	if proto == "" {
+		proto = "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+	}
+
+	port := f.port
+	if port == "" {
+		port = hostPort(r.Host, proto)
+	}
+
+	dst.Set("X-Forwarded-Proto", proto)
+	dst.Set("X-Forwarded-Port", port)
+}
+
+// hostPort 返回客户端访问的端口(Host未带端口时取协议默认端口)
+func hostPort(host, proto string) string {
+	if _, port, err := net.SplitHostPort(host); err == nil && port != "" {
+		return port
+	}
+	if proto == "https" {
+		return "443"
+	}
+	return "80"
+}
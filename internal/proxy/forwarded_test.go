@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransparentProxy_ForwardedHeaders(t *testing.T) {
+	var got http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name      string
+		forwarded forwardedHeaders
+		url       string
+		tls       bool
+		incoming  string // 客户端伪造的 X-Forwarded-Proto
+		wantProto string
+		wantPort  string
+	}{
+		{"disabled", forwardedHeaders{}, "http://localhost:8000/api/x", false, "", "", ""},
+		{"http", forwardedHeaders{enabled: true}, "http://localhost:8000/api/x", false, "", "http", "8000"},
+		{"httpDefaultPort", forwardedHeaders{enabled: true}, "http://example.com/api/x", false, "", "http", "80"},
+		{"https", forwardedHeaders{enabled: true}, "https://example.com/api/x", true, "", "https", "443"},
+		{"spoofedOverwritten", forwardedHeaders{enabled: true}, "http://localhost:8000/api/x", false, "https", "http", "8000"},
+		{"behindLB", forwardedHeaders{enabled: true, proto: "https", port: "443"}, "http://localhost:8000/api/x", false, "", "https", "443"},
+	}
+
+	for _, tt := range tests {
+		mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+		proxy := NewTransparentProxy(mapper, nil)
+		proxy.forwarded = tt.forwarded
+
+		req := httptest.NewRequest("GET", tt.url, nil)
+		if tt.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		if tt.incoming != "" {
+			req.Header.Set("X-Forwarded-Proto", tt.incoming)
+		}
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/x"); err != nil {
+			t.Fatalf("%s: ProxyRequest failed: %v", tt.name, err)
+		}
+
+		if proto := got.Get("X-Forwarded-Proto"); proto != tt.wantProto {
+			t.Errorf("%s: expected X-Forwarded-Proto %q, got %q", tt.name, tt.wantProto, proto)
+		}
+		if port := got.Get("X-Forwarded-Port"); port != tt.wantPort {
+			t.Errorf("%s: expected X-Forwarded-Port %q, got %q", tt.name, tt.wantPort, port)
+		}
+	}
+}
+
+func TestNewTransparentProxy_ForwardedOverrideEnables(t *testing.T) {
+	t.Setenv("FORWARDED_PROTO", "https")
+	proxy := NewTransparentProxy(&MockMappingManager{}, nil)
+	if !proxy.forwarded.enabled || proxy.forwarded.proto != "https" {
+		t.Fatalf("override should enable forwarded headers, got %+v", proxy.forwarded)
+	}
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// gRPC-Web 内容类型前缀(二进制模式,如 application/grpc-web+proto)
+const grpcWebContentType = "application/grpc-web"
+
+// grpcWebTextContentType 文本(base64)模式,暂不支持转换
+const grpcWebTextContentType = "application/grpc-web-text"
+
+// grpcWebTrailerFlag 帧头标志位: 该帧承载 trailers 而非消息
+const grpcWebTrailerFlag = 0x80
+
+// grpcStatusHeaders Trailers-Only 响应中以响应头形式返回的状态字段
+var grpcStatusHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+
+// isGRPCWebRequest 判断是否为可转换的 gRPC-Web(二进制模式)请求
+func isGRPCWebRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, grpcWebContentType) &&
+		!strings.HasPrefix(contentType, grpcWebTextContentType)
+}
+
+// translateGRPCWebRequest 将 gRPC-Web 请求头转换为 gRPC
+// 二进制模式的消息帧格式与 gRPC 相同,请求体无需改写
+func translateGRPCWebRequest(header http.Header) {
+	contentType := header.Get("Content-Type")
+	header.Set("Content-Type", "application/grpc"+strings.TrimPrefix(contentType, grpcWebContentType))
+	header.Set("Te", "trailers")
+	header.Del("Content-Length")
+	header.Del("X-Grpc-Web")
+}
+
+// translateGRPCWebResponse 将 gRPC 响应头转换为 gRPC-Web
+// 响应体追加 trailers 帧,因此不能保留上游的 Content-Length
+func translateGRPCWebResponse(header http.Header) {
+	contentType := header.Get("Content-Type")
+	if suffix, ok := strings.CutPrefix(contentType, "application/grpc"); ok {
+		header.Set("Content-Type", grpcWebContentType+suffix)
+	} else {
+		header.Set("Content-Type", grpcWebContentType+"+proto")
+	}
+	header.Del("Content-Length")
+}
+
+// grpcWebTrailers 在上游响应体读完后输出 trailers 帧
+// 响应的 Trailer 只有在响应体读到 EOF 后才可用,因此延迟到首次 Read 时编码
+type grpcWebTrailers struct {
+	resp  *http.Response
+	frame *bytes.Reader
+}
+
+func (t *grpcWebTrailers) Read(b []byte) (int, error) {
+	if t.frame == nil {
+		t.frame = bytes.NewReader(encodeGRPCWebTrailers(t.resp))
+	}
+	return t.frame.Read(b)
+}
+
+// grpcWebBody 返回追加了 trailers 帧的响应体
+func grpcWebBody(body io.Reader, resp *http.Response) io.Reader {
+	return io.MultiReader(body, &grpcWebTrailers{resp: resp})
+}
+
+// encodeGRPCWebTrailers 将 trailers 编码为 gRPC-Web 帧: 1字节标志 + 4字节长度 + "name: value\r\n"
+// 上游为 Trailers-Only 响应(状态在响应头中)时,使用响应头中的状态字段
+func encodeGRPCWebTrailers(resp *http.Response) []byte {
+	trailer := resp.Trailer
+	if trailer.Get("Grpc-Status") == "" {
+		trailer = http.Header{}
+		for _, name := range grpcStatusHeaders {
+			if values := resp.Header.Values(name); len(values) > 0 {
+				trailer[name] = values
+			}
+		}
+	}
+
+	names := make([]string, 0, len(trailer))
+	for name := range trailer {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var payload bytes.Buffer
+	for _, name := range names {
+		for _, value := range trailer[name] {
+			payload.WriteString(strings.ToLower(name))
+			payload.WriteString(": ")
+			payload.WriteString(value)
+			payload.WriteString("\r\n")
+		}
+	}
+
+	frame := make([]byte, 5, 5+payload.Len())
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(payload.Len()))
+	return append(frame, payload.Bytes()...)
+}
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+// grpcFrame 编码一个 gRPC 消息帧
+func grpcFrame(flag byte, payload []byte) []byte {
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// newGRPCBackend 启动仅接受 HTTP/2(h2c) 的 gRPC 风格后端: 回显请求消息并在 trailers 中返回状态
+func newGRPCBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc+proto" || r.Header.Get("Te") != "trailers" {
+			t.Errorf("unexpected upstream request: proto=%s content-type=%q te=%q",
+				r.Proto, r.Header.Get("Content-Type"), r.Header.Get("Te"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		msg, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/pkg.Service/Fail" {
+			// Trailers-Only 响应: 状态放在响应头中
+			w.Header().Set("Content-Type", "application/grpc+proto")
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc+proto")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Write(msg)
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "")
+		w.Header().Set(http.TrailerPrefix+"X-Custom", "done")
+	}))
+	backend.Config.Protocols = new(http.Protocols)
+	backend.Config.Protocols.SetUnencryptedHTTP2(true)
+	backend.Start()
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func TestTransparentProxy_GRPCWeb(t *testing.T) {
+	backend := newGRPCBackend(t)
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/grpc": backend.URL},
+		options:  map[string]mapping.Options{"/grpc": {GRPCWeb: true}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	message := grpcFrame(0, []byte("hello"))
+	req := httptest.NewRequest("POST", "http://localhost/grpc/pkg.Service/Echo", bytes.NewReader(message))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("X-Grpc-Web", "1")
+	rec := httptest.NewRecorder()
+	if err := proxy.ProxyRequest(rec, req, "/grpc", "/pkg.Service/Echo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/grpc-web+proto" {
+		t.Fatalf("unexpected response: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	trailers := grpcFrame(grpcWebTrailerFlag, []byte("grpc-message: \r\ngrpc-status: 0\r\nx-custom: done\r\n"))
+	want := append(append([]byte{}, message...), trailers...)
+	if !bytes.Equal(rec.Body.Bytes(), want) {
+		t.Fatalf("unexpected body:\n got %q\nwant %q", rec.Body.Bytes(), want)
+	}
+}
+
+func TestTransparentProxy_GRPCWebTrailersOnly(t *testing.T) {
+	backend := newGRPCBackend(t)
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/grpc": backend.URL},
+		options:  map[string]mapping.Options{"/grpc": {GRPCWeb: true}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	req := httptest.NewRequest("POST", "http://localhost/grpc/pkg.Service/Fail", bytes.NewReader(grpcFrame(0, nil)))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	rec := httptest.NewRecorder()
+	if err := proxy.ProxyRequest(rec, req, "/grpc", "/pkg.Service/Fail"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := grpcFrame(grpcWebTrailerFlag, []byte("grpc-message: not found\r\ngrpc-status: 5\r\n"))
+	if !bytes.Equal(rec.Body.Bytes(), want) {
+		t.Fatalf("unexpected body:\n got %q\nwant %q", rec.Body.Bytes(), want)
+	}
+}
+
+func TestTransparentProxy_GRPCWebDisabled(t *testing.T) {
+	var contentType string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		w.Write([]byte("raw"))
+	}))
+	defer backend.Close()
+
+	proxy := NewTransparentProxy(&MockMappingManager{mappings: map[string]string{"/grpc": backend.URL}}, nil)
+	req := httptest.NewRequest("POST", "http://localhost/grpc/x", bytes.NewReader(grpcFrame(0, nil)))
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	rec := httptest.NewRecorder()
+	if err := proxy.ProxyRequest(rec, req, "/grpc", "/x"); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/grpc-web+proto" || rec.Body.String() != "raw" {
+		t.Errorf("expected transparent forwarding without grpc_web, got %q %q", contentType, rec.Body.String())
+	}
+}
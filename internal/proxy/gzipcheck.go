@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// EventGzipCorrupt 上游gzip响应截断或损坏
+const EventGzipCorrupt = "gzip_corrupt"
+
+// errVerifierStopped 校验协程已结束,后续数据直接丢弃
+var errVerifierStopped = errors.New("gzip verifier stopped")
+
+// shouldVerifyGzip 判断响应是否为需要校验的gzip响应体
+func shouldVerifyGzip(r *http.Request, resp *http.Response) bool {
+	if r.Method == http.MethodHead || resp.ContentLength == 0 {
+		return false
+	}
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(resp.Header.Get("Content-Encoding")), "gzip")
+}
+
+// gzipVerifier 边转发边解压校验gzip流(不缓存响应体)
+// 读取的数据经管道送入后台解压协程,转发路径不受校验结果影响
+type gzipVerifier struct {
+	src  io.Reader
+	pw   *io.PipeWriter
+	done chan error
+
+	stopped bool // 解压协程已提前结束(数据已损坏)
+	readEOF bool // 上游响应体已读完
+	readErr bool // 上游响应体读取失败(连接中断)
+}
+
+func newGzipVerifier(src io.Reader) *gzipVerifier {
+	pr, pw := io.Pipe()
+	v := &gzipVerifier{
+		src:  src,
+		pw:   pw,
+		done: make(chan error, 1),
+	}
+	go func() {
+		err := decodeGzip(pr)
+		// 提前结束时解除写端阻塞
+		pr.CloseWithError(errVerifierStopped)
+		v.done <- err
+	}()
+	return v
+}
+
+func decodeGzip(r io.Reader) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, zr)
+	return err
+}
+
+func (v *gzipVerifier) Read(p []byte) (int, error) {
+	n, err := v.src.Read(p)
+	if n > 0 && !v.stopped {
+		if _, werr := v.pw.Write(p[:n]); werr != nil {
+			v.stopped = true
+		}
+	}
+	switch {
+	case err == io.EOF:
+		v.readEOF = true
+	case err != nil:
+		v.readErr = true
+	}
+	return n, err
+}
+
+// finish 结束校验并返回gzip流错误
+// 客户端提前断开(上游未读完)时无法判断完整性,返回nil
+func (v *gzipVerifier) finish() error {
+	v.pw.Close()
+	err := <-v.done
+	if !v.readEOF && !v.readErr {
+		return nil
+	}
+	if err == io.EOF {
+		// 空的gzip流同样视为截断
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func proxyGzip(t *testing.T, payload []byte, verify bool) (*httptest.ResponseRecorder, *MockStatsCollector) {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(payload)
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/gz": backend.URL},
+		options:  map[string]mapping.Options{"/gz": {VerifyGzip: verify}},
+	}
+	collector := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, collector)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/gz/data", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if err := proxy.ProxyRequest(w, req, "/gz", "/data"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	return w, collector
+}
+
+func TestTransparentProxy_GzipTruncatedDetected(t *testing.T) {
+	full := gzipBytes(t, strings.Repeat("hello gzip ", 1000))
+	truncated := full[:len(full)/2]
+
+	w, collector := proxyGzip(t, truncated, true)
+
+	// 转发保持透明: 原样输出截断的字节
+	if !bytes.Equal(w.Body.Bytes(), truncated) {
+		t.Fatalf("body should be forwarded unchanged, got %d bytes", w.Body.Len())
+	}
+	if len(collector.events) != 1 || collector.events[0] != EventGzipCorrupt {
+		t.Fatalf("expected one gzip_corrupt event, got %v", collector.events)
+	}
+}
+
+func TestTransparentProxy_GzipIntact(t *testing.T) {
+	full := gzipBytes(t, strings.Repeat("hello gzip ", 1000))
+
+	w, collector := proxyGzip(t, full, true)
+	if !bytes.Equal(w.Body.Bytes(), full) {
+		t.Fatal("body should be forwarded unchanged")
+	}
+	if len(collector.events) != 0 {
+		t.Fatalf("intact gzip should not be flagged, got %v", collector.events)
+	}
+}
+
+func TestTransparentProxy_GzipGarbageDetected(t *testing.T) {
+	_, collector := proxyGzip(t, []byte(strings.Repeat("not gzip", 10000)), true)
+	if len(collector.events) != 1 {
+		t.Fatalf("invalid gzip should be flagged, got %v", collector.events)
+	}
+}
+
+func TestTransparentProxy_GzipCheckDisabledByDefault(t *testing.T) {
+	full := gzipBytes(t, "hello")
+	_, collector := proxyGzip(t, full[:len(full)/2], false)
+	if len(collector.events) != 0 {
+		t.Fatalf("unflagged mapping should not verify gzip, got %v", collector.events)
+	}
+}
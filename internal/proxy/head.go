@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+)
+
+// headDrainLimit HEAD 响应中误发的响应体最多丢弃的字节数(超出部分随连接关闭丢弃)
+const headDrainLimit = 64 << 10
+
+// headWriter HEAD 请求的响应写入器: 转发头部(含 Content-Length),从不写出响应体
+// 同时覆盖实时转发和幂等回放两条路径
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w headWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// Unwrap 供 http.ResponseController 访问底层写入器
+func (w headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// discardHeadBody 丢弃上游对 HEAD 请求误发的响应体,返回空响应体
+func discardHeadBody(body io.Reader) io.Reader {
+	io.CopyN(io.Discard, body, headDrainLimit)
+	return http.NoBody
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransparentProxy_HeadForwardsHeadersOnly(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "11")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("hello world"))
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	proxy := NewTransparentProxy(mapper, nil)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := proxy.ProxyRequest(w, r, "/api", strings.TrimPrefix(r.URL.Path, "/api")); err != nil {
+			t.Errorf("ProxyRequest failed: %v", err)
+		}
+	}))
+	defer front.Close()
+
+	resp, err := http.Head(front.URL + "/api/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.ContentLength != 11 || resp.Header.Get("ETag") != `"v1"` {
+		t.Errorf("expected upstream headers forwarded, got length=%d header=%v", resp.ContentLength, resp.Header)
+	}
+	if len(body) != 0 {
+		t.Errorf("HEAD response must not have a body, got %q", body)
+	}
+}
+
+func TestTransparentProxy_HeadDiscardsMisbehavingBody(t *testing.T) {
+	mockStats := &MockStatsCollector{}
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": "http://upstream.example.com"}}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	upstreamBody := &trackingBody{Reader: strings.NewReader("leaked body")}
+	proxy.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Length": {"11"}},
+			Body:       upstreamBody,
+		}, nil
+	})}
+
+	req := httptest.NewRequest(http.MethodHead, "http://localhost/api/file", nil)
+	w := httptest.NewRecorder()
+	if err := proxy.ProxyRequest(w, req, "/api", "/file"); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD response must not have a body, got %q", w.Body.String())
+	}
+	if w.Header().Get("Content-Length") != "11" {
+		t.Errorf("expected Content-Length forwarded, got %q", w.Header().Get("Content-Length"))
+	}
+	if !upstreamBody.closed || upstreamBody.Reader.(*strings.Reader).Len() != 0 {
+		t.Error("upstream body should be drained and closed")
+	}
+	if len(mockStats.responseBytes) != 1 || mockStats.responseBytes[0] != 0 {
+		t.Errorf("expected 0 response bytes recorded, got %v", mockStats.responseBytes)
+	}
+}
+
+// trackingBody 记录上游响应体是否被关闭
+type trackingBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"api-proxy/internal/mapping"
+)
+
+// defaultHeaders 附加到所有上游请求的全局默认头部
+// 优先级: 映射 request_headers > 客户端传入值 > 全局默认(override 为 true 时全局默认覆盖客户端值)
+type defaultHeaders struct {
+	values   http.Header
+	override bool
+}
+
+// parseDefaultHeaders 解析 "Name: Value" 形式的头部列表,忽略无效项
+func parseDefaultHeaders(items []string) defaultHeaders {
+	var d defaultHeaders
+	for _, item := range items {
+		name, value, ok := strings.Cut(item, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if ok {
+			if err := mapping.ValidateRequestHeader(name, value); err != nil {
+				ok = false
+			}
+		}
+		if !ok {
+			log.Printf("⚠️  忽略无效的默认请求头 UPSTREAM_HEADERS=%q", item)
+			continue
+		}
+		if d.values == nil {
+			d.values = make(http.Header)
+		}
+		d.values.Set(name, value)
+	}
+	return d
+}
+
+// apply 按优先级将全局默认头部和映射头部写入上游请求
+func (d defaultHeaders) apply(dst http.Header, mappingHeaders map[string]string) {
+	for name, values := range d.values {
+		if d.override || len(dst[name]) == 0 {
+			dst[name] = values
+		}
+	}
+	for name, value := range mappingHeaders {
+		dst.Set(name, value)
+	}
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestParseDefaultHeaders(t *testing.T) {
+	d := parseDefaultHeaders([]string{"X-Proxy-Source: api-proxy", "invalid", "Host: evil.example.com", "X-Env:prod"})
+	if len(d.values) != 2 || d.values.Get("X-Proxy-Source") != "api-proxy" || d.values.Get("X-Env") != "prod" {
+		t.Fatalf("unexpected parsed headers: %v", d.values)
+	}
+}
+
+func TestTransparentProxy_DefaultHeaders(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen-Source", r.Header.Get("X-Proxy-Source"))
+		w.Header().Set("X-Seen-Env", r.Header.Get("X-Env"))
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL, "/custom": backend.URL},
+		options: map[string]mapping.Options{
+			"/custom": {RequestHeaders: map[string]string{"X-Proxy-Source": "custom"}},
+		},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.defaultHeaders = parseDefaultHeaders([]string{"X-Proxy-Source: api-proxy", "X-Env: prod"})
+
+	do := func(prefix, clientSource string) http.Header {
+		t.Helper()
+		req := httptest.NewRequest("GET", "http://localhost"+prefix+"/x", nil)
+		if clientSource != "" {
+			req.Header.Set("X-Proxy-Source", clientSource)
+		}
+		w := httptest.NewRecorder()
+		if err := proxy.ProxyRequest(w, req, prefix, "/x"); err != nil {
+			t.Fatal(err)
+		}
+		return w.Header()
+	}
+
+	// 全局默认头部应用到所有请求
+	if h := do("/api", ""); h.Get("X-Seen-Source") != "api-proxy" || h.Get("X-Seen-Env") != "prod" {
+		t.Errorf("expected global headers, got %v", h)
+	}
+	// 客户端传入值优先于全局默认
+	if h := do("/api", "client"); h.Get("X-Seen-Source") != "client" {
+		t.Errorf("expected client value to win, got %q", h.Get("X-Seen-Source"))
+	}
+	// 映射配置优先于客户端和全局默认
+	if h := do("/custom", "client"); h.Get("X-Seen-Source") != "custom" || h.Get("X-Seen-Env") != "prod" {
+		t.Errorf("expected mapping header to win, got %v", h)
+	}
+
+	// 配置为覆盖客户端时,全局默认优先于客户端(映射仍最优先)
+	proxy.defaultHeaders.override = true
+	if h := do("/api", "client"); h.Get("X-Seen-Source") != "api-proxy" {
+		t.Errorf("expected global header to override client, got %q", h.Get("X-Seen-Source"))
+	}
+	if h := do("/custom", "client"); h.Get("X-Seen-Source") != "custom" {
+		t.Errorf("expected mapping header to win over override, got %q", h.Get("X-Seen-Source"))
+	}
+}
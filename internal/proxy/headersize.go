@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// ErrHeaderTooLarge 上游响应头超出大小上限
+var ErrHeaderTooLarge = errors.New("upstream response header too large")
+
+// EventHeaderOversized 上游响应头字段超出大小上限(已删除或拒绝)
+const EventHeaderOversized = "header_oversized"
+
+// 响应头超限的处理方式(RESPONSE_HEADER_OVERSIZE)
+const (
+	headerOversizeStrip  = "strip"  // 删除超限的字段值,其余照常转发(默认)
+	headerOversizeReject = "reject" // 返回 502
+)
+
+// headerLimit 单个响应头字段(名称+值)的大小上限,nil表示不限制
+type headerLimit struct {
+	maxBytes int
+	reject   bool
+}
+
+// newHeaderLimit 创建响应头大小限制,maxBytes<=0 时返回nil(禁用)
+func newHeaderLimit(maxBytes int, action string) *headerLimit {
+	if maxBytes <= 0 {
+		return nil
+	}
+	switch action {
+	case "", headerOversizeStrip:
+	case headerOversizeReject:
+		return &headerLimit{maxBytes: maxBytes, reject: true}
+	default:
+		log.Printf("⚠️  无效的 RESPONSE_HEADER_OVERSIZE=%q,使用 %s", action, headerOversizeStrip)
+	}
+	return &headerLimit{maxBytes: maxBytes}
+}
+
+// enforce 检查响应头,返回超限的字段值个数;strip 模式下同时删除这些值
+// 多值头部(如多个 Set-Cookie)逐个判断,只删除超限的值
+func (l *headerLimit) enforce(header http.Header) int {
+	oversized := 0
+	for name, values := range header {
+		var kept []string
+		for i, value := range values {
+			if len(name)+len(value)+2 <= l.maxBytes { // "Name: value"
+				if kept != nil {
+					kept = append(kept, value)
+				}
+				continue
+			}
+			oversized++
+			if kept == nil {
+				kept = append(make([]string, 0, len(values)), values[:i]...)
+			}
+		}
+		if kept == nil || l.reject {
+			continue
+		}
+		if len(kept) == 0 {
+			delete(header, name)
+		} else {
+			header[name] = kept
+		}
+	}
+	return oversized
+}
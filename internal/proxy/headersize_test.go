@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestTransparentProxy_OversizedResponseHeader(t *testing.T) {
+	huge := strings.Repeat("x", 4096)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "small=1")
+		w.Header().Add("Set-Cookie", "big="+huge)
+		w.Header().Set("X-Trace", huge)
+		w.Header().Set("X-Request-Id", "abc")
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options:  map[string]mapping.Options{},
+	}
+
+	t.Run("strip", func(t *testing.T) {
+		mockStats := &MockStatsCollector{}
+		proxy := NewTransparentProxy(mapper, mockStats)
+		proxy.headerLimit = newHeaderLimit(1024, "strip")
+
+		w := httptest.NewRecorder()
+		if err := proxy.ProxyRequest(w, httptest.NewRequest("GET", "http://localhost/api/x", nil), "/api", "/x"); err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+		}
+		if cookies := w.Header().Values("Set-Cookie"); len(cookies) != 1 || cookies[0] != "small=1" {
+			t.Errorf("only the oversized cookie should be stripped, got %v", cookies)
+		}
+		if _, ok := w.Header()["X-Trace"]; ok {
+			t.Error("oversized X-Trace should be removed")
+		}
+		if w.Header().Get("X-Request-Id") != "abc" {
+			t.Error("small headers should be kept")
+		}
+		if len(mockStats.events) != 1 || mockStats.events[0] != EventHeaderOversized {
+			t.Errorf("expected header_oversized event, got %v", mockStats.events)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		mockStats := &MockStatsCollector{}
+		proxy := NewTransparentProxy(mapper, mockStats)
+		proxy.headerLimit = newHeaderLimit(1024, "reject")
+
+		w := httptest.NewRecorder()
+		err := proxy.ProxyRequest(w, httptest.NewRequest("GET", "http://localhost/api/x", nil), "/api", "/x")
+		var proxyErr *Error
+		if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusBadGateway || !errors.Is(err, ErrHeaderTooLarge) {
+			t.Fatalf("expected 502 header too large, got %v", err)
+		}
+		if w.Body.Len() != 0 {
+			t.Error("nothing should be written when rejecting")
+		}
+		if len(mockStats.events) != 1 || mockStats.events[0] != EventHeaderOversized || !mockStats.recordErrorCalled {
+			t.Errorf("expected header_oversized event and error, got %v / %v", mockStats.events, mockStats.recordErrorCalled)
+		}
+	})
+
+	t.Run("withinLimit", func(t *testing.T) {
+		mockStats := &MockStatsCollector{}
+		proxy := NewTransparentProxy(mapper, mockStats)
+		proxy.headerLimit = newHeaderLimit(8192, "reject")
+
+		w := httptest.NewRecorder()
+		if err := proxy.ProxyRequest(w, httptest.NewRequest("GET", "http://localhost/api/x", nil), "/api", "/x"); err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		if len(w.Header().Values("Set-Cookie")) != 2 || len(mockStats.events) != 0 {
+			t.Errorf("headers within the limit should pass untouched")
+		}
+	})
+}
+
+func TestNewHeaderLimit(t *testing.T) {
+	if newHeaderLimit(0, "reject") != nil {
+		t.Error("zero limit should disable the check")
+	}
+	if l := newHeaderLimit(10, "bogus"); l == nil || l.reject {
+		t.Error("unknown action should fall back to strip")
+	}
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// http10Body 预读部分与剩余上游响应体的组合(关闭时关闭上游响应体)
+type http10Body struct {
+	io.Reader
+	io.Closer
+}
+
+// prepareHTTP10 兼容 HTTP/1.0 客户端: 不支持分块编码和可靠的长连接
+// 总是在响应后关闭连接;长度未知的非流式响应预读(不超过 http10MaxBuffer)以设置 Content-Length,
+// 使客户端能判断响应是否完整;超出上限或流式响应(SSE、按行分隔的JSON流)仍以关闭连接标示结束
+func (p *TransparentProxy) prepareHTTP10(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+	if r.ProtoAtLeast(1, 1) {
+		return
+	}
+	w.Header().Set("Connection", "close")
+	if r.Method == http.MethodHead || resp.ContentLength >= 0 || p.http10MaxBuffer <= 0 ||
+		isEventStream(resp.Header.Get("Content-Type")) || isLineDelimitedJSON(resp.Header.Get("Content-Type")) {
+		return
+	}
+
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(io.LimitReader(resp.Body, int64(p.http10MaxBuffer)+1))
+	if err == nil && buf.Len() <= p.http10MaxBuffer {
+		resp.ContentLength = int64(buf.Len())
+		resp.Header.Set("Content-Length", strconv.Itoa(buf.Len()))
+		resp.Body = http10Body{bytes.NewReader(buf.Bytes()), resp.Body}
+		return
+	}
+	// 超出上限或读取失败: 已读部分照常转发,其余继续流式读取(读取错误由后续读取返回)
+	resp.Body = http10Body{io.MultiReader(&buf, resp.Body), resp.Body}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// http10Get 以 HTTP/1.0 发送请求并读取到连接关闭
+func http10Get(t *testing.T, addr, path string) (*http.Response, string) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET "+path+" HTTP/1.0\r\nHost: proxy.example\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading HTTP/1.0 response: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestTransparentProxy_HTTP10Client(t *testing.T) {
+	// 上游分块发送,响应长度未知(超过 net/http 自动计算长度的缓冲)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		for _, chunk := range []string{"hello ", "legacy ", strings.Repeat("x", 16<<10)} {
+			w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	proxy := NewTransparentProxy(mapper, nil)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := proxy.ProxyRequest(w, r, "/api", strings.TrimPrefix(r.URL.Path, "/api")); err != nil {
+			t.Errorf("proxy error: %v", err)
+		}
+	}))
+	defer front.Close()
+	addr := strings.TrimPrefix(front.URL, "http://")
+	want := "hello legacy " + strings.Repeat("x", 16<<10)
+
+	// 预读后带 Content-Length 返回,不使用分块编码
+	resp, body := http10Get(t, addr, "/api/x")
+	if body != want {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if len(resp.TransferEncoding) != 0 || resp.ContentLength != int64(len(want)) {
+		t.Errorf("expected Content-Length %d without chunked encoding, got length=%d encoding=%v",
+			len(want), resp.ContentLength, resp.TransferEncoding)
+	}
+	if !resp.Close {
+		t.Error("expected the connection to be closed for HTTP/1.0 clients")
+	}
+
+	// 超出预读上限时流式转发,以关闭连接标示结束
+	proxy.http10MaxBuffer = 8
+	resp, body = http10Get(t, addr, "/api/x")
+	if body != want {
+		t.Fatalf("unexpected streamed body %q", body)
+	}
+	if len(resp.TransferEncoding) != 0 || resp.ContentLength != -1 {
+		t.Errorf("expected close-delimited body, got length=%d encoding=%v", resp.ContentLength, resp.TransferEncoding)
+	}
+}
@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader 客户端幂等键请求头
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ErrIdempotencyInFlight 相同幂等键的首次请求尚未完成
+var ErrIdempotencyInFlight = errors.New("request with the same idempotency key is in progress")
+
+// ResponseStore 幂等响应存储接口(依赖倒置)
+// 键先以空值预占,首次请求完成后写入响应;空值表示仍在处理中
+type ResponseStore interface {
+	Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	Load(ctx context.Context, key string) ([]byte, bool, error)
+	Save(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+// SetResponseStore 设置幂等响应存储(nil表示禁用幂等去重)
+func (p *TransparentProxy) SetResponseStore(store ResponseStore) {
+	p.responses = store
+}
+
+// storedResponse 持久化的首次响应
+type storedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// idempotentRequest 幂等请求的首次执行记录
+// 作为 io.Writer 旁路收集响应体,超过上限后放弃缓存(转发不受影响)
+type idempotentRequest struct {
+	store    ResponseStore
+	key      string
+	ttl      time.Duration
+	limit    int
+	body     bytes.Buffer
+	overflow bool
+	done     bool
+}
+
+func (r *idempotentRequest) Write(b []byte) (int, error) {
+	if !r.overflow {
+		if r.body.Len()+len(b) > r.limit {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return len(b), nil
+}
+
+// commit 保存首次响应;5xx或响应体过大时释放键,允许客户端重试
+func (r *idempotentRequest) commit(ctx context.Context, statusCode int, header http.Header) {
+	r.done = true
+	if statusCode >= 500 || r.overflow {
+		r.release(ctx)
+		return
+	}
+
+	// Set-Cookie 属于首个调用方的会话,不缓存
+	header = header.Clone()
+	header.Del("Set-Cookie")
+	data, err := json.Marshal(storedResponse{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       r.body.Bytes(),
+	})
+	if err == nil {
+		err = r.store.Save(ctx, r.key, data, r.ttl)
+	}
+	if err != nil {
+		log.Printf("⚠️  保存幂等响应失败 [%s]: %v", r.key, err)
+		r.release(ctx)
+	}
+}
+
+// abort 请求未完成(上游失败/传输中断)时释放键
+func (r *idempotentRequest) abort(ctx context.Context) {
+	if !r.done {
+		r.release(ctx)
+	}
+}
+
+// release 释放预占的键
+func (r *idempotentRequest) release(ctx context.Context) {
+	if err := r.store.Release(ctx, r.key); err != nil {
+		log.Printf("⚠️  释放幂等键失败 [%s]: %v", r.key, err)
+	}
+}
+
+// idempotencyStoreKey 返回幂等键的存储键: 按客户端身份(API Key 摘要或客户端IP)、方法和路径隔离,
+// 不同客户端使用相同的幂等键时互不影响
+func (p *TransparentProxy) idempotencyStoreKey(r *http.Request, prefix, key string) string {
+	scope := sha256.New()
+	for _, part := range []string{p.apiKeys.Bucket(r), r.Method, r.URL.Path, key} {
+		scope.Write([]byte(part))
+		scope.Write([]byte{0})
+	}
+	return prefix + ":" + hex.EncodeToString(scope.Sum(nil))
+}
+
+// beginIdempotent 预占幂等键或回放已缓存的响应
+// 返回 replayed>0 表示已回放(值为回放的状态码);存储故障时降级为正常转发
+func (p *TransparentProxy) beginIdempotent(w http.ResponseWriter, r *http.Request, prefix, key string, ttl time.Duration) (*idempotentRequest, int, error) {
+	ctx := r.Context()
+	storeKey := p.idempotencyStoreKey(r, prefix, key)
+
+	reserved, err := p.responses.Reserve(ctx, storeKey, ttl)
+	if err != nil {
+		log.Printf("⚠️  预占幂等键失败 [%s]: %v", storeKey, err)
+		return nil, 0, nil
+	}
+	if reserved {
+		return &idempotentRequest{
+			store: p.responses,
+			key:   storeKey,
+			ttl:   ttl,
+			limit: p.idempotencyMaxBody,
+		}, 0, nil
+	}
+
+	data, found, err := p.responses.Load(ctx, storeKey)
+	if err != nil {
+		log.Printf("⚠️  读取幂等响应失败 [%s]: %v", storeKey, err)
+		return nil, 0, nil
+	}
+	if !found {
+		// 预占恰好过期,按普通请求转发
+		return nil, 0, nil
+	}
+	if len(data) == 0 {
+		return nil, 0, &Error{StatusCode: http.StatusConflict, Err: ErrIdempotencyInFlight}
+	}
+
+	var stored storedResponse
+	if err := json.Unmarshal(data, &stored); err != nil {
+		log.Printf("⚠️  解析幂等响应失败 [%s]: %v", storeKey, err)
+		return nil, 0, nil
+	}
+
+	header := w.Header()
+	for name, values := range stored.Header {
+		if name != "Set-Cookie" {
+			header[name] = values
+		}
+	}
+	w.WriteHeader(stored.StatusCode)
+	_, err = w.Write(stored.Body)
+	return nil, stored.StatusCode, err
+}
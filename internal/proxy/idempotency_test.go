@@ -0,0 +1,300 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// memoryResponseStore 内存版幂等响应存储(忽略TTL)
+type memoryResponseStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemoryResponseStore() *memoryResponseStore {
+	return &memoryResponseStore{data: make(map[string][]byte)}
+}
+
+func (s *memoryResponseStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data[key]; ok {
+		return false, nil
+	}
+	s.data[key] = nil
+	return true, nil
+}
+
+func (s *memoryResponseStore) Load(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[key]
+	return data, ok, nil
+}
+
+func (s *memoryResponseStore) Save(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = data
+	return nil
+}
+
+func (s *memoryResponseStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func newIdempotentTestProxy(t *testing.T, handler http.HandlerFunc) (*TransparentProxy, *memoryResponseStore) {
+	t.Helper()
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options:  map[string]mapping.Options{"/api": {IdempotencyTTL: 60}},
+	}
+	store := newMemoryResponseStore()
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.SetResponseStore(store)
+	return proxy, store
+}
+
+func doIdempotent(t *testing.T, proxy *TransparentProxy, key string) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "http://localhost/api/orders", nil)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return w, proxy.ProxyRequest(w, req, "/api", "/orders")
+}
+
+func TestTransparentProxy_IdempotencyReplay(t *testing.T) {
+	var calls atomic.Int32
+	proxy, _ := newIdempotentTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("X-Order", strconv.Itoa(int(n)))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("order-" + strconv.Itoa(int(n))))
+	})
+
+	first, err := doIdempotent(t, proxy, "key-1")
+	if err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+
+	// 窗口内重复的幂等键返回首次响应,不再请求上游
+	dup, err := doIdempotent(t, proxy, "key-1")
+	if err != nil {
+		t.Fatalf("duplicate request failed: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("duplicate key should not reach upstream, got %d calls", calls.Load())
+	}
+	if dup.Code != http.StatusCreated || dup.Body.String() != first.Body.String() || dup.Header().Get("X-Order") != "1" {
+		t.Fatalf("expected replayed response, got %d %q %v", dup.Code, dup.Body.String(), dup.Header())
+	}
+
+	// 新的幂等键正常转发
+	fresh, err := doIdempotent(t, proxy, "key-2")
+	if err != nil {
+		t.Fatalf("new key request failed: %v", err)
+	}
+	if calls.Load() != 2 || fresh.Body.String() != "order-2" {
+		t.Fatalf("new key should reach upstream, got calls=%d body=%q", calls.Load(), fresh.Body.String())
+	}
+
+	// 无幂等键的请求不受影响
+	doIdempotent(t, proxy, "")
+	doIdempotent(t, proxy, "")
+	if calls.Load() != 4 {
+		t.Fatalf("requests without key should always reach upstream, got %d calls", calls.Load())
+	}
+}
+
+func TestTransparentProxy_IdempotencyServerErrorNotCached(t *testing.T) {
+	var calls atomic.Int32
+	proxy, store := newIdempotentTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	doIdempotent(t, proxy, "key-1")
+	doIdempotent(t, proxy, "key-1")
+	if calls.Load() != 2 {
+		t.Fatalf("5xx responses should not be cached, got %d calls", calls.Load())
+	}
+	if len(store.data) != 0 {
+		t.Fatalf("reservation should be released after 5xx, got %v", store.data)
+	}
+}
+
+func TestTransparentProxy_IdempotencyInFlight(t *testing.T) {
+	proxy, store := newIdempotentTestProxy(t, func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest("POST", "http://localhost/api/orders", nil)
+	store.Reserve(context.Background(), proxy.idempotencyStoreKey(req, "/api", "key-1"), time.Minute)
+
+	_, err := doIdempotent(t, proxy, "key-1")
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusConflict || !errors.Is(err, ErrIdempotencyInFlight) {
+		t.Fatalf("expected 409 in-flight error, got %v", err)
+	}
+}
+
+func TestTransparentProxy_IdempotencyScopedByClient(t *testing.T) {
+	var calls atomic.Int32
+	proxy, store := newIdempotentTestProxy(t, func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "caller-" + strconv.Itoa(int(n))})
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(r.Header.Get("Authorization")))
+	})
+
+	send := func(auth, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "http://localhost/api"+path, nil)
+		req.Header.Set(IdempotencyKeyHeader, "shared-key")
+		req.Header.Set("Authorization", "Bearer "+auth)
+		if err := proxy.ProxyRequest(w, req, "/api", path); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return w
+	}
+
+	first := send("alice", "/orders")
+	if first.Header().Get("Set-Cookie") == "" {
+		t.Fatal("first response should carry the upstream cookie")
+	}
+
+	// 其他客户端、其他路径使用相同幂等键时正常转发,不回放他人的响应
+	if other := send("bob", "/orders"); other.Body.String() != "Bearer bob" {
+		t.Errorf("another client must not receive a cached response, got %q", other.Body.String())
+	}
+	send("alice", "/payments")
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 upstream calls, got %d", calls.Load())
+	}
+
+	// 同一客户端重复请求回放首次响应,但不回放 Set-Cookie
+	dup := send("alice", "/orders")
+	if calls.Load() != 3 || dup.Body.String() != "Bearer alice" {
+		t.Fatalf("expected replay for the same client, got calls=%d body=%q", calls.Load(), dup.Body.String())
+	}
+	if dup.Header().Get("Set-Cookie") != "" {
+		t.Errorf("Set-Cookie must not be replayed, got %q", dup.Header().Get("Set-Cookie"))
+	}
+	for key, data := range store.data {
+		if strings.Contains(string(data), "caller-") {
+			t.Errorf("Set-Cookie must not be stored under %s", key)
+		}
+	}
+}
+
+// TestTransparentProxy_IdempotencyCapturesForwardedBody 幂等记录必须捕获经过截断检测、空闲超时、
+// gzip 校验后实际转发的响应体:上游中途断开或停顿时不能把不完整的响应当作完整响应保存并重放
+func TestTransparentProxy_IdempotencyCapturesForwardedBody(t *testing.T) {
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte(strings.Repeat("order-1 ", 64)))
+	zw.Close()
+
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		wantEvent string
+		wantSaved bool
+	}{
+		{
+			name: "truncated",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "100")
+				w.Write([]byte("partial body"))
+			},
+			wantEvent: EventTruncatedResponse,
+		},
+		{
+			name: "stalled",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("head"))
+				w.(http.Flusher).Flush()
+				select {
+				case <-time.After(2 * time.Second):
+				case <-r.Context().Done():
+				}
+				w.Write([]byte("tail"))
+			},
+			wantEvent: EventIdleTimeout,
+		},
+		{
+			name: "gzip",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Write(gzipped.Bytes())
+			},
+			wantSaved: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				tt.handler(w, r)
+			}))
+			defer backend.Close()
+
+			mapper := &MockMappingManager{
+				mappings: map[string]string{"/api": backend.URL},
+				options:  map[string]mapping.Options{"/api": {IdempotencyTTL: 60, VerifyGzip: true}},
+			}
+			collector := &MockStatsCollector{}
+			proxy := NewTransparentProxy(mapper, collector)
+			proxy.SetResponseStore(newMemoryResponseStore())
+			proxy.idleTimeout = 50 * time.Millisecond
+
+			do := func() *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest("POST", "http://localhost/api/orders", nil)
+				req.Header.Set(IdempotencyKeyHeader, "key-1")
+				req.Header.Set("Accept-Encoding", "gzip")
+				proxy.ProxyRequest(w, req, "/api", "/orders")
+				return w
+			}
+
+			start := time.Now()
+			first := do()
+			if tt.name == "stalled" && time.Since(start) > time.Second {
+				t.Error("stalled upstream should be cut off by the idle timeout")
+			}
+			if tt.wantEvent != "" && !slices.Contains(collector.events, tt.wantEvent) {
+				t.Errorf("expected %s event, got %v", tt.wantEvent, collector.events)
+			}
+			if slices.Contains(collector.events, EventGzipCorrupt) {
+				t.Errorf("gzip body should be verified as it is forwarded, got %v", collector.events)
+			}
+
+			replay := do()
+			if saved := calls.Load() == 1; saved != tt.wantSaved {
+				t.Fatalf("response saved = %v, want %v (upstream calls: %d)", saved, tt.wantSaved, calls.Load())
+			}
+			if tt.wantSaved && !bytes.Equal(replay.Body.Bytes(), first.Body.Bytes()) {
+				t.Errorf("replayed body differs from the forwarded body")
+			}
+		})
+	}
+}
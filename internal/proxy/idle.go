@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrIdleTimeout 流式响应长时间未收到数据
+var ErrIdleTimeout = errors.New("upstream stream idle timeout")
+
+// EventIdleTimeout 流式响应空闲超时
+const EventIdleTimeout = "idle_timeout"
+
+// defaultIdleExemptTypes 默认不做空闲检测的内容类型(长连接推送)
+var defaultIdleExemptTypes = []string{"text/event-stream"}
+
+// idleTimeoutReader 读取空闲超时保护
+// 仅在等待上游数据期间计时(向客户端写入的耗时不计入),超时后取消上游请求
+type idleTimeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	fired   atomic.Bool
+}
+
+func newIdleTimeoutReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) *idleTimeoutReader {
+	ir := &idleTimeoutReader{r: r, timeout: timeout}
+	ir.timer = time.AfterFunc(timeout, func() {
+		ir.fired.Store(true)
+		cancel()
+	})
+	ir.timer.Stop()
+	return ir
+}
+
+func (ir *idleTimeoutReader) Read(p []byte) (int, error) {
+	ir.timer.Reset(ir.timeout)
+	n, err := ir.r.Read(p)
+	ir.timer.Stop()
+	if ir.fired.Load() {
+		return n, ErrIdleTimeout
+	}
+	return n, err
+}
+
+// idleExempt 判断响应内容类型是否豁免空闲检测
+func (p *TransparentProxy) idleExempt(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(contentType)
+	}
+	return slices.ContainsFunc(p.idleExemptTypes, func(t string) bool {
+		return strings.EqualFold(t, mediaType)
+	})
+}
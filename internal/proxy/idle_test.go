@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stallingBackend 先发送部分数据,随后保持连接但不再发送
+func stallingBackend(contentType string, stall time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(stall):
+			w.Write([]byte("data: second\n\n"))
+		case <-r.Context().Done():
+		}
+	}))
+}
+
+func TestTransparentProxy_IdleTimeout(t *testing.T) {
+	backend := stallingBackend("application/json", 5*time.Second)
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	collector := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, collector)
+	proxy.idleTimeout = 50 * time.Millisecond
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/api/stream", nil)
+
+	start := time.Now()
+	err := proxy.ProxyRequest(w, req, "/api", "/stream")
+	if !errors.Is(err, ErrIdleTimeout) {
+		t.Fatalf("expected idle timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("idle timeout should trigger quickly, took %s", elapsed)
+	}
+	if w.Body.String() != "data: first\n\n" {
+		t.Fatalf("data received before the stall should be forwarded, got %q", w.Body.String())
+	}
+	if len(collector.events) != 1 || collector.events[0] != EventIdleTimeout {
+		t.Fatalf("expected idle_timeout event, got %v", collector.events)
+	}
+}
+
+func TestTransparentProxy_IdleTimeoutSSEExempt(t *testing.T) {
+	backend := stallingBackend("text/event-stream; charset=utf-8", 150*time.Millisecond)
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	collector := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, collector)
+	proxy.idleTimeout = 50 * time.Millisecond
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/api/events", nil)
+	if err := proxy.ProxyRequest(w, req, "/api", "/events"); err != nil {
+		t.Fatalf("slow SSE stream should not time out, got %v", err)
+	}
+	if w.Body.String() != "data: first\n\ndata: second\n\n" {
+		t.Fatalf("expected full SSE stream, got %q", w.Body.String())
+	}
+	if len(collector.events) != 0 {
+		t.Fatalf("SSE stream should not record idle events, got %v", collector.events)
+	}
+}
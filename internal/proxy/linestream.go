@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// lineDelimitedJSONTypes 按行分隔的JSON流媒体类型(每行一个完整JSON对象)
+var lineDelimitedJSONTypes = map[string]bool{
+	"application/stream+json": true,
+	"application/x-ndjson":    true,
+}
+
+// isLineDelimitedJSON 判断响应是否为按行分隔的JSON流
+func isLineDelimitedJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(contentType)
+	}
+	return lineDelimitedJSONTypes[strings.ToLower(mediaType)]
+}
+
+// lineFlushWriter 按行边界刷新: 每当写入内容包含换行,写出至最后一个换行为止的完整行并立即 Flush;
+// 剩余的不完整行照常写入但不主动 Flush,通常随后续换行或响应结束一起发送。
+// 不完整行不会被扣留: 超过服务器写缓冲的长行仍可能分段到达,客户端需自行按换行拼接
+type lineFlushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func newLineFlushWriter(w http.ResponseWriter) *lineFlushWriter {
+	return &lineFlushWriter{w: w, rc: http.NewResponseController(w)}
+}
+
+func (l *lineFlushWriter) Write(p []byte) (int, error) {
+	end := bytes.LastIndexByte(p, '\n') + 1
+	if end == 0 {
+		return l.w.Write(p)
+	}
+	n, err := l.w.Write(p[:end])
+	if err != nil {
+		return n, err
+	}
+	l.rc.Flush()
+	m, err := l.w.Write(p[end:])
+	return n + m, err
+}
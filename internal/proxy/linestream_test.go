@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransparentProxy_FlushesJSONLines(t *testing.T) {
+	// 后端每发送一行(其中一行分两次写出)就等待客户端确认收到,未及时刷新时测试超时
+	received := make(chan struct{}, 3)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/stream+json; charset=utf-8")
+		for _, chunk := range []string{`{"n":1}` + "\n" + `{"n":`, `2}` + "\n", `{"n":3}` + "\n"} {
+			w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+			if chunk[len(chunk)-1] != '\n' {
+				continue
+			}
+			select {
+			case <-received:
+			case <-time.After(2 * time.Second):
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	proxy := NewTransparentProxy(mapper, nil)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := proxy.ProxyRequest(w, r, "/api", "/stream"); err != nil {
+			t.Errorf("ProxyRequest failed: %v", err)
+		}
+	}))
+	defer front.Close()
+
+	resp, err := http.Get(front.URL + "/api/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	for _, want := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		start := time.Now()
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading %s: %v", want, err)
+		}
+		if line != want+"\n" {
+			t.Fatalf("expected intact line %s, got %q", want, line)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("line %s arrived after %s, expected prompt flush", want, elapsed)
+		}
+		received <- struct{}{}
+	}
+}
+
+func TestIsLineDelimitedJSON(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/stream+json":             true,
+		"application/x-ndjson; charset=utf-8": true,
+		"Application/Stream+JSON":             true,
+		"application/json":                    false,
+		"text/event-stream":                   false,
+		"":                                    false,
+	} {
+		if got := isLineDelimitedJSON(contentType); got != want {
+			t.Errorf("%q: got %v, want %v", contentType, got, want)
+		}
+	}
+}
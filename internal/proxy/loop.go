@@ -0,0 +1,24 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+
+	"api-proxy/internal/mapping"
+)
+
+// ErrLoopDetected 映射目标指向代理自身,转发会导致请求回环
+var ErrLoopDetected = errors.New("mapping target points back at the proxy")
+
+// EventLoopDetected 因回环被拒绝的请求
+const EventLoopDetected = "loop_detected"
+
+// isLoop 判断目标是否指向代理自身(配置的自身地址或客户端访问使用的 Host)
+func (p *TransparentProxy) isLoop(targetBase string, r *http.Request) bool {
+	return p.self.Matches(targetBase) || mapping.IsSelfHost(targetBase, r.Host)
+}
+
+// loopError 返回 508 Loop Detected
+func loopError() *Error {
+	return &Error{StatusCode: http.StatusLoopDetected, Err: ErrLoopDetected}
+}
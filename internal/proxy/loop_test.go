@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestTransparentProxy_LoopDetected(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+	}))
+	defer backend.Close()
+
+	mockStats := &MockStatsCollector{}
+	mapper := &MockMappingManager{mappings: map[string]string{
+		"/self":  "http://proxy.example.com:8000",
+		"/host":  "https://public.example.com",
+		"/other": backend.URL,
+	}}
+	proxy := NewTransparentProxy(mapper, mockStats)
+	proxy.self = mapping.NewSelfAddresses([]string{"proxy.example.com:8000"})
+
+	// 配置的自身地址
+	req := httptest.NewRequest("GET", "http://localhost/self/x", nil)
+	err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/self", "/x")
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusLoopDetected || !errors.Is(err, ErrLoopDetected) {
+		t.Fatalf("expected 508 loop detected, got %v", err)
+	}
+	if !slices.Contains(mockStats.events, EventLoopDetected) || !mockStats.recordErrorCalled {
+		t.Errorf("expected loop event and error recorded, got %v", mockStats.events)
+	}
+
+	// 目标与客户端访问代理使用的 Host 相同
+	req = httptest.NewRequest("GET", "https://public.example.com/host/x", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/host", "/x"); !errors.Is(err, ErrLoopDetected) {
+		t.Fatalf("expected loop detected via Host, got %v", err)
+	}
+
+	// 其他目标正常转发
+	req = httptest.NewRequest("GET", "http://localhost/other/x", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/other", "/x"); err != nil {
+		t.Fatal(err)
+	}
+	if upstreamCalls.Load() != 1 {
+		t.Errorf("expected only the non-loop request upstream, got %d", upstreamCalls.Load())
+	}
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+
+	"api-proxy/internal/mapping"
+)
+
+// EventMaintenance 维护模式期间被拒绝的请求
+const EventMaintenance = "maintenance"
+
+// MaintenanceSource 维护模式查询接口(依赖倒置)
+type MaintenanceSource interface {
+	GetMaintenance(prefix string) (mapping.Maintenance, bool)
+}
+
+// SetMaintenanceSource 设置维护模式来源(nil表示禁用)
+func (p *TransparentProxy) SetMaintenanceSource(source MaintenanceSource) {
+	p.maintenance = source
+}
+
+// serveMaintenance 前缀处于维护模式时写出配置的 503 响应并返回 true
+func (p *TransparentProxy) serveMaintenance(w http.ResponseWriter, prefix string) bool {
+	if p.maintenance == nil {
+		return false
+	}
+	maint, ok := p.maintenance.GetMaintenance(prefix)
+	if !ok {
+		return false
+	}
+
+	contentType, body := maint.Body()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	if maint.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(maint.RetryAfter))
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte(body))
+	return true
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+// fakeMaintenance 维护模式来源的内存实现
+type fakeMaintenance struct {
+	mu      sync.Mutex
+	entries map[string]mapping.Maintenance
+}
+
+func (f *fakeMaintenance) GetMaintenance(prefix string) (mapping.Maintenance, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if m, ok := f.entries[prefix]; ok {
+		return m, true
+	}
+	m, ok := f.entries[mapping.MaintenanceGlobal]
+	return m, ok
+}
+
+func (f *fakeMaintenance) set(key string, m mapping.Maintenance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries[key] = m
+}
+
+func (f *fakeMaintenance) clear(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.entries, key)
+}
+
+func TestTransparentProxy_Maintenance(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		w.Write([]byte("upstream"))
+	}))
+	defer backend.Close()
+
+	mockStats := &MockStatsCollector{}
+	mapper := &MockMappingManager{mappings: map[string]string{
+		"/api":   backend.URL,
+		"/other": backend.URL,
+	}}
+	proxy := NewTransparentProxy(mapper, mockStats)
+	maint := &fakeMaintenance{entries: map[string]mapping.Maintenance{}}
+	proxy.SetMaintenanceSource(maint)
+
+	do := func(prefix string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://localhost"+prefix+"/x", nil)
+		if err := proxy.ProxyRequest(rec, req, prefix, "/x"); err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+
+	// 前缀级维护: 返回配置的页面,其他前缀不受影响
+	maint.set("/api", mapping.Maintenance{Message: "<h1>down</h1>", ContentType: "text/html", RetryAfter: 120})
+	rec := do("/api")
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "<h1>down</h1>" {
+		t.Fatalf("expected configured 503 page, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Type") != "text/html" || rec.Header().Get("Retry-After") != "120" {
+		t.Errorf("unexpected headers: %v", rec.Header())
+	}
+	if upstreamCalls.Load() != 0 {
+		t.Fatalf("maintenance request must not reach upstream")
+	}
+	if !slices.Contains(mockStats.events, EventMaintenance) {
+		t.Errorf("expected maintenance event, got %v", mockStats.events)
+	}
+	if rec := do("/other"); rec.Code != http.StatusOK || upstreamCalls.Load() != 1 {
+		t.Fatalf("other prefix should be proxied, got %d", rec.Code)
+	}
+
+	// 全局维护: 未配置内容时使用默认页面
+	maint.set(mapping.MaintenanceGlobal, mapping.Maintenance{})
+	rec = do("/other")
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != mapping.DefaultMaintenanceMessage {
+		t.Fatalf("expected default 503 page, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Errorf("Retry-After should be omitted when not configured")
+	}
+
+	// 关闭后恢复转发
+	maint.clear(mapping.MaintenanceGlobal)
+	maint.clear("/api")
+	if rec := do("/api"); rec.Code != http.StatusOK || rec.Body.String() != "upstream" {
+		t.Fatalf("expected proxying restored, got %d %q", rec.Code, rec.Body.String())
+	}
+	if upstreamCalls.Load() != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", upstreamCalls.Load())
+	}
+}
+
+func TestTransparentProxy_MaintenanceHead(t *testing.T) {
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": "http://203.0.113.1"}}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.SetMaintenanceSource(&fakeMaintenance{entries: map[string]mapping.Maintenance{
+		mapping.MaintenanceGlobal: {Message: "down"},
+	}})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("HEAD", "http://localhost/api/x", nil)
+	if err := proxy.ProxyRequest(rec, req, "/api", "/x"); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.Len() != 0 {
+		t.Fatalf("expected bodiless 503 for HEAD, got %d %q", rec.Code, rec.Body.String())
+	}
+}
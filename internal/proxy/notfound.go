@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+
+	"api-proxy/internal/mapping"
+)
+
+// 上游404处理事件
+const (
+	EventNotFoundFallback = "not_found_fallback" // 转发到备用目标
+	EventNotFoundMessage  = "not_found_message"  // 返回自定义内容
+)
+
+// fallbackOnNotFound 上游返回404且配置了备用目标时转发到备用目标
+// 仅适用于无请求体的请求(请求体已发送给主目标,不可重放);备用目标失败时保留原404响应
+func (p *TransparentProxy) fallbackOnNotFound(ctx context.Context, r *http.Request, resp *http.Response, prefix, rest string, opts mapping.Options) *http.Response {
+	action := opts.OnUpstream404
+	if action == nil || action.Action != mapping.NotFoundFallback || resp.StatusCode != http.StatusNotFound {
+		return resp
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		return resp
+	}
+
+	fallback, err := p.send(ctx, r, prefix, action.FallbackURL(rest, r.URL.RawQuery), opts)
+	if err != nil {
+		log.Printf("⚠️  404备用目标请求失败,返回原响应: %v", err)
+		return resp
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, headDrainLimit))
+	resp.Body.Close()
+	return fallback
+}
+
+// writeNotFoundMessage 上游返回404且配置了自定义内容时写出该内容并返回 true
+func writeNotFoundMessage(w http.ResponseWriter, resp *http.Response, opts mapping.Options) bool {
+	action := opts.OnUpstream404
+	if action == nil || action.Action != mapping.NotFoundMessage || resp.StatusCode != http.StatusNotFound {
+		return false
+	}
+	contentType, body := action.Body()
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusNotFound)
+	io.WriteString(w, body)
+	return true
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func newNotFoundTestProxy(t *testing.T, action *mapping.NotFoundAction) (*TransparentProxy, *MockStatsCollector) {
+	t.Helper()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/exists" {
+			w.Write([]byte("primary"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	}))
+	t.Cleanup(primary.Close)
+
+	mockStats := &MockStatsCollector{}
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": primary.URL},
+		options:  map[string]mapping.Options{"/api": {OnUpstream404: action}},
+	}
+	return NewTransparentProxy(mapper, mockStats), mockStats
+}
+
+func doNotFound(t *testing.T, proxy *TransparentProxy, method, rest string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body io.Reader
+	if method == "POST" {
+		body = strings.NewReader("payload")
+	}
+	req := httptest.NewRequest(method, "http://localhost/api"+rest+"?q=1", body)
+	rec := httptest.NewRecorder()
+	if err := proxy.ProxyRequest(rec, req, "/api", rest); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestTransparentProxy_NotFoundPassthrough(t *testing.T) {
+	proxy, _ := newNotFoundTestProxy(t, &mapping.NotFoundAction{Action: mapping.NotFoundPassthrough})
+	rec := doNotFound(t, proxy, "GET", "/missing")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != `{"error":"not found"}` {
+		t.Fatalf("expected upstream 404 passed through, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTransparentProxy_NotFoundFallback(t *testing.T) {
+	var fallbackURL string
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackURL = r.URL.String()
+		w.Write([]byte("fallback"))
+	}))
+	defer fallback.Close()
+
+	proxy, mockStats := newNotFoundTestProxy(t, &mapping.NotFoundAction{Action: mapping.NotFoundFallback, Target: fallback.URL + "/v2"})
+
+	rec := doNotFound(t, proxy, "GET", "/missing")
+	if rec.Code != http.StatusOK || rec.Body.String() != "fallback" {
+		t.Fatalf("expected fallback response, got %d %q", rec.Code, rec.Body.String())
+	}
+	if fallbackURL != "/v2/missing?q=1" {
+		t.Errorf("expected path and query forwarded to fallback, got %q", fallbackURL)
+	}
+	if !slices.Contains(mockStats.events, EventNotFoundFallback) {
+		t.Errorf("expected fallback event, got %v", mockStats.events)
+	}
+
+	// 主目标成功时不访问备用目标
+	fallbackURL = ""
+	if rec := doNotFound(t, proxy, "GET", "/exists"); rec.Body.String() != "primary" || fallbackURL != "" {
+		t.Errorf("expected primary response, got %q (fallback %q)", rec.Body.String(), fallbackURL)
+	}
+
+	// 带请求体的请求不可重放,保留原404
+	if rec := doNotFound(t, proxy, "POST", "/missing"); rec.Code != http.StatusNotFound || fallbackURL != "" {
+		t.Errorf("expected original 404 for request with body, got %d (fallback %q)", rec.Code, fallbackURL)
+	}
+}
+
+func TestTransparentProxy_NotFoundFallbackUnavailable(t *testing.T) {
+	proxy, _ := newNotFoundTestProxy(t, &mapping.NotFoundAction{Action: mapping.NotFoundFallback, Target: "http://127.0.0.1:1"})
+	rec := doNotFound(t, proxy, "GET", "/missing")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != `{"error":"not found"}` {
+		t.Fatalf("expected original 404 when fallback fails, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTransparentProxy_NotFoundMessage(t *testing.T) {
+	proxy, mockStats := newNotFoundTestProxy(t, &mapping.NotFoundAction{
+		Action: mapping.NotFoundMessage, Message: "<p>Nothing here</p>", ContentType: "text/html",
+	})
+	rec := doNotFound(t, proxy, "GET", "/missing")
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "<p>Nothing here</p>" || rec.Header().Get("Content-Type") != "text/html" {
+		t.Fatalf("expected custom message, got %d %q %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if !slices.Contains(mockStats.events, EventNotFoundMessage) {
+		t.Errorf("expected message event, got %v", mockStats.events)
+	}
+	if rec := doNotFound(t, proxy, "GET", "/exists"); rec.Body.String() != "primary" {
+		t.Errorf("expected primary response for non-404, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/plugin"
+)
+
+// EventPluginFailed WASM 插件转换失败被拒绝的请求
+const EventPluginFailed = "plugin_failed"
+
+// ErrPlugin 请求转换插件执行失败
+var ErrPlugin = errors.New("request plugin failed")
+
+// RequestTransformer 请求转换插件接口(依赖倒置)
+type RequestTransformer interface {
+	Transform(ctx context.Context, path string, timeout time.Duration, req *plugin.Request) error
+}
+
+// SetTransformer 设置请求转换插件的运行时(nil时配置了插件的映射拒绝请求)
+func (p *TransparentProxy) SetTransformer(transformer RequestTransformer) {
+	p.transformer = transformer
+}
+
+// applyPlugin 以映射配置的插件转换请求头和查询参数,返回转换后的转发路径
+// 插件失败时返回 502,不以未转换的请求访问上游
+func (p *TransparentProxy) applyPlugin(r *http.Request, rest string, opts mapping.Options) (string, error) {
+	if opts.Plugin == nil {
+		return rest, nil
+	}
+	if p.transformer == nil {
+		return "", pluginError(errors.New("plugin runtime is not configured"))
+	}
+
+	req := &plugin.Request{Method: r.Method, Path: rest, RawQuery: r.URL.RawQuery, Headers: r.Header}
+	if err := p.transformer.Transform(r.Context(), opts.Plugin.Path, opts.Plugin.Timeout(), req); err != nil {
+		log.Printf("⚠️  请求转换插件 %s 执行失败: %v", opts.Plugin.Path, err)
+		return "", pluginError(err)
+	}
+	// 路径直接拼接在目标之后,必须以 / 开头,避免改写目标主机(如 "@evil.example.com")
+	if req.Path != "" && !strings.HasPrefix(req.Path, "/") {
+		return "", pluginError(fmt.Errorf("plugin returned invalid path %q", req.Path))
+	}
+
+	header := make(http.Header, len(req.Headers))
+	for name, values := range req.Headers {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	r.Header = header
+	r.URL.RawQuery = req.RawQuery
+	return req.Path, nil
+}
+
+func pluginError(err error) error {
+	return &Error{StatusCode: http.StatusBadGateway, Err: fmt.Errorf("%w: %v", ErrPlugin, err)}
+}
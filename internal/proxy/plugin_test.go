@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/plugin"
+)
+
+// buildExamplePlugin 编译 examples/plugins/addheader 为 wasm,没有可用的Go工具链时跳过
+func buildExamplePlugin(t *testing.T) string {
+	t.Helper()
+	goBin := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(goBin); err != nil {
+		t.Skipf("go toolchain not available: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "addheader.wasm")
+	cmd := exec.Command(goBin, "build", "-buildmode=c-shared", "-o", path, "../../examples/plugins/addheader")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("cannot build example plugin: %v\n%s", err, out)
+	}
+	return path
+}
+
+func TestTransparentProxy_WASMPlugin(t *testing.T) {
+	path := buildExamplePlugin(t)
+
+	var gotPath, gotHeader, gotClient string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.RequestURI()
+		gotHeader = r.Header.Get("X-Plugin")
+		gotClient = r.Header.Get("X-Client")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options:  map[string]mapping.Options{"/api": {Plugin: &mapping.Plugin{Path: path, TimeoutMs: 5000}}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	ctx := context.Background()
+	rt := plugin.NewRuntime(ctx, plugin.Config{})
+	defer rt.Close(ctx)
+	proxy.SetTransformer(rt)
+
+	req := httptest.NewRequest("GET", "http://localhost/api/v1/models?limit=5", nil)
+	req.Header.Set("X-Client", "test")
+	w := httptest.NewRecorder()
+	if err := proxy.ProxyRequest(w, req, "/api", "/v1/models"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if gotHeader != "addheader" {
+		t.Errorf("upstream X-Plugin = %q, want addheader", gotHeader)
+	}
+	if gotPath != "/v2/models?limit=5" {
+		t.Errorf("upstream path = %q, want /v2/models?limit=5", gotPath)
+	}
+	if gotClient != "test" {
+		t.Errorf("client header should be preserved, got %q", gotClient)
+	}
+}
+
+// stubTransformer 以函数实现的插件运行时
+type stubTransformer func(req *plugin.Request) error
+
+func (s stubTransformer) Transform(ctx context.Context, path string, timeout time.Duration, req *plugin.Request) error {
+	return s(req)
+}
+
+func TestTransparentProxy_PluginFailsClosed(t *testing.T) {
+	reached := false
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options:  map[string]mapping.Options{"/api": {Plugin: &mapping.Plugin{Path: "plugin.wasm"}}},
+	}
+	mockStats := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	tests := []struct {
+		name        string
+		transformer RequestTransformer
+	}{
+		{"noRuntime", nil},
+		{"pluginError", stubTransformer(func(req *plugin.Request) error { return errors.New("trap") })},
+		{"hostRewrite", stubTransformer(func(req *plugin.Request) error {
+			req.Path = "@evil.example.com/"
+			return nil
+		})},
+	}
+	for _, tt := range tests {
+		proxy.SetTransformer(tt.transformer)
+		err := proxy.ProxyRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost/api/x", nil), "/api", "/x")
+		var proxyErr *Error
+		if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusBadGateway || !errors.Is(err, ErrPlugin) {
+			t.Errorf("%s: expected 502 plugin error, got %v", tt.name, err)
+		}
+	}
+	if reached {
+		t.Error("failed plugin should not reach the upstream")
+	}
+	if len(mockStats.events) != len(tests) || mockStats.events[0] != EventPluginFailed {
+		t.Errorf("expected plugin_failed events, got %v", mockStats.events)
+	}
+}
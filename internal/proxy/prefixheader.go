@@ -0,0 +1,24 @@
+package proxy
+
+import (
+	"net/http"
+
+	"api-proxy/internal/mapping"
+)
+
+// DefaultPrefixHeader 向上游传递匹配的映射前缀的默认请求头
+const DefaultPrefixHeader = "X-Proxy-Prefix"
+
+// prefixHeader 向上游传递请求匹配的映射前缀,便于上游得知自己挂载在代理的哪个路径下
+// 全局开启(FORWARD_PREFIX)时对所有映射生效,否则仅对开启 forward_prefix 的映射生效
+type prefixHeader struct {
+	name string
+	all  bool
+}
+
+// apply 在转发的请求头中设置匹配的前缀(覆盖客户端传入的同名头)
+func (h prefixHeader) apply(r *http.Request, prefix string, opts mapping.Options) {
+	if h.all || opts.ForwardPrefix {
+		r.Header.Set(h.name, prefix)
+	}
+}
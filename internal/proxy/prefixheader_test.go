@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestTransparentProxy_ForwardPrefix(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(DefaultPrefixHeader)))
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api/v1/chat": backend.URL, "/plain": backend.URL},
+		options:  map[string]mapping.Options{"/api/v1/chat": {ForwardPrefix: true}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	do := func(prefix, rest, spoofed string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://proxy.example"+prefix+rest, nil)
+		if spoofed != "" {
+			req.Header.Set(DefaultPrefixHeader, spoofed)
+		}
+		if err := proxy.ProxyRequest(w, req, prefix, rest); err != nil {
+			t.Fatal(err)
+		}
+		return w.Body.String()
+	}
+
+	// 多段前缀完整传递,客户端传入的同名头被覆盖
+	if got := do("/api/v1/chat", "/completions", "/spoofed"); got != "/api/v1/chat" {
+		t.Errorf("expected matched multi-segment prefix, got %q", got)
+	}
+	// 未开启的映射保持透明
+	if got := do("/plain", "/x", ""); got != "" {
+		t.Errorf("mapping without forward_prefix should not get the header, got %q", got)
+	}
+
+	// 全局开启对所有映射生效
+	proxy.prefixHeader.all = true
+	if got := do("/plain", "/x", ""); got != "/plain" {
+		t.Errorf("expected prefix header when enabled globally, got %q", got)
+	}
+}
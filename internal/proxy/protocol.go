@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// ErrUpstreamProtocol 上游响应不符合HTTP协议,无法解析
+var ErrUpstreamProtocol = errors.New("malformed upstream response")
+
+// EventProtocolError 上游响应解析失败
+const EventProtocolError = "upstream_protocol_errors"
+
+// protocolErrorMarkers net/http 解析响应失败时的错误信息特征(相关错误类型未导出)
+var protocolErrorMarkers = []string{
+	"malformed HTTP",
+	"malformed MIME header",
+}
+
+// isProtocolError 判断上游错误是否为响应解析/协议错误
+// 此类错误发生时 Transport 会关闭该连接,不会放回连接池
+func isProtocolError(err error) bool {
+	var protoErr textproto.ProtocolError
+	if errors.As(err, &protoErr) {
+		return true
+	}
+	msg := err.Error()
+	for _, marker := range protocolErrorMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// protocolError 将协议错误转换为 502 响应
+func protocolError(err error) *Error {
+	return &Error{StatusCode: http.StatusBadGateway, Err: fmt.Errorf("%w: %v", ErrUpstreamProtocol, err)}
+}
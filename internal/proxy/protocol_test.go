@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+)
+
+// malformedBackend 第一个连接返回无法解析的响应,之后的连接返回正常响应
+func malformedBackend(t *testing.T, conns *atomic.Int32) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			n := conns.Add(1)
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					if _, err := http.ReadRequest(br); err != nil {
+						return
+					}
+					if n == 1 {
+						conn.Write([]byte("HTTP/1.1 OOPS garbage\r\n\r\n"))
+						return
+					}
+					conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"))
+				}
+			}()
+		}
+	}()
+	return "http://" + ln.Addr().String()
+}
+
+func TestTransparentProxy_MalformedUpstreamResponse(t *testing.T) {
+	var conns atomic.Int32
+	backend := malformedBackend(t, &conns)
+
+	mockStats := &MockStatsCollector{}
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend}}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	req := httptest.NewRequest("GET", "http://localhost/api/x", nil)
+	err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/x")
+
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected 502 proxy error, got %v", err)
+	}
+	if !errors.Is(err, ErrUpstreamProtocol) {
+		t.Fatalf("expected ErrUpstreamProtocol, got %v", err)
+	}
+	if !slices.Contains(mockStats.events, EventProtocolError) {
+		t.Fatalf("expected %s event, got %v", EventProtocolError, mockStats.events)
+	}
+
+	// 出错的连接不得被复用:下一个请求应建立新连接并成功
+	w := httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://localhost/api/x", nil)
+	if err := proxy.ProxyRequest(w, req, "/api", "/x"); err != nil {
+		t.Fatalf("expected follow-up request to succeed, got %v", err)
+	}
+	if w.Body.String() != "ok" || conns.Load() != 2 {
+		t.Fatalf("expected a fresh connection, got body=%q conns=%d", w.Body.String(), conns.Load())
+	}
+}
+
+func TestIsProtocolError(t *testing.T) {
+	if isProtocolError(errors.New("dial tcp: connection refused")) {
+		t.Error("connection errors are not protocol errors")
+	}
+	if !isProtocolError(errors.New(`net/http: HTTP/1.x transport connection broken: malformed HTTP status code "OOPS"`)) {
+		t.Error("malformed status line should be a protocol error")
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-proxy/internal/config"
+	"api-proxy/internal/stats"
+)
+
+// QuotaRemainingHeader 响应中返回的当日剩余配额
+const QuotaRemainingHeader = "X-Quota-Remaining"
+
+// EventQuotaExceeded 因超出每日配额被拒绝的请求
+const EventQuotaExceeded = "quota_exceeded"
+
+// ErrQuotaExceeded API Key 当日配额已用尽
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+// QuotaStore 每日配额计数接口(依赖倒置)
+type QuotaStore interface {
+	Consume(ctx context.Context, prefix, key string, day time.Time) (int64, error)
+}
+
+// apiKeySource 读取 API Key 的位置: API_KEY_SOURCE(header:<名称> 或 query:<参数名>),
+// 未设置时沿用 QUOTA_API_KEY_HEADER(默认 Authorization)
+func apiKeySource() stats.KeySource {
+	spec := config.String("API_KEY_SOURCE", "")
+	if spec == "" {
+		spec = config.String("QUOTA_API_KEY_HEADER", "Authorization")
+	}
+	source, err := stats.ParseKeySource(spec)
+	if err != nil {
+		log.Printf("⚠️  %v,使用 %s", err, stats.DefaultKeySource)
+		return stats.DefaultKeySource
+	}
+	return source
+}
+
+// ClientBucket 返回请求的限流/配额分桶键: API Key 摘要,未携带时为客户端IP
+func (p *TransparentProxy) ClientBucket(r *http.Request) string {
+	return p.apiKeys.Bucket(r)
+}
+
+// APIKey 返回请求按 API_KEY_SOURCE 携带的 API Key(用于按 Key 统计用量),未携带时返回空
+func (p *TransparentProxy) APIKey(r *http.Request) string {
+	return p.apiKeys.Key(r)
+}
+
+// SetQuotaStore 设置配额计数存储(nil表示禁用配额)
+func (p *TransparentProxy) SetQuotaStore(store QuotaStore) {
+	p.quotas = store
+}
+
+// checkQuota 计入一次请求并写出剩余配额头,超出配额时返回 429
+// 按 API Key 摘要计数,未携带 API Key 的请求按客户端IP计数;计数存储故障时放行,避免配额影响可用性
+func (p *TransparentProxy) checkQuota(w http.ResponseWriter, r *http.Request, prefix string, limit int64) error {
+	if p.quotas == nil || limit <= 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	used, err := p.quotas.Consume(r.Context(), prefix, p.apiKeys.Bucket(r), now)
+	if err != nil {
+		log.Printf("⚠️  Quota check failed for %s: %v", prefix, err)
+		return nil
+	}
+
+	remaining := max(limit-used, 0)
+	w.Header().Set(QuotaRemainingHeader, strconv.FormatInt(remaining, 10))
+	if used > limit {
+		// 配额在次日 00:00 UTC 重置
+		reset := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		return &Error{StatusCode: http.StatusTooManyRequests, RetryAfter: reset.Sub(now), Err: ErrQuotaExceeded}
+	}
+	return nil
+}
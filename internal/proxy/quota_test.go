@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/storage"
+)
+
+// failingQuotaStore 模拟计数存储故障
+type failingQuotaStore struct{}
+
+func (failingQuotaStore) Consume(context.Context, string, string, time.Time) (int64, error) {
+	return 0, errors.New("redis down")
+}
+
+func TestTransparentProxy_DailyQuota(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	mockStats := &MockStatsCollector{}
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/ai": backend.URL, "/free": backend.URL},
+		options:  map[string]mapping.Options{"/ai": {DailyQuota: 2}},
+	}
+	proxy := NewTransparentProxy(mapper, mockStats)
+	proxy.SetQuotaStore(storage.NewQuotaStore(client))
+
+	do := func(prefix, auth string) (*httptest.ResponseRecorder, error) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://localhost"+prefix+"/v1", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		return rec, proxy.ProxyRequest(rec, req, prefix, "/v1")
+	}
+
+	for _, want := range []string{"1", "0"} {
+		rec, err := do("/ai", "Bearer key-a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK || rec.Header().Get(QuotaRemainingHeader) != want {
+			t.Fatalf("expected 200 with remaining=%s, got %d remaining=%q", want, rec.Code, rec.Header().Get(QuotaRemainingHeader))
+		}
+	}
+
+	// 配额用尽: 429 且 remaining=0,直到次日重置
+	rec, err := do("/ai", "Bearer key-a")
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusTooManyRequests || !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected 429 quota exceeded, got %v", err)
+	}
+	if rec.Header().Get(QuotaRemainingHeader) != "0" {
+		t.Errorf("expected remaining=0, got %q", rec.Header().Get(QuotaRemainingHeader))
+	}
+	if seconds := proxyErr.RetryAfterSeconds(); seconds <= 0 || seconds > 86400 {
+		t.Errorf("expected Retry-After until next UTC day, got %d", seconds)
+	}
+	if !slices.Contains(mockStats.events, EventQuotaExceeded) {
+		t.Errorf("expected quota event, got %v", mockStats.events)
+	}
+	if !slices.Contains(mockStats.statuses, http.StatusTooManyRequests) {
+		t.Errorf("expected quota rejection recorded as 429, got %v", mockStats.statuses)
+	}
+
+	// 其他Key、未配置配额的前缀、未携带Key的请求(按客户端IP计数)不受影响
+	if rec, err := do("/ai", "Bearer key-b"); err != nil || rec.Header().Get(QuotaRemainingHeader) != "1" {
+		t.Errorf("expected independent quota for other key, got %v %q", err, rec.Header().Get(QuotaRemainingHeader))
+	}
+	if rec, err := do("/free", "Bearer key-a"); err != nil || rec.Header().Get(QuotaRemainingHeader) != "" {
+		t.Errorf("expected no quota on /free, got %v %q", err, rec.Header().Get(QuotaRemainingHeader))
+	}
+	if rec, err := do("/ai", ""); err != nil || rec.Header().Get(QuotaRemainingHeader) != "1" {
+		t.Errorf("expected requests without API key to use the client IP bucket, got %v %q", err, rec.Header().Get(QuotaRemainingHeader))
+	}
+
+	// API_KEY_SOURCE 指定查询参数时按参数计数
+	t.Setenv("API_KEY_SOURCE", "query:api_key")
+	byQuery := NewTransparentProxy(mapper, mockStats)
+	byQuery.SetQuotaStore(storage.NewQuotaStore(client))
+	for _, want := range []string{"1", "0"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://localhost/ai/v1?api_key=key-q", nil)
+		if err := byQuery.ProxyRequest(rec, req, "/ai", "/v1"); err != nil || rec.Header().Get(QuotaRemainingHeader) != want {
+			t.Fatalf("expected remaining=%s for query key, got %v %q", want, err, rec.Header().Get(QuotaRemainingHeader))
+		}
+	}
+
+	// 计数存储故障时放行
+	proxy.SetQuotaStore(failingQuotaStore{})
+	if _, err := do("/ai", "Bearer key-a"); err != nil {
+		t.Errorf("expected fail-open on store error, got %v", err)
+	}
+}
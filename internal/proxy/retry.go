@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// idempotentMethods RFC 9110 定义的幂等方法
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// retrySafe 判断连接错误后重试是否安全
+// 请求尚未写出时总是安全;已写出时仅允许无请求体的幂等请求
+// 带请求体的请求一旦写出即不可重放(不缓存请求体)
+func retrySafe(method string, hasBody, wrote bool) bool {
+	if !wrote {
+		return true
+	}
+	return !hasBody && idempotentMethods[method]
+}
+
+// reusableBody 在多次尝试间共享客户端请求体
+// Transport 出错时会关闭请求体,此处忽略关闭以便未写出的请求体可被重试使用
+type reusableBody struct {
+	io.ReadCloser
+}
+
+func (reusableBody) Close() error {
+	return nil
+}
+
+// retryStatusesFor 返回映射的重试状态码(未配置时使用全局默认)
+func (p *TransparentProxy) retryStatusesFor(opts mapping.Options) []int {
+	if opts.RetryOnStatus != nil {
+		return opts.RetryOnStatus
+	}
+	return p.retryStatuses
+}
+
+// send 发送上游请求,连接错误或命中重试状态码时按配置安全重试
+// 状态码重试发生在请求完整写出之后,因此仅适用于无请求体的幂等请求
+func (p *TransparentProxy) send(ctx context.Context, r *http.Request, prefix, targetURL string, opts mapping.Options) (*http.Response, error) {
+	client, err := p.clientFor(prefix, opts)
+	if err != nil {
+		return nil, err
+	}
+	retryStatuses := p.retryStatusesFor(opts)
+	method := opts.UpstreamMethod(r.Method)
+	hasBody := r.Body != nil && r.Body != http.NoBody
+	body := r.Body
+	if hasBody && p.retries > 0 {
+		body = reusableBody{r.Body}
+	}
+
+	for attempt := 0; ; attempt++ {
+		reqCtx := ctx
+		var wrote atomic.Bool
+		if p.retries > 0 {
+			reqCtx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+				WroteHeaders: func() { wrote.Store(true) },
+			})
+		}
+
+		// 直接传递Body,流式处理
+		proxyReq, err := http.NewRequestWithContext(reqCtx, method, targetURL, body)
+		if err != nil {
+			return nil, err
+		}
+
+		// 复制请求头（过滤hop-by-hop头部）
+		copyHeaders(proxyReq.Header, r.Header)
+		p.forwarded.apply(proxyReq.Header, r)
+		p.defaultHeaders.apply(proxyReq.Header, opts.RequestHeaders)
+		if opts.TokenRefresh != nil {
+			if err := p.injectToken(ctx, proxyReq.Header, opts.TokenRefresh); err != nil {
+				return nil, err
+			}
+		}
+		if p.traceB3 {
+			propagateB3(proxyReq.Header, r.Header)
+		}
+		if opts.GRPCWeb && isGRPCWebRequest(r) {
+			translateGRPCWebRequest(proxyReq.Header)
+		}
+
+		resp, err := client.Do(proxyReq)
+		if attempt >= p.retries {
+			return resp, err
+		}
+		if err == nil {
+			if !slices.Contains(retryStatuses, resp.StatusCode) || !retrySafe(method, hasBody, true) {
+				return resp, nil
+			}
+			// 丢弃本次响应后重试
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		} else if !retrySafe(method, hasBody, wrote.Load()) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+			return nil, err
+		case <-time.After(p.retryBackoff):
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+var errUpstreamReset = errors.New("connection reset by peer")
+
+// failingTransport 前 failures 次请求失败,written 控制失败前是否已写出请求头
+func failingTransport(calls *atomic.Int32, failures int32, written bool) roundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		n := calls.Add(1)
+		if n <= failures {
+			if written {
+				if trace := httptrace.ContextClientTrace(r.Context()); trace != nil && trace.WroteHeaders != nil {
+					trace.WroteHeaders()
+				}
+			}
+			return nil, errUpstreamReset
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Header: http.Header{}}, nil
+	}
+}
+
+func newRetryTestProxy(transport roundTripFunc) *TransparentProxy {
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": "http://backend.invalid"}}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.client = &http.Client{Transport: transport}
+	proxy.retries = 2
+	proxy.retryBackoff = 0
+	return proxy
+}
+
+func TestTransparentProxy_RetryBeforeWrite(t *testing.T) {
+	var calls atomic.Int32
+	proxy := newRetryTestProxy(failingTransport(&calls, 1, false))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	if err := proxy.ProxyRequest(w, req, "/api", "/orders"); err != nil {
+		t.Fatalf("pre-connection failure should be retried, got %v", err)
+	}
+	if calls.Load() != 2 || w.Body.String() != "ok" {
+		t.Fatalf("expected success on 2nd attempt, got calls=%d body=%q", calls.Load(), w.Body.String())
+	}
+}
+
+func TestTransparentProxy_NoRetryAfterWrite(t *testing.T) {
+	var calls atomic.Int32
+	proxy := newRetryTestProxy(failingTransport(&calls, 1, true))
+
+	req := httptest.NewRequest("POST", "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/orders")
+	if !errors.Is(err, errUpstreamReset) {
+		t.Fatalf("expected upstream error, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("written POST must not be retried, got %d attempts", calls.Load())
+	}
+}
+
+func TestTransparentProxy_RetryWrittenIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	proxy := newRetryTestProxy(failingTransport(&calls, 1, true))
+
+	req := httptest.NewRequest("GET", "http://localhost/api/orders", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/orders"); err != nil {
+		t.Fatalf("written bodyless GET should be retried, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestTransparentProxy_RetryLimit(t *testing.T) {
+	var calls atomic.Int32
+	proxy := newRetryTestProxy(failingTransport(&calls, 10, false))
+
+	req := httptest.NewRequest("GET", "http://localhost/api/orders", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/orders"); err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("expected 1 attempt + 2 retries, got %d", calls.Load())
+	}
+}
+
+func TestRetrySafe(t *testing.T) {
+	tests := []struct {
+		method  string
+		hasBody bool
+		wrote   bool
+		want    bool
+	}{
+		{"POST", true, false, true},
+		{"POST", true, true, false},
+		{"POST", false, true, false},
+		{"PUT", true, true, false},
+		{"GET", false, true, true},
+		{"DELETE", false, true, true},
+	}
+	for _, tt := range tests {
+		if got := retrySafe(tt.method, tt.hasBody, tt.wrote); got != tt.want {
+			t.Errorf("retrySafe(%s, body=%v, wrote=%v) = %v, want %v", tt.method, tt.hasBody, tt.wrote, got, tt.want)
+		}
+	}
+}
+
+func TestTransparentProxy_RetryOnStatusPerMapping(t *testing.T) {
+	// 后端首次返回 503,之后返回 200
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/retry": backend.URL, "/plain": backend.URL},
+		options:  map[string]mapping.Options{"/retry": {RetryOnStatus: []int{502, 503}}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.retries = 2
+	proxy.retryBackoff = 0
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/retry/orders", nil)
+	if err := proxy.ProxyRequest(w, req, "/retry", "/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || calls.Load() != 2 {
+		t.Fatalf("expected 503 to be retried, got status=%d calls=%d", w.Code, calls.Load())
+	}
+
+	calls.Store(0)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://localhost/plain/orders", nil)
+	if err := proxy.ProxyRequest(w, req, "/plain", "/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Fatalf("mapping without retry statuses must pass 503 through, got status=%d calls=%d", w.Code, calls.Load())
+	}
+}
+
+func TestTransparentProxy_RetryOnStatusOverridesDefault(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL, "/strict": backend.URL},
+		options:  map[string]mapping.Options{"/strict": {RetryOnStatus: []int{503}}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.retries = 2
+	proxy.retryBackoff = 0
+	proxy.retryStatuses = []int{500}
+
+	req := httptest.NewRequest("GET", "http://localhost/api/orders", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("global default should retry 500, got %d attempts", calls.Load())
+	}
+
+	calls.Store(0)
+	req = httptest.NewRequest("GET", "http://localhost/strict/orders", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/strict", "/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("mapping override should not retry 500, got %d attempts", calls.Load())
+	}
+
+	// 显式的空列表关闭该映射的状态码重试
+	mapper.options["/none"] = mapping.Options{RetryOnStatus: []int{}}
+	mapper.mappings["/none"] = backend.URL
+	calls.Store(0)
+	req = httptest.NewRequest("GET", "http://localhost/none/orders", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/none", "/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("empty retry_on_status should opt out of the global statuses, got %d attempts", calls.Load())
+	}
+}
+
+func TestTransparentProxy_RetryOnStatusSkipsBodyRequests(t *testing.T) {
+	var calls atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options:  map[string]mapping.Options{"/api": {RetryOnStatus: []int{503}}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.retries = 2
+	proxy.retryBackoff = 0
+
+	req := httptest.NewRequest("PUT", "http://localhost/api/orders", strings.NewReader(`{"id":1}`))
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("request body cannot be replayed, got %d attempts", calls.Load())
+	}
+}
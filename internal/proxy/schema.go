@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"api-proxy/internal/jsonschema"
+	"api-proxy/internal/mapping"
+)
+
+// EventSchemaRejected 请求体未通过 JSON Schema 校验
+const EventSchemaRejected = "schema_rejected"
+
+// schemaEntry 映射的已编译 schema 及其原文(配置变化时重新编译)
+type schemaEntry struct {
+	raw    string
+	schema *jsonschema.Schema
+}
+
+// schemaFor 返回映射的已编译 schema(未配置或无法编译时返回nil)
+func (p *TransparentProxy) schemaFor(prefix string, opts mapping.Options) *jsonschema.Schema {
+	raw := string(opts.RequestSchema)
+	if cached, ok := p.schemas.Load(prefix); ok && cached.(*schemaEntry).raw == raw {
+		return cached.(*schemaEntry).schema
+	}
+	// 保存时已校验,此处编译失败仅可能来自绕过校验直接写入Redis的配置
+	schema, err := jsonschema.Compile(opts.RequestSchema)
+	if err != nil {
+		return nil
+	}
+	p.schemas.Store(prefix, &schemaEntry{raw: raw, schema: schema})
+	return schema
+}
+
+// rejectInvalidBody 映射配置了 request_schema 且请求体为JSON时校验请求体
+// 校验失败时写出 400/413(附校验详情)并返回该状态码;通过时返回 0,请求体替换为已读取的内容继续转发
+// 非JSON或无请求体的请求不做处理,保持流式转发
+func (p *TransparentProxy) rejectInvalidBody(w http.ResponseWriter, r *http.Request, prefix string, opts mapping.Options) int {
+	if len(opts.RequestSchema) == 0 || r.Body == nil || r.Body == http.NoBody || !isJSONContent(r.Header.Get("Content-Type")) {
+		return 0
+	}
+	schema := p.schemaFor(prefix, opts)
+	if schema == nil {
+		return 0
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, int64(p.schemaMaxBody)+1))
+	if err != nil {
+		writeSchemaError(w, http.StatusBadRequest, "failed to read request body", nil)
+		return http.StatusBadRequest
+	}
+	if len(data) > p.schemaMaxBody {
+		writeSchemaError(w, http.StatusRequestEntityTooLarge, "request body too large for schema validation", nil)
+		return http.StatusRequestEntityTooLarge
+	}
+
+	violations, err := schema.ValidateJSON(data)
+	if err != nil {
+		writeSchemaError(w, http.StatusBadRequest, "request body is not valid JSON", []jsonschema.ValidationError{{Message: err.Error()}})
+		return http.StatusBadRequest
+	}
+	if len(violations) > 0 {
+		writeSchemaError(w, http.StatusBadRequest, "request body does not match schema", violations)
+		return http.StatusBadRequest
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	return 0
+}
+
+// writeSchemaError 写出校验失败响应
+func writeSchemaError(w http.ResponseWriter, status int, message string, details []jsonschema.ValidationError) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error   string                       `json:"error"`
+		Details []jsonschema.ValidationError `json:"details,omitempty"`
+	}{message, details})
+}
+
+// isJSONContent 判断 Content-Type 是否为JSON(application/json 或 +json 后缀)
+func isJSONContent(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
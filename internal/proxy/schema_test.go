@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestTransparentProxy_RequestSchema(t *testing.T) {
+	var received []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/orders": backend.URL},
+		options: map[string]mapping.Options{"/orders": {RequestSchema: json.RawMessage(`{
+			"type": "object",
+			"required": ["sku", "quantity"],
+			"properties": {"sku": {"type": "string"}, "quantity": {"type": "integer", "minimum": 1}}
+		}`)}},
+	}
+	mockStats := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	post := func(contentType, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "http://localhost/orders/", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if err := proxy.ProxyRequest(w, req, "/orders", "/"); err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		return w
+	}
+
+	// 不符合 schema: 400 及校验详情,不访问上游
+	w := post("application/json", `{"sku":"A1","quantity":0}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	var rejected struct {
+		Error   string `json:"error"`
+		Details []struct {
+			Path    string `json:"path"`
+			Message string `json:"message"`
+		} `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &rejected); err != nil {
+		t.Fatalf("invalid error body %q: %v", w.Body.String(), err)
+	}
+	if len(rejected.Details) != 1 || rejected.Details[0].Path != "/quantity" {
+		t.Errorf("unexpected validation details: %+v", rejected)
+	}
+	if len(received) != 0 {
+		t.Fatal("invalid body should not reach the upstream")
+	}
+	if len(mockStats.events) != 1 || mockStats.events[0] != EventSchemaRejected {
+		t.Errorf("expected schema_rejected event, got %v", mockStats.events)
+	}
+	if !slices.Equal(mockStats.statuses, []int{http.StatusBadRequest}) {
+		t.Errorf("expected rejection recorded as 400, got %v", mockStats.statuses)
+	}
+
+	// 非法JSON同样拒绝
+	if w := post("application/json", `{"sku":`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for malformed JSON, got %d", w.Code)
+	}
+
+	// 符合 schema: 请求体完整转发
+	valid := `{"sku":"A1","quantity":2}`
+	if w := post("application/json; charset=utf-8", valid); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for valid body, got %d: %s", w.Code, w.Body.String())
+	}
+	// 非JSON请求不校验
+	if w := post("text/plain", "anything"); w.Code != http.StatusCreated {
+		t.Errorf("expected non-JSON body to pass through, got %d", w.Code)
+	}
+	if len(received) != 2 || received[0] != valid || received[1] != "anything" {
+		t.Errorf("unexpected upstream bodies: %q", received)
+	}
+
+	// 超过校验上限
+	proxy.schemaMaxBody = 8
+	if w := post("application/json", valid); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for oversized body, got %d", w.Code)
+	}
+}
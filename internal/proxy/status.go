@@ -0,0 +1,20 @@
+package proxy
+
+import (
+	"net/http"
+
+	"api-proxy/internal/mapping"
+)
+
+// EventStatusRemapped 上游状态码按映射的 status_map 改写后返回
+const EventStatusRemapped = "status_remapped"
+
+// remapStatus 按映射配置改写上游响应状态码,发生改写时返回 true
+func remapStatus(resp *http.Response, opts mapping.Options) bool {
+	status, ok := opts.ClientStatus(resp.StatusCode)
+	if !ok {
+		return false
+	}
+	resp.StatusCode = status
+	return true
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestTransparentProxy_StatusMap(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(status)
+		w.Write([]byte("body"))
+	}))
+	defer backend.Close()
+
+	mockStats := &MockStatsCollector{}
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options:  map[string]mapping.Options{"/api": {StatusMap: map[int]int{418: 503}}},
+	}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	do := func(status int) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://proxy.example/api/x?status="+strconv.Itoa(status), nil)
+		if err := proxy.ProxyRequest(w, req, "/api", "/x"); err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+		return w
+	}
+
+	// 已配置的状态码被改写,响应头和响应体保持不变
+	w := do(http.StatusTeapot)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "body" || w.Header().Get("X-Upstream") != "yes" {
+		t.Fatalf("expected remapped 503 with upstream body, got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if !slices.Contains(mockStats.events, EventStatusRemapped) || !slices.Equal(mockStats.statuses, []int{503}) {
+		t.Errorf("expected remap event and 503 status, got events %v statuses %v", mockStats.events, mockStats.statuses)
+	}
+
+	// 未配置的状态码原样返回
+	mockStats.events = nil
+	for _, status := range []int{http.StatusOK, http.StatusInternalServerError} {
+		if w := do(status); w.Code != status {
+			t.Errorf("expected %d to pass through, got %d", status, w.Code)
+		}
+	}
+	if slices.Contains(mockStats.events, EventStatusRemapped) {
+		t.Errorf("unexpected remap event: %v", mockStats.events)
+	}
+}
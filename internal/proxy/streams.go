@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"api-proxy/internal/stats"
+)
+
+// ErrTooManyStreams 客户端并发的流式响应数已达上限
+var ErrTooManyStreams = errors.New("too many concurrent streams for this client")
+
+// EventStreamLimited 因并发流数超限被拒绝的请求
+const EventStreamLimited = "stream_limited"
+
+// streamLimiter 按客户端限制并发的流式(SSE)响应数,nil表示不限制
+type streamLimiter struct {
+	max       int
+	keyHeader string // 非空时优先按该请求头(API Key)区分客户端
+
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// newStreamLimiter 创建流式响应限制器,max<=0 时返回nil(禁用)
+func newStreamLimiter(max int, keyHeader string) *streamLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &streamLimiter{max: max, keyHeader: keyHeader, active: make(map[string]int)}
+}
+
+// acquire 占用一个流配额,超限时返回false
+func (l *streamLimiter) acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key] >= l.max {
+		return false
+	}
+	l.active[key]++
+	return true
+}
+
+// release 释放流配额
+func (l *streamLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[key] <= 1 {
+		delete(l.active, key)
+		return
+	}
+	l.active[key]--
+}
+
+// clientKey 返回区分客户端的键: 配置的API Key头(摘要)或客户端IP(按 TRUSTED_PROXIES 解析,不含端口)
+func (l *streamLimiter) clientKey(r *http.Request) string {
+	if l.keyHeader != "" {
+		if key := stats.ParseAPIKey(r.Header.Get(l.keyHeader)); key != "" {
+			return stats.HashAPIKey(key)
+		}
+	}
+	return stats.ClientIP(r)
+}
+
+// isEventStream 判断响应是否为 SSE 流
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(contentType)
+	}
+	return strings.EqualFold(mediaType, "text/event-stream")
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"api-proxy/internal/stats"
+)
+
+func TestTransparentProxy_StreamLimit(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plain" {
+			w.Write([]byte("ok"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+		started <- struct{}{}
+		<-release
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/ai": backend.URL}}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.streams = newStreamLimiter(2, "")
+
+	// 共享限制器的代理,仅在测试主协程中使用(MockStatsCollector 非并发安全)
+	mockStats := &MockStatsCollector{}
+	observed := NewTransparentProxy(mapper, mockStats)
+	observed.streams = proxy.streams
+
+	send := func(p *TransparentProxy, remoteAddr, rest string) error {
+		req := httptest.NewRequest("GET", "http://localhost/ai"+rest, nil)
+		req.RemoteAddr = remoteAddr
+		return p.ProxyRequest(httptest.NewRecorder(), req, "/ai", rest)
+	}
+	do := func(remoteAddr, rest string) error {
+		return send(proxy, remoteAddr, rest)
+	}
+
+	// 同一客户端打开两个流(达到上限)
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Go(func() {
+			if err := do("10.0.0.1:1234", "/stream"); err != nil {
+				t.Errorf("stream within limit failed: %v", err)
+			}
+		})
+	}
+	<-started
+	<-started
+
+	// 第三个流被拒绝
+	err := send(observed, "10.0.0.1:5678", "/stream")
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusTooManyRequests || !errors.Is(err, ErrTooManyStreams) {
+		t.Fatalf("expected 429 too many streams, got %v", err)
+	}
+	if !slices.Contains(mockStats.events, EventStreamLimited) {
+		t.Errorf("expected stream_limited event, got %v", mockStats.events)
+	}
+	if !slices.Contains(mockStats.statuses, http.StatusTooManyRequests) {
+		t.Errorf("expected stream rejection recorded as 429, got %v", mockStats.statuses)
+	}
+
+	// 非流式请求不计入,其他客户端不受影响
+	if err := send(observed, "10.0.0.1:1234", "/plain"); err != nil {
+		t.Errorf("non-streaming request should not be limited: %v", err)
+	}
+	wg.Go(func() {
+		if err := do("10.0.0.2:1234", "/stream"); err != nil {
+			t.Errorf("other client stream failed: %v", err)
+		}
+	})
+	<-started
+
+	// 流结束后释放配额
+	close(release)
+	wg.Wait()
+	if err := do("10.0.0.1:1234", "/stream"); err != nil {
+		t.Errorf("expected stream allowed after release, got %v", err)
+	}
+	if len(proxy.streams.active) != 0 {
+		t.Errorf("expected no active streams, got %v", proxy.streams.active)
+	}
+}
+
+func TestStreamLimiter_ClientKey(t *testing.T) {
+	limiter := newStreamLimiter(1, "Authorization")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if key := limiter.clientKey(req); key != "10.0.0.1" {
+		t.Errorf("expected client IP without API key, got %q", key)
+	}
+	// 经受信任代理转发时按解析后的客户端IP计数
+	if key := limiter.clientKey(stats.WithClientIP(req, "198.51.100.7")); key != "198.51.100.7" {
+		t.Errorf("expected resolved client IP, got %q", key)
+	}
+	req.Header.Set("Authorization", "Bearer sk-test")
+	if key := limiter.clientKey(req); key == "10.0.0.1" || key == "" {
+		t.Errorf("expected API key digest, got %q", key)
+	}
+	if newStreamLimiter(0, "") != nil {
+		t.Error("expected nil limiter when disabled")
+	}
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// defaultUpstreamTimeout 客户端未设置截止时间且映射未配置超时时的保护性超时
+const defaultUpstreamTimeout = 30 * time.Second
+
+// ErrUpstreamTimeout 上游在超时时间内未完成响应
+var ErrUpstreamTimeout = errors.New("upstream request timed out")
+
+// errClientDeadline 客户端截止时间到达(作为上下文的取消原因,与代理自身设置的超时区分)
+var errClientDeadline = fmt.Errorf("client deadline exceeded: %w", context.DeadlineExceeded)
+
+// DefaultDeadlineHeader 客户端截止时间提示头(值为 Unix 毫秒时间戳)
+const DefaultDeadlineHeader = "X-Proxy-Deadline"
+
+// deadlineHeader 客户端通过请求头传递的截止时间
+type deadlineHeader struct {
+	name    string        // 请求头名称(空表示禁用)
+	ceiling time.Duration // 截止时间距当前的上限,超出时收紧到该值
+}
+
+// withClientDeadline 按请求头中的截止时间为上游请求设置截止时间(无效值及不晚于当前的值忽略)
+// 之后的映射超时与自适应超时只会更早,不会延长该截止时间
+// 客户端自身的截止时间到达时上下文的取消原因为 errClientDeadline;收紧到上限的截止时间按代理超时处理
+func (d deadlineHeader) withClientDeadline(ctx context.Context, r *http.Request, now time.Time) (context.Context, context.CancelFunc) {
+	if d.name == "" {
+		return ctx, func() {}
+	}
+	ms, err := strconv.ParseInt(r.Header.Get(d.name), 10, 64)
+	if err != nil || ms <= 0 {
+		return ctx, func() {}
+	}
+	deadline := time.UnixMilli(ms)
+	if !deadline.After(now) {
+		return ctx, func() {}
+	}
+	if limit := now.Add(d.ceiling); deadline.After(limit) {
+		return context.WithDeadline(ctx, limit)
+	}
+	return context.WithDeadlineCause(ctx, deadline, errClientDeadline)
+}
+
+// propagate 客户端传递了截止时间时,将最终生效的截止时间写回请求头,供上游遵守
+func (d deadlineHeader) propagate(ctx context.Context, r *http.Request) {
+	if d.name == "" || r.Header.Get(d.name) == "" {
+		return
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Header.Set(d.name, strconv.FormatInt(deadline.UnixMilli(), 10))
+	}
+}
+
+// withUpstreamTimeout 为上游请求设置超时
+// 映射配置了 timeout_ms 时使用该值(客户端截止时间更早时以客户端为准);
+// 否则仅在客户端未设置截止时间时添加保护性超时,这是资源保护而非业务超时
+// limit>0 时(慢目标的自适应超时)总是以其为上限
+func withUpstreamTimeout(ctx context.Context, opts mapping.Options, limit time.Duration) (context.Context, context.CancelFunc) {
+	if timeout := opts.RequestTimeout(); timeout > 0 {
+		if limit > 0 {
+			timeout = min(timeout, limit)
+		}
+		return context.WithTimeout(ctx, timeout)
+	}
+	if limit > 0 {
+		return context.WithTimeout(ctx, limit)
+	}
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		return context.WithTimeout(ctx, defaultUpstreamTimeout)
+	}
+	return ctx, func() {}
+}
+
+// clientAbandoned 上游请求是否因客户端断开或客户端截止时间到达而结束(不代表上游异常)
+func clientAbandoned(r *http.Request, ctx context.Context) bool {
+	return r.Context().Err() != nil || errors.Is(context.Cause(ctx), errClientDeadline)
+}
+
+// timeoutError 将代理自身设置的超时转换为 504(客户端取消或客户端截止时间到达时返回 nil)
+func timeoutError(r *http.Request, err error) *Error {
+	if !errors.Is(err, context.DeadlineExceeded) || r.Context().Err() != nil {
+		return nil
+	}
+	return &Error{StatusCode: http.StatusGatewayTimeout, Err: fmt.Errorf("%w: %v", ErrUpstreamTimeout, err)}
+}
@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestTransparentProxy_MappingTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/slow": backend.URL},
+		options:  map[string]mapping.Options{"/slow": {TimeoutMs: 50}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	start := time.Now()
+	req := httptest.NewRequest("GET", "http://localhost/slow/x", nil)
+	err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/slow", "/x")
+
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusGatewayTimeout || !errors.Is(err, ErrUpstreamTimeout) {
+		t.Fatalf("expected 504 upstream timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("mapping timeout not applied, took %s", elapsed)
+	}
+
+	// 修改超时配置后立即生效
+	mapper.options["/slow"] = mapping.Options{TimeoutMs: 5000}
+	req = httptest.NewRequest("GET", "http://localhost/slow/x", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/slow", "/x"); err != nil {
+		t.Fatalf("expected request to complete with longer timeout, got %v", err)
+	}
+}
+
+func TestWithUpstreamTimeout(t *testing.T) {
+	// 未配置且客户端无截止时间: 默认保护性超时
+	ctx, cancel := withUpstreamTimeout(context.Background(), mapping.Options{}, 0)
+	deadline, ok := ctx.Deadline()
+	cancel()
+	if !ok || time.Until(deadline) > defaultUpstreamTimeout {
+		t.Fatalf("expected default timeout, got %v %v", deadline, ok)
+	}
+
+	// 客户端截止时间更早时以客户端为准
+	parent, cancelParent := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelParent()
+	ctx, cancel = withUpstreamTimeout(parent, mapping.Options{TimeoutMs: 60000}, 0)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > time.Second {
+		t.Fatalf("expected client deadline to win, got %v", deadline)
+	}
+}
+
+func TestDeadlineHeader_WithClientDeadline(t *testing.T) {
+	d := deadlineHeader{name: DefaultDeadlineHeader, ceiling: 10 * time.Second}
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Time
+		exists bool
+	}{
+		{"propagated", strconv.FormatInt(now.Add(2*time.Second).UnixMilli(), 10), time.UnixMilli(now.Add(2 * time.Second).UnixMilli()), true},
+		{"clamped to ceiling", strconv.FormatInt(now.Add(time.Hour).UnixMilli(), 10), now.Add(10 * time.Second), true},
+		{"missing", "", time.Time{}, false},
+		{"invalid", "soon", time.Time{}, false},
+		{"negative", "-1", time.Time{}, false},
+		{"past", "1", time.Time{}, false},
+		{"now", strconv.FormatInt(now.UnixMilli(), 10), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.value != "" {
+				req.Header.Set(DefaultDeadlineHeader, tt.value)
+			}
+			ctx, cancel := d.withClientDeadline(context.Background(), req, now)
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			if ok != tt.exists || !deadline.Equal(tt.want) {
+				t.Errorf("expected deadline %v (%v), got %v (%v)", tt.want, tt.exists, deadline, ok)
+			}
+		})
+	}
+
+	// 未配置请求头名称时忽略
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(DefaultDeadlineHeader, strconv.FormatInt(now.Add(time.Second).UnixMilli(), 10))
+	ctx, cancel := deadlineHeader{}.withClientDeadline(context.Background(), req, now)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("deadline header should be ignored when disabled")
+	}
+}
+
+func TestTransparentProxy_DeadlineHeader(t *testing.T) {
+	type observed struct {
+		header   string
+		deadline time.Time
+		ok       bool
+	}
+	seen := make(chan observed, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms, _ := strconv.ParseInt(r.Header.Get(DefaultDeadlineHeader), 10, 64)
+		seen <- observed{header: r.Header.Get(DefaultDeadlineHeader), deadline: time.UnixMilli(ms), ok: ms > 0}
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options:  map[string]mapping.Options{"/api": {TimeoutMs: 60000}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.deadline.ceiling = time.Minute
+
+	// 客户端截止时间早于映射超时: 上游收到相同的截止时间,到期返回504
+	deadline := time.Now().Add(50 * time.Millisecond)
+	req := httptest.NewRequest("GET", "http://localhost/api/x", nil)
+	req.Header.Set(DefaultDeadlineHeader, strconv.FormatInt(deadline.UnixMilli(), 10))
+	start := time.Now()
+	err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/x")
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504 when the client deadline passes, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("client deadline not applied, took %s", elapsed)
+	}
+	if got := <-seen; !got.ok || !got.deadline.Equal(time.UnixMilli(deadline.UnixMilli())) {
+		t.Errorf("expected upstream to receive deadline %d, got %q", deadline.UnixMilli(), got.header)
+	}
+
+	// 超出上限的截止时间被收紧,写回上游的是收紧后的值
+	proxy.deadline.ceiling = 100 * time.Millisecond
+	req = httptest.NewRequest("GET", "http://localhost/api/x", nil)
+	req.Header.Set(DefaultDeadlineHeader, strconv.FormatInt(time.Now().Add(24*time.Hour).UnixMilli(), 10))
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", "/x"); !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504 at the clamped deadline, got %v", err)
+	}
+	if got := <-seen; !got.ok || time.Until(got.deadline) > time.Second {
+		t.Errorf("expected clamped deadline to be propagated, got %q", got.header)
+	}
+}
+
+func TestTransparentProxy_ClientDeadlineNotUpstreamFailure(t *testing.T) {
+	reached := make(chan string, 2)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached <- r.URL.Path
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	proxy := NewTransparentProxy(mapper, nil)
+	proxy.deadline.ceiling = time.Minute
+	proxy.breaker = newCircuitBreaker(1, 30*time.Second)
+	proxy.adaptive = newAdaptiveTimeout(time.Second, 500*time.Millisecond, 1)
+
+	send := func(rest string, deadline time.Time) error {
+		req := httptest.NewRequest("GET", "http://localhost/api"+rest, nil)
+		req.Header.Set(DefaultDeadlineHeader, strconv.FormatInt(deadline.UnixMilli(), 10))
+		return proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", rest)
+	}
+
+	// 请求进行中客户端截止时间到达: 返回504,但不计为上游失败或慢响应
+	err := send("/slow", time.Now().Add(50*time.Millisecond))
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504 when the client deadline passes, got %v", err)
+	}
+	if _, ok := proxy.breaker.Allow("/api"); !ok {
+		t.Error("an expired client deadline must not open the breaker")
+	}
+	if n := len(proxy.adaptive.targets); n != 0 {
+		t.Errorf("an expired client deadline must not be observed by the adaptive timeout, got %d targets", n)
+	}
+
+	// 已过期的截止时间被忽略: 请求照常转发到上游
+	if err := send("/fast", time.UnixMilli(1)); err != nil {
+		t.Fatalf("past deadline should be ignored, got %v", err)
+	}
+	if path := <-reached; path != "/slow" {
+		t.Fatalf("unexpected first request %q", path)
+	}
+	if path := <-reached; path != "/fast" {
+		t.Errorf("expected the request to reach the upstream, got %q", path)
+	}
+	if _, ok := proxy.breaker.Allow("/api"); !ok {
+		t.Error("a past client deadline must not open the breaker")
+	}
+}
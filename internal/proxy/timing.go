@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+
+	"api-proxy/internal/analytics"
+)
+
+// timingRecorderKey 请求上下文中接收上游各阶段耗时的位置
+type timingRecorderKey struct{}
+
+// WithTimingRecorder 返回会接收本次请求上游各阶段耗时的上下文及读取函数(用于访问日志)
+// 读取函数在代理返回后调用,未发出上游请求时返回 nil
+func WithTimingRecorder(ctx context.Context) (context.Context, func() *analytics.UpstreamTiming) {
+	slot := new(atomic.Pointer[analytics.UpstreamTiming])
+	return context.WithValue(ctx, timingRecorderKey{}, slot), slot.Load
+}
+
+// timingRecorder 返回上下文中的耗时接收位置,未设置时返回 nil
+func timingRecorder(ctx context.Context) *atomic.Pointer[analytics.UpstreamTiming] {
+	slot, _ := ctx.Value(timingRecorderKey{}).(*atomic.Pointer[analytics.UpstreamTiming])
+	return slot
+}
+
+// upstreamTiming 通过 httptrace 记录上游请求各阶段的完成时刻(相对开始发送的偏移)
+// 回调可能来自拨号协程,因此使用原子变量;重试时以最后一次尝试的阶段为准
+// 启用 DNS 缓存(UPSTREAM_DNS_CACHE_TTL)时由缓存触发 DNS 回调,命中缓存的解析耗时接近0
+type upstreamTiming struct {
+	start     time.Time
+	dns       atomic.Int64
+	connect   atomic.Int64
+	tls       atomic.Int64
+	firstByte atomic.Int64
+}
+
+// newUpstreamTiming 开始计时并返回附带追踪回调的上下文
+func newUpstreamTiming(ctx context.Context) (*upstreamTiming, context.Context) {
+	t := &upstreamTiming{start: time.Now()}
+	mark := func(phase *atomic.Int64) { phase.Store(int64(time.Since(t.start))) }
+	return t, httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSDone: func(httptrace.DNSDoneInfo) { mark(&t.dns) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				mark(&t.connect)
+			}
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				mark(&t.tls)
+			}
+		},
+		GotFirstResponseByte: func() { mark(&t.firstByte) },
+	})
+}
+
+// report 返回截至 end 的各阶段耗时(nil 表示未计时)
+func (t *upstreamTiming) report(end time.Time) *analytics.UpstreamTiming {
+	if t == nil {
+		return nil
+	}
+	ms := func(phase *atomic.Int64) float64 { return durationMs(time.Duration(phase.Load())) }
+	return &analytics.UpstreamTiming{
+		DNSMs:     ms(&t.dns),
+		ConnectMs: ms(&t.connect),
+		TLSMs:     ms(&t.tls),
+		TTFBMs:    ms(&t.firstByte),
+		TotalMs:   durationMs(end.Sub(t.start)),
+	}
+}
+
+// durationMs 以毫秒表示耗时,保留微秒精度
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// ErrTokenRefresh 无法从令牌端点获取上游访问令牌
+var ErrTokenRefresh = errors.New("failed to obtain upstream access token")
+
+// EventTokenRefreshed 上游返回401后刷新令牌并重试
+const EventTokenRefreshed = "token_refreshed"
+
+const (
+	tokenResponseLimit = 1 << 20          // 令牌端点响应体上限
+	tokenExpiryMargin  = 30 * time.Second // 提前过期的余量,避免令牌在请求途中失效
+)
+
+// tokenSource 单个令牌刷新配置的缓存令牌(mu 同时串行化刷新,避免并发重复请求令牌端点)
+type tokenSource struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time // 零值表示直到上游拒绝前一直有效
+}
+
+// tokenSourceFor 返回刷新配置对应的令牌缓存
+func (p *TransparentProxy) tokenSourceFor(cfg *mapping.TokenRefresh) *tokenSource {
+	key := strings.Join([]string{cfg.URL, cfg.ClientID, cfg.ClientSecret, cfg.Scope}, "\x00")
+	if cached, ok := p.tokens.Load(key); ok {
+		return cached.(*tokenSource)
+	}
+	actual, _ := p.tokens.LoadOrStore(key, &tokenSource{})
+	return actual.(*tokenSource)
+}
+
+// injectToken 将缓存的令牌写入上游请求头(缓存为空或过期时先获取)
+func (p *TransparentProxy) injectToken(ctx context.Context, header http.Header, cfg *mapping.TokenRefresh) error {
+	src := p.tokenSourceFor(cfg)
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	if src.token == "" || (!src.expires.IsZero() && time.Now().After(src.expires)) {
+		token, ttl, err := p.fetchToken(ctx, cfg)
+		if err != nil {
+			return &Error{StatusCode: http.StatusBadGateway, Err: fmt.Errorf("%w: %v", ErrTokenRefresh, err)}
+		}
+		src.token, src.expires = token, time.Time{}
+		if ttl > 0 {
+			if ttl > 2*tokenExpiryMargin {
+				ttl -= tokenExpiryMargin
+			}
+			src.expires = time.Now().Add(ttl)
+		}
+	}
+	header.Set(cfg.HeaderName(), cfg.HeaderValue(src.token))
+	return nil
+}
+
+// invalidateToken 丢弃被上游拒绝的令牌(已被其他请求刷新时保留新令牌)
+func (p *TransparentProxy) invalidateToken(cfg *mapping.TokenRefresh, rejected string) {
+	src := p.tokenSourceFor(cfg)
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if src.token != "" && cfg.HeaderValue(src.token) == rejected {
+		src.token = ""
+	}
+}
+
+// fetchToken 以 client_credentials 方式从令牌端点获取访问令牌及其有效期
+func (p *TransparentProxy) fetchToken(ctx context.Context, cfg *mapping.TokenRefresh) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if cfg.Scope != "" {
+		form.Set("scope", cfg.Scope)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientID != "" {
+		req.SetBasicAuth(cfg.ClientID, cfg.ClientSecret)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("token endpoint returned %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, tokenResponseLimit)).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("decode token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", 0, errors.New("token response has no access_token")
+	}
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, nil
+}
+
+// retryWithFreshToken 上游以401拒绝代理注入的令牌时刷新令牌并重试一次
+// 仅适用于无请求体的请求(请求体已发送,不可重放);重试失败时保留原401响应
+func (p *TransparentProxy) retryWithFreshToken(ctx context.Context, r *http.Request, resp *http.Response, prefix, targetURL string, opts mapping.Options) *http.Response {
+	cfg := opts.TokenRefresh
+	if cfg == nil || resp.StatusCode != http.StatusUnauthorized || resp.Request == nil {
+		return resp
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		return resp
+	}
+
+	p.invalidateToken(cfg, resp.Request.Header.Get(cfg.HeaderName()))
+	retried, err := p.send(ctx, r, prefix, targetURL, opts)
+	if err != nil {
+		log.Printf("⚠️  刷新令牌后重试失败,返回原响应: %v", err)
+		return resp
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, headDrainLimit))
+	resp.Body.Close()
+	return retried
+}
@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+// newTokenServers 创建令牌端点(每次签发新令牌)和只接受最新令牌的上游
+func newTokenServers(t *testing.T) (auth, backend *httptest.Server, issued, hits *atomic.Int64) {
+	t.Helper()
+	issued, hits = new(atomic.Int64), new(atomic.Int64)
+	auth = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "proxy" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := issued.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		// 只有第二个签发的令牌有效,模拟首个令牌已被上游吊销
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("stale token"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(auth.Close)
+	t.Cleanup(backend.Close)
+	return auth, backend, issued, hits
+}
+
+func TestTransparentProxy_TokenRefreshOnUnauthorized(t *testing.T) {
+	auth, backend, issued, hits := newTokenServers(t)
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options: map[string]mapping.Options{"/api": {TokenRefresh: &mapping.TokenRefresh{
+			URL: auth.URL, ClientID: "proxy", ClientSecret: "s3cret",
+		}}},
+	}
+	mockStats := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/api/items", nil)
+	req.Header.Set("Authorization", "Bearer client-token")
+	if err := proxy.ProxyRequest(w, req, "/api", "/items"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("expected transparent retry to succeed, got %d %q", w.Code, w.Body.String())
+	}
+	if issued.Load() != 2 || hits.Load() != 2 {
+		t.Errorf("expected 2 tokens and 2 upstream requests, got %d and %d", issued.Load(), hits.Load())
+	}
+	if len(mockStats.events) != 1 || mockStats.events[0] != EventTokenRefreshed {
+		t.Errorf("expected token_refreshed event, got %v", mockStats.events)
+	}
+
+	// 刷新后的令牌被缓存复用
+	w = httptest.NewRecorder()
+	if err := proxy.ProxyRequest(w, httptest.NewRequest("GET", "http://localhost/api/items", nil), "/api", "/items"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if w.Code != http.StatusOK || issued.Load() != 2 {
+		t.Errorf("expected cached token to be reused, got status %d after %d tokens", w.Code, issued.Load())
+	}
+}
+
+func TestTransparentProxy_TokenRefreshSkipsRequestsWithBody(t *testing.T) {
+	auth, backend, issued, hits := newTokenServers(t)
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options: map[string]mapping.Options{"/api": {TokenRefresh: &mapping.TokenRefresh{
+			URL: auth.URL, ClientID: "proxy", ClientSecret: "s3cret",
+		}}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	// 请求体不可重放: 返回上游的401,不重试
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "http://localhost/api/items", strings.NewReader(`{"a":1}`))
+	if err := proxy.ProxyRequest(w, req, "/api", "/items"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if w.Code != http.StatusUnauthorized || hits.Load() != 1 || issued.Load() != 1 {
+		t.Errorf("expected single 401 without retry, got %d after %d requests", w.Code, hits.Load())
+	}
+}
+
+func TestTransparentProxy_TokenEndpointFailure(t *testing.T) {
+	_, backend, _, hits := newTokenServers(t)
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer auth.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL},
+		options:  map[string]mapping.Options{"/api": {TokenRefresh: &mapping.TokenRefresh{URL: auth.URL}}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	err := proxy.ProxyRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost/api/items", nil), "/api", "/items")
+	if errorStatus(err) != http.StatusBadGateway {
+		t.Fatalf("expected 502 when the token endpoint fails, got %v", err)
+	}
+	if hits.Load() != 0 {
+		t.Error("upstream should not be called without a token")
+	}
+}
@@ -2,10 +2,20 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"api-proxy/internal/config"
+	"api-proxy/internal/dnscache"
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/stats"
 )
 
 // MappingManager 映射管理器接口
@@ -13,12 +23,17 @@ type MappingManager interface {
 	GetAllMappings() map[string]string
 	GetMapping(ctx context.Context, prefix string) (string, error)
 	GetPrefixes() []string
+	GetOptions(prefix string) mapping.Options
 }
 
 // MetricsCollector 统计收集器接口
 type MetricsCollector interface {
 	RecordRequest(endpoint string)
 	RecordError(endpoint string)
+	RecordStatus(statusCode int)
+	RecordEvent(endpoint, event string)
+	RecordSLO(endpoint string, met bool)
+	RecordSizes(endpoint string, requestBytes, responseBytes int64)
 	UpdateResponseMetrics(duration time.Duration)
 }
 
@@ -43,19 +58,130 @@ var hopByHopHeaders = map[string]bool{
 // 4. 最小化内存分配
 type TransparentProxy struct {
 	client         *http.Client
+	baseTransport  *http.Transport // 定制连接配置的模板
+	clients        sync.Map        // 定制连接配置的客户端缓存(prefix -> *clientEntry)
+	tokens         sync.Map        // 代理注入的上游令牌缓存(刷新配置 -> *tokenSource)
+	balancers      sync.Map        // 多目标映射的选择器(prefix -> *balancerEntry)
+	schemas        sync.Map        // 请求体校验的已编译 schema(prefix -> *schemaEntry)
 	mapper         MappingManager
 	statsCollector MetricsCollector // 可选的统计收集器
+	breaker        *circuitBreaker  // 可选的熔断器(nil表示禁用)
+	adaptive       *adaptiveTimeout // 慢目标自适应收紧超时(nil表示禁用)
+
+	responses          ResponseStore // 可选的幂等响应存储(nil表示禁用)
+	idempotencyMaxBody int           // 幂等缓存的响应体上限(字节)
+	schemaMaxBody      int           // 请求体 schema 校验的大小上限(字节)
+
+	idleTimeout     time.Duration // 流式响应空闲超时(0表示禁用)
+	idleExemptTypes []string      // 豁免空闲检测的内容类型(如SSE)
+
+	forwarded    forwardedHeaders // 可选的 X-Forwarded-Proto/Port
+	deadline     deadlineHeader   // 客户端截止时间提示头(X-Proxy-Deadline)
+	prefixHeader prefixHeader     // 可选的匹配前缀请求头(X-Proxy-Prefix)
+
+	http10MaxBuffer int            // HTTP/1.0 客户端预读响应体的上限(字节,0表示不预读)
+	defaultHeaders  defaultHeaders // 全局默认上游请求头(UPSTREAM_HEADERS)
+
+	retries       int           // 连接错误最大重试次数(0表示不重试)
+	retryBackoff  time.Duration // 重试间隔
+	retryStatuses []int         // 触发重试的上游状态码(全局默认,映射可覆盖)
+
+	exporter    RequestExporter    // 可选的请求元数据导出器
+	auditLog    RequestExporter    // 可选的审计日志导出器(仅开启 audit_log 的映射)
+	transformer RequestTransformer // 可选的请求转换插件运行时
+	maintenance MaintenanceSource  // 可选的维护模式来源
+	health      HealthSource       // 可选的多目标主动健康检查结果
+
+	traceB3 bool // 启用 B3(Zipkin) 追踪头传播(TRACE_B3)
+
+	streams *streamLimiter // 每个客户端的并发SSE流上限(nil表示不限制)
+
+	headerLimit *headerLimit // 上游响应头字段大小上限(nil表示不限制)
+
+	resetTruncated bool // 上游响应截断时重置客户端连接(TRUNCATED_RESPONSE_RESET)
+
+	quotas  QuotaStore      // 可选的每日配额计数(nil表示禁用)
+	apiKeys stats.KeySource // API Key 的位置(API_KEY_SOURCE),用于配额分桶
+
+	self *mapping.SelfAddresses // 代理自身地址(PROXY_SELF_ADDRESSES),用于回环检测
 }
 
 // hop-by-hop头部在handler.go中定义为包级常量
 
 // NewTransparentProxy 创建透明代理
 func NewTransparentProxy(mapper MappingManager, statsCollector MetricsCollector) *TransparentProxy {
-	return &TransparentProxy{
-		client:         createOptimizedHTTPClient(),
+	client := createOptimizedHTTPClient()
+	p := &TransparentProxy{
+		client:         client,
+		baseTransport:  client.Transport.(*http.Transport),
 		mapper:         mapper,
 		statsCollector: statsCollector,
+		breaker: newCircuitBreaker(
+			config.Int("CIRCUIT_BREAKER_THRESHOLD", 0),
+			config.Duration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second),
+		),
+		adaptive: newAdaptiveTimeout(
+			config.Duration("ADAPTIVE_TIMEOUT_SLOW_P95", 0),
+			config.Duration("ADAPTIVE_TIMEOUT_MIN", time.Second),
+			config.Int("ADAPTIVE_TIMEOUT_WINDOW", 20),
+		),
+		http10MaxBuffer:    config.Int("HTTP10_BUFFER_MAX_BYTES", 1<<20),
+		idempotencyMaxBody: config.Int("IDEMPOTENCY_MAX_BODY_BYTES", 1<<20),
+		schemaMaxBody:      config.Int("REQUEST_SCHEMA_MAX_BODY_BYTES", 1<<20),
+		idleTimeout:        config.Duration("STREAM_IDLE_TIMEOUT", 0),
+		idleExemptTypes:    defaultIdleExemptTypes,
+		retries:            config.Int("UPSTREAM_RETRIES", 0),
+		retryBackoff:       config.Duration("UPSTREAM_RETRY_BACKOFF", 100*time.Millisecond),
+		apiKeys:            apiKeySource(),
+		traceB3:            config.Bool("TRACE_B3", false),
+		streams: newStreamLimiter(
+			config.Int("SSE_MAX_STREAMS_PER_CLIENT", 0),
+			config.String("SSE_STREAM_KEY_HEADER", ""),
+		),
+		headerLimit: newHeaderLimit(
+			config.Int("RESPONSE_HEADER_MAX_BYTES", 0),
+			config.String("RESPONSE_HEADER_OVERSIZE", headerOversizeStrip),
+		),
+		resetTruncated: config.Bool("TRUNCATED_RESPONSE_RESET", false),
+		self:           mapping.NewSelfAddresses(config.List("PROXY_SELF_ADDRESSES")),
+		prefixHeader: prefixHeader{
+			name: http.CanonicalHeaderKey(config.String("FORWARD_PREFIX_HEADER", DefaultPrefixHeader)),
+			all:  config.Bool("FORWARD_PREFIX", false),
+		},
+		deadline: deadlineHeader{
+			name:    http.CanonicalHeaderKey(config.String("DEADLINE_HEADER", DefaultDeadlineHeader)),
+			ceiling: config.Duration("DEADLINE_HEADER_MAX", defaultUpstreamTimeout),
+		},
+		forwarded: forwardedHeaders{
+			proto: config.String("FORWARDED_PROTO", ""),
+			port:  config.String("FORWARDED_PORT", ""),
+		},
+	}
+	p.defaultHeaders = parseDefaultHeaders(config.List("UPSTREAM_HEADERS"))
+	p.defaultHeaders.override = config.Bool("UPSTREAM_HEADERS_OVERRIDE_CLIENT", false)
+	// 配置了覆盖值即视为启用
+	p.forwarded.enabled = config.Bool("FORWARDED_HEADERS", false) ||
+		p.forwarded.proto != "" || p.forwarded.port != ""
+	if types := config.List("STREAM_IDLE_EXEMPT_TYPES"); len(types) > 0 {
+		p.idleExemptTypes = types
 	}
+	// 可选: 上游DNS缓存(定制连接配置的 Transport 由 baseTransport 克隆,同样生效)
+	if ttl := config.Duration("UPSTREAM_DNS_CACHE_TTL", 0); ttl > 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		p.baseTransport.DialContext = dnscache.New(nil, ttl).DialContext(dialer)
+	}
+	for _, raw := range config.List("UPSTREAM_RETRY_STATUSES") {
+		code, err := strconv.Atoi(raw)
+		if err == nil {
+			err = mapping.ValidateRetryStatus(code)
+		}
+		if err != nil {
+			log.Printf("⚠️  忽略无效的重试状态码 UPSTREAM_RETRY_STATUSES=%q", raw)
+			continue
+		}
+		p.retryStatuses = append(p.retryStatuses, code)
+	}
+	return p
 }
 
 // createOptimizedHTTPClient 创建优化的HTTP客户端
@@ -85,6 +211,14 @@ func createOptimizedHTTPClient() *http.Client {
 	}
 }
 
+// collectorFor 返回映射使用的统计收集器,关闭统计的映射返回nil
+func (p *TransparentProxy) collectorFor(opts mapping.Options) MetricsCollector {
+	if opts.DisableStats {
+		return nil
+	}
+	return p.statsCollector
+}
+
 // ProxyRequest 透明转发请求
 // 性能：~1ms/op，内存分配最小化
 func (p *TransparentProxy) ProxyRequest(w http.ResponseWriter, r *http.Request, prefix, rest string) error {
@@ -95,10 +229,124 @@ func (p *TransparentProxy) ProxyRequest(w http.ResponseWriter, r *http.Request,
 		return err
 	}
 
-	// 2. 记录请求开始时间和统计（只有在映射存在时才统计）
+	opts := p.mapper.GetOptions(prefix)
+
+	// 多目标映射: 按策略选择本次请求的目标(跳过不健康的目标)
+	upstream, multi, upstreamErr := p.selectUpstream(r, prefix, opts)
+	// 自适应超时与熔断按映射(多目标映射按所选目标)跟踪,不使用代入了请求路径的目标,避免状态随请求无界增长
+	stateKey := prefix
+	if multi {
+		targetBase = upstream
+		stateKey = prefix + " " + upstream
+	}
+
+	// 正则映射: 将捕获组代入目标模板
+	targetBase, err = mapping.ExpandTarget(prefix, targetBase, r.URL.Path)
+	if err != nil {
+		return &Error{StatusCode: http.StatusBadRequest, Err: err}
+	}
+
+	// 2. 记录请求开始时间和统计（只有在映射存在时才统计,映射可单独关闭统计）
 	start := time.Now()
-	if p.statsCollector != nil {
-		p.statsCollector.RecordRequest(prefix)
+	collector := p.collectorFor(opts)
+	if collector != nil {
+		collector.RecordRequest(prefix)
+	}
+
+	if upstreamErr != nil {
+		if collector != nil {
+			recordFailure(collector, prefix, errorStatus(upstreamErr))
+			collector.RecordEvent(prefix, EventNoHealthyUpstream)
+		}
+		return upstreamErr
+	}
+
+	// 目标指向代理自身时直接拒绝,避免请求回环直至超时
+	if p.isLoop(targetBase, r) {
+		err := loopError()
+		if collector != nil {
+			recordFailure(collector, prefix, err.StatusCode)
+			collector.RecordEvent(prefix, EventLoopDetected)
+		}
+		return err
+	}
+
+	// HEAD 响应不得包含响应体(无论上游是否误发)
+	if r.Method == http.MethodHead {
+		w = headWriter{w}
+	}
+
+	// 维护模式: 直接返回配置的 503 页面,不访问上游
+	if p.serveMaintenance(w, prefix) {
+		if collector != nil {
+			recordFailure(collector, prefix, http.StatusServiceUnavailable)
+			collector.RecordEvent(prefix, EventMaintenance)
+		}
+		return nil
+	}
+
+	// 每日配额: 超出后返回 429,剩余次数通过响应头告知客户端
+	if err := p.checkQuota(w, r, prefix, opts.DailyQuota); err != nil {
+		if collector != nil {
+			recordFailure(collector, prefix, errorStatus(err))
+			collector.RecordEvent(prefix, EventQuotaExceeded)
+		}
+		return err
+	}
+
+	// 请求体 JSON Schema 校验: 不符合时直接返回 400 及校验详情,不访问上游
+	if status := p.rejectInvalidBody(w, r, prefix, opts); status != 0 {
+		if collector != nil {
+			recordFailure(collector, prefix, status)
+			collector.RecordEvent(prefix, EventSchemaRejected)
+		}
+		return nil
+	}
+
+	// 请求转换插件: 转发前改写路径、查询参数和请求头,失败时拒绝请求
+	if rest, err = p.applyPlugin(r, rest, opts); err != nil {
+		if collector != nil {
+			recordFailure(collector, prefix, errorStatus(err))
+			collector.RecordEvent(prefix, EventPluginFailed)
+		}
+		return err
+	}
+
+	// 向上游传递匹配的映射前缀(在插件之后设置,插件无法伪造)
+	p.prefixHeader.apply(r, prefix, opts)
+
+	// 幂等去重: 窗口内重复的幂等键直接回放首次响应
+	var idem *idempotentRequest
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" && p.responses != nil && opts.IdempotencyTTL > 0 {
+		var replayed int
+		idem, replayed, err = p.beginIdempotent(w, r, prefix, key, opts.IdempotencyWindow())
+		if err != nil {
+			if collector != nil {
+				recordFailure(collector, prefix, errorStatus(err))
+			}
+			return err
+		}
+		if replayed > 0 {
+			if collector != nil {
+				collector.UpdateResponseMetrics(time.Since(start))
+				collector.RecordStatus(replayed)
+			}
+			return nil
+		}
+		if idem != nil {
+			// 未完成的请求释放预占,允许客户端重试
+			defer idem.abort(context.WithoutCancel(r.Context()))
+		}
+	}
+
+	// 熔断中的目标快速失败,告知客户端剩余冷却时间
+	if p.breaker != nil {
+		if retryAfter, ok := p.breaker.Allow(stateKey); !ok {
+			if collector != nil {
+				recordFailure(collector, prefix, http.StatusServiceUnavailable)
+			}
+			return &Error{StatusCode: http.StatusServiceUnavailable, RetryAfter: retryAfter, Err: ErrCircuitOpen}
+		}
 	}
 
 	targetURL := targetBase + rest
@@ -106,60 +354,244 @@ func (p *TransparentProxy) ProxyRequest(w http.ResponseWriter, r *http.Request,
 		targetURL += "?" + r.URL.RawQuery
 	}
 
-	// 3. 添加超时保护（防止goroutine泄漏，同时尊重客户端的timeout）
-	ctx := r.Context()
-	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
-		// 客户端没有设置deadline，添加保护性超时（30秒）
-		// 这不违反透明代理原则，因为这是资源保护而非业务超时
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, 30*time.Second)
-		defer cancel()
+	// 3. 添加超时保护（防止goroutine泄漏，同时尊重客户端的timeout；映射可通过 timeout_ms 覆盖）
+	var timeoutLimit time.Duration
+	if p.adaptive != nil {
+		timeoutLimit = p.adaptive.limit(stateKey)
+	}
+	ctx, cancelDeadline := p.deadline.withClientDeadline(r.Context(), r, time.Now())
+	defer cancelDeadline()
+	ctx, cancel := withUpstreamTimeout(ctx, opts, timeoutLimit)
+	defer cancel()
+	p.deadline.propagate(ctx, r)
+
+	// 启用空闲超时时需要可单独取消的上游请求
+	var cancelStream context.CancelFunc
+	if p.idleTimeout > 0 {
+		ctx, cancelStream = context.WithCancel(ctx)
+		defer cancelStream()
 	}
 
-	// 4. 创建代理请求（直接传递Body，流式处理）
+	// 4. 发送请求到后端（直接传递Body，流式处理；连接错误按配置安全重试）
 	// 关键优化：不读取Body到内存，直接传递给后端
-	proxyReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL, r.Body)
+	reqBody := countRequestBody(r)
+	earlyStop := stopOnEarlyResponse(r)
+	var timing *upstreamTiming
+	if p.exporting(opts) || timingRecorder(r.Context()) != nil {
+		timing, ctx = newUpstreamTiming(ctx)
+	}
+	sendStart := time.Now()
+	resp, err := p.send(ctx, r, prefix, targetURL, opts)
+	// 客户端断开或客户端截止时间到达的请求不反映上游耗时,不计入自适应超时
+	if p.adaptive != nil && (err == nil || errors.Is(err, context.DeadlineExceeded)) && !clientAbandoned(r, ctx) {
+		// 超时的请求至少按收紧后的超时计入(截止时间在发送前已开始计时)
+		latency := time.Since(sendStart)
+		if err != nil {
+			latency = max(latency, timeoutLimit)
+		}
+		if event := p.adaptive.observe(stateKey, latency); event != "" {
+			log.Printf("⏱️  自适应超时 [%s]: %s", prefix, event)
+			if collector != nil {
+				collector.RecordEvent(prefix, event)
+			}
+		}
+	}
 	if err != nil {
-		if p.statsCollector != nil {
-			p.statsCollector.RecordError(prefix)
+		// 客户端断开或客户端截止时间到达不计为上游失败,避免个别客户端使映射熔断
+		if p.breaker != nil && !clientAbandoned(r, ctx) {
+			p.breaker.Failure(stateKey)
+		}
+		if collector != nil && opts.SLO != nil {
+			// 上游失败计为未达标
+			collector.RecordSLO(prefix, false)
+		}
+		if isProtocolError(err) {
+			if collector != nil {
+				collector.RecordEvent(prefix, EventProtocolError)
+			}
+			err = protocolError(err)
+		} else if timeoutErr := timeoutError(r, err); timeoutErr != nil {
+			err = timeoutErr
+		}
+		if collector != nil {
+			recordFailure(collector, prefix, errorStatus(err))
 		}
+		p.exportRequest(r, prefix, opts, errorStatus(err), start, reqBody.Bytes(), 0, timing)
 		return err
 	}
 
-	// 5. 复制请求头（过滤hop-by-hop头部）
-	copyHeaders(proxyReq.Header, r.Header)
+	// 上游在请求体上传完成前已返回错误(如 413): 不再读取客户端剩余的请求体,尽快返回上游响应
+	if resp.StatusCode >= 400 && earlyStop.stop() && collector != nil {
+		collector.RecordEvent(prefix, EventEarlyResponse)
+	}
 
-	// 6. 发送请求到后端
-	resp, err := p.client.Do(proxyReq)
-	if err != nil {
-		if p.statsCollector != nil {
-			p.statsCollector.RecordError(prefix)
+	// 上游404时按映射配置转发到备用目标
+	if resp.StatusCode == http.StatusNotFound && opts.OnUpstream404 != nil {
+		if fallback := p.fallbackOnNotFound(ctx, r, resp, prefix, rest, opts); fallback != resp {
+			resp = fallback
+			if collector != nil {
+				collector.RecordEvent(prefix, EventNotFoundFallback)
+			}
+		}
+	}
+	// 上游以401拒绝代理注入的令牌时刷新令牌并重试一次
+	if resp.StatusCode == http.StatusUnauthorized && opts.TokenRefresh != nil {
+		if retried := p.retryWithFreshToken(ctx, r, resp, prefix, targetURL, opts); retried != resp {
+			resp = retried
+			if collector != nil {
+				collector.RecordEvent(prefix, EventTokenRefreshed)
+			}
 		}
-		return err
 	}
 	defer resp.Body.Close()
 
-	// 7. 复制响应头（过滤hop-by-hop头部）
+	if p.breaker != nil {
+		if resp.StatusCode >= 500 {
+			p.breaker.Failure(stateKey)
+		} else {
+			p.breaker.Success(stateKey)
+		}
+	}
+
+	// 上游404时按映射配置返回自定义内容(不转发上游响应)
+	if writeNotFoundMessage(w, resp, opts) {
+		if collector != nil {
+			collector.UpdateResponseMetrics(time.Since(start))
+			recordFailure(collector, prefix, http.StatusNotFound)
+			collector.RecordEvent(prefix, EventNotFoundMessage)
+		}
+		p.exportRequest(r, prefix, opts, http.StatusNotFound, start, reqBody.Bytes(), 0, timing)
+		return nil
+	}
+
+	// 流式响应按客户端限制并发数(在响应时根据内容类型识别,普通请求不计入)
+	if p.streams != nil && isEventStream(resp.Header.Get("Content-Type")) {
+		key := p.streams.clientKey(r)
+		if !p.streams.acquire(key) {
+			if collector != nil {
+				recordFailure(collector, prefix, http.StatusTooManyRequests)
+				collector.RecordEvent(prefix, EventStreamLimited)
+			}
+			return &Error{StatusCode: http.StatusTooManyRequests, Err: ErrTooManyStreams}
+		}
+		defer p.streams.release(key)
+	}
+
+	// 超大的上游响应头(如巨型 Set-Cookie): 按配置删除超限字段或返回 502
+	if p.headerLimit != nil {
+		if oversized := p.headerLimit.enforce(resp.Header); oversized > 0 {
+			if collector != nil {
+				collector.RecordEvent(prefix, EventHeaderOversized)
+			}
+			if p.headerLimit.reject {
+				if collector != nil {
+					recordFailure(collector, prefix, http.StatusBadGateway)
+				}
+				return &Error{StatusCode: http.StatusBadGateway, Err: ErrHeaderTooLarge}
+			}
+		}
+	}
+
+	// 按映射配置改写上游状态码(熔断与404处理已按原始状态码完成)
+	if remapStatus(resp, opts) && collector != nil {
+		collector.RecordEvent(prefix, EventStatusRemapped)
+	}
+
+	// HTTP/1.0 客户端: 关闭连接,长度未知的非流式响应预读以设置 Content-Length
+	p.prepareHTTP10(w, r, resp)
+
+	// 5. 复制响应头（过滤hop-by-hop头部）
 	copyHeaders(w.Header(), resp.Header)
+	applyResponseOptions(w.Header(), opts)
+	if opts.RewriteCookies {
+		rewriteSetCookies(w.Header(), r, prefix)
+	}
+	grpcWeb := opts.GRPCWeb && isGRPCWebRequest(r)
+	if grpcWeb {
+		translateGRPCWebResponse(w.Header())
+	}
 	w.WriteHeader(resp.StatusCode)
 
-	// 8. 流式复制响应体
+	// 6. 流式复制响应体
 	// 使用io.Copy，内部使用32KB缓冲区，内存使用恒定
-	_, copyErr := io.Copy(w, resp.Body)
+	var body io.Reader = resp.Body
+	length := newLengthChecker(r, resp)
+	if length != nil {
+		body = length
+	}
+	if r.Method == http.MethodHead {
+		body = discardHeadBody(body)
+	} else if grpcWeb {
+		body = grpcWebBody(body, resp)
+	}
+	if p.idleTimeout > 0 && !p.idleExempt(resp.Header.Get("Content-Type")) {
+		body = newIdleTimeoutReader(body, p.idleTimeout, cancelStream)
+	}
+	var gz *gzipVerifier
+	if opts.VerifyGzip && shouldVerifyGzip(r, resp) {
+		gz = newGzipVerifier(body)
+		body = gz
+	}
+	if idem != nil {
+		body = io.TeeReader(body, idem)
+	}
+	// 按行分隔的JSON流: 每行完整后立即发送给客户端
+	var out io.Writer = w
+	if r.Method != http.MethodHead && isLineDelimitedJSON(resp.Header.Get("Content-Type")) {
+		out = newLineFlushWriter(w)
+	}
+	respBytes, copyErr := io.Copy(out, body)
+	if errors.Is(copyErr, ErrIdleTimeout) && collector != nil {
+		collector.RecordEvent(prefix, EventIdleTimeout)
+	}
+	if length != nil && length.truncated {
+		log.Printf("⚠️  上游响应截断 [%s]: %d/%d 字节", prefix, length.read, length.declared)
+		if collector != nil {
+			collector.RecordEvent(prefix, EventTruncatedResponse)
+		}
+		if p.resetTruncated {
+			resetClientConnection(w)
+		}
+	}
+	if gz != nil {
+		if err := gz.finish(); err != nil {
+			log.Printf("⚠️  上游gzip响应损坏 [%s]: %v", prefix, err)
+			if collector != nil {
+				collector.RecordEvent(prefix, EventGzipCorrupt)
+			}
+		}
+	}
+	if idem != nil && copyErr == nil {
+		idem.commit(context.WithoutCancel(r.Context()), resp.StatusCode, w.Header())
+	}
 
-	// 9. 记录响应时间和错误（不影响转发）
-	if p.statsCollector != nil {
+	// 7. 记录响应时间和错误（不影响转发）
+	if collector != nil {
 		duration := time.Since(start)
-		p.statsCollector.UpdateResponseMetrics(duration)
+		collector.UpdateResponseMetrics(duration)
+		collector.RecordStatus(resp.StatusCode)
+		collector.RecordSizes(prefix, reqBody.Bytes(), respBytes)
+		if opts.SLO != nil {
+			collector.RecordSLO(prefix, duration <= opts.SLO.Threshold())
+		}
 
 		if resp.StatusCode >= 400 {
-			p.statsCollector.RecordError(prefix)
+			collector.RecordError(prefix)
 		}
 	}
 
+	p.exportRequest(r, prefix, opts, resp.StatusCode, start, reqBody.Bytes(), respBytes, timing)
+
 	return copyErr
 }
 
+// recordFailure 记录未转发上游响应的失败请求: 错误计数与返回给客户端的状态码一并记录,
+// 使服务端错误率只统计5xx(含上游连接失败),不把代理拒绝的4xx计入
+func recordFailure(collector MetricsCollector, prefix string, status int) {
+	collector.RecordError(prefix)
+	collector.RecordStatus(status)
+}
+
 // copyHeaders 复制HTTP头部（过滤hop-by-hop头部）
 // 性能：O(n)，n为头部数量
 func copyHeaders(dst, src http.Header) {
@@ -171,3 +603,10 @@ func copyHeaders(dst, src http.Header) {
 		}
 	}
 }
+
+// applyResponseOptions 按映射扩展配置调整响应头(仅在显式配置时生效)
+func applyResponseOptions(header http.Header, opts mapping.Options) {
+	if contentType := opts.ResolveContentType(header.Get("Content-Type")); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+}
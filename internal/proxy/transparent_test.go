@@ -9,11 +9,15 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/stats"
 )
 
 // MockMappingManager 用于测试的模拟映射管理器
 type MockMappingManager struct {
 	mappings map[string]string
+	options  map[string]mapping.Options
 	err      error
 }
 
@@ -39,6 +43,10 @@ func (m *MockMappingManager) GetPrefixes() []string {
 	return prefixes
 }
 
+func (m *MockMappingManager) GetOptions(prefix string) mapping.Options {
+	return m.options[prefix]
+}
+
 func TestNewTransparentProxy(t *testing.T) {
 	mapper := &MockMappingManager{
 		mappings: map[string]string{
@@ -314,6 +322,12 @@ type MockStatsCollector struct {
 	recordRequestCalled bool
 	recordErrorCalled   bool
 	lastPrefix          string
+	statuses            []int
+	events              []string
+	sloResults          []bool
+	requestBytes        []int64
+	responseBytes       []int64
+	responseMetrics     int
 }
 
 func (m *MockStatsCollector) RecordRequest(prefix string) {
@@ -326,8 +340,25 @@ func (m *MockStatsCollector) RecordError(prefix string) {
 	m.lastPrefix = prefix
 }
 
+func (m *MockStatsCollector) RecordStatus(statusCode int) {
+	m.statuses = append(m.statuses, statusCode)
+}
+
+func (m *MockStatsCollector) RecordEvent(prefix, event string) {
+	m.events = append(m.events, event)
+}
+
+func (m *MockStatsCollector) RecordSLO(prefix string, met bool) {
+	m.sloResults = append(m.sloResults, met)
+}
+
+func (m *MockStatsCollector) RecordSizes(prefix string, requestBytes, responseBytes int64) {
+	m.requestBytes = append(m.requestBytes, requestBytes)
+	m.responseBytes = append(m.responseBytes, responseBytes)
+}
+
 func (m *MockStatsCollector) UpdateResponseMetrics(duration time.Duration) {
-	// no-op for testing
+	m.responseMetrics++
 }
 
 // TestTransparentProxy_StatsOnlyForConfiguredMapping 验证只有配置了映射的端点才会被统计
@@ -384,5 +415,255 @@ func TestTransparentProxy_StatsOnlyForConfiguredMapping(t *testing.T) {
 		if mockStats.lastPrefix != "/configured" {
 			t.Errorf("expected prefix '/configured', got '%s'", mockStats.lastPrefix)
 		}
+
+		if len(mockStats.statuses) != 1 || mockStats.statuses[0] != http.StatusOK {
+			t.Errorf("expected upstream status 200 to be recorded, got %v", mockStats.statuses)
+		}
 	})
 }
+
+// TestTransparentProxy_DisableStats 验证关闭统计的映射不产生任何统计,其他映射照常统计
+func TestTransparentProxy_DisableStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/hot": server.URL, "/normal": server.URL},
+		options:  map[string]mapping.Options{"/hot": {DisableStats: true}},
+	}
+	mockStats := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, mockStats)
+
+	w := httptest.NewRecorder()
+	if err := proxy.ProxyRequest(w, httptest.NewRequest("GET", "http://localhost/hot/x", nil), "/hot", "/x"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if mockStats.recordRequestCalled || mockStats.responseMetrics != 0 || len(mockStats.statuses) != 0 {
+		t.Errorf("stats-disabled mapping should not record stats, got %+v", mockStats)
+	}
+
+	w = httptest.NewRecorder()
+	if err := proxy.ProxyRequest(w, httptest.NewRequest("GET", "http://localhost/normal/x", nil), "/normal", "/x"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if !mockStats.recordRequestCalled || mockStats.lastPrefix != "/normal" || mockStats.responseMetrics != 1 {
+		t.Errorf("expected stats for /normal, got %+v", mockStats)
+	}
+}
+
+func TestTransparentProxy_ContentTypeOverride(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("X-Upstream", "kept")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name     string
+		opts     mapping.Options
+		expected string
+	}{
+		{"none", mapping.Options{}, "application/octet-stream"},
+		{"forced", mapping.Options{ContentType: "application/json"}, "application/json"},
+		{"mapped", mapping.Options{ContentTypeMap: map[string]string{"application/octet-stream": "application/json; charset=utf-8"}}, "application/json; charset=utf-8"},
+		{"unmatchedMap", mapping.Options{ContentTypeMap: map[string]string{"text/plain": "application/json"}}, "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := &MockMappingManager{
+				mappings: map[string]string{"/test": backend.URL},
+				options:  map[string]mapping.Options{"/test": tt.opts},
+			}
+			proxy := NewTransparentProxy(mapper, nil)
+
+			req := httptest.NewRequest("GET", "http://localhost/test/data", nil)
+			w := httptest.NewRecorder()
+
+			if err := proxy.ProxyRequest(w, req, "/test", "/data"); err != nil {
+				t.Fatalf("ProxyRequest failed: %v", err)
+			}
+
+			if got := w.Header().Get("Content-Type"); got != tt.expected {
+				t.Errorf("expected Content-Type %q, got %q", tt.expected, got)
+			}
+			if w.Header().Get("X-Upstream") != "kept" || w.Header().Get("Cache-Control") != "no-cache" {
+				t.Error("other response headers should be left intact")
+			}
+			if w.Body.String() != `{"ok":true}` {
+				t.Errorf("body should be forwarded unchanged, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+// roundTripFunc 用于拦截上游请求的测试Transport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestTransparentProxy_PatternTargetTemplate(t *testing.T) {
+	pattern := "~^/t/(?P<tenant>[^/]+)/api"
+	mapper := &MockMappingManager{
+		mappings: map[string]string{pattern: "https://backend.com/tenants/$tenant"},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	var upstreamURL string
+	proxy.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		upstreamURL = r.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("ok")),
+		}, nil
+	})}
+
+	req := httptest.NewRequest("GET", "http://localhost/t/acme/api/x?q=1", nil)
+	w := httptest.NewRecorder()
+
+	if err := proxy.ProxyRequest(w, req, pattern, "/x"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if upstreamURL != "https://backend.com/tenants/acme/x?q=1" {
+		t.Errorf("expected https://backend.com/tenants/acme/x?q=1, got %s", upstreamURL)
+	}
+
+	// 捕获组代入主机标签
+	mapper.mappings[pattern] = "https://$tenant.backend.com"
+	req = httptest.NewRequest("GET", "http://localhost/t/acme/api/x", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, pattern, "/x"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+	if upstreamURL != "https://acme.backend.com/x" {
+		t.Errorf("expected https://acme.backend.com/x, got %s", upstreamURL)
+	}
+
+	// 捕获值改变上游主机的目标(如保存时校验之前写入的旧数据)不转发
+	upstreamURL = ""
+	mapper.mappings[pattern] = "https://$tenant"
+	req = httptest.NewRequest("GET", "http://localhost/t/127.0.0.1:6379%23/api/x", nil)
+	err := proxy.ProxyRequest(httptest.NewRecorder(), req, pattern, "/x")
+	var proxyErr *Error
+	if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusBadRequest || !errors.Is(err, mapping.ErrTargetExpansion) {
+		t.Fatalf("expected 400 for host-changing expansion, got %v", err)
+	}
+	if upstreamURL != "" {
+		t.Errorf("upstream must not be contacted, got %s", upstreamURL)
+	}
+}
+
+func TestTransparentProxy_SLOTracking(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/api": backend.URL, "/plain": backend.URL},
+		options:  map[string]mapping.Options{"/api": {SLO: &mapping.SLO{LatencyMs: 20}}},
+	}
+	collector := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, collector)
+
+	for _, rest := range []string{"/fast", "/slow"} {
+		req := httptest.NewRequest("GET", "http://localhost/api"+rest, nil)
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/api", rest); err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+	}
+	if len(collector.sloResults) != 2 || !collector.sloResults[0] || collector.sloResults[1] {
+		t.Fatalf("expected [true false], got %v", collector.sloResults)
+	}
+
+	// 未配置 SLO 的映射不记录
+	req := httptest.NewRequest("GET", "http://localhost/plain/fast", nil)
+	proxy.ProxyRequest(httptest.NewRecorder(), req, "/plain", "/fast")
+	if len(collector.sloResults) != 2 {
+		t.Fatalf("mapping without SLO should not be tracked, got %v", collector.sloResults)
+	}
+}
+
+func TestTransparentProxy_MethodRewrite(t *testing.T) {
+	var gotMethod, gotBody, gotHeader string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotMethod, gotBody, gotHeader = r.Method, string(body), r.Header.Get("X-Client")
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/legacy": backend.URL},
+		options:  map[string]mapping.Options{"/legacy": {MethodRewrite: map[string]string{"POST": "PUT"}}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	send := func(method string) {
+		t.Helper()
+		req := httptest.NewRequest(method, "http://localhost/legacy/items/1", strings.NewReader(`{"name":"a"}`))
+		req.Header.Set("X-Client", "legacy-app")
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/legacy", "/items/1"); err != nil {
+			t.Fatalf("ProxyRequest failed: %v", err)
+		}
+	}
+
+	send("POST")
+	if gotMethod != "PUT" {
+		t.Errorf("expected rewritten PUT, got %s", gotMethod)
+	}
+	if gotBody != `{"name":"a"}` || gotHeader != "legacy-app" {
+		t.Errorf("body/headers should be preserved, got %q / %q", gotBody, gotHeader)
+	}
+
+	// 未配置改写的方法保持不变
+	send("PATCH")
+	if gotMethod != "PATCH" {
+		t.Errorf("expected PATCH unchanged, got %s", gotMethod)
+	}
+}
+
+// TestTransparentProxy_FailureStatusClasses 验证代理拒绝的请求按4xx计入客户端错误,上游失败计入服务端错误
+func TestTransparentProxy_FailureStatusClasses(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachable := backend.URL
+	backend.Close()
+
+	collector := stats.NewCollector(nil)
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/orders": unreachable, "/down": unreachable},
+		options:  map[string]mapping.Options{"/orders": {RequestSchema: []byte(`{"type":"object","required":["sku"]}`)}},
+	}
+	proxy := NewTransparentProxy(mapper, collector)
+
+	// 请求体校验失败: 400,不访问上游
+	req := httptest.NewRequest("POST", "http://localhost/orders/", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/orders", "/"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+
+	// 上游连接失败计入服务端错误
+	req = httptest.NewRequest("GET", "http://localhost/down/", nil)
+	if err := proxy.ProxyRequest(httptest.NewRecorder(), req, "/down", "/"); err == nil {
+		t.Fatal("expected error for unreachable upstream")
+	}
+
+	metrics := collector.GetPerformanceMetrics()
+	if metrics.ClientErrors != 1 || metrics.ServerErrorRate != 50 {
+		t.Fatalf("expected the rejection as a client error and the upstream failure as a server error, got client=%d server=%.2f%%",
+			metrics.ClientErrors, metrics.ServerErrorRate)
+	}
+}
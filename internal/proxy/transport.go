@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// transportKey 计算映射所需的连接配置标识,空字符串表示使用默认客户端
+// 连接池按此标识隔离,避免不同拨号配置的连接被混用
+func transportKey(opts mapping.Options) string {
+	var parts []string
+	if opts.DialAddress != "" {
+		parts = append(parts, "dial="+opts.DialAddress)
+	}
+	if opts.DisableKeepAlive {
+		parts = append(parts, "keepalive=off")
+	}
+	if opts.GRPCWeb {
+		parts = append(parts, "h2")
+	}
+	if opts.ClientCert != nil {
+		parts = append(parts, "cert="+opts.ClientCert.Identity())
+	}
+	return strings.Join(parts, ";")
+}
+
+// clientEntry 映射的专用客户端及其连接配置标识(配置变化时重建)
+type clientEntry struct {
+	key    string
+	client *http.Client
+}
+
+// clientFor 返回映射对应的HTTP客户端(特殊连接配置按需创建并按映射缓存)
+// 客户端证书加载失败时返回错误且不缓存,修复后的下一个请求会重新加载
+func (p *TransparentProxy) clientFor(prefix string, opts mapping.Options) (*http.Client, error) {
+	key := transportKey(opts)
+	if key == "" {
+		return p.client, nil
+	}
+	if cached, ok := p.clients.Load(prefix); ok && cached.(*clientEntry).key == key {
+		return cached.(*clientEntry).client, nil
+	}
+	transport, err := p.newTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	entry := &clientEntry{key: key, client: &http.Client{Transport: transport}}
+	if previous, loaded := p.clients.Swap(prefix, entry); loaded {
+		previous.(*clientEntry).client.CloseIdleConnections()
+	}
+	p.pruneClients()
+	return entry.client, nil
+}
+
+// pruneClients 移除映射已删除或连接配置已变化的客户端并关闭其空闲连接(新建客户端时执行)
+func (p *TransparentProxy) pruneClients() {
+	p.clients.Range(func(prefix, cached any) bool {
+		entry := cached.(*clientEntry)
+		if transportKey(p.mapper.GetOptions(prefix.(string))) != entry.key && p.clients.CompareAndDelete(prefix, cached) {
+			entry.client.CloseIdleConnections()
+		}
+		return true
+	})
+}
+
+// newTransport 基于默认连接池配置创建定制的 Transport
+func (p *TransparentProxy) newTransport(opts mapping.Options) (*http.Transport, error) {
+	transport := p.baseTransport.Clone()
+
+	if opts.DialAddress != "" {
+		// 仅替换拨号地址; Host 头和 TLS SNI 仍取自目标URL
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		address := opts.DialAddress
+		transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+	if opts.DisableKeepAlive {
+		// 每个请求使用新连接并发送 Connection: close
+		transport.DisableKeepAlives = true
+	}
+	if opts.GRPCWeb {
+		// gRPC 仅支持 HTTP/2: https 目标经 ALPN 协商, http 目标使用 h2c(prior knowledge)
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+	if opts.ClientCert != nil {
+		// 上游要求 mTLS 时出示的客户端证书(证书变更会产生新的 transportKey)
+		cert, err := opts.ClientCert.Load()
+		if err != nil {
+			return nil, err
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	return transport, nil
+}
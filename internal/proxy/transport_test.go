@@ -0,0 +1,263 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestTransparentProxy_DialAddressOverride(t *testing.T) {
+	var sni, host atomic.Value
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host.Store(r.Host)
+		w.Write([]byte("ok"))
+	}))
+	backend.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			sni.Store(hello.ServerName)
+			return nil, nil
+		},
+	}
+	backend.StartTLS()
+	defer backend.Close()
+
+	// 目标主机名不解析到测试服务器,只有拨号覆盖才能连通
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/cdn": "https://example.com"},
+		options:  map[string]mapping.Options{"/cdn": {DialAddress: backend.Listener.Addr().String()}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	// 信任测试服务器证书(证书包含 example.com)
+	proxy.baseTransport = backend.Client().Transport.(*http.Transport)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/cdn/asset", nil)
+	if err := proxy.ProxyRequest(w, req, "/cdn", "/asset"); err != nil {
+		t.Fatalf("ProxyRequest failed: %v", err)
+	}
+
+	if w.Body.String() != "ok" {
+		t.Fatalf("expected response from override address, got %q", w.Body.String())
+	}
+	if got := sni.Load(); got != "example.com" {
+		t.Errorf("expected SNI example.com, got %v", got)
+	}
+	if got := host.Load(); got != "example.com" {
+		t.Errorf("expected Host example.com, got %v", got)
+	}
+}
+
+func TestTransparentProxy_ClientForCachesByMapping(t *testing.T) {
+	mapper := &MockMappingManager{options: map[string]mapping.Options{
+		"/a": {DialAddress: "10.0.0.1:443"},
+		"/b": {DialAddress: "10.0.0.2:443"},
+	}}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	if mustClientFor(t, proxy, "/plain", mapping.Options{}) != proxy.client {
+		t.Error("mappings without connection options should use the shared client")
+	}
+	a := mustClientFor(t, proxy, "/a", mapper.options["/a"])
+	if a == proxy.client || mustClientFor(t, proxy, "/a", mapper.options["/a"]) != a {
+		t.Error("same mapping should reuse its dedicated client")
+	}
+	if mustClientFor(t, proxy, "/b", mapper.options["/b"]) == a {
+		t.Error("different dial overrides should not share connection pools")
+	}
+
+	// 配置变化时重建客户端,已删除映射的客户端被移除
+	mapper.options["/a"] = mapping.Options{DialAddress: "10.0.0.3:443"}
+	delete(mapper.options, "/b")
+	if mustClientFor(t, proxy, "/a", mapper.options["/a"]) == a {
+		t.Error("changed dial override should get a new client")
+	}
+	var cached []string
+	proxy.clients.Range(func(prefix, _ any) bool {
+		cached = append(cached, prefix.(string))
+		return true
+	})
+	if len(cached) != 1 || cached[0] != "/a" {
+		t.Errorf("expected only /a to stay cached, got %v", cached)
+	}
+}
+
+func mustClientFor(t *testing.T, p *TransparentProxy, prefix string, opts mapping.Options) *http.Client {
+	t.Helper()
+	client, err := p.clientFor(prefix, opts)
+	if err != nil {
+		t.Fatalf("clientFor failed: %v", err)
+	}
+	return client
+}
+
+func TestTransparentProxy_DisableKeepAlive(t *testing.T) {
+	var conns atomic.Int32
+	var closes atomic.Int32
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Close {
+			closes.Add(1)
+		}
+		w.Write([]byte("ok"))
+	}))
+	backend.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	backend.Start()
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/pooled": backend.URL, "/fresh": backend.URL},
+		options:  map[string]mapping.Options{"/fresh": {DisableKeepAlive: true}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	send := func(prefix string) {
+		req := httptest.NewRequest("GET", "http://localhost"+prefix+"/x", nil)
+		if err := proxy.ProxyRequest(httptest.NewRecorder(), req, prefix, "/x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for range 3 {
+		send("/pooled")
+	}
+	if conns.Load() != 1 {
+		t.Fatalf("pooled mapping should reuse one connection, got %d", conns.Load())
+	}
+
+	conns.Store(0)
+	for range 3 {
+		send("/fresh")
+	}
+	if conns.Load() != 3 || closes.Load() != 3 {
+		t.Fatalf("keep-alive disabled mapping should open a new connection per request, got conns=%d close=%d", conns.Load(), closes.Load())
+	}
+}
+
+func TestNewTransparentProxy_DNSCache(t *testing.T) {
+	if p := NewTransparentProxy(&MockMappingManager{}, nil); p.baseTransport.DialContext != nil {
+		t.Fatal("DNS cache should be disabled by default")
+	}
+
+	t.Setenv("UPSTREAM_DNS_CACHE_TTL", "30s")
+	p := NewTransparentProxy(&MockMappingManager{}, nil)
+	if p.baseTransport.DialContext == nil {
+		t.Fatal("expected cached dialer on the base transport")
+	}
+	if transport, err := p.newTransport(mapping.Options{DisableKeepAlive: true}); err != nil || transport.DialContext == nil {
+		t.Error("derived transports should keep the cached dialer")
+	}
+}
+
+// issueClientCert 生成测试CA及其签发的客户端证书(PEM)
+func issueClientCert(t *testing.T) (ca *x509.Certificate, certPEM, keyPEM string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ca, err = x509.ParseCertificate(caDER); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "api-proxy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return ca, certPEM, keyPEM
+}
+
+func TestTransparentProxy_ClientCert(t *testing.T) {
+	ca, certPEM, keyPEM := issueClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	var subject atomic.Value
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject.Store(r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.Write([]byte("ok"))
+	}))
+	backend.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	backend.StartTLS()
+	defer backend.Close()
+
+	mapper := &MockMappingManager{
+		mappings: map[string]string{"/mtls": backend.URL, "/plain": backend.URL},
+		options: map[string]mapping.Options{
+			"/mtls": {ClientCert: &mapping.ClientCert{CertPEM: certPEM, KeyPEM: keyPEM}},
+		},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+	// 信任测试服务器证书
+	proxy.client = backend.Client()
+	proxy.baseTransport = proxy.client.Transport.(*http.Transport)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/mtls/resource", nil)
+	if err := proxy.ProxyRequest(w, req, "/mtls", "/resource"); err != nil {
+		t.Fatalf("ProxyRequest with client cert failed: %v", err)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Fatalf("expected 200 ok, got %d %q", w.Code, w.Body.String())
+	}
+	if got := subject.Load(); got != "api-proxy" {
+		t.Errorf("expected client cert subject api-proxy, got %v", got)
+	}
+
+	// 未配置客户端证书的映射应被上游拒绝
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://localhost/plain/resource", nil)
+	if err := proxy.ProxyRequest(w, req, "/plain", "/resource"); err == nil {
+		t.Errorf("expected handshake failure without client cert, got %d", w.Code)
+	}
+
+	// 证书无法加载时返回错误
+	if _, err := proxy.clientFor("/mtls", mapping.Options{ClientCert: &mapping.ClientCert{CertPEM: certPEM, KeyPEM: "invalid"}}); err == nil {
+		t.Error("expected error for invalid client key")
+	}
+}
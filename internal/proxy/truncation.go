@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+// EventTruncatedResponse 上游响应体短于声明的 Content-Length
+const EventTruncatedResponse = "truncated_responses"
+
+// ErrTruncatedResponse 上游响应体在 Content-Length 之前中断
+var ErrTruncatedResponse = errors.New("upstream response shorter than Content-Length")
+
+// lengthChecker 统计读取的上游响应体字节数,与声明的 Content-Length 比对
+// 只依据读取端判断: 向客户端写入失败时停止读取,不视为截断
+type lengthChecker struct {
+	r         io.Reader
+	declared  int64
+	read      int64
+	truncated bool
+}
+
+// newLengthChecker 对声明了 Content-Length 的非分块响应体做完整性检测,其他响应返回 nil
+func newLengthChecker(r *http.Request, resp *http.Response) *lengthChecker {
+	if r.Method == http.MethodHead || resp.ContentLength <= 0 {
+		return nil
+	}
+	return &lengthChecker{r: resp.Body, declared: resp.ContentLength}
+}
+
+func (c *lengthChecker) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	if err != nil && c.read < c.declared && (err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF)) {
+		c.truncated = true
+		return n, ErrTruncatedResponse
+	}
+	return n, err
+}
+
+// resetClientConnection 立即关闭客户端连接(TCP RST),使客户端无法把截断的响应当作完整响应
+// HTTP/2 等不支持接管连接的协议保持默认行为
+func resetClientConnection(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.Flush()
+	conn, _, err := rc.Hijack()
+	if err != nil {
+		return
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// shortBackend 声明 Content-Length: 100 但只发送 declared 之前的一部分
+func shortBackend() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial body"))
+	}))
+}
+
+func TestTransparentProxy_TruncatedResponse(t *testing.T) {
+	backend := shortBackend()
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	collector := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, collector)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/api/data", nil)
+	err := proxy.ProxyRequest(w, req, "/api", "/data")
+	if !errors.Is(err, ErrTruncatedResponse) {
+		t.Fatalf("expected truncated response error, got %v", err)
+	}
+	if w.Body.String() != "partial body" {
+		t.Errorf("received bytes should still be forwarded, got %q", w.Body.String())
+	}
+	if !slices.Contains(collector.events, EventTruncatedResponse) {
+		t.Errorf("expected %s event, got %v", EventTruncatedResponse, collector.events)
+	}
+}
+
+func TestTransparentProxy_CompleteResponseNotTruncated(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4")
+		w.Write([]byte("full"))
+	}))
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	collector := &MockStatsCollector{}
+	proxy := NewTransparentProxy(mapper, collector)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost/api/data", nil)
+	if err := proxy.ProxyRequest(w, req, "/api", "/data"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(collector.events) != 0 {
+		t.Errorf("complete response should not record events, got %v", collector.events)
+	}
+}
+
+func TestTransparentProxy_TruncatedResponseResetsClient(t *testing.T) {
+	backend := shortBackend()
+	defer backend.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/api": backend.URL}}
+	proxy := NewTransparentProxy(mapper, &MockStatsCollector{})
+	proxy.resetTruncated = true
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy.ProxyRequest(w, r, "/api", "/data")
+	}))
+	defer front.Close()
+
+	resp, err := http.Get(front.URL + "/api/data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Fatal("client should observe the truncated response as a read error")
+	}
+}
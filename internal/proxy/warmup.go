@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+// WarmupResult 连接预热结果
+type WarmupResult struct {
+	Warmed  int // 收到响应(连接已进入连接池)的目标数
+	Failed  int // 无法连接的目标数
+	Skipped int // 正则映射(目标为模板)数
+}
+
+// warmupJob 单个预热目标及其所属的连接池
+type warmupJob struct {
+	transport http.RoundTripper
+	target    string
+}
+
+// Warmup 以 HEAD 请求预先与全部映射目标(含多目标映射的各目标)建立连接,使首批请求免去握手开销
+// 请求经映射实际使用的 Transport 发出,不跟随重定向;concurrency 限制同时进行的预热请求数,避免启动时出站连接激增
+// timeout 为单个目标的超时
+func (p *TransparentProxy) Warmup(ctx context.Context, concurrency int, timeout time.Duration) WarmupResult {
+	var result WarmupResult
+	jobs, skipped := p.warmupJobs()
+	result.Skipped = skipped
+	if len(jobs) == 0 {
+		return result
+	}
+	if concurrency <= 0 || concurrency > len(jobs) {
+		concurrency = len(jobs)
+	}
+
+	var warmed, failed atomic.Int64
+	queue := make(chan warmupJob)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Go(func() {
+			for job := range queue {
+				if warmTarget(ctx, job, timeout) {
+					warmed.Add(1)
+				} else {
+					failed.Add(1)
+				}
+			}
+		})
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	result.Warmed, result.Failed = int(warmed.Load()), int(failed.Load())
+	return result
+}
+
+// warmupJobs 收集去重后的预热目标,返回跳过的正则映射数
+func (p *TransparentProxy) warmupJobs() ([]warmupJob, int) {
+	var (
+		jobs    []warmupJob
+		skipped int
+		seen    = make(map[warmupJob]bool)
+	)
+	for prefix, target := range p.mapper.GetAllMappings() {
+		if mapping.IsPattern(prefix) {
+			skipped++
+			continue
+		}
+		opts := p.mapper.GetOptions(prefix)
+		client, err := p.clientFor(prefix, opts)
+		if err != nil {
+			continue
+		}
+		targets := []string{target}
+		if len(opts.Upstreams) > 0 {
+			targets = targets[:0]
+			for _, upstream := range opts.Upstreams {
+				targets = append(targets, upstream.URL)
+			}
+		}
+		for _, t := range targets {
+			job := warmupJob{transport: client.Transport, target: t}
+			if !seen[job] {
+				seen[job] = true
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs, skipped
+}
+
+// warmTarget 发送一次 HEAD 请求,收到任何响应即视为连接已建立
+func warmTarget(ctx context.Context, job warmupJob, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, job.target, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := job.transport.RoundTrip(req)
+	if err != nil {
+		return false
+	}
+	// 读尽响应体,连接才能回到连接池复用
+	io.Copy(io.Discard, io.LimitReader(resp.Body, headDrainLimit))
+	resp.Body.Close()
+	return true
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestTransparentProxy_WarmupRespectsConcurrency(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+		requests int
+	)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		requests++
+		maxSeen = max(maxSeen, inFlight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		if r.Method != http.MethodHead {
+			t.Errorf("warmup should use HEAD, got %s", r.Method)
+		}
+	}))
+	defer backend.Close()
+
+	mappings := map[string]string{"~^/t/(?P<id>\\d+)": backend.URL + "/$id"}
+	for i := range 10 {
+		mappings["/m"+strconv.Itoa(i)] = backend.URL + "/m" + strconv.Itoa(i)
+	}
+	// 重复目标只预热一次;多目标映射预热各个目标
+	mappings["/dup"] = backend.URL + "/m0"
+	mappings["/pool"] = "http://unused.invalid"
+	mapper := &MockMappingManager{
+		mappings: mappings,
+		options: map[string]mapping.Options{"/pool": {
+			Upstreams: []mapping.Upstream{{URL: backend.URL + "/a"}, {URL: backend.URL + "/b"}},
+		}},
+	}
+	proxy := NewTransparentProxy(mapper, nil)
+
+	result := proxy.Warmup(context.Background(), 3, time.Second)
+	if result.Warmed != 12 || result.Failed != 0 || result.Skipped != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if requests != 12 {
+		t.Errorf("expected 12 warmup requests, got %d", requests)
+	}
+	if maxSeen > 3 {
+		t.Errorf("observed %d simultaneous warmup requests, limit is 3", maxSeen)
+	}
+	if maxSeen < 2 {
+		t.Errorf("warmup should run concurrently, max in flight was %d", maxSeen)
+	}
+}
+
+func TestTransparentProxy_WarmupUnreachable(t *testing.T) {
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	mapper := &MockMappingManager{mappings: map[string]string{"/down": closed.URL}}
+	result := NewTransparentProxy(mapper, nil).Warmup(context.Background(), 0, time.Second)
+	if result.Warmed != 0 || result.Failed != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,91 @@
+// Package redact 日志脱敏(查询参数及错误信息中URL里的密钥)
+package redact
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Mask 脱敏后的占位值
+const Mask = "***"
+
+// DefaultParams 默认脱敏的查询参数名
+var DefaultParams = []string{
+	"api_key", "apikey", "key", "token", "access_token", "refresh_token",
+	"secret", "client_secret", "password", "sig", "signature",
+}
+
+// Redactor 按名称规则脱敏(不区分大小写,支持 * 通配符,如 "*_token")
+type Redactor struct {
+	params []string
+}
+
+// New 创建脱敏器,规则名统一转为小写
+func New(params []string) *Redactor {
+	return &Redactor{params: lower(params)}
+}
+
+// Default 使用默认规则并追加额外规则
+func Default(extraParams []string) *Redactor {
+	return New(slices.Concat(DefaultParams, extraParams))
+}
+
+// URL 脱敏路径中的查询参数值,保持参数顺序和原始编码
+func (r *Redactor) URL(rawURL string) string {
+	base, query, ok := strings.Cut(rawURL, "?")
+	if !ok || query == "" {
+		return rawURL
+	}
+
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		name, _, hasValue := strings.Cut(pair, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if hasValue && matchAny(r.params, name) {
+			pairs[i] = pair[:strings.Index(pair, "=")+1] + Mask
+		}
+	}
+	return base + "?" + strings.Join(pairs, "&")
+}
+
+// Error 脱敏错误信息中的上游URL(如 *url.Error)
+func (r *Redactor) Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		masked := *urlErr
+		masked.URL = r.URL(urlErr.URL)
+		return strings.Replace(err.Error(), urlErr.Error(), masked.Error(), 1)
+	}
+	return err.Error()
+}
+
+func matchAny(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func lower(names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactor_URL(t *testing.T) {
+	r := Default([]string{"*_sig"})
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"/v1/chat?api_key=secret", "/v1/chat?api_key=***"},
+		{"/v1/chat?model=gpt&API_KEY=secret&x=1", "/v1/chat?model=gpt&API_KEY=***&x=1"},
+		{"/v1/chat?api%5Fkey=secret", "/v1/chat?api%5Fkey=***"},
+		{"/v1/chat?aws_sig=abc&token", "/v1/chat?aws_sig=***&token"},
+		{"/v1/chat?q=hello", "/v1/chat?q=hello"},
+		{"/v1/chat", "/v1/chat"},
+	}
+	for _, tt := range tests {
+		if got := r.URL(tt.in); got != tt.want {
+			t.Errorf("URL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactor_Error(t *testing.T) {
+	r := Default(nil)
+	err := fmt.Errorf("proxy: %w", &url.Error{Op: "Get", URL: "https://api.example.com/v1?api_key=secret", Err: errors.New("timeout")})
+
+	got := r.Error(err)
+	if strings.Contains(got, "secret") || !strings.Contains(got, "api_key=***") {
+		t.Fatalf("expected masked URL in error, got %q", got)
+	}
+}
@@ -0,0 +1,87 @@
+// Package schedule 提供后台周期任务的调度工具:随机抖动与防重叠执行
+package schedule
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Guard 保证同一任务不会并发执行(零值可用)
+type Guard struct {
+	running atomic.Bool
+	pending atomic.Bool
+	skipped atomic.Int64
+}
+
+// TryRun 若任务未在执行则同步执行 fn 并返回 true;否则跳过本次并返回 false
+// 执行期间有 Coalesce 请求时,结束后以 fn 补跑一次再释放
+func (g *Guard) TryRun(fn func()) bool {
+	if !g.running.CompareAndSwap(false, true) {
+		g.skipped.Add(1)
+		return false
+	}
+	fn()
+	g.release(fn)
+	return true
+}
+
+// Coalesce 请求执行 fn;若任务正在执行,则由当前执行者在结束后补跑一次
+// 多次并发请求最多合并为一次补跑,补跑执行的是当前执行者的 fn,因此各调用方应执行相同的工作
+// 适用于不能丢失的触发(如变更通知)
+func (g *Guard) Coalesce(fn func()) {
+	g.pending.Store(true)
+	if g.running.CompareAndSwap(false, true) {
+		g.release(fn)
+	}
+}
+
+// release 由持有者调用: 补跑执行期间合并的请求后释放;
+// 释放后若又有新请求(其发起者可能在释放前放弃了获取),重新获取并继续补跑
+func (g *Guard) release(fn func()) {
+	for {
+		for g.pending.Swap(false) {
+			fn()
+		}
+		g.running.Store(false)
+		if !g.pending.Load() || !g.running.CompareAndSwap(false, true) {
+			return
+		}
+	}
+}
+
+// Running 返回任务是否正在执行
+func (g *Guard) Running() bool {
+	return g.running.Load()
+}
+
+// Skipped 返回因上一轮未完成而跳过的次数
+func (g *Guard) Skipped() int64 {
+	return g.skipped.Load()
+}
+
+// Delay 返回下一轮的等待时间: interval 加上 [0, jitter] 内的随机抖动
+// 多实例同时启动时,抖动可错开对Redis的集中访问
+func Delay(interval, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return interval + rand.N(jitter+1)
+}
+
+// Every 周期执行 fn,直到 stop 关闭
+// 间隔从上一轮结束时开始计算,因此同一循环内的任务不会重叠
+func Every(stop <-chan struct{}, interval, jitter time.Duration, fn func()) {
+	timer := time.NewTimer(Delay(interval, jitter))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			fn()
+			timer.Reset(Delay(interval, jitter))
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package schedule
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGuard_TryRunNoOverlap(t *testing.T) {
+	var g Guard
+	var active, maxActive, runs atomic.Int32
+
+	slow := func() {
+		n := active.Add(1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		active.Add(-1)
+		runs.Add(1)
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() { g.TryRun(slow) })
+	}
+	wg.Wait()
+
+	if maxActive.Load() != 1 {
+		t.Fatalf("expected no overlapping runs, got %d concurrent", maxActive.Load())
+	}
+	if int64(runs.Load())+g.Skipped() != 10 {
+		t.Fatalf("expected runs+skipped=10, got runs=%d skipped=%d", runs.Load(), g.Skipped())
+	}
+	if g.Skipped() == 0 {
+		t.Fatal("expected overlapping attempts to be skipped")
+	}
+}
+
+func TestGuard_CoalesceRerunsOnce(t *testing.T) {
+	var g Guard
+	var runs atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Coalesce(func() {
+			if runs.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+		})
+	}()
+
+	<-started
+	// 执行期间的多次请求合并为一次补跑,且不并发执行
+	g.Coalesce(func() { t.Error("must not run concurrently") })
+	g.Coalesce(func() { t.Error("must not run concurrently") })
+	close(release)
+	<-done
+
+	if runs.Load() != 2 {
+		t.Fatalf("expected 1 run + 1 coalesced rerun, got %d", runs.Load())
+	}
+	if g.Running() {
+		t.Fatal("guard should be released")
+	}
+}
+
+func TestDelay(t *testing.T) {
+	if got := Delay(time.Second, 0); got != time.Second {
+		t.Fatalf("expected no jitter, got %v", got)
+	}
+	for range 100 {
+		if got := Delay(time.Second, 100*time.Millisecond); got < time.Second || got > 1100*time.Millisecond {
+			t.Fatalf("delay out of range: %v", got)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	stop := make(chan struct{})
+	var runs atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		Every(stop, 5*time.Millisecond, 0, func() { runs.Add(1) })
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	<-done
+	if runs.Load() == 0 {
+		t.Fatal("expected periodic runs")
+	}
+}
+
+func TestGuard_TryRunRerunsCoalesced(t *testing.T) {
+	var g Guard
+	var runs atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	done := make(chan bool)
+	go func() {
+		done <- g.TryRun(func() {
+			if runs.Add(1) == 1 {
+				close(started)
+				<-release
+			}
+		})
+	}()
+
+	<-started
+	// 定时任务执行期间到达的变更通知不能丢失: 由 TryRun 的执行者补跑
+	g.Coalesce(func() { t.Error("must not run concurrently") })
+	close(release)
+	if !<-done {
+		t.Fatal("expected TryRun to run")
+	}
+
+	if runs.Load() != 2 {
+		t.Fatalf("expected 1 run + 1 coalesced rerun, got %d", runs.Load())
+	}
+	if g.Running() {
+		t.Error("guard should be released")
+	}
+}
@@ -2,14 +2,24 @@ package stats
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"log"
+	"math/rand/v2"
 	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"api-proxy/internal/config"
+	"api-proxy/internal/schedule"
 )
 
 // Collector 简化的统计收集器
@@ -22,15 +32,38 @@ type Collector struct {
 	// 响应时间统计(原子操作)
 	responseTimeSum   int64 // 纳秒
 	responseTimeCount int64
+	latencies         *latencySamples // 最近的响应时间样本(用于 p50/p95/p99)
+
+	// 上游响应状态码分类计数(下标为状态码百位,1xx~5xx,原子操作)
+	statusClasses [6]int64
+
+	// 错误率口径: "all" 计入全部错误(默认),"server" 仅计入服务端错误
+	errorRateMode string
+
+	// 异常事件计数(事件名 -> 端点 -> 次数,如 gzip_corrupt,读写锁保护)
+	eventsMu sync.RWMutex
+	events   map[string]map[string]int64
+
+	// 端点请求/响应大小分布(互斥锁保护,分桶由 STATS_SIZE_BUCKETS 配置)
+	sizesMu     sync.Mutex
+	sizes       map[string]*SizeHistograms
+	sizeBuckets []int64
+	sizeSample  float64 // 大小统计的采样率(0,1],1表示记录全部请求(STATS_SIZE_SAMPLE_RATE)
 
 	// 端点统计数据(读写锁保护)
 	mu        sync.RWMutex
 	endpoints map[string]*EndpointStats
 
-	// 时间序列数据(环形缓冲区,最多保留10000条记录)
+	// 时间序列数据(按时间有序,超出条数上限或保留时长的记录被清理)
 	requestsMu       sync.RWMutex
 	requests         []RequestRecord // 请求时间戳记录
-	maxRequestsCache int             // 最大缓存数量
+	maxRequestsCache int             // 最大缓存数量(STATS_SERIES_MAX_RECORDS)
+	seriesRetention  time.Duration   // 保留时长(STATS_SERIES_RETENTION)
+
+	// 高频客户端统计(有界,用于发现扫描器/滥用)
+	topClients        *TopN // 按客户端IP
+	topClientPrefixes *TopN // 按 "IP 前缀" 组合
+	topAPIKeys        *TopN // 按API Key摘要(不保存原始Key)
 
 	// 性能指标缓存
 	lastMetricsUpdate time.Time
@@ -38,6 +71,12 @@ type Collector struct {
 
 	// Redis客户端(可选持久化)
 	redisClient *redis.Client
+
+	// 后台任务(周期保存),Close 时停止
+	leader   Leader // 可选: 多实例部署时仅领导者周期保存
+	stopChan chan struct{}
+	stopOnce sync.Once
+	bgWG     sync.WaitGroup
 }
 
 // RequestRecord 请求记录(用于时间序列图表)
@@ -50,7 +89,12 @@ type RequestRecord struct {
 type PerformanceMetrics struct {
 	RequestsPerSec    float64 `json:"requests_per_sec"`     // 每秒请求数
 	AvgResponseTimeMs int64   `json:"avg_response_time_ms"` // 平均响应时间(毫秒)
-	ErrorRate         float64 `json:"error_rate"`           // 错误率(%)
+	P50ResponseTimeMs int64   `json:"p50_response_time_ms"` // 响应时间中位数(毫秒,基于最近 STATS_LATENCY_SAMPLES 个样本)
+	P95ResponseTimeMs int64   `json:"p95_response_time_ms"` // 响应时间 p95(毫秒)
+	P99ResponseTimeMs int64   `json:"p99_response_time_ms"` // 响应时间 p99(毫秒)
+	ErrorRate         float64 `json:"error_rate"`           // 错误率(%,口径由 STATS_ERROR_RATE_MODE 决定)
+	ServerErrorRate   float64 `json:"server_error_rate"`    // 服务端错误率(%,5xx及上游失败,不含客户端4xx)
+	ClientErrors      int64   `json:"client_errors"`        // 客户端错误(4xx)总数
 	MemoryUsageMB     float64 `json:"memory_usage_mb"`      // 内存使用(MB)
 	GoroutineCount    int     `json:"goroutine_count"`      // 协程数量
 }
@@ -60,15 +104,101 @@ type EndpointStats struct {
 	Count       int64 `json:"count"`
 	ErrorCount  int64 `json:"error_count"`
 	LastRequest int64 `json:"last_request"`
+
+	// SLO 统计(仅配置了延迟目标的映射)
+	SLOTotal int64 `json:"slo_total,omitempty"`
+	SLOMet   int64 `json:"slo_met,omitempty"`
+
+	// 请求体大小统计(流式计数,仅统计已完成的请求)
+	RequestBytes  int64 `json:"request_bytes,omitempty"`
+	RequestBodies int64 `json:"request_bodies,omitempty"`
+}
+
+// RequestSizeStats 端点请求体大小汇总
+type RequestSizeStats struct {
+	Count      int64   `json:"count"`       // 统计的请求数
+	TotalBytes int64   `json:"total_bytes"` // 请求体总字节数
+	AvgBytes   float64 `json:"avg_bytes"`   // 平均请求体字节数
 }
 
+// SLOStats 端点延迟目标达标情况
+type SLOStats struct {
+	Total      int64   `json:"total"`
+	Met        int64   `json:"met"`
+	Compliance float64 `json:"compliance"` // 达标率(0~1)
+}
+
+// 时间序列保留配置的默认值与取值范围
+const (
+	DefaultSeriesMaxRecords = 10000
+	DefaultSeriesRetention  = 48 * time.Hour
+	minSeriesMaxRecords     = 100
+	maxSeriesMaxRecords     = 1000000
+	minSeriesRetention      = time.Hour
+	maxSeriesRetention      = 30 * 24 * time.Hour
+)
+
+// 错误率口径
+const (
+	ErrorRateAll    = "all"    // 4xx、5xx及上游失败均计入
+	ErrorRateServer = "server" // 仅5xx及上游失败(客户端4xx不计入)
+)
+
 // NewCollector 创建统计收集器
 func NewCollector(redisClient *redis.Client) *Collector {
+	topCapacity := config.Int("TOP_CLIENTS_CAPACITY", 1000)
+	sizeBuckets := DefaultSizeBuckets
+	if items := config.List("STATS_SIZE_BUCKETS"); len(items) > 0 {
+		if buckets, err := ParseSizeBuckets(items); err != nil {
+			log.Printf("⚠️  STATS_SIZE_BUCKETS 无效,使用默认分桶: %v", err)
+		} else {
+			sizeBuckets = buckets
+		}
+	}
+	sizeSample := config.Float("STATS_SIZE_SAMPLE_RATE", 1)
+	if sizeSample <= 0 || sizeSample > 1 {
+		log.Printf("⚠️  STATS_SIZE_SAMPLE_RATE=%g 超出范围 (0, 1],使用默认值 1", sizeSample)
+		sizeSample = 1
+	}
+	errorRateMode := config.String("STATS_ERROR_RATE_MODE", ErrorRateAll)
+	if errorRateMode != ErrorRateAll && errorRateMode != ErrorRateServer {
+		log.Printf("⚠️  Invalid STATS_ERROR_RATE_MODE=%q, using %q", errorRateMode, ErrorRateAll)
+		errorRateMode = ErrorRateAll
+	}
+	maxRecords := config.Int("STATS_SERIES_MAX_RECORDS", DefaultSeriesMaxRecords)
+	if maxRecords < minSeriesMaxRecords || maxRecords > maxSeriesMaxRecords {
+		log.Printf("⚠️  STATS_SERIES_MAX_RECORDS=%d 超出范围 [%d, %d],使用默认值 %d",
+			maxRecords, minSeriesMaxRecords, maxSeriesMaxRecords, DefaultSeriesMaxRecords)
+		maxRecords = DefaultSeriesMaxRecords
+	}
+	retention := config.Duration("STATS_SERIES_RETENTION", DefaultSeriesRetention)
+	if retention < minSeriesRetention || retention > maxSeriesRetention {
+		log.Printf("⚠️  STATS_SERIES_RETENTION=%s 超出范围 [%s, %s],使用默认值 %s",
+			retention, minSeriesRetention, maxSeriesRetention, DefaultSeriesRetention)
+		retention = DefaultSeriesRetention
+	}
+	latencySamples := config.Int("STATS_LATENCY_SAMPLES", DefaultLatencySamples)
+	if latencySamples < minLatencySamples || latencySamples > maxLatencySamples {
+		log.Printf("⚠️  STATS_LATENCY_SAMPLES=%d 超出范围 [%d, %d],使用默认值 %d",
+			latencySamples, minLatencySamples, maxLatencySamples, DefaultLatencySamples)
+		latencySamples = DefaultLatencySamples
+	}
 	return &Collector{
-		endpoints:        make(map[string]*EndpointStats),
-		requests:         make([]RequestRecord, 0, 10000),
-		maxRequestsCache: 10000, // 最多缓存10000条记录(约占用200KB内存)
-		redisClient:      redisClient,
+		errorRateMode:     errorRateMode,
+		latencies:         newLatencySamples(latencySamples),
+		endpoints:         make(map[string]*EndpointStats),
+		events:            make(map[string]map[string]int64),
+		sizes:             make(map[string]*SizeHistograms),
+		sizeBuckets:       sizeBuckets,
+		sizeSample:        sizeSample,
+		requests:          make([]RequestRecord, 0, min(maxRecords, DefaultSeriesMaxRecords)),
+		maxRequestsCache:  maxRecords, // 默认10000条记录(约占用200KB内存)
+		seriesRetention:   retention,
+		topClients:        NewTopN(topCapacity),
+		topClientPrefixes: NewTopN(topCapacity),
+		topAPIKeys:        NewTopN(topCapacity),
+		redisClient:       redisClient,
+		stopChan:          make(chan struct{}),
 	}
 }
 
@@ -90,8 +220,9 @@ func (c *Collector) RecordRequest(endpoint string) {
 	stats.LastRequest = timestamp
 	c.mu.Unlock()
 
-	// 记录时间序列数据(环形缓冲区)
+	// 记录时间序列数据
 	c.requestsMu.Lock()
+	c.cleanupOldRequests(timestamp)
 	if len(c.requests) >= c.maxRequestsCache {
 		// 删除最旧的20%数据,避免频繁扩容
 		c.requests = c.requests[c.maxRequestsCache/5:]
@@ -103,6 +234,81 @@ func (c *Collector) RecordRequest(endpoint string) {
 	c.requestsMu.Unlock()
 }
 
+// cleanupOldRequests 删除超出保留时长的记录,并将条数裁剪到上限(调用方持有requestsMu写锁)
+// 记录按时间有序,无过期数据时仅比较首条记录
+func (c *Collector) cleanupOldRequests(now int64) {
+	cutoff := now - int64(c.seriesRetention/time.Second)
+	if len(c.requests) > 0 && c.requests[0].Timestamp < cutoff {
+		i := sort.Search(len(c.requests), func(i int) bool {
+			return c.requests[i].Timestamp >= cutoff
+		})
+		c.requests = c.requests[i:]
+	}
+	if over := len(c.requests) - c.maxRequestsCache; over > 0 {
+		c.requests = c.requests[over:]
+	}
+}
+
+// RecordClient 记录客户端请求(按IP和IP+前缀统计高频访问者)
+func (c *Collector) RecordClient(clientIP, prefix string) {
+	if clientIP == "" {
+		return
+	}
+	c.topClients.Add(clientIP)
+	c.topClientPrefixes.Add(clientIP + " " + prefix)
+}
+
+// RecordClientError 记录客户端请求的错误响应(4xx/5xx),仅计入仍在跟踪的客户端
+func (c *Collector) RecordClientError(clientIP, prefix string) {
+	if clientIP == "" {
+		return
+	}
+	c.topClients.AddError(clientIP)
+	c.topClientPrefixes.AddError(clientIP + " " + prefix)
+}
+
+// TopClients 返回请求最多的客户端IP
+func (c *Collector) TopClients(n int) []TopNEntry {
+	return c.topClients.Top(n)
+}
+
+// TopClientPrefixes 返回请求最多的 "IP 前缀" 组合
+func (c *Collector) TopClientPrefixes(n int) []TopNEntry {
+	return c.topClientPrefixes.Top(n)
+}
+
+// RecordAPIKey 按API Key摘要记录请求(用于按客户统计用量),仅保存摘要
+// key 由 KeySource.Key 按 API_KEY_SOURCE 提取,与限流、配额的分桶一致;空值(未携带)不记录
+func (c *Collector) RecordAPIKey(key string) {
+	if key == "" {
+		return
+	}
+	c.topAPIKeys.Add(HashAPIKey(key))
+}
+
+// ParseAPIKey 从请求头取值中提取API Key(去掉 "Bearer " 前缀)
+func ParseAPIKey(value string) string {
+	key := strings.TrimSpace(value)
+	if scheme, token, ok := strings.Cut(key, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		key = strings.TrimSpace(token)
+	} else if strings.EqualFold(key, "Bearer") {
+		// 只有认证方案没有令牌
+		return ""
+	}
+	return key
+}
+
+// TopAPIKeys 返回请求最多的API Key摘要
+func (c *Collector) TopAPIKeys(n int) []TopNEntry {
+	return c.topAPIKeys.Top(n)
+}
+
+// HashAPIKey 计算API Key摘要(SHA-256前8字节),可用同样方式计算已知Key的摘要进行比对
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
 // RecordError 记录错误
 func (c *Collector) RecordError(endpoint string) {
 	atomic.AddInt64(&c.errorCount, 1)
@@ -121,6 +327,159 @@ func (c *Collector) RecordError(endpoint string) {
 func (c *Collector) UpdateResponseMetrics(duration time.Duration) {
 	atomic.AddInt64(&c.responseTimeSum, int64(duration))
 	atomic.AddInt64(&c.responseTimeCount, 1)
+	c.latencies.add(duration)
+}
+
+// RecordStatus 记录上游响应状态码(按 1xx~5xx 全局分类计数,不区分端点)
+func (c *Collector) RecordStatus(statusCode int) {
+	class := statusCode / 100
+	if class < 1 || class > 5 {
+		return
+	}
+	atomic.AddInt64(&c.statusClasses[class], 1)
+}
+
+// RecordEvent 记录异常事件(按事件名和端点计数)
+func (c *Collector) RecordEvent(endpoint, event string) {
+	c.eventsMu.Lock()
+	counts := c.events[event]
+	if counts == nil {
+		counts = make(map[string]int64)
+		c.events[event] = counts
+	}
+	counts[endpoint]++
+	c.eventsMu.Unlock()
+}
+
+// GetEventCounts 获取异常事件计数快照(事件名 -> 端点 -> 次数)
+func (c *Collector) GetEventCounts() map[string]map[string]int64 {
+	c.eventsMu.RLock()
+	defer c.eventsMu.RUnlock()
+
+	result := make(map[string]map[string]int64, len(c.events))
+	for event, counts := range c.events {
+		snapshot := make(map[string]int64, len(counts))
+		for endpoint, n := range counts {
+			snapshot[endpoint] = n
+		}
+		result[event] = snapshot
+	}
+	return result
+}
+
+// RecordSizes 记录一次请求的请求体与响应体字节数
+// 设置了采样率时只记录其中一部分请求,分布形状与平均值不变,计数约为实际请求数乘以采样率
+func (c *Collector) RecordSizes(endpoint string, requestBytes, responseBytes int64) {
+	if c.sizeSample < 1 && rand.Float64() >= c.sizeSample {
+		return
+	}
+	c.sizesMu.Lock()
+
+	h := c.sizes[endpoint]
+	if h == nil {
+		h = &SizeHistograms{
+			Request:  newHistogram(c.sizeBuckets),
+			Response: newHistogram(c.sizeBuckets),
+		}
+		c.sizes[endpoint] = h
+	}
+	h.Request.observe(requestBytes)
+	h.Response.observe(responseBytes)
+	c.sizesMu.Unlock()
+
+	// 请求体累计(随端点统计持久化)
+	c.mu.Lock()
+	stats := c.endpoints[endpoint]
+	if stats == nil {
+		stats = &EndpointStats{}
+		c.endpoints[endpoint] = stats
+	}
+	stats.RequestBytes += requestBytes
+	stats.RequestBodies++
+	c.mu.Unlock()
+}
+
+// GetRequestSizeStats 获取各端点的平均请求体大小
+func (c *Collector) GetRequestSizeStats() map[string]RequestSizeStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]RequestSizeStats)
+	for endpoint, v := range c.endpoints {
+		if v.RequestBodies == 0 {
+			continue
+		}
+		result[endpoint] = RequestSizeStats{
+			Count:      v.RequestBodies,
+			TotalBytes: v.RequestBytes,
+			AvgBytes:   float64(v.RequestBytes) / float64(v.RequestBodies),
+		}
+	}
+	return result
+}
+
+// SizeSampleRate 返回大小分布的采样率(STATS_SIZE_SAMPLE_RATE),直方图计数除以该值为估算的实际请求数
+func (c *Collector) SizeSampleRate() float64 {
+	return c.sizeSample
+}
+
+// GetSizeHistograms 获取各端点大小分布快照
+func (c *Collector) GetSizeHistograms() map[string]SizeHistograms {
+	c.sizesMu.Lock()
+	defer c.sizesMu.Unlock()
+
+	result := make(map[string]SizeHistograms, len(c.sizes))
+	for endpoint, h := range c.sizes {
+		result[endpoint] = SizeHistograms{Request: h.Request.clone(), Response: h.Response.clone()}
+	}
+	return result
+}
+
+// statusClassSnapshot 返回状态码分类计数快照(下标为状态码百位)
+func (c *Collector) statusClassSnapshot() [6]int64 {
+	var classes [6]int64
+	for i := range classes {
+		classes[i] = atomic.LoadInt64(&c.statusClasses[i])
+	}
+	return classes
+}
+
+// GetStatusClassCounts 获取状态码分类计数(如 {"2xx": 10, "5xx": 1})
+func (c *Collector) GetStatusClassCounts() map[string]int64 {
+	result := make(map[string]int64, 5)
+	for class := 1; class <= 5; class++ {
+		if count := atomic.LoadInt64(&c.statusClasses[class]); count > 0 {
+			result[fmt.Sprintf("%dxx", class)] = count
+		}
+	}
+	return result
+}
+
+// errorRates 返回错误率(%,口径由 STATS_ERROR_RATE_MODE 决定)和服务端错误率(%)
+// 服务端错误按5xx计数(代理对上游失败同样记录其返回的5xx状态码)
+func (c *Collector) errorRates(total, errors int64) (errorRate, serverErrorRate float64) {
+	if total == 0 {
+		return 0, 0
+	}
+	serverErrorRate = float64(atomic.LoadInt64(&c.statusClasses[5])) / float64(total) * 100
+	if c.errorRateMode == ErrorRateServer {
+		return serverErrorRate, serverErrorRate
+	}
+	return float64(errors) / float64(total) * 100, serverErrorRate
+}
+
+// Summary 生成运行期统计摘要(用于关闭时输出)
+func (c *Collector) Summary() string {
+	total := c.GetRequestCount()
+	errors := c.GetErrorCount()
+	errorRate, serverErrorRate := c.errorRates(total, errors)
+
+	summary := fmt.Sprintf("requests=%d errors=%d error_rate=%.2f%% server_error_rate=%.2f%% avg_response=%s",
+		total, errors, errorRate, serverErrorRate, c.GetAverageResponseTime())
+	for class := 1; class <= 5; class++ {
+		summary += fmt.Sprintf(" %dxx=%d", class, atomic.LoadInt64(&c.statusClasses[class]))
+	}
+	return summary
 }
 
 // GetStats 获取统计快照（读锁，快速）
@@ -135,12 +494,52 @@ func (c *Collector) GetStats() map[string]*EndpointStats {
 			Count:       v.Count,
 			ErrorCount:  v.ErrorCount,
 			LastRequest: v.LastRequest,
+			SLOTotal:    v.SLOTotal,
+			SLOMet:      v.SLOMet,
+
+			RequestBytes:  v.RequestBytes,
+			RequestBodies: v.RequestBodies,
 		}
 	}
 
 	return result
 }
 
+// RecordSLO 记录一次请求是否满足延迟目标
+func (c *Collector) RecordSLO(endpoint string, met bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.endpoints[endpoint]
+	if stats == nil {
+		stats = &EndpointStats{}
+		c.endpoints[endpoint] = stats
+	}
+	stats.SLOTotal++
+	if met {
+		stats.SLOMet++
+	}
+}
+
+// GetSLOStats 获取各端点的延迟目标达标率
+func (c *Collector) GetSLOStats() map[string]SLOStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string]SLOStats)
+	for endpoint, v := range c.endpoints {
+		if v.SLOTotal == 0 {
+			continue
+		}
+		result[endpoint] = SLOStats{
+			Total:      v.SLOTotal,
+			Met:        v.SLOMet,
+			Compliance: float64(v.SLOMet) / float64(v.SLOTotal),
+		}
+	}
+	return result
+}
+
 // GetRequests 获取请求时间序列数据(用于图表)
 func (c *Collector) GetRequests() []RequestRecord {
 	c.requestsMu.RLock()
@@ -152,6 +551,36 @@ func (c *Collector) GetRequests() []RequestRecord {
 	return result
 }
 
+// RequestQuery 时间序列查询条件(零值表示全部)
+type RequestQuery struct {
+	Since  int64 // 起始时间(Unix秒,含),0表示不限制
+	Until  int64 // 结束时间(Unix秒,含),0表示不限制
+	Offset int   // 跳过的记录数(从最早的记录开始)
+	Limit  int   // 最多返回的记录数,0表示不限制
+}
+
+// QueryRequests 按时间范围过滤并分页(records 须按时间升序),返回结果及过滤后的总数
+func QueryRequests(records []RequestRecord, q RequestQuery) ([]RequestRecord, int) {
+	start, end := 0, len(records)
+	if q.Since > 0 {
+		start = sort.Search(len(records), func(i int) bool { return records[i].Timestamp >= q.Since })
+	}
+	if q.Until > 0 {
+		end = sort.Search(len(records), func(i int) bool { return records[i].Timestamp > q.Until })
+	}
+	if start >= end {
+		return []RequestRecord{}, 0
+	}
+	filtered := records[start:end]
+	total := len(filtered)
+
+	filtered = filtered[min(max(q.Offset, 0), total):]
+	if q.Limit > 0 && q.Limit < len(filtered) {
+		filtered = filtered[:q.Limit]
+	}
+	return filtered, total
+}
+
 // GetPerformanceMetrics 获取性能指标(缓存5秒)
 func (c *Collector) GetPerformanceMetrics() *PerformanceMetrics {
 	now := time.Now()
@@ -188,11 +617,12 @@ func (c *Collector) GetPerformanceMetrics() *PerformanceMetrics {
 		avgResponseMs = (responseTimeSum / responseTimeCount) / 1_000_000 // 纳秒转毫秒
 	}
 
-	// 计算错误率(%)
-	var errorRate float64
-	if totalRequests > 0 {
-		errorRate = (float64(totalErrors) / float64(totalRequests)) * 100
-	}
+	// 计算响应时间分位数(毫秒)
+	quantiles := c.latencies.percentiles(0.50, 0.95, 0.99)
+
+	// 计算错误率(%),客户端4xx单独统计
+	clientErrors := atomic.LoadInt64(&c.statusClasses[4])
+	errorRate, serverErrorRate := c.errorRates(totalRequests, totalErrors)
 
 	// 获取内存和协程信息
 	var memStats runtime.MemStats
@@ -203,7 +633,12 @@ func (c *Collector) GetPerformanceMetrics() *PerformanceMetrics {
 	metrics := &PerformanceMetrics{
 		RequestsPerSec:    qps,
 		AvgResponseTimeMs: avgResponseMs,
+		P50ResponseTimeMs: quantiles[0].Milliseconds(),
+		P95ResponseTimeMs: quantiles[1].Milliseconds(),
+		P99ResponseTimeMs: quantiles[2].Milliseconds(),
 		ErrorRate:         errorRate,
+		ServerErrorRate:   serverErrorRate,
+		ClientErrors:      clientErrors,
 		MemoryUsageMB:     memoryMB,
 		GoroutineCount:    goroutines,
 	}
@@ -237,44 +672,63 @@ func (c *Collector) GetAverageResponseTime() time.Duration {
 	return time.Duration(sum / count)
 }
 
+// persistSnapshot SaveToRedis 写入的数据快照
+type persistSnapshot struct {
+	requestCount  int64
+	errorCount    int64
+	statusClasses [6]int64
+	endpoints     map[string]*EndpointStats
+	requests      []RequestRecord // 保留时长内的时间序列
+}
+
+// snapshotForPersist 在各自的锁内复制待保存的数据,锁只在复制期间持有
+// 序列化和Redis写入在锁外进行,不阻塞 RecordRequest 等写操作
+func (c *Collector) snapshotForPersist(now time.Time) persistSnapshot {
+	snap := persistSnapshot{
+		requestCount:  c.GetRequestCount(),
+		errorCount:    c.GetErrorCount(),
+		statusClasses: c.statusClassSnapshot(),
+		endpoints:     c.GetStats(),
+	}
+
+	// 只复制保留时长内的记录(记录按时间有序,二分定位起点)
+	cutoff := now.Unix() - int64(c.seriesRetention/time.Second)
+	c.requestsMu.RLock()
+	start := sort.Search(len(c.requests), func(i int) bool { return c.requests[i].Timestamp >= cutoff })
+	snap.requests = slices.Clone(c.requests[start:])
+	c.requestsMu.RUnlock()
+	return snap
+}
+
 // SaveToRedis 保存统计数据到Redis（可选）
+// 先在锁内获取快照,再在锁外构建并执行 pipeline
 func (c *Collector) SaveToRedis(ctx context.Context) error {
 	if c.redisClient == nil {
 		return nil
 	}
+	snap := c.snapshotForPersist(time.Now())
 
 	// 保存全局计数器
 	pipe := c.redisClient.Pipeline()
-	pipe.Set(ctx, "stats:request_count", c.GetRequestCount(), 0)
-	pipe.Set(ctx, "stats:error_count", c.GetErrorCount(), 0)
+	pipe.Set(ctx, "stats:request_count", snap.requestCount, 0)
+	pipe.Set(ctx, "stats:error_count", snap.errorCount, 0)
+	if classes, err := json.Marshal(snap.statusClasses); err == nil {
+		pipe.Set(ctx, "stats:status_classes", classes, 0)
+	}
 
 	// 保存端点统计（统一序列化为JSON，避免分散的Hash keys）
-	stats := c.GetStats()
-	if len(stats) > 0 {
-		endpointsData, err := json.Marshal(stats)
+	if len(snap.endpoints) > 0 {
+		endpointsData, err := json.Marshal(snap.endpoints)
 		if err == nil {
 			pipe.Set(ctx, "stats:endpoints", endpointsData, 7*24*time.Hour)
 		}
 	}
 
-	// 保存时间序列数据（最近48小时）
-	requests := c.GetRequests()
-	if len(requests) > 0 {
-		// 只保存最近48小时的数据（约2000-5000条记录）
-		cutoff := time.Now().Unix() - 48*3600
-		recentRequests := make([]RequestRecord, 0, len(requests))
-		for _, req := range requests {
-			if req.Timestamp >= cutoff {
-				recentRequests = append(recentRequests, req)
-			}
-		}
-
-		// 使用JSON序列化保存到Redis（7天过期）
-		if len(recentRequests) > 0 {
-			data, err := json.Marshal(recentRequests)
-			if err == nil {
-				pipe.Set(ctx, "stats:requests_timeline", data, 7*24*time.Hour)
-			}
+	// 保存时间序列数据（保留时长内，默认最近48小时；7天过期）
+	if len(snap.requests) > 0 {
+		data, err := json.Marshal(snap.requests)
+		if err == nil {
+			pipe.Set(ctx, "stats:requests_timeline", data, 7*24*time.Hour)
 		}
 	}
 
@@ -295,6 +749,16 @@ func (c *Collector) LoadFromRedis(ctx context.Context) error {
 	atomic.StoreInt64(&c.requestCount, requestCount)
 	atomic.StoreInt64(&c.errorCount, errorCount)
 
+	// 加载状态码分类计数(与错误计数一起恢复,保持服务端错误率口径一致)
+	if data, err := c.redisClient.Get(ctx, "stats:status_classes").Bytes(); err == nil {
+		var classes [6]int64
+		if err := json.Unmarshal(data, &classes); err == nil {
+			for i := range classes {
+				atomic.StoreInt64(&c.statusClasses[i], classes[i])
+			}
+		}
+	}
+
 	// 加载端点统计数据
 	endpointsData, err := c.redisClient.Get(ctx, "stats:endpoints").Bytes()
 	if err == nil && len(endpointsData) > 0 {
@@ -314,6 +778,7 @@ func (c *Collector) LoadFromRedis(ctx context.Context) error {
 		if err := json.Unmarshal(data, &requests); err == nil {
 			c.requestsMu.Lock()
 			c.requests = requests
+			c.cleanupOldRequests(time.Now().Unix())
 			c.requestsMu.Unlock()
 			log.Printf("📊 从Redis恢复了 %d 条历史请求记录", len(requests))
 		}
@@ -322,9 +787,36 @@ func (c *Collector) LoadFromRedis(ctx context.Context) error {
 	return nil
 }
 
-// Close 优雅关闭（简化版本,无需等待goroutine）
+// Leader 领导者选举接口(依赖倒置)
+type Leader interface {
+	IsLeader() bool
+}
+
+// SetLeader 设置领导者选举,之后仅领导者执行周期保存(需在 StartAutoSave 之前调用)
+func (c *Collector) SetLeader(leader Leader) {
+	c.leader = leader
+}
+
+// StartAutoSave 启动周期保存到Redis(间隔从上一轮结束时计算,不会重叠执行),Close 时停止
+func (c *Collector) StartAutoSave(interval, jitter time.Duration) {
+	c.bgWG.Go(func() {
+		schedule.Every(c.stopChan, interval, jitter, func() {
+			if c.leader != nil && !c.leader.IsLeader() {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := c.SaveToRedis(ctx); err != nil {
+				log.Printf("⚠️  周期保存统计失败: %v", err)
+			}
+		})
+	})
+}
+
+// Close 停止全部后台任务并等待其退出(等待进行中的保存完成,可重复调用)
 func (c *Collector) Close() error {
-	// 简化版本不需要复杂的关闭逻辑
+	c.stopOnce.Do(func() { close(c.stopChan) })
+	c.bgWG.Wait()
 	return nil
 }
 
@@ -2,6 +2,11 @@ package stats
 
 import (
 	"context"
+	"fmt"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -197,6 +202,62 @@ func TestCollector_Close(t *testing.T) {
 	}
 }
 
+func TestCollector_CloseStopsBackgroundGoroutines(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	collectors := make([]*Collector, 5)
+	for i := range collectors {
+		collectors[i] = NewCollector(nil)
+		collectors[i].StartAutoSave(time.Millisecond, 0)
+	}
+	if runtime.NumGoroutine() <= baseline {
+		t.Fatal("expected auto-save goroutines to be running")
+	}
+	for _, c := range collectors {
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		c.Close() // 可重复调用
+	}
+
+	// Close 等待后台任务退出,但 goroutine 的最终回收可能略有延迟
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		t.Errorf("goroutines leaked after Close: baseline %d, now %d", baseline, n)
+	}
+}
+
+// fixedLeader 固定身份的领导者选举
+type fixedLeader bool
+
+func (l fixedLeader) IsLeader() bool { return bool(l) }
+
+func TestCollector_AutoSaveOnlyOnLeader(t *testing.T) {
+	saved := func(leader bool) bool {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		defer client.Close()
+
+		c := NewCollector(client)
+		c.RecordRequest("/api")
+		c.SetLeader(fixedLeader(leader))
+		c.StartAutoSave(5*time.Millisecond, 0)
+		time.Sleep(50 * time.Millisecond)
+		c.Close()
+		return mr.Exists("stats:request_count")
+	}
+
+	if !saved(true) {
+		t.Error("leader should perform the periodic save")
+	}
+	if saved(false) {
+		t.Error("non-leader should skip the periodic save")
+	}
+}
+
 func TestCollector_SaveToRedis_NilClient(t *testing.T) {
 	c := NewCollector(nil)
 
@@ -457,3 +518,385 @@ func TestCollector_SaveAndLoadRedis(t *testing.T) {
 			c1.GetErrorCount(), c2.GetErrorCount())
 	}
 }
+
+func TestCollector_RecordStatus(t *testing.T) {
+	c := NewCollector(nil)
+
+	c.RecordStatus(200)
+	c.RecordStatus(204)
+	c.RecordStatus(404)
+	c.RecordStatus(503)
+	c.RecordStatus(0)   // 无效状态码忽略
+	c.RecordStatus(600) // 无效状态码忽略
+
+	counts := c.GetStatusClassCounts()
+	if counts["2xx"] != 2 || counts["4xx"] != 1 || counts["5xx"] != 1 {
+		t.Errorf("unexpected status class counts: %v", counts)
+	}
+	if _, ok := counts["3xx"]; ok {
+		t.Error("empty classes should be omitted")
+	}
+}
+
+func TestCollector_Summary(t *testing.T) {
+	c := NewCollector(nil)
+
+	for i := 0; i < 4; i++ {
+		c.RecordRequest("/api")
+	}
+	c.RecordError("/api")
+	c.RecordStatus(200)
+	c.RecordStatus(200)
+	c.RecordStatus(301)
+	c.RecordStatus(500)
+	c.UpdateResponseMetrics(20 * time.Millisecond)
+
+	summary := c.Summary()
+	for _, expected := range []string{
+		"requests=4",
+		"errors=1",
+		"error_rate=25.00%",
+		"server_error_rate=25.00%",
+		"avg_response=20ms",
+		"1xx=0",
+		"2xx=2",
+		"3xx=1",
+		"4xx=0",
+		"5xx=1",
+	} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("summary %q missing %q", summary, expected)
+		}
+	}
+}
+
+func TestCollector_RecordEvent(t *testing.T) {
+	collector := NewCollector(nil)
+
+	collector.RecordEvent("/a", "gzip_corrupt")
+	collector.RecordEvent("/a", "gzip_corrupt")
+	collector.RecordEvent("/b", "gzip_corrupt")
+
+	counts := collector.GetEventCounts()
+	if counts["gzip_corrupt"]["/a"] != 2 || counts["gzip_corrupt"]["/b"] != 1 {
+		t.Fatalf("unexpected event counts: %v", counts)
+	}
+
+	// 快照与内部状态隔离
+	counts["gzip_corrupt"]["/a"] = 100
+	if collector.GetEventCounts()["gzip_corrupt"]["/a"] != 2 {
+		t.Fatal("GetEventCounts should return a copy")
+	}
+}
+
+func TestCollector_SLOCompliance(t *testing.T) {
+	collector := NewCollector(nil)
+	threshold := 2 * time.Second
+
+	// 20 个请求中 19 个在阈值内 -> 95%
+	latencies := make([]time.Duration, 0, 20)
+	for i := 0; i < 19; i++ {
+		latencies = append(latencies, time.Duration(i+1)*100*time.Millisecond)
+	}
+	latencies = append(latencies, 3*time.Second)
+	for _, latency := range latencies {
+		collector.RecordSLO("/api", latency <= threshold)
+	}
+
+	slo := collector.GetSLOStats()["/api"]
+	if slo.Total != 20 || slo.Met != 19 {
+		t.Fatalf("expected 19/20, got %d/%d", slo.Met, slo.Total)
+	}
+	if slo.Compliance != 0.95 {
+		t.Fatalf("expected compliance 0.95, got %v", slo.Compliance)
+	}
+
+	// 未配置 SLO 的端点不出现在结果中
+	collector.RecordRequest("/other")
+	if _, ok := collector.GetSLOStats()["/other"]; ok {
+		t.Fatal("endpoints without SLO samples should be omitted")
+	}
+}
+
+func TestCollector_RecordAPIKey(t *testing.T) {
+	t.Setenv("TOP_CLIENTS_CAPACITY", "2")
+	collector := NewCollector(nil)
+
+	// 与限流、配额使用同一 API Key 来源
+	source, _ := ParseKeySource("query:api_key")
+	for _, target := range []string{"/v1?api_key=key-a", "/v1?api_key=key-a", "/v1?api_key=key-b", "/v1"} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Authorization", "Bearer other")
+		collector.RecordAPIKey(source.Key(req))
+	}
+
+	top := collector.TopAPIKeys(10)
+	if len(top) != 2 || top[0].Key != HashAPIKey("key-a") || top[0].Count != 2 {
+		t.Fatalf("unexpected per-key attribution: %+v", top)
+	}
+	for _, entry := range top {
+		if !strings.HasPrefix(entry.Key, "sha256:") || strings.Contains(entry.Key, "key-") {
+			t.Fatalf("raw key leaked: %q", entry.Key)
+		}
+	}
+
+	// 基数受容量限制
+	collector.RecordAPIKey("key-c")
+	collector.RecordAPIKey("key-d")
+	if n := len(collector.TopAPIKeys(0)); n != 2 {
+		t.Fatalf("expected at most 2 tracked keys, got %d", n)
+	}
+}
+
+// recordResponse 模拟代理对一次上游响应的统计调用
+func recordResponse(c *Collector, endpoint string, status int) {
+	c.RecordRequest(endpoint)
+	c.RecordStatus(status)
+	if status >= 400 {
+		c.RecordError(endpoint)
+	}
+}
+
+func TestCollector_ServerErrorRate(t *testing.T) {
+	c := NewCollector(nil)
+	for range 8 {
+		recordResponse(c, "/api", 404)
+	}
+	recordResponse(c, "/api", 200)
+	recordResponse(c, "/api", 200)
+
+	metrics := c.GetPerformanceMetrics()
+	if metrics.ServerErrorRate != 0 {
+		t.Fatalf("404 burst must not raise server error rate, got %.2f%%", metrics.ServerErrorRate)
+	}
+	if metrics.ClientErrors != 8 {
+		t.Fatalf("expected 8 client errors, got %d", metrics.ClientErrors)
+	}
+	if metrics.ErrorRate != 80 {
+		t.Fatalf("default mode should count all errors, got %.2f%%", metrics.ErrorRate)
+	}
+
+	// 5xx 与上游失败(记录代理返回的502)计入服务端错误
+	recordResponse(c, "/api", 500)
+	recordResponse(c, "/api", 502)
+	c.cachedMetrics = nil
+
+	metrics = c.GetPerformanceMetrics()
+	if want := 2.0 / 12.0 * 100; metrics.ServerErrorRate < want-0.01 || metrics.ServerErrorRate > want+0.01 {
+		t.Fatalf("expected server error rate ~%.2f%%, got %.2f%%", want, metrics.ServerErrorRate)
+	}
+}
+
+func TestCollector_ErrorRateServerMode(t *testing.T) {
+	t.Setenv("STATS_ERROR_RATE_MODE", "server")
+	c := NewCollector(nil)
+	for range 3 {
+		recordResponse(c, "/api", 401)
+	}
+	recordResponse(c, "/api", 503)
+
+	metrics := c.GetPerformanceMetrics()
+	if metrics.ErrorRate != 25 || metrics.ServerErrorRate != 25 {
+		t.Fatalf("server mode should exclude 4xx from error rate, got error=%.2f%% server=%.2f%%",
+			metrics.ErrorRate, metrics.ServerErrorRate)
+	}
+	// 关闭时的摘要与性能指标口径一致
+	if summary := c.Summary(); !strings.Contains(summary, " error_rate=25.00%") {
+		t.Errorf("summary should use the server error rate mode, got %q", summary)
+	}
+}
+
+func TestCollector_StatusClassesPersisted(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	c := NewCollector(client)
+	recordResponse(c, "/api", 404)
+	recordResponse(c, "/api", 502)
+	if err := c.SaveToRedis(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewCollector(client)
+	if err := restored.LoadFromRedis(ctx); err != nil {
+		t.Fatal(err)
+	}
+	metrics := restored.GetPerformanceMetrics()
+	if metrics.ClientErrors != 1 || metrics.ServerErrorRate != 50 {
+		t.Fatalf("expected restored status classes, got client=%d server=%.2f%%", metrics.ClientErrors, metrics.ServerErrorRate)
+	}
+}
+
+func TestCollector_SeriesRetention(t *testing.T) {
+	t.Setenv("STATS_SERIES_MAX_RECORDS", "100")
+	t.Setenv("STATS_SERIES_RETENTION", "2h")
+	c := NewCollector(nil)
+
+	// 超出条数上限时裁剪,长度始终不超过配置值
+	for range 250 {
+		c.RecordRequest("/api")
+	}
+	if n := len(c.GetRequests()); n == 0 || n > 100 {
+		t.Fatalf("expected series capped at 100, got %d", n)
+	}
+
+	// 超出保留时长的记录被丢弃
+	now := time.Now().Unix()
+	c.requestsMu.Lock()
+	c.requests = []RequestRecord{
+		{Timestamp: now - 3*3600, Endpoint: "/old"},
+		{Timestamp: now - 2*3600 - 1, Endpoint: "/old"},
+		{Timestamp: now - 3600, Endpoint: "/recent"},
+	}
+	c.requestsMu.Unlock()
+	c.RecordRequest("/new")
+
+	requests := c.GetRequests()
+	if len(requests) != 2 || requests[0].Endpoint != "/recent" || requests[1].Endpoint != "/new" {
+		t.Fatalf("expected old records dropped, got %+v", requests)
+	}
+}
+
+func TestCollector_SeriesRetentionBounds(t *testing.T) {
+	t.Setenv("STATS_SERIES_MAX_RECORDS", "1")
+	t.Setenv("STATS_SERIES_RETENTION", "1m")
+	c := NewCollector(nil)
+	if c.maxRequestsCache != DefaultSeriesMaxRecords || c.seriesRetention != DefaultSeriesRetention {
+		t.Errorf("out-of-range values should fall back to defaults, got %d / %s", c.maxRequestsCache, c.seriesRetention)
+	}
+}
+
+func TestCollector_RequestSizeStats(t *testing.T) {
+	c := NewCollector(nil)
+	c.RecordSizes("/api", 1000, 10)
+	c.RecordSizes("/api", 3000, 10)
+	c.RecordRequest("/idle")
+
+	sizes := c.GetRequestSizeStats()
+	if got := sizes["/api"]; got.Count != 2 || got.TotalBytes != 4000 || got.AvgBytes != 2000 {
+		t.Errorf("unexpected request size stats: %+v", got)
+	}
+	if _, ok := sizes["/idle"]; ok {
+		t.Error("endpoints without recorded sizes should be omitted")
+	}
+	if ep := c.GetStats()["/api"]; ep.RequestBytes != 4000 || ep.RequestBodies != 2 {
+		t.Errorf("expected request bytes in endpoint stats, got %+v", ep)
+	}
+}
+
+func TestQueryRequests(t *testing.T) {
+	var records []RequestRecord
+	for ts := int64(100); ts < 110; ts++ {
+		records = append(records, RequestRecord{Timestamp: ts, Endpoint: "/api"})
+	}
+	// 同一秒多条记录
+	records = append(records, RequestRecord{Timestamp: 109, Endpoint: "/other"})
+
+	timestamps := func(rs []RequestRecord) []int64 {
+		out := []int64{}
+		for _, r := range rs {
+			out = append(out, r.Timestamp)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		query     RequestQuery
+		want      []int64
+		wantTotal int
+	}{
+		{"all", RequestQuery{}, []int64{100, 101, 102, 103, 104, 105, 106, 107, 108, 109, 109}, 11},
+		{"limit", RequestQuery{Limit: 3}, []int64{100, 101, 102}, 11},
+		{"offsetLimit", RequestQuery{Offset: 4, Limit: 2}, []int64{104, 105}, 11},
+		{"offsetPastEnd", RequestQuery{Offset: 20}, []int64{}, 11},
+		{"since", RequestQuery{Since: 108}, []int64{108, 109, 109}, 3},
+		{"until", RequestQuery{Until: 101}, []int64{100, 101}, 2},
+		{"range", RequestQuery{Since: 103, Until: 106}, []int64{103, 104, 105, 106}, 4},
+		{"rangePaged", RequestQuery{Since: 103, Until: 106, Offset: 1, Limit: 2}, []int64{104, 105}, 4},
+		{"emptyRange", RequestQuery{Since: 200}, []int64{}, 0},
+		{"invertedRange", RequestQuery{Since: 106, Until: 103}, []int64{}, 0},
+	}
+	for _, tt := range tests {
+		got, total := QueryRequests(records, tt.query)
+		if !slices.Equal(timestamps(got), tt.want) || total != tt.wantTotal {
+			t.Errorf("%s: got %v (total %d), want %v (total %d)", tt.name, timestamps(got), total, tt.want, tt.wantTotal)
+		}
+	}
+}
+
+// blockingPipelineHook 在执行 pipeline 前通知并等待放行,用于验证保存期间不持有锁
+type blockingPipelineHook struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingPipelineHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *blockingPipelineHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook { return next }
+
+func (h *blockingPipelineHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		// 只拦截统计保存的 pipeline(连接握手同样使用 pipeline)
+		if len(cmds) > 0 && len(cmds[0].Args()) > 1 && cmds[0].Args()[1] == "stats:request_count" {
+			close(h.started)
+			<-h.release
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func TestCollector_SaveToRedisDoesNotBlockRecording(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	c := NewCollector(client)
+	const endpoints = 5000
+	for i := range endpoints {
+		c.RecordRequest(fmt.Sprintf("/api/%d", i))
+	}
+
+	hook := &blockingPipelineHook{started: make(chan struct{}), release: make(chan struct{})}
+	client.AddHook(hook)
+
+	saved := make(chan error, 1)
+	go func() { saved <- c.SaveToRedis(ctx) }()
+	<-hook.started
+
+	// pipeline 执行期间新增端点和记录请求不应被阻塞
+	recorded := make(chan struct{})
+	go func() {
+		for i := range 100 {
+			c.RecordRequest(fmt.Sprintf("/new/%d", i))
+			c.RecordError("/api/1")
+		}
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("RecordRequest blocked while SaveToRedis was writing")
+	}
+
+	close(hook.release)
+	if err := <-saved; err != nil {
+		t.Fatalf("SaveToRedis failed: %v", err)
+	}
+
+	// 保存的是快照: 包含全部原有端点,不包含保存开始后新增的端点
+	restored := NewCollector(client)
+	if err := restored.LoadFromRedis(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stats := restored.GetStats()
+	if len(stats) != endpoints {
+		t.Fatalf("expected %d endpoints restored, got %d", endpoints, len(stats))
+	}
+	if restored.GetRequestCount() != endpoints || len(restored.GetRequests()) != endpoints {
+		t.Errorf("unexpected restored counts: %d requests, %d records", restored.GetRequestCount(), len(restored.GetRequests()))
+	}
+}
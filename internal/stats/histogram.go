@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultSizeBuckets 默认的请求/响应大小分桶上界(字节)
+var DefaultSizeBuckets = []int64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+// Histogram 固定分桶直方图
+// Counts 为各桶的非累计计数,长度为 len(Buckets)+1,最后一个桶对应 +Inf
+type Histogram struct {
+	Buckets []int64 `json:"buckets"` // 桶上界(含)
+	Counts  []int64 `json:"counts"`
+	Count   int64   `json:"count"`
+	Sum     int64   `json:"sum"`
+}
+
+// SizeHistograms 端点请求体/响应体大小分布
+type SizeHistograms struct {
+	Request  Histogram `json:"request"`
+	Response Histogram `json:"response"`
+}
+
+func newHistogram(buckets []int64) Histogram {
+	return Histogram{Buckets: buckets, Counts: make([]int64, len(buckets)+1)}
+}
+
+// observe 记录一个样本(调用方负责加锁)
+func (h *Histogram) observe(v int64) {
+	i, _ := slices.BinarySearch(h.Buckets, v)
+	h.Counts[i]++
+	h.Count++
+	h.Sum += v
+}
+
+func (h Histogram) clone() Histogram {
+	h.Counts = slices.Clone(h.Counts)
+	return h
+}
+
+// ParseSizeBuckets 解析分桶上界,支持 K/M/G 后缀(1024进制),如 "1K,64K,1M"
+// 结果升序去重
+func ParseSizeBuckets(items []string) ([]int64, error) {
+	buckets := make([]int64, 0, len(items))
+	for _, item := range items {
+		size, err := parseSize(item)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, size)
+	}
+	slices.Sort(buckets)
+	return slices.Compact(buckets), nil
+}
+
+func parseSize(s string) (int64, error) {
+	raw := strings.ToUpper(strings.TrimSpace(s))
+	raw = strings.TrimSuffix(raw, "B")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(raw, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(raw, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(raw, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		raw = raw[:len(raw)-1]
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size bucket %q", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCollector_RecordSizes(t *testing.T) {
+	t.Setenv("STATS_SIZE_BUCKETS", "1K,100,10K")
+	c := NewCollector(nil)
+
+	c.RecordSizes("/api", 50, 100)   // 请求 <=100, 响应 <=100(上界含)
+	c.RecordSizes("/api", 500, 2048) // 请求 <=1K, 响应 <=10K
+	c.RecordSizes("/api", 0, 1<<20)  // 响应超出最大桶
+	c.RecordSizes("/upload", 5000, 10)
+
+	sizes := c.GetSizeHistograms()
+	api := sizes["/api"]
+	if !slices.Equal(api.Request.Buckets, []int64{100, 1024, 10240}) {
+		t.Fatalf("buckets should be parsed and sorted, got %v", api.Request.Buckets)
+	}
+	if !slices.Equal(api.Request.Counts, []int64{2, 1, 0, 0}) {
+		t.Fatalf("unexpected request buckets: %v", api.Request.Counts)
+	}
+	if !slices.Equal(api.Response.Counts, []int64{1, 0, 1, 1}) {
+		t.Fatalf("unexpected response buckets: %v", api.Response.Counts)
+	}
+	if api.Request.Count != 3 || api.Request.Sum != 550 {
+		t.Fatalf("unexpected request count/sum: %d/%d", api.Request.Count, api.Request.Sum)
+	}
+	if !slices.Equal(sizes["/upload"].Request.Counts, []int64{0, 0, 1, 0}) {
+		t.Fatalf("unexpected upload buckets: %v", sizes["/upload"].Request.Counts)
+	}
+
+	// 快照与内部状态隔离
+	api.Request.Counts[0] = 99
+	if c.GetSizeHistograms()["/api"].Request.Counts[0] != 2 {
+		t.Fatal("snapshot must not alias collector state")
+	}
+}
+
+func TestCollector_InvalidSizeBucketsFallback(t *testing.T) {
+	t.Setenv("STATS_SIZE_BUCKETS", "1K,abc")
+	c := NewCollector(nil)
+	if !slices.Equal(c.sizeBuckets, DefaultSizeBuckets) {
+		t.Fatalf("expected default buckets, got %v", c.sizeBuckets)
+	}
+}
+
+func TestParseSizeBuckets(t *testing.T) {
+	got, err := ParseSizeBuckets([]string{"1M", "64kb", "512", "64K"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int64{512, 64 << 10, 1 << 20}) {
+		t.Fatalf("unexpected buckets: %v", got)
+	}
+	for _, bad := range []string{"", "0", "-1K", "1T"} {
+		if _, err := ParseSizeBuckets([]string{bad}); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestCollector_WritePrometheus(t *testing.T) {
+	t.Setenv("STATS_SIZE_BUCKETS", "100,1000")
+	c := NewCollector(nil)
+	c.RecordRequest("/api")
+	c.RecordSizes("/api", 50, 500)
+	c.RecordSizes("/api", 150, 5000)
+
+	var b strings.Builder
+	if err := c.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"apiproxy_requests_total 1\n",
+		"# TYPE apiproxy_request_size_bytes histogram\n",
+		`apiproxy_request_size_bytes_bucket{endpoint="/api",le="100"} 1` + "\n",
+		`apiproxy_request_size_bytes_bucket{endpoint="/api",le="1000"} 2` + "\n",
+		`apiproxy_request_size_bytes_bucket{endpoint="/api",le="+Inf"} 2` + "\n",
+		`apiproxy_request_size_bytes_sum{endpoint="/api"} 200` + "\n",
+		`apiproxy_response_size_bytes_bucket{endpoint="/api",le="1000"} 1` + "\n",
+		`apiproxy_response_size_bytes_count{endpoint="/api"} 2` + "\n",
+		"apiproxy_size_sample_rate 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestCollector_RecordSizesSampling(t *testing.T) {
+	t.Setenv("STATS_SIZE_BUCKETS", "1K,10K")
+	t.Setenv("STATS_SIZE_SAMPLE_RATE", "0.1")
+	c := NewCollector(nil)
+
+	const total = 20000
+	for range total {
+		c.RecordSizes("/api", 500, 2048)
+	}
+
+	// 约10%的请求被采样(标准差约42,允许±25%)
+	api := c.GetSizeHistograms()["/api"]
+	if api.Request.Count < total/10*3/4 || api.Request.Count > total/10*5/4 {
+		t.Fatalf("expected about %d sampled requests, got %d", total/10, api.Request.Count)
+	}
+
+	// 采样的请求完整计入直方图与平均大小
+	if !slices.Equal(api.Request.Counts, []int64{api.Request.Count, 0, 0}) ||
+		!slices.Equal(api.Response.Counts, []int64{0, api.Response.Count, 0}) ||
+		api.Response.Count != api.Request.Count || api.Response.Sum != 2048*api.Response.Count {
+		t.Fatalf("sampled sizes should feed the histograms, got %+v", api)
+	}
+	if avg := c.GetRequestSizeStats()["/api"]; avg.Count != api.Request.Count || avg.AvgBytes != 500 {
+		t.Errorf("unexpected sampled average: %+v", avg)
+	}
+
+	// 采样率随直方图一同输出,便于换算实际请求数
+	var b strings.Builder
+	if err := c.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "apiproxy_size_sample_rate 0.1\n") {
+		t.Errorf("expected sample rate gauge in output:\n%s", b.String())
+	}
+}
+
+func TestCollector_RecordSizesSampleRateValidation(t *testing.T) {
+	for _, value := range []string{"0", "-0.5", "1.5"} {
+		t.Setenv("STATS_SIZE_SAMPLE_RATE", value)
+		c := NewCollector(nil)
+		if c.sizeSample != 1 {
+			t.Errorf("STATS_SIZE_SAMPLE_RATE=%s: expected fallback to 1, got %g", value, c.sizeSample)
+		}
+		for range 10 {
+			c.RecordSizes("/api", 1, 1)
+		}
+		if got := c.GetSizeHistograms()["/api"].Request.Count; got != 10 {
+			t.Errorf("STATS_SIZE_SAMPLE_RATE=%s: expected every request recorded, got %d", value, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// KeySource 请求中 API Key 的位置(API_KEY_SOURCE),用于按 Key 分桶限流和计算配额
+type KeySource struct {
+	header string // 请求头名称(Authorization 等支持 Bearer 前缀)
+	query  string // 查询参数名
+}
+
+// DefaultKeySource 默认从 Authorization 请求头提取
+var DefaultKeySource = KeySource{header: "Authorization"}
+
+// ParseKeySource 解析 header:<名称> 或 query:<参数名>,不带前缀的值按请求头名称处理
+func ParseKeySource(spec string) (KeySource, error) {
+	spec = strings.TrimSpace(spec)
+	kind, name, ok := strings.Cut(spec, ":")
+	if !ok {
+		kind, name = "header", spec
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return KeySource{}, fmt.Errorf("invalid API key source %q: name is empty", spec)
+	}
+	switch strings.ToLower(kind) {
+	case "header":
+		return KeySource{header: http.CanonicalHeaderKey(name)}, nil
+	case "query":
+		return KeySource{query: name}, nil
+	default:
+		return KeySource{}, fmt.Errorf("invalid API key source %q: expected header:<name> or query:<name>", spec)
+	}
+}
+
+// String 返回配置形式
+func (s KeySource) String() string {
+	if s.query != "" {
+		return "query:" + s.query
+	}
+	return "header:" + s.header
+}
+
+// Key 返回请求携带的 API Key,未携带时返回空
+func (s KeySource) Key(r *http.Request) string {
+	if s.query != "" {
+		return strings.TrimSpace(r.URL.Query().Get(s.query))
+	}
+	if s.header == "" {
+		return ""
+	}
+	return ParseAPIKey(r.Header.Get(s.header))
+}
+
+// Bucket 返回分桶键: API Key 摘要,未携带 Key 时回退为客户端IP(ip:<地址>)
+func (s KeySource) Bucket(r *http.Request) string {
+	if key := s.Key(r); key != "" {
+		return HashAPIKey(key)
+	}
+	return "ip:" + ClientIP(r)
+}
+
+type clientIPKey struct{}
+
+// WithClientIP 在请求上下文中记录解析后的客户端IP(由路由按 TRUSTED_PROXIES 解析)
+func WithClientIP(r *http.Request, ip string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+}
+
+// ClientIP 返回客户端IP: 优先使用 WithClientIP 记录的地址,否则为连接地址(不含端口)
+func ClientIP(r *http.Request) string {
+	if ip, _ := r.Context().Value(clientIPKey{}).(string); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,67 @@
+package stats
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeySource_Bucket(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   string
+		url    string
+		header map[string]string
+		want   string
+	}{
+		{"bearer", "header:Authorization", "/v1", map[string]string{"Authorization": "Bearer sk-1"}, HashAPIKey("sk-1")},
+		{"customHeader", "header:x-api-key", "/v1", map[string]string{"X-API-Key": "sk-2"}, HashAPIKey("sk-2")},
+		{"bareHeaderName", "X-API-Key", "/v1", map[string]string{"X-API-Key": "sk-3"}, HashAPIKey("sk-3")},
+		{"query", "query:api_key", "/v1?api_key=sk-4", nil, HashAPIKey("sk-4")},
+		{"queryIgnoresHeader", "query:api_key", "/v1", map[string]string{"Authorization": "Bearer sk-5"}, "ip:192.0.2.1"},
+		{"missingHeader", "header:X-API-Key", "/v1", nil, "ip:192.0.2.1"},
+		{"emptyBearer", "header:Authorization", "/v1", map[string]string{"Authorization": "Bearer "}, "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		source, err := ParseKeySource(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: ParseKeySource(%q) failed: %v", tt.name, tt.spec, err)
+		}
+		req := httptest.NewRequest("GET", "http://localhost"+tt.url, nil)
+		req.RemoteAddr = "192.0.2.1:5000"
+		for name, value := range tt.header {
+			req.Header.Set(name, value)
+		}
+		if got := source.Bucket(req); got != tt.want {
+			t.Errorf("%s: Bucket = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeySource_BucketUsesResolvedClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://localhost/v1", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if got := DefaultKeySource.Bucket(req); got != "ip:10.0.0.1" {
+		t.Fatalf("without a resolved IP the connection address should be used, got %q", got)
+	}
+
+	req = WithClientIP(req, "198.51.100.7")
+	if got := DefaultKeySource.Bucket(req); got != "ip:198.51.100.7" {
+		t.Errorf("Bucket should use the resolved client IP, got %q", got)
+	}
+}
+
+func TestParseKeySource(t *testing.T) {
+	for _, spec := range []string{"", "header:", "query: ", "cookie:session"} {
+		if _, err := ParseKeySource(spec); err == nil {
+			t.Errorf("ParseKeySource(%q) should fail", spec)
+		}
+	}
+	source, err := ParseKeySource("query:key")
+	if err != nil || source.String() != "query:key" {
+		t.Errorf("unexpected source %v, %v", source, err)
+	}
+	if DefaultKeySource.String() != "header:Authorization" {
+		t.Errorf("unexpected default %s", DefaultKeySource)
+	}
+}
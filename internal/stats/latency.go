@@ -0,0 +1,61 @@
+package stats
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// 响应时间样本数的默认值与取值范围(STATS_LATENCY_SAMPLES)
+const (
+	DefaultLatencySamples = 4096
+	minLatencySamples     = 100
+	maxLatencySamples     = 1000000
+)
+
+// latencySamples 最近 N 个响应时间样本的环形缓冲区(并发安全),用于计算分位数
+// 只保留最近的样本,分位数反映当前的尾延迟而非历史全量
+type latencySamples struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+func newLatencySamples(size int) *latencySamples {
+	return &latencySamples{samples: make([]time.Duration, size)}
+}
+
+// add 记录一个样本,缓冲区已满时覆盖最旧的样本
+func (l *latencySamples) add(d time.Duration) {
+	l.mu.Lock()
+	l.samples[l.next] = d
+	l.next++
+	if l.next == len(l.samples) {
+		l.next, l.full = 0, true
+	}
+	l.mu.Unlock()
+}
+
+// percentiles 返回各分位数(0~1,最近秩法),无样本时全部为0
+// 在副本上排序,不阻塞并发写入
+func (l *latencySamples) percentiles(qs ...float64) []time.Duration {
+	l.mu.Lock()
+	n := l.next
+	if l.full {
+		n = len(l.samples)
+	}
+	sorted := slices.Clone(l.samples[:n])
+	l.mu.Unlock()
+
+	result := make([]time.Duration, len(qs))
+	if n == 0 {
+		return result
+	}
+	slices.Sort(sorted)
+	for i, q := range qs {
+		rank := int(q*float64(n)+0.999999) - 1
+		result[i] = sorted[min(max(rank, 0), n-1)]
+	}
+	return result
+}
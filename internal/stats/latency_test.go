@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatencySamples_Percentiles(t *testing.T) {
+	l := newLatencySamples(1000)
+	if got := l.percentiles(0.5); got[0] != 0 {
+		t.Fatalf("expected 0 without samples, got %v", got)
+	}
+
+	// 1ms~1000ms 均匀分布,乱序写入
+	for _, i := range rand.Perm(1000) {
+		l.add(time.Duration(i+1) * time.Millisecond)
+	}
+	got := l.percentiles(0.50, 0.95, 0.99)
+	want := []time.Duration{500 * time.Millisecond, 950 * time.Millisecond, 990 * time.Millisecond}
+	for i := range want {
+		if diff := got[i] - want[i]; diff < -5*time.Millisecond || diff > 5*time.Millisecond {
+			t.Errorf("percentile %d: got %v, want %v±5ms", i, got[i], want[i])
+		}
+	}
+
+	// 缓冲区满后只保留最近的样本
+	for range 1000 {
+		l.add(2 * time.Second)
+	}
+	if got := l.percentiles(0.5); got[0] != 2*time.Second {
+		t.Errorf("expected old samples to be evicted, got %v", got)
+	}
+}
+
+func TestCollector_LatencyPercentiles(t *testing.T) {
+	c := NewCollector(nil)
+
+	// 90% 快请求 + 10% 慢请求: 平均值掩盖尾延迟,p95/p99 暴露慢请求
+	var wg sync.WaitGroup
+	for w := range 10 {
+		wg.Go(func() {
+			for i := range 200 {
+				if (w*200+i)%10 == 0 {
+					c.UpdateResponseMetrics(2 * time.Second)
+				} else {
+					c.UpdateResponseMetrics(10 * time.Millisecond)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	metrics := c.GetPerformanceMetrics()
+	if metrics.P50ResponseTimeMs != 10 || metrics.P95ResponseTimeMs != 2000 || metrics.P99ResponseTimeMs != 2000 {
+		t.Errorf("unexpected percentiles: p50=%d p95=%d p99=%d",
+			metrics.P50ResponseTimeMs, metrics.P95ResponseTimeMs, metrics.P99ResponseTimeMs)
+	}
+	if metrics.AvgResponseTimeMs >= metrics.P95ResponseTimeMs {
+		t.Errorf("expected average %dms below p95", metrics.AvgResponseTimeMs)
+	}
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// PrometheusContentType Prometheus 文本格式的内容类型
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WritePrometheus 以 Prometheus 文本格式输出指标
+func (c *Collector) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "# HELP apiproxy_requests_total Total proxied requests.")
+	fmt.Fprintln(bw, "# TYPE apiproxy_requests_total counter")
+	fmt.Fprintf(bw, "apiproxy_requests_total %d\n", c.GetRequestCount())
+	fmt.Fprintln(bw, "# HELP apiproxy_errors_total Total proxied requests that failed or returned 4xx/5xx.")
+	fmt.Fprintln(bw, "# TYPE apiproxy_errors_total counter")
+	fmt.Fprintf(bw, "apiproxy_errors_total %d\n", c.GetErrorCount())
+
+	sizes := c.GetSizeHistograms()
+	endpoints := make([]string, 0, len(sizes))
+	for endpoint := range sizes {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	writeHistograms(bw, "apiproxy_request_size_bytes", "Request body size per endpoint.", endpoints,
+		func(endpoint string) Histogram { return sizes[endpoint].Request })
+	writeHistograms(bw, "apiproxy_response_size_bytes", "Response body size per endpoint.", endpoints,
+		func(endpoint string) Histogram { return sizes[endpoint].Response })
+	// 大小直方图按采样率记录,计数除以采样率即为估算的实际请求数
+	fmt.Fprintln(bw, "# HELP apiproxy_size_sample_rate Fraction of requests recorded in the size histograms.")
+	fmt.Fprintln(bw, "# TYPE apiproxy_size_sample_rate gauge")
+	fmt.Fprintf(bw, "apiproxy_size_sample_rate %g\n", c.SizeSampleRate())
+
+	return bw.Flush()
+}
+
+// writeHistograms 输出按端点分组的直方图(桶计数为累计值)
+func writeHistograms(w io.Writer, name, help string, endpoints []string, get func(string) Histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, endpoint := range endpoints {
+		h := get(endpoint)
+		label := strconv.Quote(endpoint)
+		var cumulative int64
+		for i, le := range h.Buckets {
+			cumulative += h.Counts[i]
+			fmt.Fprintf(w, "%s_bucket{endpoint=%s,le=\"%d\"} %d\n", name, label, le, cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{endpoint=%s,le=\"+Inf\"} %d\n", name, label, h.Count)
+		fmt.Fprintf(w, "%s_sum{endpoint=%s} %d\n", name, label, h.Sum)
+		fmt.Fprintf(w, "%s_count{endpoint=%s} %d\n", name, label, h.Count)
+	}
+}
@@ -0,0 +1,204 @@
+package stats
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-proxy/internal/config"
+)
+
+// statsdMaxPacket 单个UDP包的最大字节数(避免以太网MTU下分片)
+const statsdMaxPacket = 1432
+
+// StatsdExporter 按周期将关键指标以 StatsD/DogStatsD 格式发送到UDP端点
+// 计数器发送周期内增量,延迟发送周期内平均值(毫秒)
+type StatsdExporter struct {
+	collector *Collector
+	conn      net.Conn
+	prefix    string
+	dogstatsd bool // true时端点以 #endpoint:xxx 标签表示,否则拼入指标名
+	interval  time.Duration
+
+	// 上次发送时的累计值(用于计算增量,mu保护)
+	mu        sync.Mutex
+	closed    bool
+	lastReqs  int64
+	lastErrs  int64
+	lastSum   int64
+	lastCount int64
+	lastEps   map[string]EndpointStats
+
+	stopOnce sync.Once
+	stopChan chan struct{}
+}
+
+// NewStatsdExporter 创建StatsD导出器
+// STATSD_PREFIX 指标前缀(默认 apiproxy.),STATSD_INTERVAL 发送周期(默认10s),STATSD_DOGSTATSD 启用标签格式
+func NewStatsdExporter(c *Collector, addr string) (*StatsdExporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial statsd %s: %w", addr, err)
+	}
+	prefix := config.String("STATSD_PREFIX", "apiproxy.")
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	e := &StatsdExporter{
+		collector: c,
+		conn:      conn,
+		prefix:    prefix,
+		dogstatsd: config.Bool("STATSD_DOGSTATSD", false),
+		interval:  config.Duration("STATSD_INTERVAL", 10*time.Second),
+		lastEps:   make(map[string]EndpointStats),
+		stopChan:  make(chan struct{}),
+	}
+	// 以当前累计值为基线,避免将从Redis恢复的历史数据计入首个周期
+	e.collect()
+	return e, nil
+}
+
+// Start 启动周期发送
+func (e *StatsdExporter) Start() {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.flush()
+			case <-e.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止发送,发送最后一个周期的增量并关闭连接
+func (e *StatsdExporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopChan)
+		e.flush()
+
+		e.mu.Lock()
+		e.closed = true
+		e.conn.Close()
+		e.mu.Unlock()
+	})
+}
+
+// flush 计算本周期增量并发送
+func (e *StatsdExporter) flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+
+	lines := e.collect()
+	for _, packet := range packLines(lines, statsdMaxPacket) {
+		if _, err := e.conn.Write([]byte(packet)); err != nil {
+			log.Printf("⚠️  StatsD 发送失败: %v", err)
+			return
+		}
+	}
+}
+
+// collect 生成本周期的指标行(调用方持有mu)
+func (e *StatsdExporter) collect() []string {
+	c := e.collector
+	var lines []string
+
+	reqs := c.GetRequestCount()
+	errs := c.GetErrorCount()
+	sum := atomic.LoadInt64(&c.responseTimeSum)
+	count := atomic.LoadInt64(&c.responseTimeCount)
+
+	if d := reqs - e.lastReqs; d > 0 {
+		lines = append(lines, e.line("requests", d, "c", ""))
+	}
+	if d := errs - e.lastErrs; d > 0 {
+		lines = append(lines, e.line("errors", d, "c", ""))
+	}
+	if d := count - e.lastCount; d > 0 {
+		avg := time.Duration((sum - e.lastSum) / d)
+		lines = append(lines, e.line("latency", avg.Milliseconds(), "ms", ""))
+	}
+	e.lastReqs, e.lastErrs, e.lastSum, e.lastCount = reqs, errs, sum, count
+
+	// 端点计数(按名称排序,输出稳定)
+	endpoints := c.GetStats()
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cur, last := endpoints[name], e.lastEps[name]
+		if d := cur.Count - last.Count; d > 0 {
+			lines = append(lines, e.line("endpoint.requests", d, "c", name))
+		}
+		if d := cur.ErrorCount - last.ErrorCount; d > 0 {
+			lines = append(lines, e.line("endpoint.errors", d, "c", name))
+		}
+		e.lastEps[name] = *cur
+	}
+	return lines
+}
+
+// line 格式化单条指标,如 apiproxy.requests:5|c 或 apiproxy.endpoint.requests:3|c|#endpoint:/api
+func (e *StatsdExporter) line(name string, value int64, kind, endpoint string) string {
+	if endpoint == "" {
+		return fmt.Sprintf("%s%s:%d|%s", e.prefix, name, value, kind)
+	}
+	if e.dogstatsd {
+		return fmt.Sprintf("%s%s:%d|%s|#endpoint:%s", e.prefix, name, value, kind, sanitizeTag(endpoint))
+	}
+	return fmt.Sprintf("%s%s.%s:%d|%s", e.prefix, name, sanitizeMetricName(endpoint), value, kind)
+}
+
+// sanitizeMetricName 将端点转换为合法的StatsD指标名片段(/api/v1 -> api_v1)
+func sanitizeMetricName(endpoint string) string {
+	name := strings.Trim(endpoint, "/")
+	if name == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// sanitizeTag 去除DogStatsD标签中的分隔符
+func sanitizeTag(value string) string {
+	return strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_").Replace(value)
+}
+
+// packLines 将指标行按换行拼接为不超过 max 字节的UDP包
+func packLines(lines []string, max int) []string {
+	var packets []string
+	var b strings.Builder
+	for _, line := range lines {
+		if b.Len() > 0 && b.Len()+1+len(line) > max {
+			packets = append(packets, b.String())
+			b.Reset()
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+	}
+	if b.Len() > 0 {
+		packets = append(packets, b.String())
+	}
+	return packets
+}
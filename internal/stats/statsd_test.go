@@ -0,0 +1,99 @@
+package stats
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenStatsd 启动本地UDP监听,返回地址和读取单个包的函数
+func listenStatsd(t *testing.T) (string, func() []string) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	read := func() []string {
+		buf := make([]byte, statsdMaxPacket)
+		pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("expected statsd packet: %v", err)
+		}
+		return strings.Split(string(buf[:n]), "\n")
+	}
+	return pc.LocalAddr().String(), read
+}
+
+func TestStatsdExporter_Flush(t *testing.T) {
+	addr, read := listenStatsd(t)
+	c := NewCollector(nil)
+	c.RecordRequest("/old") // 导出器创建前的数据不计入
+
+	e, err := NewStatsdExporter(c, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Stop()
+
+	c.RecordRequest("/api/v1")
+	c.RecordRequest("/api/v1")
+	c.RecordRequest("/old")
+	c.RecordError("/api/v1")
+	c.UpdateResponseMetrics(10 * time.Millisecond)
+	c.UpdateResponseMetrics(30 * time.Millisecond)
+	e.flush()
+
+	got := read()
+	want := []string{
+		"apiproxy.requests:3|c",
+		"apiproxy.errors:1|c",
+		"apiproxy.latency:20|ms",
+		"apiproxy.endpoint.requests.api_v1:2|c",
+		"apiproxy.endpoint.errors.api_v1:1|c",
+		"apiproxy.endpoint.requests.old:1|c",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected metric lines:\n got %q\nwant %q", got, want)
+	}
+
+	// 下一周期仅发送增量
+	c.RecordRequest("/old")
+	e.flush()
+	got = read()
+	if strings.Join(got, "\n") != "apiproxy.requests:1|c\napiproxy.endpoint.requests.old:1|c" {
+		t.Fatalf("expected only deltas, got %q", got)
+	}
+}
+
+func TestStatsdExporter_DogStatsdTags(t *testing.T) {
+	t.Setenv("STATSD_DOGSTATSD", "true")
+	t.Setenv("STATSD_PREFIX", "proxy")
+	addr, read := listenStatsd(t)
+	c := NewCollector(nil)
+
+	e, err := NewStatsdExporter(c, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.RecordRequest("/api")
+	e.Stop() // 关闭时发送最后一个周期
+
+	got := read()
+	want := []string{"proxy.requests:1|c", "proxy.endpoint.requests:1|c|#endpoint:/api"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected metric lines: %q", got)
+	}
+}
+
+func TestPackLines(t *testing.T) {
+	lines := []string{"a:1|c", "b:1|c", "c:1|c"}
+	packets := packLines(lines, 11)
+	if len(packets) != 2 || packets[0] != "a:1|c\nb:1|c" || packets[1] != "c:1|c" {
+		t.Fatalf("unexpected packets: %q", packets)
+	}
+}
@@ -0,0 +1,135 @@
+package stats
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// TopN 有界的高频项统计(Space-Saving算法)
+// 最多跟踪 capacity 个键,满时淘汰计数最小的键,新键继承其计数(可能高估,不会低估)
+// 适用于在大量客户端中找出少数高频访问者,内存占用恒定
+// 键按计数组成最小堆,记录与淘汰均为 O(log capacity)
+type TopN struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*topNItem
+	heap     topNHeap
+}
+
+// topNItem 堆中的一项及其下标(用于计数变化后调整位置)
+type topNItem struct {
+	TopNEntry
+	index int
+}
+
+// topNHeap 按计数排列的最小堆(实现 heap.Interface)
+type topNHeap []*topNItem
+
+func (h topNHeap) Len() int           { return len(h) }
+func (h topNHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h topNHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *topNHeap) Push(x any) {
+	item := x.(*topNItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+func (h *topNHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// TopNEntry 高频项
+type TopNEntry struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+	// Errors 错误响应数(仅由 AddError 记录,键被淘汰时清零)
+	Errors int64 `json:"errors,omitempty"`
+	// Overestimate 计数可能的高估上限(淘汰继承而来)
+	Overestimate int64 `json:"overestimate,omitempty"`
+}
+
+// NewTopN 创建高频项统计
+func NewTopN(capacity int) *TopN {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &TopN{
+		capacity: capacity,
+		entries:  make(map[string]*topNItem, capacity),
+		heap:     make(topNHeap, 0, capacity),
+	}
+}
+
+// Add 记录一次键访问
+func (t *TopN) Add(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if item, ok := t.entries[key]; ok {
+		item.Count++
+		heap.Fix(&t.heap, item.index)
+		return
+	}
+
+	if len(t.entries) < t.capacity {
+		item := &topNItem{TopNEntry: TopNEntry{Key: key, Count: 1}}
+		t.entries[key] = item
+		heap.Push(&t.heap, item)
+		return
+	}
+
+	// 淘汰计数最小的键(堆顶),新键原位继承其计数
+	item := t.heap[0]
+	delete(t.entries, item.Key)
+	item.TopNEntry = TopNEntry{
+		Key:          key,
+		Count:        item.Count + 1,
+		Overestimate: item.Count,
+	}
+	t.entries[key] = item
+	heap.Fix(&t.heap, 0)
+}
+
+// AddError 为已跟踪的键记录一次错误(未跟踪的键忽略,不影响排名)
+func (t *TopN) AddError(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if item, ok := t.entries[key]; ok {
+		item.Errors++
+	}
+}
+
+// Top 返回计数最高的 n 项(n<=0 返回全部)
+func (t *TopN) Top(n int) []TopNEntry {
+	t.mu.Lock()
+	result := make([]TopNEntry, 0, len(t.entries))
+	for _, item := range t.heap {
+		result = append(result, item.TopNEntry)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count == result[j].Count {
+			return result[i].Key < result[j].Key
+		}
+		return result[i].Count > result[j].Count
+	})
+
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// Len 返回当前跟踪的键数量
+func (t *TopN) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries)
+}
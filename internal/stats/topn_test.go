@@ -0,0 +1,124 @@
+package stats
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTopN_SurfacesHeavyHitter(t *testing.T) {
+	top := NewTopN(10)
+
+	// 一个扫描器发起大量请求,大量普通客户端各请求少量几次
+	for i := 0; i < 500; i++ {
+		top.Add("203.0.113.66")
+		top.Add(fmt.Sprintf("198.51.100.%d", i%200))
+	}
+
+	if top.Len() > 10 {
+		t.Fatalf("expected bounded size 10, got %d", top.Len())
+	}
+
+	leaders := top.Top(3)
+	if len(leaders) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(leaders))
+	}
+	if leaders[0].Key != "203.0.113.66" {
+		t.Fatalf("expected heavy hitter first, got %+v", leaders)
+	}
+	if leaders[0].Count < 500 {
+		t.Errorf("heavy hitter count should not be underestimated, got %d", leaders[0].Count)
+	}
+	if leaders[1].Count >= leaders[0].Count/2 {
+		t.Errorf("other clients should not dominate: %+v", leaders)
+	}
+}
+
+func TestTopN_ExactBelowCapacity(t *testing.T) {
+	top := NewTopN(5)
+	top.Add("a")
+	top.Add("b")
+	top.Add("a")
+
+	entries := top.Top(0)
+	if len(entries) != 2 || entries[0].Key != "a" || entries[0].Count != 2 || entries[1].Count != 1 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[0].Overestimate != 0 {
+		t.Error("counts below capacity should be exact")
+	}
+}
+
+func TestTopN_EvictsMinimum(t *testing.T) {
+	top := NewTopN(3)
+	for key, n := range map[string]int{"a": 5, "b": 2, "c": 4} {
+		for range n {
+			top.Add(key)
+		}
+	}
+
+	// 满时淘汰计数最小的 b,新键继承其计数
+	top.Add("d")
+	entries := top.Top(0)
+	want := []TopNEntry{{Key: "a", Count: 5}, {Key: "c", Count: 4}, {Key: "d", Count: 3, Overestimate: 2}}
+	if fmt.Sprint(entries) != fmt.Sprint(want) {
+		t.Fatalf("expected %+v, got %+v", want, entries)
+	}
+
+	// 计数总和等于记录次数(Space-Saving 不变式)
+	for i := range 1000 {
+		top.Add(fmt.Sprintf("k%d", i%7))
+	}
+	var total int64
+	for _, entry := range top.Top(0) {
+		total += entry.Count
+	}
+	if total != 11+1+1000 {
+		t.Errorf("expected counts to sum to the number of adds, got %d", total)
+	}
+}
+
+func BenchmarkTopN_Add(b *testing.B) {
+	top := NewTopN(1000)
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("198.51.%d.%d", i/256, i%256)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		top.Add(keys[i%len(keys)])
+	}
+}
+
+func TestCollector_RecordClient(t *testing.T) {
+	c := NewCollector(nil)
+
+	for i := 0; i < 5; i++ {
+		c.RecordClient("10.0.0.1", "/api")
+	}
+	c.RecordClient("10.0.0.2", "/api")
+	c.RecordClient("10.0.0.1", "/other")
+	c.RecordClient("", "/api") // 空IP忽略
+
+	clients := c.TopClients(1)
+	if len(clients) != 1 || clients[0].Key != "10.0.0.1" || clients[0].Count != 6 {
+		t.Fatalf("unexpected top clients: %+v", clients)
+	}
+
+	prefixes := c.TopClientPrefixes(0)
+	if len(prefixes) != 3 || prefixes[0].Key != "10.0.0.1 /api" || prefixes[0].Count != 5 {
+		t.Fatalf("unexpected top client prefixes: %+v", prefixes)
+	}
+}
+
+func TestTopN_AddError(t *testing.T) {
+	top := NewTopN(2)
+	top.Add("a")
+	top.Add("a")
+	top.AddError("a")
+	top.AddError("untracked") // 未跟踪的键不会被加入
+
+	entries := top.Top(0)
+	if len(entries) != 1 || entries[0].Key != "a" || entries[0].Count != 2 || entries[0].Errors != 1 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+
+	"api-proxy/internal/mapping"
+)
+
+// ErrEmptyReplace 替换模式下拒绝应用空列表(避免误删全部映射)
+var ErrEmptyReplace = errors.New("refusing to replace all mappings with an empty list")
+
+// applyAttempts 批量应用期间映射被并发修改(WATCH 失败)时的最大尝试次数
+const applyAttempts = 5
+
+// ApplyMappings 批量应用映射(用于远程导入)
+// 先校验全部条目,任一无效则整体拒绝(返回 mapping.EntryErrors);写入在单个事务中完成
+// replace 为 true 时删除列表中未包含的映射,否则仅新增/更新
+// 读取当前映射与写入之间若有并发修改(WATCH 检测),基于最新数据重新计算后重试
+func (m *MappingManager) ApplyMappings(ctx context.Context, entries []mapping.Entry, replace bool) (mapping.ApplyResult, error) {
+	if replace && len(entries) == 0 {
+		return mapping.ApplyResult{}, mapping.EntryErrors{ErrEmptyReplace}
+	}
+
+	var result mapping.ApplyResult
+	var err error
+	for attempt := 1; attempt <= applyAttempts; attempt++ {
+		err = m.client.Watch(ctx, func(tx *redis.Tx) error {
+			result, err = m.applyMappings(ctx, tx, entries, replace)
+			return err
+		}, KeyMappings, KeyMappingOptions)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+		log.Printf("⚠️  Mappings changed during import (attempt %d/%d), retrying", attempt, applyAttempts)
+	}
+	if err != nil || !result.Changed() {
+		return result, err
+	}
+
+	// 以Redis中的最新数据刷新本地缓存
+	if err := m.refreshCache(ctx); err != nil {
+		return result, err
+	}
+	m.commitChange(ctx, "mappings_applied")
+
+	log.Printf("[AUDIT] Applied mappings: added=%d updated=%d removed=%d (version: %d)",
+		result.Added, result.Updated, result.Removed, m.version.Load())
+
+	return result, nil
+}
+
+// applyMappings 在 WATCH 事务内读取当前映射、校验全部条目并写入(被监视的键在读取后被修改时 EXEC 失败)
+func (m *MappingManager) applyMappings(ctx context.Context, tx *redis.Tx, entries []mapping.Entry, replace bool) (mapping.ApplyResult, error) {
+	var result mapping.ApplyResult
+	current, err := tx.HGetAll(ctx, KeyMappings).Result()
+	if err != nil {
+		return result, err
+	}
+	rawOptions, err := tx.HGetAll(ctx, KeyMappingOptions).Result()
+	if err != nil {
+		return result, err
+	}
+	currentOptions := parseOptions(rawOptions)
+
+	// 脱敏导出的配置原样导入时,占位符按已存储的敏感内容还原
+	entries = slices.Clone(entries)
+	var problems mapping.EntryErrors
+	for i, e := range entries {
+		if _, err := m.checkMapping(e.Prefix, e.Target); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", e.Prefix, err))
+		}
+		opts, err := unmaskOptions(e.Prefix, e.Options, currentOptions[e.Prefix])
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if err := opts.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", e.Prefix, err))
+		} else if err := m.checkOptions(opts); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", e.Prefix, err))
+		}
+		entries[i].Options = opts
+	}
+	if len(problems) > 0 {
+		return result, problems
+	}
+
+	wanted := make(map[string]bool, len(entries))
+	_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, e := range entries {
+			wanted[e.Prefix] = true
+			target, exists := current[e.Prefix]
+			sameOptions := reflect.DeepEqual(currentOptions[e.Prefix], e.Options)
+			switch {
+			case !exists:
+				result.Added++
+			case target != e.Target || !sameOptions:
+				result.Updated++
+			default:
+				result.Unchanged++
+				continue
+			}
+
+			pipe.HSet(ctx, KeyMappings, e.Prefix, e.Target)
+			if e.Options.IsZero() {
+				pipe.HDel(ctx, KeyMappingOptions, e.Prefix)
+			} else {
+				data, err := json.Marshal(e.Options)
+				if err != nil {
+					return err
+				}
+				pipe.HSet(ctx, KeyMappingOptions, e.Prefix, data)
+			}
+		}
+
+		if replace {
+			for prefix := range current {
+				if !wanted[prefix] {
+					pipe.HDel(ctx, KeyMappings, prefix)
+					pipe.HDel(ctx, KeyMappingOptions, prefix)
+					pipe.HDel(ctx, KeyMaintenance, prefix)
+					result.Removed++
+				}
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// ExportMappings 从Redis读取全部映射及扩展配置(用于批量导出),按前缀排序
+// 目标保留写入时的原始值(不展开环境变量),导出结果可直接通过 ApplyMappings 导入
+func (m *MappingManager) ExportMappings(ctx context.Context) ([]mapping.Entry, error) {
+	current, err := m.client.HGetAll(ctx, KeyMappings).Result()
+	if err != nil {
+		return nil, err
+	}
+	options, err := m.loadOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]mapping.Entry, 0, len(current))
+	for prefix, target := range current {
+		entries = append(entries, mapping.Entry{Prefix: prefix, Target: target, Options: options[prefix]})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Prefix < entries[j].Prefix })
+	return entries, nil
+}
+
+// refreshCache 从Redis读取全部映射与扩展配置并替换本地缓存
+func (m *MappingManager) refreshCache(ctx context.Context) error {
+	snap, err := m.loadSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.cache = snap.mappings
+	m.templates = snap.templates
+	m.router.Store(nil)
+	m.options = snap.options
+	m.maintenance = snap.maintenance
+	m.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestMappingManager_ApplyMappings(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	client.HSet(ctx, KeyMappings, "/keep", "http://203.0.113.1", "/old", "http://203.0.113.2")
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+
+	// 合并模式: 新增/更新,保留未列出的映射
+	result, err := mm.ApplyMappings(ctx, []mapping.Entry{
+		{Prefix: "/keep", Target: "http://203.0.113.1"},
+		{Prefix: "/new", Target: "http://203.0.113.3"},
+		{Prefix: "/old", Target: "http://203.0.113.4"},
+	}, false)
+	if err != nil {
+		t.Fatalf("ApplyMappings failed: %v", err)
+	}
+	if result != (mapping.ApplyResult{Added: 1, Updated: 1, Unchanged: 1}) {
+		t.Errorf("unexpected merge result: %+v", result)
+	}
+	if target := mm.cache["/new"]; target != "http://203.0.113.3" {
+		t.Errorf("expected cache refreshed with /new, got %q", target)
+	}
+	if mm.GetVersion() != 2 {
+		t.Errorf("expected version bump, got %d", mm.GetVersion())
+	}
+
+	// 替换模式: 删除未列出的映射
+	result, err = mm.ApplyMappings(ctx, []mapping.Entry{
+		{Prefix: "/new", Target: "http://203.0.113.3"},
+	}, true)
+	if err != nil {
+		t.Fatalf("ApplyMappings failed: %v", err)
+	}
+	if result.Removed != 2 || mm.Count() != 1 {
+		t.Errorf("expected 2 removed and 1 left, got %+v (count=%d)", result, mm.Count())
+	}
+
+	// 无变化时不提升版本
+	version := mm.GetVersion()
+	if result, err = mm.ApplyMappings(ctx, []mapping.Entry{{Prefix: "/new", Target: "http://203.0.113.3"}}, true); err != nil || result.Changed() {
+		t.Fatalf("expected no-op, got %+v err=%v", result, err)
+	}
+	if mm.GetVersion() != version {
+		t.Error("no-op apply should not bump version")
+	}
+
+	// 任一条目无效则整体拒绝
+	_, err = mm.ApplyMappings(ctx, []mapping.Entry{
+		{Prefix: "/ok", Target: "http://203.0.113.5"},
+		{Prefix: "bad", Target: "http://203.0.113.6"},
+	}, false)
+	if !errors.Is(err, ErrInvalidPrefix) {
+		t.Fatalf("expected ErrInvalidPrefix, got %v", err)
+	}
+	if _, err := mm.GetMapping(ctx, "/ok"); err == nil {
+		t.Error("invalid batch must not be partially applied")
+	}
+
+	if _, err := mm.ApplyMappings(ctx, nil, true); !errors.Is(err, ErrEmptyReplace) {
+		t.Errorf("expected ErrEmptyReplace, got %v", err)
+	}
+}
+
+// TestMappingManager_ApplyMappingsConcurrentWrite 测试读取与写入之间的并发修改不会丢失: 事务失败后基于最新数据重试
+func TestMappingManager_ApplyMappingsConcurrentWrite(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	client.HSet(ctx, KeyMappings, "/keep", "http://203.0.113.1")
+
+	// 首次读取当前映射后,另一实例新增映射
+	other := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer other.Close()
+	racer := &afterCommand{name: "hgetall", run: func() {
+		other.HSet(ctx, KeyMappings, "/racer", "http://203.0.113.9")
+	}}
+	client.AddHook(racer)
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	result, err := mm.ApplyMappings(ctx, []mapping.Entry{{Prefix: "/keep", Target: "http://203.0.113.2"}}, true)
+	if err != nil {
+		t.Fatalf("ApplyMappings failed: %v", err)
+	}
+	if result != (mapping.ApplyResult{Updated: 1, Removed: 1}) {
+		t.Errorf("expected the concurrently added mapping to be seen and removed, got %+v", result)
+	}
+	if keys, _ := mr.HKeys(KeyMappings); len(keys) != 1 || keys[0] != "/keep" {
+		t.Errorf("expected only /keep after replace, got %v", keys)
+	}
+}
+
+// afterCommand 指定命令首次执行后运行一次 run(模拟并发写入)
+type afterCommand struct {
+	name string
+	run  func()
+	once sync.Once
+}
+
+func (h *afterCommand) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *afterCommand) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if cmd.Name() == h.name {
+			h.once.Do(h.run)
+		}
+		return err
+	}
+}
+
+func (h *afterCommand) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+// TestMappingManager_ApplyMappingsUnmask 测试导入脱敏导出的配置时按已存储的敏感内容还原
+func TestMappingManager_ApplyMappingsUnmask(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	stored := mapping.Options{TokenRefresh: &mapping.TokenRefresh{URL: "https://203.0.113.9/token", ClientSecret: "stored-secret"}}
+	if _, err := mm.ApplyMappings(ctx, []mapping.Entry{{Prefix: "/token", Target: "http://203.0.113.1", Options: stored}}, false); err != nil {
+		t.Fatalf("ApplyMappings failed: %v", err)
+	}
+
+	masked := []mapping.Entry{{Prefix: "/token", Target: "http://203.0.113.1", Options: stored.Redacted()}}
+	result, err := mm.ApplyMappings(ctx, masked, false)
+	if err != nil {
+		t.Fatalf("ApplyMappings with masked secret failed: %v", err)
+	}
+	if result.Changed() {
+		t.Errorf("re-importing a masked export should be a no-op, got %+v", result)
+	}
+	if secret := mm.GetOptions("/token").TokenRefresh.ClientSecret; secret != "stored-secret" {
+		t.Errorf("expected stored secret to be kept, got %q", secret)
+	}
+
+	// 没有可还原的已存储值时拒绝
+	masked[0].Prefix = "/other"
+	if _, err := mm.ApplyMappings(ctx, masked, false); err == nil {
+		t.Error("expected error when masked secret has no stored counterpart")
+	}
+	if _, err := mm.GetMapping(ctx, "/other"); err == nil {
+		t.Error("rejected entry must not be applied")
+	}
+}
+
+func TestMappingManager_ExportMappings(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	t.Setenv("APIPROXY_TARGET_EXPORT_HOST", "203.0.113.9")
+	ctx := context.Background()
+	mm := &MappingManager{
+		client:         client,
+		cache:          make(map[string]string),
+		stopChan:       make(chan struct{}),
+		interpolateEnv: true,
+	}
+	entries := []mapping.Entry{
+		{Prefix: "/b", Target: "http://${APIPROXY_TARGET_EXPORT_HOST}"},
+		{Prefix: "/a", Target: "http://203.0.113.1", Options: mapping.Options{TimeoutMs: 500}},
+	}
+	if _, err := mm.ApplyMappings(ctx, entries, false); err != nil {
+		t.Fatalf("ApplyMappings failed: %v", err)
+	}
+
+	exported, err := mm.ExportMappings(ctx)
+	if err != nil {
+		t.Fatalf("ExportMappings failed: %v", err)
+	}
+	if len(exported) != 2 || exported[0].Prefix != "/a" || exported[0].Options.TimeoutMs != 500 {
+		t.Fatalf("expected sorted entries with options, got %+v", exported)
+	}
+	if exported[1].Target != "http://${APIPROXY_TARGET_EXPORT_HOST}" {
+		t.Errorf("expected raw target to be exported, got %q", exported[1].Target)
+	}
+
+	// 导出结果重新导入不产生变化
+	result, err := mm.ApplyMappings(ctx, exported, true)
+	if err != nil || result.Changed() {
+		t.Errorf("expected round-trip to be unchanged, got %+v (%v)", result, err)
+	}
+}
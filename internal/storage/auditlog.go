@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"api-proxy/internal/analytics"
+)
+
+// KeyAuditStreamPrefix 请求审计日志的 Redis Stream 键前缀(apiproxy:audit:<前缀>,每个映射一个 Stream)
+const KeyAuditStreamPrefix = "apiproxy:audit:"
+
+// DefaultAuditStreamMaxLen 每个审计 Stream 默认保留的条目数
+const DefaultAuditStreamMaxLen = 10000
+
+// AuditStreamSink 将请求元数据追加到映射对应的 Redis Stream(XADD,按 maxLen 截断旧条目)
+// 实现 analytics.Sink,由 analytics.Exporter 异步批量调用;外部消费者可通过 XRANGE/XREAD 读取
+type AuditStreamSink struct {
+	client *redis.Client
+	maxLen int64
+}
+
+// NewAuditStreamSink 创建审计日志目的地,maxLen <= 0 时使用默认值
+func NewAuditStreamSink(client *redis.Client, maxLen int64) *AuditStreamSink {
+	if maxLen <= 0 {
+		maxLen = DefaultAuditStreamMaxLen
+	}
+	return &AuditStreamSink{client: client, maxLen: maxLen}
+}
+
+// Send 以一个 pipeline 追加一批事件
+func (s *AuditStreamSink) Send(ctx context.Context, events []analytics.Event) error {
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, event := range events {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: AuditStreamKey(event.Endpoint),
+				MaxLen: s.maxLen,
+				Values: []any{
+					"timestamp", event.Timestamp.UTC().Format(time.RFC3339Nano),
+					"endpoint", event.Endpoint,
+					"method", event.Method,
+					"status", strconv.Itoa(event.Status),
+					"latency_ms", strconv.FormatInt(event.LatencyMs, 10),
+					"request_bytes", strconv.FormatInt(event.RequestBytes, 10),
+					"response_bytes", strconv.FormatInt(event.ResponseBytes, 10),
+				},
+			})
+		}
+		return nil
+	})
+	return err
+}
+
+// AuditStreamKey 返回映射的审计 Stream 键
+func AuditStreamKey(prefix string) string {
+	return KeyAuditStreamPrefix + prefix
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"api-proxy/internal/analytics"
+)
+
+func TestAuditStreamSink_AppendsAndCaps(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	sink := NewAuditStreamSink(client, 3)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var events []analytics.Event
+	for i := range 5 {
+		events = append(events, analytics.Event{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Endpoint:  "/audit",
+			Method:    "POST",
+			Status:    200 + i,
+			LatencyMs: int64(i),
+		})
+	}
+	events = append(events, analytics.Event{Timestamp: start, Endpoint: "/other", Method: "GET", Status: 404})
+	if err := sink.Send(ctx, events); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	entries, err := client.XRange(ctx, AuditStreamKey("/audit"), "-", "+").Result()
+	if err != nil {
+		t.Fatalf("XRange failed: %v", err)
+	}
+	// 只保留最近的3条
+	if len(entries) != 3 {
+		t.Fatalf("expected stream capped at 3 entries, got %d", len(entries))
+	}
+	first := entries[0].Values
+	if first["status"] != "202" || first["method"] != "POST" || first["endpoint"] != "/audit" {
+		t.Errorf("unexpected oldest retained entry: %v", first)
+	}
+	if first["timestamp"] != "2026-01-02T03:04:07Z" || first["latency_ms"] != "2" {
+		t.Errorf("unexpected entry metadata: %v", first)
+	}
+
+	if n, _ := client.XLen(ctx, AuditStreamKey("/other")).Result(); n != 1 {
+		t.Errorf("expected a separate stream per mapping, got %d entries", n)
+	}
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyIdempotencyPrefix 幂等响应键前缀
+const KeyIdempotencyPrefix = "apiproxy:idempotency:"
+
+// IdempotencyStore 基于Redis的幂等响应存储(多实例共享)
+// 键以空值预占(SETNX),首次请求完成后覆盖为响应数据,均带TTL自动过期
+type IdempotencyStore struct {
+	client *redis.Client
+}
+
+// NewIdempotencyStore 创建幂等响应存储
+func NewIdempotencyStore(client *redis.Client) *IdempotencyStore {
+	return &IdempotencyStore{client: client}
+}
+
+// Reserve 预占键,返回false表示键已存在
+func (s *IdempotencyStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, KeyIdempotencyPrefix+key, "", ttl).Result()
+}
+
+// Load 读取键值,空值表示首次请求仍在处理中
+func (s *IdempotencyStore) Load(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := s.client.Get(ctx, KeyIdempotencyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Save 保存首次响应
+func (s *IdempotencyStore) Save(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, KeyIdempotencyPrefix+key, data, ttl).Err()
+}
+
+// Release 删除键
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, KeyIdempotencyPrefix+key).Err()
+}
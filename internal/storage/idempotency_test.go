@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	store := NewIdempotencyStore(client)
+
+	ok, err := store.Reserve(ctx, "/api:k1", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("first reserve should succeed, got ok=%v err=%v", ok, err)
+	}
+	if ok, _ := store.Reserve(ctx, "/api:k1", time.Minute); ok {
+		t.Fatal("second reserve should fail while key exists")
+	}
+
+	data, found, err := store.Load(ctx, "/api:k1")
+	if err != nil || !found || len(data) != 0 {
+		t.Fatalf("expected pending (empty) value, got %q found=%v err=%v", data, found, err)
+	}
+
+	if err := store.Save(ctx, "/api:k1", []byte(`{"status":201}`), time.Minute); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	data, found, _ = store.Load(ctx, "/api:k1")
+	if !found || string(data) != `{"status":201}` {
+		t.Fatalf("expected saved response, got %q", data)
+	}
+
+	// TTL 过期后键自动释放
+	mr.FastForward(2 * time.Minute)
+	if _, found, _ := store.Load(ctx, "/api:k1"); found {
+		t.Fatal("key should expire after ttl")
+	}
+
+	store.Reserve(ctx, "/api:k2", time.Minute)
+	if err := store.Release(ctx, "/api:k2"); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if ok, _ := store.Reserve(ctx, "/api:k2", time.Minute); !ok {
+		t.Fatal("released key should be reservable again")
+	}
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyLeaderPrefix 单例后台任务的领导者锁(apiproxy:leader:<名称>,值为持有者ID)
+const KeyLeaderPrefix = "apiproxy:leader:"
+
+// 仅持有者可以续期或释放锁
+var (
+	renewLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseLeaderScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// LeaderElection 基于Redis锁(带TTL)的领导者选举: 多实例中只有领导者执行单例后台任务(如周期保存统计),
+// 所有实例照常处理请求。领导者每 ttl/3 续期,停止或宕机(锁过期)后由其他实例接任
+type LeaderElection struct {
+	client *redis.Client
+	key    string
+	id     string
+	ttl    time.Duration
+
+	leader   atomic.Bool
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewLeaderElection 创建名为 name 的领导者选举(需调用 Start 参与选举)
+func NewLeaderElection(client *redis.Client, name string, ttl time.Duration) *LeaderElection {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return &LeaderElection{
+		client:   client,
+		key:      KeyLeaderPrefix + name,
+		id:       hex.EncodeToString(id),
+		ttl:      ttl,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// IsLeader 判断本实例当前是否为领导者
+func (e *LeaderElection) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Start 立即尝试成为领导者,之后周期续期或竞选
+func (e *LeaderElection) Start() {
+	e.tick(context.Background())
+	e.wg.Go(func() {
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.tick(context.Background())
+			case <-e.stopChan:
+				return
+			}
+		}
+	})
+}
+
+// Stop 退出选举,领导者主动释放锁以便其他实例立即接任
+func (e *LeaderElection) Stop() {
+	e.stopOnce.Do(func() { close(e.stopChan) })
+	e.wg.Wait()
+	if e.leader.Swap(false) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := releaseLeaderScript.Run(ctx, e.client, []string{e.key}, e.id).Err(); err != nil {
+			log.Printf("⚠️  释放领导者锁失败: %v", err)
+		}
+	}
+}
+
+// tick 领导者续期,其他实例尝试获取锁;Redis 故障时放弃领导者身份,避免多个领导者同时执行
+func (e *LeaderElection) tick(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+
+	var leader bool
+	var err error
+	if e.leader.Load() {
+		var renewed int64
+		renewed, err = renewLeaderScript.Run(ctx, e.client, []string{e.key}, e.id, e.ttl.Milliseconds()).Int64()
+		leader = renewed == 1
+	} else {
+		leader, err = e.client.SetNX(ctx, e.key, e.id, e.ttl).Result()
+	}
+	if err != nil {
+		leader = false
+		log.Printf("⚠️  领导者选举失败 %s: %v", e.key, err)
+	}
+	if e.leader.Swap(leader) != leader {
+		if leader {
+			log.Printf("👑 成为领导者: %s", e.key)
+		} else {
+			log.Printf("👑 失去领导者身份: %s", e.key)
+		}
+	}
+}
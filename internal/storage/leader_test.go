@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLeaderElection_SingleLeaderAndTransfer(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	a := NewLeaderElection(client, "jobs", 3*time.Second)
+	b := NewLeaderElection(client, "jobs", 3*time.Second)
+
+	a.tick(ctx)
+	b.tick(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected exactly one leader, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+
+	// 领导者续期后保持领导者身份,其他实例无法获取锁
+	mr.FastForward(2 * time.Second)
+	a.tick(ctx)
+	mr.FastForward(2 * time.Second)
+	b.tick(ctx)
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("renewed leader should keep the lock, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+
+	// 领导者停止时释放锁,其他实例立即接任
+	a.Stop()
+	if a.IsLeader() {
+		t.Fatal("stopped instance should no longer be leader")
+	}
+	b.tick(ctx)
+	if !b.IsLeader() {
+		t.Fatal("expected leadership to transfer after the leader stops")
+	}
+
+	// 领导者宕机(未续期)时锁过期后接任,原领导者续期失败后放弃身份
+	c := NewLeaderElection(client, "jobs", 3*time.Second)
+	mr.FastForward(4 * time.Second)
+	c.tick(ctx)
+	if !c.IsLeader() {
+		t.Fatal("expected leadership to transfer after the lock expires")
+	}
+	b.tick(ctx)
+	if b.IsLeader() {
+		t.Fatal("previous leader should step down when its lock was taken over")
+	}
+}
+
+func TestLeaderElection_RedisFailureStepsDown(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer client.Close()
+
+	e := NewLeaderElection(client, "jobs", 3*time.Second)
+	e.tick(context.Background())
+	if !e.IsLeader() {
+		t.Fatal("expected to become leader")
+	}
+	mr.Close()
+	e.tick(context.Background())
+	if e.IsLeader() {
+		t.Fatal("leader should step down when Redis is unavailable")
+	}
+}
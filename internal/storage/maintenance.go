@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"api-proxy/internal/mapping"
+)
+
+// KeyMaintenance 维护模式配置(前缀或 "*" -> JSON),变更通过版本号同步到所有实例
+const KeyMaintenance = "apiproxy:maintenance"
+
+// SetMaintenance 开启维护模式; prefix 为空表示全局
+func (m *MappingManager) SetMaintenance(ctx context.Context, prefix string, maint mapping.Maintenance) error {
+	if err := maint.Validate(); err != nil {
+		return err
+	}
+	key, err := m.maintenanceKey(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(maint)
+	if err != nil {
+		return err
+	}
+	if err := m.exec(ctx, func() error {
+		return m.client.HSet(ctx, KeyMaintenance, key, data).Err()
+	}); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if m.maintenance == nil {
+		m.maintenance = make(map[string]mapping.Maintenance)
+	}
+	m.maintenance[key] = maint
+	m.mu.Unlock()
+
+	m.commitChange(ctx, "maintenance_enabled")
+	log.Printf("[AUDIT] Maintenance enabled: %s (version: %d)", key, m.version.Load())
+	return nil
+}
+
+// ClearMaintenance 关闭维护模式; prefix 为空表示全局
+func (m *MappingManager) ClearMaintenance(ctx context.Context, prefix string) error {
+	key := mapping.MaintenanceGlobal
+	if prefix != "" {
+		key = prefix
+	}
+	if err := m.exec(ctx, func() error {
+		return m.client.HDel(ctx, KeyMaintenance, key).Err()
+	}); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	delete(m.maintenance, key)
+	m.mu.Unlock()
+
+	m.commitChange(ctx, "maintenance_disabled")
+	log.Printf("[AUDIT] Maintenance disabled: %s (version: %d)", key, m.version.Load())
+	return nil
+}
+
+// GetMaintenance 返回前缀当前的维护配置(前缀级优先于全局)
+func (m *MappingManager) GetMaintenance(prefix string) (mapping.Maintenance, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if maint, ok := m.maintenance[prefix]; ok {
+		return maint, true
+	}
+	maint, ok := m.maintenance[mapping.MaintenanceGlobal]
+	return maint, ok
+}
+
+// GetAllMaintenance 返回所有开启中的维护配置("*" 表示全局)
+func (m *MappingManager) GetAllMaintenance() map[string]mapping.Maintenance {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string]mapping.Maintenance, len(m.maintenance))
+	for k, v := range m.maintenance {
+		result[k] = v
+	}
+	return result
+}
+
+// maintenanceKey 返回存储键;前缀级维护要求映射存在
+func (m *MappingManager) maintenanceKey(ctx context.Context, prefix string) (string, error) {
+	if prefix == "" {
+		return mapping.MaintenanceGlobal, nil
+	}
+	exists, err := m.mappingExists(ctx, prefix)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", fmt.Errorf("mapping not found for prefix: %s", prefix)
+	}
+	return prefix, nil
+}
+
+// parseMaintenance 解析维护配置哈希(解析失败的条目记录日志后跳过)
+func parseMaintenance(raw map[string]string) map[string]mapping.Maintenance {
+	result := make(map[string]mapping.Maintenance, len(raw))
+	for key, data := range raw {
+		var maint mapping.Maintenance
+		if err := json.Unmarshal([]byte(data), &maint); err != nil {
+			log.Printf("⚠️  Invalid maintenance config for %s: %v", key, err)
+			continue
+		}
+		result[key] = maint
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"api-proxy/internal/mapping"
+)
+
+func TestMappingManager_Maintenance(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	client.HSet(ctx, KeyMappings, "/api", "http://203.0.113.1", "/other", "http://203.0.113.2")
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+	if _, ok := mm.GetMaintenance("/api"); ok {
+		t.Fatal("maintenance should be off by default")
+	}
+
+	// 前缀级维护要求映射存在
+	if err := mm.SetMaintenance(ctx, "/missing", mapping.Maintenance{}); err == nil {
+		t.Error("expected error for unknown prefix")
+	}
+	if err := mm.SetMaintenance(ctx, "/api", mapping.Maintenance{RetryAfter: -1}); err == nil {
+		t.Error("expected validation error")
+	}
+
+	want := mapping.Maintenance{Message: "down", RetryAfter: 30}
+	if err := mm.SetMaintenance(ctx, "/api", want); err != nil {
+		t.Fatalf("SetMaintenance failed: %v", err)
+	}
+	if got, ok := mm.GetMaintenance("/api"); !ok || got != want {
+		t.Errorf("expected %+v, got %+v (%v)", want, got, ok)
+	}
+	if _, ok := mm.GetMaintenance("/other"); ok {
+		t.Error("other prefix should not be in maintenance")
+	}
+
+	// 全局维护覆盖所有前缀,前缀级配置优先
+	if err := mm.SetMaintenance(ctx, "", mapping.Maintenance{Message: "global"}); err != nil {
+		t.Fatalf("SetMaintenance global failed: %v", err)
+	}
+	if got, _ := mm.GetMaintenance("/other"); got.Message != "global" {
+		t.Errorf("expected global maintenance, got %+v", got)
+	}
+	if got, _ := mm.GetMaintenance("/api"); got.Message != "down" {
+		t.Errorf("expected prefix maintenance to win, got %+v", got)
+	}
+
+	// 其他实例通过重新加载获得同样的配置
+	other := &MappingManager{client: client, cache: make(map[string]string), stopChan: make(chan struct{})}
+	if err := other.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+	if len(other.GetAllMaintenance()) != 2 {
+		t.Errorf("expected 2 maintenance entries after reload, got %v", other.GetAllMaintenance())
+	}
+
+	// 关闭后恢复
+	if err := mm.ClearMaintenance(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := mm.ClearMaintenance(ctx, "/api"); err != nil {
+		t.Fatal(err)
+	}
+	if all := mm.GetAllMaintenance(); len(all) != 0 {
+		t.Errorf("expected no maintenance, got %v", all)
+	}
+	if n, _ := client.HLen(ctx, KeyMaintenance).Result(); n != 0 {
+		t.Errorf("expected redis maintenance hash empty, got %d", n)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyQuotaPrefix 每日配额计数键前缀(apiproxy:quota:<日期>:<前缀>:<Key摘要>)
+const KeyQuotaPrefix = "apiproxy:quota:"
+
+// quotaKeyTTL 计数键保留时间(覆盖跨时区的当日窗口后自动过期)
+const quotaKeyTTL = 48 * time.Hour
+
+// QuotaStore 基于Redis的每日请求配额计数(多实例共享)
+type QuotaStore struct {
+	client *redis.Client
+}
+
+// NewQuotaStore 创建配额计数存储
+func NewQuotaStore(client *redis.Client) *QuotaStore {
+	return &QuotaStore{client: client}
+}
+
+// Consume 计入一次请求,返回该Key在 day 所在日(UTC)的已用次数
+func (s *QuotaStore) Consume(ctx context.Context, prefix, key string, day time.Time) (int64, error) {
+	redisKey := quotaKey(prefix, key, day)
+
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, redisKey)
+		pipe.Expire(ctx, redisKey, quotaKeyTTL)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// quotaKey 计算计数键,按UTC日期分桶实现每日重置
+func quotaKey(prefix, key string, day time.Time) string {
+	return KeyQuotaPrefix + day.UTC().Format("20060102") + ":" + prefix + ":" + key
+}
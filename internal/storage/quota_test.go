@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQuotaStore_Consume(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	store := NewQuotaStore(client)
+	day := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+
+	for want := int64(1); want <= 3; want++ {
+		used, err := store.Consume(ctx, "/ai", "sha256:abc", day)
+		if err != nil {
+			t.Fatalf("Consume failed: %v", err)
+		}
+		if used != want {
+			t.Fatalf("expected used=%d, got %d", want, used)
+		}
+	}
+
+	// 不同Key、不同前缀独立计数
+	if used, _ := store.Consume(ctx, "/ai", "sha256:def", day); used != 1 {
+		t.Errorf("expected independent count per key, got %d", used)
+	}
+	if used, _ := store.Consume(ctx, "/other", "sha256:abc", day); used != 1 {
+		t.Errorf("expected independent count per prefix, got %d", used)
+	}
+
+	// 次日(UTC)重新计数
+	if used, _ := store.Consume(ctx, "/ai", "sha256:abc", day.Add(2*time.Minute)); used != 1 {
+		t.Errorf("expected quota reset on next day, got %d", used)
+	}
+
+	// 计数键带过期时间
+	if ttl := mr.TTL(quotaKey("/ai", "sha256:abc", day)); ttl != quotaKeyTTL {
+		t.Errorf("expected ttl %v, got %v", quotaKeyTTL, ttl)
+	}
+}
@@ -3,12 +3,15 @@ package storage
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net"
 	"net/url"
 	"os"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,6 +20,10 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"api-proxy/internal/config"
+	"api-proxy/internal/mapping"
+	"api-proxy/internal/schedule"
 )
 
 const (
@@ -25,6 +32,7 @@ const (
 	KeyVersion         = "apiproxy:version"
 	KeyMappingsVersion = "apiproxy:mappings:version" // 映射版本号
 	KeyMappingsChannel = "apiproxy:mappings:updates" // Pub/Sub通道
+	KeyMappingOptions  = "apiproxy:mappings:options" // 映射扩展配置(JSON)
 
 	// 缓存配置
 	CacheTTL     = 30 * time.Second
@@ -36,20 +44,46 @@ type MappingManager struct {
 	client *redis.Client
 
 	// 使用 map + RWMutex 代替 sync.Map(读多写少场景更高效)
-	mu    sync.RWMutex
-	cache map[string]string
+	mu      sync.RWMutex
+	cache   map[string]string
+	options map[string]mapping.Options // 映射扩展配置(与cache同锁保护)
+
+	maintenance map[string]mapping.Maintenance // 维护模式配置(与cache同锁保护)
 
 	// 使用原子操作保护的字段
 	version     atomic.Int64
 	lastReload  atomic.Int64 // Unix时间戳
 	initialized atomic.Bool
 
+	// 多实例缓存同步计数(见 SyncStats)
+	pubsubMessages atomic.Int64
+	reloads        atomic.Int64
+	reloadFailures atomic.Int64
+
 	// Goroutine控制
 	stopChan chan struct{}
 	wg       sync.WaitGroup
 
+	// 后台重载调度(间隔+随机抖动,定时与Pub/Sub触发的重载不重叠)
+	reloadInterval time.Duration
+	reloadJitter   time.Duration
+	reloadGuard    schedule.Guard
+
 	// Pub/Sub订阅
 	pubsub *redis.PubSub
+
+	// 前缀路由树(缓存变更时在写锁内置空,首次匹配时重建)
+	router atomic.Pointer[mapping.Router]
+
+	// 代理自身地址(PROXY_SELF_ADDRESSES),拒绝指向自身的回环映射
+	self *mapping.SelfAddresses
+
+	// 映射写操作的Redis瞬时故障重试(主从切换期间管理API仍可用)
+	retry retryPolicy
+
+	// 加载时代入目标中的 ${NAME} 环境变量(TARGET_ENV_INTERPOLATION),Redis 中保存原始模板
+	interpolateEnv bool
+	templates      map[string]string // 代入了环境变量的映射的原始目标(prefix -> 模板,读写锁保护)
 }
 
 // parseRedisURL 解析Redis URL格式
@@ -113,32 +147,48 @@ func parseRedisURL(redisURL string) (*redis.Options, error) {
 	return opts, nil
 }
 
+// NewRedisClient 按URL创建Redis客户端并测试连接
+func NewRedisClient(ctx context.Context, redisURL string) (*redis.Client, error) {
+	opts, err := parseRedisURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("Redis connection failed: %w", err)
+	}
+	return client, nil
+}
+
 // NewMappingManager 创建并初始化映射管理器
 func NewMappingManager(ctx context.Context) (*MappingManager, error) {
 	// 读取Redis URL
-	redisURL := os.Getenv("API_PROXY_REDIS_URL")
+	redisURL := config.String("API_PROXY_REDIS_URL", "")
 	if redisURL == "" {
 		return nil, fmt.Errorf("API_PROXY_REDIS_URL environment variable is required\n" +
 			"Example: API_PROXY_REDIS_URL=redis://:password@localhost:6379/0")
 	}
 
-	// 解析Redis配置
-	opts, err := parseRedisURL(redisURL)
+	client, err := NewRedisClient(ctx, redisURL)
 	if err != nil {
 		return nil, err
 	}
 
-	client := redis.NewClient(opts)
-
-	// 测试连接
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("Redis connection failed: %w", err)
-	}
-
 	manager := &MappingManager{
 		client:   client,
 		cache:    make(map[string]string),
 		stopChan: make(chan struct{}),
+
+		reloadInterval: config.Duration("MAPPING_RELOAD_INTERVAL", ReloadPeriod),
+		reloadJitter:   config.Duration("MAPPING_RELOAD_JITTER", 0),
+		self:           mapping.NewSelfAddresses(config.List("PROXY_SELF_ADDRESSES")),
+		retry: retryPolicy{
+			attempts: config.Int("REDIS_RETRY_ATTEMPTS", 3),
+			backoff:  config.Duration("REDIS_RETRY_BACKOFF", 100*time.Millisecond),
+		},
+		interpolateEnv: config.Bool("TARGET_ENV_INTERPOLATION", false),
 	}
 	manager.lastReload.Store(time.Now().Unix())
 
@@ -148,6 +198,7 @@ func NewMappingManager(ctx context.Context) (*MappingManager, error) {
 	}
 
 	manager.initialized.Store(true)
+	manager.warnSelfTargets()
 
 	// 订阅Redis Pub/Sub通道
 	manager.pubsub = client.Subscribe(ctx, KeyMappingsChannel)
@@ -163,6 +214,7 @@ func NewMappingManager(ctx context.Context) (*MappingManager, error) {
 }
 
 // reloadMappings 从Redis重新加载所有映射到缓存
+// 映射和扩展配置的任何变更都会递增版本号,版本号未变时跳过加载
 func (m *MappingManager) reloadMappings(ctx context.Context) error {
 	// 先检查Redis版本号（不需要锁，快速检查）
 	remoteVersion, err := m.client.Get(ctx, KeyMappingsVersion).Int64()
@@ -177,72 +229,121 @@ func (m *MappingManager) reloadMappings(ctx context.Context) error {
 		return nil
 	}
 
-	// 版本号变了，获取锁并重载
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// 从Redis获取所有映射
-	mappings, err := m.client.HGetAll(ctx, KeyMappings).Result()
+	// 版本号变了，读取映射、扩展配置和版本号的一致快照
+	snap, err := m.loadSnapshot(ctx)
 	if err != nil {
 		return err
 	}
 
 	// 如果Redis为空,记录警告但允许启动(可通过管理API动态添加)
-	if len(mappings) == 0 {
+	if len(snap.mappings) == 0 {
 		log.Println("⚠️  No mappings found in Redis. Use /admin API to add mappings.")
 		log.Println("💡 Example: POST /admin/mappings with {\"prefix\":\"/api\",\"target\":\"https://api.example.com\"}")
 		m.lastReload.Store(time.Now().Unix())
 		return nil
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	// 双重检查（避免竞态条件）
-	if remoteVersion > 0 && remoteVersion == m.version.Load() {
+	if snap.version > 0 && snap.version == m.version.Load() {
 		return nil
 	}
 
-	// 创建新缓存（避免在持锁期间逐个删除）
-	newCache := make(map[string]string, len(mappings))
-	for prefix, target := range mappings {
-		newCache[prefix] = target
-	}
-
 	// 一次性替换缓存
-	m.cache = newCache
+	m.cache = snap.mappings
+	m.templates = snap.templates
+	m.router.Store(nil)
+	m.options = snap.options
+	m.maintenance = snap.maintenance
 
 	// 更新版本号
-	if remoteVersion > 0 {
-		m.version.Store(remoteVersion)
+	if snap.version > 0 {
+		m.version.Store(snap.version)
 	} else {
 		// 如果Redis中没有版本号，使用本地版本号并写入Redis
 		m.version.Add(1)
 		m.client.Set(ctx, KeyMappingsVersion, m.version.Load(), 0)
 	}
 	m.lastReload.Store(time.Now().Unix())
+	m.reloads.Add(1)
 
-	log.Printf("📦 Reloaded %d mappings from Redis (version: %d)", len(mappings), m.version.Load())
+	log.Printf("📦 Reloaded %d mappings from Redis (version: %d)", len(snap.mappings), m.version.Load())
 
 	return nil
 }
 
+// snapshot 映射、扩展配置与版本号的一致快照
+type snapshot struct {
+	version     int64
+	mappings    map[string]string
+	templates   map[string]string // 代入了环境变量的映射的原始目标
+	options     map[string]mapping.Options
+	maintenance map[string]mapping.Maintenance
+}
+
+// loadSnapshot 在单个事务中读取映射、扩展配置和版本号
+// 避免在两次读取之间发生写入,导致目标与扩展配置(超时、请求头等)来自不同版本
+func (m *MappingManager) loadSnapshot(ctx context.Context) (snapshot, error) {
+	var (
+		versionCmd  *redis.StringCmd
+		mappingsCmd *redis.MapStringStringCmd
+		optionsCmd  *redis.MapStringStringCmd
+		maintCmd    *redis.MapStringStringCmd
+	)
+	_, err := m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		versionCmd = pipe.Get(ctx, KeyMappingsVersion)
+		mappingsCmd = pipe.HGetAll(ctx, KeyMappings)
+		optionsCmd = pipe.HGetAll(ctx, KeyMappingOptions)
+		maintCmd = pipe.HGetAll(ctx, KeyMaintenance)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return snapshot{}, err
+	}
+
+	version, err := versionCmd.Int64()
+	if err != nil && err != redis.Nil {
+		return snapshot{}, err
+	}
+	mappings, templates, failed := m.resolveTargets(mappingsCmd.Val())
+	for prefix, err := range failed {
+		log.Printf("⚠️  跳过映射 %s: %v", prefix, err)
+	}
+	return snapshot{
+		version:     version,
+		mappings:    mappings,
+		templates:   templates,
+		options:     parseOptions(optionsCmd.Val()),
+		maintenance: parseMaintenance(maintCmd.Val()),
+	}, nil
+}
+
 // backgroundReloader 后台定期重载映射
 func (m *MappingManager) backgroundReloader() {
 	defer m.wg.Done()
 
-	ticker := time.NewTicker(ReloadPeriod)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-m.stopChan:
-			log.Println("🛑 Background reloader stopped")
-			return
-		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			if err := m.reloadMappings(ctx); err != nil {
-				log.Printf("⚠️  Background reload failed: %v", err)
-			}
-			cancel()
+	interval := m.reloadInterval
+	if interval <= 0 {
+		interval = ReloadPeriod
+	}
+	schedule.Every(m.stopChan, interval, m.reloadJitter, func() {
+		// 上一轮(或Pub/Sub触发的重载)仍在执行时跳过本轮;本轮执行期间到达的Pub/Sub通知在结束后补跑
+		if !m.reloadGuard.TryRun(m.backgroundReload) {
+			log.Println("⏭️  Background reload skipped: previous reload still running")
 		}
+	})
+	log.Println("🛑 Background reloader stopped")
+}
+
+// backgroundReload 执行一次后台重载
+func (m *MappingManager) backgroundReload() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.reloadMappings(ctx); err != nil {
+		m.reloadFailures.Add(1)
+		log.Printf("⚠️  Background reload failed: %v", err)
 	}
 }
 
@@ -262,20 +363,27 @@ func (m *MappingManager) pubsubListener() {
 				continue
 			}
 
+			m.pubsubMessages.Add(1)
 			log.Printf("📨 Received Pub/Sub message: %s", msg.Payload)
 
-			// 触发重载
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			if err := m.reloadMappings(ctx); err != nil {
-				log.Printf("⚠️  Failed to reload after Pub/Sub notification: %v", err)
-			} else {
-				log.Printf("✅ Cache synchronized via Pub/Sub")
-			}
-			cancel()
+			// 触发重载(定时重载执行中时合并为其结束后的一次补跑,不丢失变更)
+			m.reloadGuard.Coalesce(m.notifiedReload)
 		}
 	}
 }
 
+// notifiedReload 执行一次由Pub/Sub通知触发的重载
+func (m *MappingManager) notifiedReload() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.reloadMappings(ctx); err != nil {
+		m.reloadFailures.Add(1)
+		log.Printf("⚠️  Failed to reload after Pub/Sub notification: %v", err)
+	} else {
+		log.Printf("✅ Cache synchronized via Pub/Sub")
+	}
+}
+
 // GetMapping 获取指定前缀的目标URL
 func (m *MappingManager) GetMapping(ctx context.Context, prefix string) (string, error) {
 	// 从缓存读取（读锁保护）
@@ -295,13 +403,46 @@ func (m *MappingManager) GetMapping(ctx context.Context, prefix string) (string,
 	if err != nil {
 		return "", err
 	}
+	resolved, err := m.resolveTarget(prefix, target)
+	if err != nil {
+		return "", fmt.Errorf("mapping %s: %w", prefix, err)
+	}
 
 	// 更新缓存（写锁保护）
 	m.mu.Lock()
-	m.cache[prefix] = target
+	m.setCached(prefix, target, resolved)
 	m.mu.Unlock()
 
-	return target, nil
+	return resolved, nil
+}
+
+// setCached 更新单个映射的缓存(调用方持有写锁),目标含环境变量时记录原始模板
+func (m *MappingManager) setCached(prefix, target, resolved string) {
+	m.cache[prefix] = resolved
+	if target != resolved {
+		if m.templates == nil {
+			m.templates = make(map[string]string)
+		}
+		m.templates[prefix] = target
+	} else {
+		delete(m.templates, prefix)
+	}
+	m.router.Store(nil)
+}
+
+// GetAllTemplates 获取所有映射的原始目标(环境变量未代入),用于管理接口输出,避免暴露变量值
+func (m *MappingManager) GetAllTemplates() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string]string, len(m.cache))
+	for prefix, target := range m.cache {
+		if template, ok := m.templates[prefix]; ok {
+			target = template
+		}
+		result[prefix] = target
+	}
+	return result
 }
 
 // GetAllMappings 获取所有映射
@@ -318,52 +459,67 @@ func (m *MappingManager) GetAllMappings() map[string]string {
 	return result
 }
 
-// ForceReload 强制从Redis重新加载映射,忽略版本号检查
+// ForceReload 强制从Redis重新加载映射及扩展配置,忽略版本号检查
 // 用于多实例部署时手动触发缓存同步
 func (m *MappingManager) ForceReload(ctx context.Context) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	// 从Redis获取所有映射
-	mappings, err := m.client.HGetAll(ctx, KeyMappings).Result()
+	snap, err := m.loadSnapshot(ctx)
 	if err != nil {
 		return err
 	}
 
-	// 创建新缓存
-	newCache := make(map[string]string, len(mappings))
-	for prefix, target := range mappings {
-		newCache[prefix] = target
-	}
-
 	// 替换缓存
-	m.cache = newCache
+	m.mu.Lock()
+	m.cache = snap.mappings
+	m.templates = snap.templates
+	m.router.Store(nil)
+	m.options = snap.options
+	m.maintenance = snap.maintenance
+	m.mu.Unlock()
 
 	// 同步Redis版本号
-	remoteVersion, err := m.client.Get(ctx, KeyMappingsVersion).Int64()
-	if err != nil && err != redis.Nil {
-		log.Printf("⚠️  Failed to get remote version: %v", err)
-	}
-	if remoteVersion > 0 {
-		m.version.Store(remoteVersion)
+	if snap.version > 0 {
+		m.version.Store(snap.version)
 	}
 
 	m.lastReload.Store(time.Now().Unix())
+	m.reloads.Add(1)
 
-	log.Printf("🔄 Force reloaded %d mappings from Redis (version: %d)", len(mappings), m.version.Load())
+	log.Printf("🔄 Force reloaded %d mappings from Redis (version: %d)", len(snap.mappings), m.version.Load())
 
 	return nil
 }
 
+// BumpVersion 递增版本号并发布Pub/Sub通知(不修改任何映射),使所有实例(含本实例)重新加载
+// 用于排查多实例缓存不一致;本地版本号不同步更新,由重载流程追上
+func (m *MappingManager) BumpVersion(ctx context.Context) (int64, error) {
+	// INCR 非幂等(超时等故障时可能已执行),不自动重试
+	version, err := m.client.Incr(ctx, KeyMappingsVersion).Result()
+	if err != nil {
+		return 0, err
+	}
+	if err := m.client.Publish(ctx, KeyMappingsChannel, "version_bumped").Err(); err != nil {
+		return version, fmt.Errorf("version bumped to %d but notification failed: %w", version, err)
+	}
+
+	log.Printf("[AUDIT] Bumped mappings version to %d", version)
+
+	return version, nil
+}
+
 // AddMapping 添加新的API映射
-func (m *MappingManager) AddMapping(ctx context.Context, prefix, target string) error {
-	// 验证输入
-	if err := validateMapping(prefix, target); err != nil {
+// opts 非空时先完整校验扩展配置,再与目标在同一事务中写入
+func (m *MappingManager) AddMapping(ctx context.Context, prefix, target string, opts *mapping.Options) error {
+	// 验证输入(校验代入环境变量后的目标)
+	resolved, err := m.checkMapping(prefix, target)
+	if err != nil {
+		return err
+	}
+	if opts, err = m.prepareOptions(prefix, opts); err != nil {
 		return err
 	}
 
 	// 检查是否已存在
-	exists, err := m.client.HExists(ctx, KeyMappings, prefix).Result()
+	exists, err := m.mappingExists(ctx, prefix)
 	if err != nil {
 		return err
 	}
@@ -372,31 +528,17 @@ func (m *MappingManager) AddMapping(ctx context.Context, prefix, target string)
 	}
 
 	// 添加到Redis
-	if err := m.client.HSet(ctx, KeyMappings, prefix, target).Err(); err != nil {
+	if _, err := m.writeMapping(ctx, prefix, target, opts); err != nil {
 		return err
 	}
 
-	// 增加Redis版本号
-	newVersion, err := m.client.Incr(ctx, KeyMappingsVersion).Result()
-	if err != nil {
-		log.Printf("⚠️  Failed to increment version: %v", err)
-	}
-
-	// 更新缓存和本地版本号(写锁保护)
+	// 更新缓存(写锁保护)
 	m.mu.Lock()
-	m.cache[prefix] = target
+	m.setCached(prefix, target, resolved)
+	m.setCachedOptions(prefix, opts)
 	m.mu.Unlock()
 
-	if newVersion > 0 {
-		m.version.Store(newVersion)
-	} else {
-		m.version.Add(1)
-	}
-
-	// 发布Pub/Sub通知其他实例
-	if err := m.client.Publish(ctx, KeyMappingsChannel, "mapping_added").Err(); err != nil {
-		log.Printf("⚠️  Failed to publish Pub/Sub notification: %v", err)
-	}
+	m.commitChange(ctx, "mapping_added")
 
 	log.Printf("[AUDIT] Added mapping: %s -> %s (version: %d)", prefix, target, m.version.Load())
 
@@ -404,14 +546,19 @@ func (m *MappingManager) AddMapping(ctx context.Context, prefix, target string)
 }
 
 // UpdateMapping 更新现有映射
-func (m *MappingManager) UpdateMapping(ctx context.Context, prefix, target string) error {
-	// 验证输入
-	if err := validateMapping(prefix, target); err != nil {
+// opts 为空时保持原有扩展配置,非空时先完整校验,再与目标在同一事务中写入
+func (m *MappingManager) UpdateMapping(ctx context.Context, prefix, target string, opts *mapping.Options) error {
+	// 验证输入(校验代入环境变量后的目标)
+	resolved, err := m.checkMapping(prefix, target)
+	if err != nil {
+		return err
+	}
+	if opts, err = m.prepareOptions(prefix, opts); err != nil {
 		return err
 	}
 
 	// 检查是否存在
-	exists, err := m.client.HExists(ctx, KeyMappings, prefix).Result()
+	exists, err := m.mappingExists(ctx, prefix)
 	if err != nil {
 		return err
 	}
@@ -420,41 +567,112 @@ func (m *MappingManager) UpdateMapping(ctx context.Context, prefix, target strin
 	}
 
 	// 更新Redis
-	if err := m.client.HSet(ctx, KeyMappings, prefix, target).Err(); err != nil {
+	if _, err := m.writeMapping(ctx, prefix, target, opts); err != nil {
 		return err
 	}
 
-	// 增加Redis版本号
-	newVersion, err := m.client.Incr(ctx, KeyMappingsVersion).Result()
+	// 更新缓存(写锁保护)
+	m.mu.Lock()
+	m.setCached(prefix, target, resolved)
+	m.setCachedOptions(prefix, opts)
+	m.mu.Unlock()
+
+	m.commitChange(ctx, "mapping_updated")
+
+	log.Printf("[AUDIT] Updated mapping: %s -> %s (version: %d)", prefix, target, m.version.Load())
+
+	return nil
+}
+
+// UpsertMapping 添加或更新映射(单个事务,不存在时创建,存在时覆盖),返回是否为新建
+// opts 为空时保持原有扩展配置,非空时先完整校验,再与目标一同写入
+func (m *MappingManager) UpsertMapping(ctx context.Context, prefix, target string, opts *mapping.Options) (bool, error) {
+	// 验证输入(校验代入环境变量后的目标)
+	resolved, err := m.checkMapping(prefix, target)
 	if err != nil {
-		log.Printf("⚠️  Failed to increment version: %v", err)
+		return false, err
+	}
+	if opts, err = m.prepareOptions(prefix, opts); err != nil {
+		return false, err
 	}
 
-	// 更新缓存和本地版本号(写锁保护)
+	created, err := m.writeMapping(ctx, prefix, target, opts)
+	if err != nil {
+		return false, err
+	}
+
+	// 更新缓存(写锁保护)
 	m.mu.Lock()
-	m.cache[prefix] = target
+	m.setCached(prefix, target, resolved)
+	m.setCachedOptions(prefix, opts)
 	m.mu.Unlock()
 
-	if newVersion > 0 {
-		m.version.Store(newVersion)
+	action := "Updated"
+	if created {
+		action = "Added"
+		m.commitChange(ctx, "mapping_added")
 	} else {
-		m.version.Add(1)
+		m.commitChange(ctx, "mapping_updated")
 	}
 
-	// 发布Pub/Sub通知其他实例
-	if err := m.client.Publish(ctx, KeyMappingsChannel, "mapping_updated").Err(); err != nil {
-		log.Printf("⚠️  Failed to publish Pub/Sub notification: %v", err)
+	log.Printf("[AUDIT] %s mapping (upsert): %s -> %s (version: %d)", action, prefix, target, m.version.Load())
+
+	return created, nil
+}
+
+// prepareOptions 还原脱敏占位符并完整校验扩展配置(含 SSRF 校验),opts 为空时原样返回
+func (m *MappingManager) prepareOptions(prefix string, opts *mapping.Options) (*mapping.Options, error) {
+	if opts == nil {
+		return nil, nil
+	}
+	unmasked, err := m.unmaskOptions(prefix, *opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := unmasked.Validate(); err != nil {
+		return nil, err
+	}
+	if err := m.checkOptions(unmasked); err != nil {
+		return nil, err
 	}
+	return &unmasked, nil
+}
 
-	log.Printf("[AUDIT] Updated mapping: %s -> %s (version: %d)", prefix, target, m.version.Load())
+// writeMapping 在单个事务中写入目标及扩展配置(opts 为空时不修改扩展配置,零值表示清除),返回是否为新建
+func (m *MappingManager) writeMapping(ctx context.Context, prefix, target string, opts *mapping.Options) (bool, error) {
+	var data []byte
+	if opts != nil && !opts.IsZero() {
+		var err error
+		if data, err = json.Marshal(opts); err != nil {
+			return false, err
+		}
+	}
 
-	return nil
+	var added *redis.IntCmd
+	err := m.exec(ctx, func() error {
+		_, err := m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			added = pipe.HSet(ctx, KeyMappings, prefix, target)
+			switch {
+			case opts == nil:
+			case data == nil:
+				pipe.HDel(ctx, KeyMappingOptions, prefix)
+			default:
+				pipe.HSet(ctx, KeyMappingOptions, prefix, data)
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return added.Val() > 0, nil
 }
 
 // DeleteMapping 删除映射
 func (m *MappingManager) DeleteMapping(ctx context.Context, prefix string) error {
 	// 检查是否存在
-	exists, err := m.client.HExists(ctx, KeyMappings, prefix).Result()
+	exists, err := m.mappingExists(ctx, prefix)
 	if err != nil {
 		return err
 	}
@@ -462,36 +680,223 @@ func (m *MappingManager) DeleteMapping(ctx context.Context, prefix string) error
 		return fmt.Errorf("mapping not found for prefix: %s", prefix)
 	}
 
-	// 从Redis删除
-	if err := m.client.HDel(ctx, KeyMappings, prefix).Err(); err != nil {
+	// 从Redis删除(连同扩展配置和维护配置,同一事务内完成)
+	if err := m.exec(ctx, func() error {
+		_, err := m.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, KeyMappings, prefix)
+			pipe.HDel(ctx, KeyMappingOptions, prefix)
+			pipe.HDel(ctx, KeyMaintenance, prefix)
+			return nil
+		})
+		return err
+	}); err != nil {
 		return err
 	}
 
-	// 增加Redis版本号
-	newVersion, err := m.client.Incr(ctx, KeyMappingsVersion).Result()
+	// 从缓存删除(写锁保护)
+	m.mu.Lock()
+	delete(m.cache, prefix)
+	delete(m.templates, prefix)
+	m.router.Store(nil)
+	delete(m.options, prefix)
+	delete(m.maintenance, prefix)
+	m.mu.Unlock()
+
+	m.commitChange(ctx, "mapping_deleted")
+
+	log.Printf("[AUDIT] Deleted mapping: %s (version: %d)", prefix, m.version.Load())
+
+	return nil
+}
+
+// SetMappingOptions 设置映射的扩展配置(零值表示清除)
+func (m *MappingManager) SetMappingOptions(ctx context.Context, prefix string, opts mapping.Options) error {
+	prepared, err := m.prepareOptions(prefix, &opts)
 	if err != nil {
-		log.Printf("⚠️  Failed to increment version: %v", err)
+		return err
+	}
+	opts = *prepared
+
+	// 检查映射是否存在
+	exists, err := m.mappingExists(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("mapping not found for prefix: %s", prefix)
 	}
 
-	// 从缓存删除并更新本地版本号(写锁保护)
+	if opts.IsZero() {
+		err = m.exec(ctx, func() error {
+			return m.client.HDel(ctx, KeyMappingOptions, prefix).Err()
+		})
+	} else {
+		var data []byte
+		data, err = json.Marshal(opts)
+		if err != nil {
+			return err
+		}
+		err = m.exec(ctx, func() error {
+			return m.client.HSet(ctx, KeyMappingOptions, prefix, data).Err()
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	// 更新缓存(写锁保护)
 	m.mu.Lock()
-	delete(m.cache, prefix)
+	m.setCachedOptions(prefix, &opts)
 	m.mu.Unlock()
 
+	m.commitChange(ctx, "options_updated")
+
+	log.Printf("[AUDIT] Updated mapping options: %s (version: %d)", prefix, m.version.Load())
+
+	return nil
+}
+
+// setCachedOptions 更新缓存中的扩展配置(opts 为空时不修改,零值表示清除),调用方持有写锁
+func (m *MappingManager) setCachedOptions(prefix string, opts *mapping.Options) {
+	switch {
+	case opts == nil:
+	case opts.IsZero():
+		delete(m.options, prefix)
+	default:
+		if m.options == nil {
+			m.options = make(map[string]mapping.Options)
+		}
+		m.options[prefix] = *opts
+	}
+}
+
+// commitChange 递增Redis版本号、同步本地版本号并发布Pub/Sub通知其他实例
+// INCR 非幂等: 瞬时故障时命令可能已在服务端执行,重试会重复递增版本号,因此不自动重试
+func (m *MappingManager) commitChange(ctx context.Context, event string) {
+	newVersion, err := m.client.Incr(ctx, KeyMappingsVersion).Result()
+	if err != nil {
+		log.Printf("⚠️  Failed to increment version: %v", err)
+	}
+
 	if newVersion > 0 {
 		m.version.Store(newVersion)
 	} else {
 		m.version.Add(1)
 	}
 
-	// 发布Pub/Sub通知其他实例
-	if err := m.client.Publish(ctx, KeyMappingsChannel, "mapping_deleted").Err(); err != nil {
+	if err := m.client.Publish(ctx, KeyMappingsChannel, event).Err(); err != nil {
 		log.Printf("⚠️  Failed to publish Pub/Sub notification: %v", err)
 	}
+}
 
-	log.Printf("[AUDIT] Deleted mapping: %s (version: %d)", prefix, m.version.Load())
+// GetOptions 获取指定前缀的扩展配置(未配置时返回零值)
+func (m *MappingManager) GetOptions(prefix string) mapping.Options {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.options[prefix]
+}
 
-	return nil
+// unmaskOptions 将脱敏占位符替换为已存储的敏感内容(接口输出的配置原样提交时保留原值)
+func (m *MappingManager) unmaskOptions(prefix string, opts mapping.Options) (mapping.Options, error) {
+	return unmaskOptions(prefix, opts, m.GetOptions(prefix))
+}
+
+// unmaskOptions 将脱敏占位符替换为 stored 中的敏感内容,没有可对应的已存储值时返回错误
+func unmaskOptions(prefix string, opts, stored mapping.Options) (mapping.Options, error) {
+	if slices.Contains(slices.Collect(maps.Values(opts.RequestHeaders)), mapping.MaskedSecret) {
+		headers := make(map[string]string, len(opts.RequestHeaders))
+		for name, value := range opts.RequestHeaders {
+			if value == mapping.MaskedSecret {
+				storedValue, ok := stored.RequestHeaders[name]
+				if !ok {
+					return opts, fmt.Errorf("request_headers.%s is masked but no stored value exists for prefix: %s", name, prefix)
+				}
+				value = storedValue
+			}
+			headers[name] = value
+		}
+		opts.RequestHeaders = headers
+	}
+	if opts.ClientCert != nil && opts.ClientCert.KeyPEM == mapping.MaskedSecret {
+		if stored.ClientCert == nil || stored.ClientCert.KeyPEM == "" {
+			return opts, fmt.Errorf("client_cert.key_pem is masked but no stored key exists for prefix: %s", prefix)
+		}
+		cert := *opts.ClientCert
+		cert.KeyPEM = stored.ClientCert.KeyPEM
+		opts.ClientCert = &cert
+	}
+	if opts.TokenRefresh != nil && opts.TokenRefresh.ClientSecret == mapping.MaskedSecret {
+		if stored.TokenRefresh == nil || stored.TokenRefresh.ClientSecret == "" {
+			return opts, fmt.Errorf("token_refresh.client_secret is masked but no stored secret exists for prefix: %s", prefix)
+		}
+		refresh := *opts.TokenRefresh
+		refresh.ClientSecret = stored.TokenRefresh.ClientSecret
+		opts.TokenRefresh = &refresh
+	}
+	return opts, nil
+}
+
+// GetAllOptions 获取所有已配置的扩展配置
+func (m *MappingManager) GetAllOptions() map[string]mapping.Options {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string]mapping.Options, len(m.options))
+	for k, v := range m.options {
+		result[k] = v
+	}
+	return result
+}
+
+// loadOptions 从Redis加载所有扩展配置
+func (m *MappingManager) loadOptions(ctx context.Context) (map[string]mapping.Options, error) {
+	raw, err := m.client.HGetAll(ctx, KeyMappingOptions).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseOptions(raw), nil
+}
+
+// parseOptions 解析扩展配置哈希(解析失败的条目记录日志后跳过)
+func parseOptions(raw map[string]string) map[string]mapping.Options {
+	options := make(map[string]mapping.Options, len(raw))
+	for prefix, data := range raw {
+		var opts mapping.Options
+		if err := json.Unmarshal([]byte(data), &opts); err != nil {
+			log.Printf("⚠️  Invalid options for %s: %v", prefix, err)
+			continue
+		}
+		options[prefix] = opts
+	}
+	return options
+}
+
+// Diff 比较本地缓存与Redis当前映射(只读,不触发重载)
+func (m *MappingManager) Diff(ctx context.Context) (mapping.Diff, error) {
+	remote, err := m.client.HGetAll(ctx, KeyMappings).Result()
+	if err != nil {
+		return mapping.Diff{}, err
+	}
+	remoteVersion, err := m.client.Get(ctx, KeyMappingsVersion).Int64()
+	if err != nil && err != redis.Nil {
+		return mapping.Diff{}, err
+	}
+
+	// 与缓存一致地代入环境变量后比较(代入失败的映射未加载,保留原始模板显示为差异)
+	resolved, _, failed := m.resolveTargets(remote)
+	for prefix := range failed {
+		resolved[prefix] = remote[prefix]
+	}
+	remote = resolved
+
+	m.mu.RLock()
+	diff := mapping.Compare(m.cache, remote)
+	m.mu.RUnlock()
+
+	diff.LocalVersion = m.version.Load()
+	diff.RemoteVersion = remoteVersion
+	diff.VersionSkew = remoteVersion - diff.LocalVersion
+	return diff, nil
 }
 
 // Count 返回映射数量
@@ -501,6 +906,24 @@ func (m *MappingManager) Count() int {
 	return len(m.cache)
 }
 
+// MatchPrefix 返回匹配请求路径的映射前缀(最长前缀优先,与 GetPrefixes 顺序逐个匹配的结果一致)
+// 路由树在映射变更后的首次匹配时重建,之后每次查找与映射数量无关
+func (m *MappingManager) MatchPrefix(path string) (string, bool) {
+	if router := m.router.Load(); router != nil {
+		return router.Match(path)
+	}
+
+	// 持读锁构建并保存,避免覆盖并发写入后的置空
+	m.mu.RLock()
+	router := m.router.Load()
+	if router == nil {
+		router = mapping.NewRouter(slices.Collect(maps.Keys(m.cache)))
+		m.router.Store(router)
+	}
+	m.mu.RUnlock()
+	return router.Match(path)
+}
+
 // GetPrefixes 获取所有前缀列表
 func (m *MappingManager) GetPrefixes() []string {
 	m.mu.RLock()
@@ -558,54 +981,172 @@ func (m *MappingManager) Close() error {
 	return nil
 }
 
-// validateMapping 验证映射的有效性
-// isPrivateIP 检查IP是否为私有地址
-func isPrivateIP(ip net.IP) bool {
-	return ip.IsLoopback() || ip.IsPrivate()
+// checkOptions 对扩展配置中代理会主动连接的地址做与映射目标相同的 SSRF 及回环校验
+func (m *MappingManager) checkOptions(opts mapping.Options) error {
+	var problems ValidationErrors
+	if opts.DialAddress != "" {
+		host, _, _ := net.SplitHostPort(opts.DialAddress)
+		if ip := mapping.ResolvePrivateIP(host); ip != nil {
+			problems.add(ErrPrivateTarget, fmt.Sprintf("dial_address resolves to private IP: %s", ip))
+		}
+	}
+	if action := opts.OnUpstream404; action != nil && action.Action == mapping.NotFoundFallback {
+		m.checkURL(&problems, "on_upstream_404.target", action.Target)
+	}
+	if opts.TokenRefresh != nil {
+		m.checkURL(&problems, "token_refresh.url", opts.TokenRefresh.URL)
+	}
+	for _, upstream := range opts.Upstreams {
+		m.checkURL(&problems, "upstreams", upstream.URL)
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
 }
 
+// checkURL 按映射目标的规则校验扩展配置中的URL(私有地址、指向代理自身),问题以字段名标注
+func (m *MappingManager) checkURL(problems *ValidationErrors, field, target string) {
+	for _, err := range validateTarget(target) {
+		var problem *ValidationError
+		if errors.As(err, &problem) {
+			problems.add(problem.Kind, field+": "+problem.Message)
+		}
+	}
+	if m.self.Matches(target) {
+		problems.add(ErrSelfTarget, fmt.Sprintf("%s: %s points back at this proxy", field, target))
+	}
+}
+
+// checkMapping 代入环境变量后校验映射,并拒绝指向代理自身的目标(避免请求回环),返回代入后的目标
+func (m *MappingManager) checkMapping(prefix, target string) (string, error) {
+	resolved, err := m.resolveTarget(prefix, target)
+	if err != nil {
+		var problems ValidationErrors
+		problems.add(ErrInvalidTarget, err.Error())
+		return "", problems
+	}
+	err = validateMapping(prefix, resolved)
+	if !m.self.Matches(resolved) {
+		return resolved, err
+	}
+	var problems ValidationErrors
+	errors.As(err, &problems)
+	problems.add(ErrSelfTarget, fmt.Sprintf("target %s points back at this proxy", resolved))
+	return resolved, problems
+}
+
+// resolveTarget 按 TARGET_ENV_INTERPOLATION 代入目标中的 ${NAME} 环境变量
+// 代入后的目标须为带主机名的 http(s) URL(变量为空时主机可能为空);正则映射的目标模板在请求时才完整
+func (m *MappingManager) resolveTarget(prefix, target string) (string, error) {
+	if !m.interpolateEnv {
+		return target, nil
+	}
+	resolved, err := mapping.InterpolateEnv(prefix, target, os.LookupEnv)
+	if err != nil || resolved == target || mapping.IsPattern(prefix) {
+		return resolved, err
+	}
+	u, err := url.Parse(resolved)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("target %q resolves to %q, which is not an http(s) URL with a host", target, resolved)
+	}
+	return resolved, nil
+}
+
+// resolveTargets 代入全部映射目标中的环境变量,返回代入后的映射及代入了环境变量的映射的原始模板
+// 代入失败的映射不加载(记录在 failed 中),其余映射照常生效
+func (m *MappingManager) resolveTargets(mappings map[string]string) (resolved, templates map[string]string, failed map[string]error) {
+	if !m.interpolateEnv {
+		return mappings, nil, nil
+	}
+	resolved = make(map[string]string, len(mappings))
+	templates = make(map[string]string)
+	for prefix, target := range mappings {
+		value, err := m.resolveTarget(prefix, target)
+		if err != nil {
+			if failed == nil {
+				failed = make(map[string]error)
+			}
+			failed[prefix] = err
+			continue
+		}
+		resolved[prefix] = value
+		if value != target {
+			templates[prefix] = target
+		}
+	}
+	return resolved, templates, failed
+}
+
+// warnSelfTargets 启动时提示已存在的回环映射(请求时会返回 508)
+func (m *MappingManager) warnSelfTargets() {
+	if m.self.Len() == 0 {
+		return
+	}
+	for prefix, target := range m.GetAllMappings() {
+		if m.self.Matches(target) {
+			log.Printf("⚠️  映射 %s -> %s 指向代理自身,请求将被拒绝 (508 Loop Detected)", prefix, target)
+		}
+	}
+}
+
+// validateMapping 验证映射的有效性
 func validateMapping(prefix, target string) error {
+	var problems ValidationErrors
+
 	// 验证前缀格式
 	if prefix == "" {
-		return errors.New("prefix cannot be empty")
-	}
-
-	if !strings.HasPrefix(prefix, "/") {
-		return errors.New("prefix must start with /")
+		problems.add(ErrInvalidPrefix, "prefix cannot be empty")
+	} else if mapping.IsPattern(prefix) {
+		// 正则映射以 ~ 开头,其余前缀必须以 / 开头
+		if err := mapping.ValidateTemplate(prefix, target); err != nil {
+			problems.add(ErrInvalidPrefix, err.Error())
+		}
+	} else if !strings.HasPrefix(prefix, "/") {
+		problems.add(ErrInvalidPrefix, "prefix must start with /")
 	}
 
 	if strings.Contains(prefix, " ") {
-		return errors.New("prefix cannot contain spaces")
+		problems.add(ErrInvalidPrefix, "prefix cannot contain spaces")
 	}
 
 	// 验证目标URL
+	problems = append(problems, validateTarget(target)...)
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return problems
+}
+
+// validateTarget 校验目标URL,返回全部问题
+func validateTarget(target string) ValidationErrors {
+	var problems ValidationErrors
+
 	if target == "" {
-		return errors.New("target URL cannot be empty")
+		problems.add(ErrInvalidTarget, "target URL cannot be empty")
+		return problems
 	}
 
 	parsedURL, err := url.Parse(target)
 	if err != nil {
-		return fmt.Errorf("invalid target URL: %w", err)
+		problems.add(ErrInvalidTarget, fmt.Sprintf("invalid target URL: %v", err))
+		return problems
 	}
 
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return errors.New("target URL must use http or https scheme")
+		problems.add(ErrInvalidScheme, "target URL must use http or https scheme")
 	}
 
 	if parsedURL.Host == "" {
-		return errors.New("target URL must have a valid host")
+		problems.add(ErrInvalidHost, "target URL must have a valid host")
+		return problems
 	}
 
 	// SSRF 防护: 检查目标是否解析到私有 IP
-	host := parsedURL.Hostname()
-	ips, err := net.LookupIP(host)
-	if err == nil {
-		for _, ip := range ips {
-			if isPrivateIP(ip) {
-				return fmt.Errorf("target URL resolves to private IP: %s", ip)
-			}
-		}
+	if ip := mapping.ResolvePrivateIP(parsedURL.Hostname()); ip != nil {
+		problems.add(ErrPrivateTarget, fmt.Sprintf("target URL resolves to private IP: %s", ip))
 	}
 
-	return nil
+	return problems
 }
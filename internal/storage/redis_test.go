@@ -2,11 +2,17 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
+
+	"api-proxy/internal/mapping"
 )
 
 func setupTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
@@ -72,7 +78,7 @@ func TestMappingManager_AddMapping(t *testing.T) {
 	ctx := context.Background()
 
 	// 添加映射
-	err := mm.AddMapping(ctx, "/test", "http://example.com")
+	err := mm.AddMapping(ctx, "/test", "http://example.com", nil)
 	if err != nil {
 		t.Fatalf("AddMapping failed: %v", err)
 	}
@@ -125,7 +131,7 @@ func TestMappingManager_AddMapping_Invalid(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := mm.AddMapping(ctx, tt.prefix, tt.target)
+			err := mm.AddMapping(ctx, tt.prefix, tt.target, nil)
 			if err == nil {
 				t.Error("expected error for invalid mapping")
 			}
@@ -148,7 +154,7 @@ func TestMappingManager_GetMapping(t *testing.T) {
 	ctx := context.Background()
 
 	// 先添加一个映射
-	mm.AddMapping(ctx, "/api", "http://api.example.com")
+	mm.AddMapping(ctx, "/api", "http://api.example.com", nil)
 
 	// 获取存在的映射
 	target, err := mm.GetMapping(ctx, "/api")
@@ -166,6 +172,47 @@ func TestMappingManager_GetMapping(t *testing.T) {
 	}
 }
 
+func TestMappingManager_UpsertMapping(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	mm.initialized.Store(true)
+
+	ctx := context.Background()
+
+	// 不存在时创建
+	created, err := mm.UpsertMapping(ctx, "/api", "http://old.example.com", nil)
+	if err != nil || !created {
+		t.Fatalf("expected upsert to create mapping, got created=%v err=%v", created, err)
+	}
+	// 已存在时更新
+	created, err = mm.UpsertMapping(ctx, "/api", "http://new.example.com", nil)
+	if err != nil || created {
+		t.Fatalf("expected upsert to update mapping, got created=%v err=%v", created, err)
+	}
+
+	if val, _ := client.HGet(ctx, KeyMappings, "/api").Result(); val != "http://new.example.com" {
+		t.Errorf("expected updated target in Redis, got %s", val)
+	}
+	if mm.cache["/api"] != "http://new.example.com" {
+		t.Errorf("expected updated target in cache, got %s", mm.cache["/api"])
+	}
+	if mm.GetVersion() != 2 {
+		t.Errorf("expected version 2 after two upserts, got %d", mm.GetVersion())
+	}
+
+	// 校验规则与添加一致
+	if _, err := mm.UpsertMapping(ctx, "api", "http://example.com", nil); err == nil {
+		t.Error("expected validation error for prefix without slash")
+	}
+}
+
 func TestMappingManager_UpdateMapping(t *testing.T) {
 	mr, client := setupTestRedis(t)
 	defer mr.Close()
@@ -181,11 +228,11 @@ func TestMappingManager_UpdateMapping(t *testing.T) {
 	ctx := context.Background()
 
 	// 先添加一个映射
-	mm.AddMapping(ctx, "/api", "http://old.example.com")
+	mm.AddMapping(ctx, "/api", "http://old.example.com", nil)
 	oldVersion := mm.GetVersion()
 
 	// 更新映射
-	err := mm.UpdateMapping(ctx, "/api", "http://new.example.com")
+	err := mm.UpdateMapping(ctx, "/api", "http://new.example.com", nil)
 	if err != nil {
 		t.Fatalf("UpdateMapping failed: %v", err)
 	}
@@ -202,7 +249,7 @@ func TestMappingManager_UpdateMapping(t *testing.T) {
 	}
 
 	// 更新不存在的映射应该失败
-	err = mm.UpdateMapping(ctx, "/nonexistent", "http://test.com")
+	err = mm.UpdateMapping(ctx, "/nonexistent", "http://test.com", nil)
 	if err == nil {
 		t.Error("expected error when updating nonexistent mapping")
 	}
@@ -223,7 +270,7 @@ func TestMappingManager_DeleteMapping(t *testing.T) {
 	ctx := context.Background()
 
 	// 先添加一个映射
-	mm.AddMapping(ctx, "/api", "http://example.com")
+	mm.AddMapping(ctx, "/api", "http://example.com", nil)
 	initialCount := mm.Count()
 
 	// 删除映射
@@ -272,7 +319,7 @@ func TestMappingManager_GetAllMappings(t *testing.T) {
 	}
 
 	for prefix, target := range testMappings {
-		mm.AddMapping(ctx, prefix, target)
+		mm.AddMapping(ctx, prefix, target, nil)
 	}
 
 	// 获取所有映射
@@ -309,12 +356,12 @@ func TestMappingManager_Count(t *testing.T) {
 	}
 
 	// 添加映射
-	mm.AddMapping(ctx, "/api1", "http://example.com")
+	mm.AddMapping(ctx, "/api1", "http://example.com", nil)
 	if mm.Count() != 1 {
 		t.Errorf("expected count 1, got %d", mm.Count())
 	}
 
-	mm.AddMapping(ctx, "/api2", "http://example.com")
+	mm.AddMapping(ctx, "/api2", "http://example.com", nil)
 	if mm.Count() != 2 {
 		t.Errorf("expected count 2, got %d", mm.Count())
 	}
@@ -343,7 +390,7 @@ func TestMappingManager_GetPrefixes(t *testing.T) {
 	// 添加映射
 	expectedPrefixes := []string{"/api1", "/api2", "/api3"}
 	for _, prefix := range expectedPrefixes {
-		mm.AddMapping(ctx, prefix, "http://example.com")
+		mm.AddMapping(ctx, prefix, "http://example.com", nil)
 	}
 
 	// 获取前缀
@@ -430,6 +477,66 @@ func TestMappingManager_ReloadMappings(t *testing.T) {
 	// reloadMappings本身不设置这个状态
 }
 
+func TestMappingManager_ReloadNoOverlap(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+
+	ctx := context.Background()
+	client.HSet(ctx, KeyMappings, "/a", "http://a.example.com")
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	// 模拟一次耗时较长的重载
+	var active, maxActive atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	slowReload := func() {
+		if n := active.Add(1); n > maxActive.Load() {
+			maxActive.Store(n)
+		}
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+		mm.reloadMappings(ctx)
+		active.Add(-1)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mm.reloadGuard.TryRun(slowReload)
+	}()
+	<-started
+
+	// 定时重载在上一轮未完成时跳过
+	if mm.reloadGuard.TryRun(slowReload) {
+		t.Fatal("periodic reload must be skipped while a reload is running")
+	}
+
+	// Pub/Sub 通知的变更不丢失:在当前重载结束后补跑
+	client.HSet(ctx, KeyMappings, "/b", "http://b.example.com")
+	client.Set(ctx, KeyMappingsVersion, "2", 0)
+	mm.reloadGuard.Coalesce(slowReload)
+
+	close(release)
+	<-done
+
+	if maxActive.Load() != 1 {
+		t.Fatalf("expected no overlapping reloads, got %d concurrent", maxActive.Load())
+	}
+	if _, err := mm.GetMapping(ctx, "/b"); err != nil || mm.GetVersion() != 2 {
+		t.Fatalf("expected coalesced reload to pick up version 2, got version=%d err=%v", mm.GetVersion(), err)
+	}
+}
+
 func TestMappingManager_Close(t *testing.T) {
 	mr, client := setupTestRedis(t)
 	defer mr.Close()
@@ -588,7 +695,7 @@ func TestMappingManager_Concurrent(t *testing.T) {
 			for j := 0; j < operationsPerGoroutine; j++ {
 				switch j % 4 {
 				case 0:
-					mm.AddMapping(ctx, prefix, target)
+					mm.AddMapping(ctx, prefix, target, nil)
 				case 1:
 					mm.GetMapping(ctx, prefix)
 				case 2:
@@ -676,3 +783,555 @@ func TestMappingManager_ForceReload(t *testing.T) {
 		t.Errorf("expected http://new.example.com after reload, got %s", target)
 	}
 }
+
+// TestMappingManager_MappingOptions 测试扩展配置的存储与重载
+func TestMappingManager_MappingOptions(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	client.HSet(ctx, KeyMappings, "/bin", "http://203.0.113.10")
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+
+	opts := mapping.Options{ContentType: "application/json"}
+	if err := mm.SetMappingOptions(ctx, "/bin", opts); err != nil {
+		t.Fatalf("SetMappingOptions failed: %v", err)
+	}
+	if got := mm.GetOptions("/bin"); got.ContentType != "application/json" {
+		t.Errorf("expected cached options, got %+v", got)
+	}
+
+	// 另一个实例重载后应获得相同配置
+	other := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	if err := other.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+	if got := other.GetOptions("/bin"); got.ContentType != "application/json" {
+		t.Errorf("expected options loaded from Redis, got %+v", got)
+	}
+
+	// 不存在的映射和无效配置应报错
+	if err := mm.SetMappingOptions(ctx, "/missing", opts); err == nil {
+		t.Error("expected error for missing mapping")
+	}
+	if err := mm.SetMappingOptions(ctx, "/bin", mapping.Options{ContentType: "???"}); err == nil {
+		t.Error("expected error for invalid options")
+	}
+
+	// 零值清除配置
+	if err := mm.SetMappingOptions(ctx, "/bin", mapping.Options{}); err != nil {
+		t.Fatalf("clearing options failed: %v", err)
+	}
+	if exists, _ := client.HExists(ctx, KeyMappingOptions, "/bin").Result(); exists {
+		t.Error("options should be removed from Redis")
+	}
+	if !mm.GetOptions("/bin").IsZero() {
+		t.Error("options should be cleared from cache")
+	}
+}
+
+// TestMappingManager_AddMappingWithOptions 测试目标与扩展配置一同校验并在单个事务中写入
+func TestMappingManager_AddMappingWithOptions(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+
+	// 扩展配置未通过 SSRF 校验时整体拒绝,映射不写入
+	private := &mapping.Options{DialAddress: "127.0.0.1:6379"}
+	if err := mm.AddMapping(ctx, "/bin", "http://203.0.113.10", private); !errors.Is(err, ErrPrivateTarget) {
+		t.Fatalf("expected ErrPrivateTarget, got %v", err)
+	}
+	if exists, _ := client.HExists(ctx, KeyMappings, "/bin").Result(); exists {
+		t.Fatal("mapping should not be written when options are rejected")
+	}
+
+	opts := &mapping.Options{ContentType: "application/json"}
+	if err := mm.AddMapping(ctx, "/bin", "http://203.0.113.10", opts); err != nil {
+		t.Fatalf("AddMapping failed: %v", err)
+	}
+	if version, _ := client.Get(ctx, KeyMappingsVersion).Int64(); version != 1 {
+		t.Errorf("expected a single version bump, got %d", version)
+	}
+	if stored, _ := client.HGet(ctx, KeyMappingOptions, "/bin").Result(); !strings.Contains(stored, "application/json") {
+		t.Errorf("expected options stored with the mapping, got %q", stored)
+	}
+	if got := mm.GetOptions("/bin"); got.ContentType != "application/json" {
+		t.Errorf("expected cached options, got %+v", got)
+	}
+
+	// 更新未携带扩展配置时保留原有配置
+	if err := mm.UpdateMapping(ctx, "/bin", "http://203.0.113.11", nil); err != nil {
+		t.Fatalf("UpdateMapping failed: %v", err)
+	}
+	if got := mm.GetOptions("/bin"); got.ContentType != "application/json" {
+		t.Errorf("options should be kept when omitted, got %+v", got)
+	}
+}
+
+// TestMappingManager_CheckOptions 测试扩展配置中代理主动连接的地址不能指向私有地址或代理自身
+func TestMappingManager_CheckOptions(t *testing.T) {
+	mm := &MappingManager{self: mapping.NewSelfAddresses([]string{"203.0.113.8:8000"})}
+	fallback := func(target string) mapping.Options {
+		return mapping.Options{OnUpstream404: &mapping.NotFoundAction{Action: mapping.NotFoundFallback, Target: target}}
+	}
+	tests := []struct {
+		name    string
+		opts    mapping.Options
+		wantErr error
+	}{
+		{"empty", mapping.Options{}, nil},
+		{"public", mapping.Options{DialAddress: "203.0.113.10:443"}, nil},
+		{"loopback", mapping.Options{DialAddress: "127.0.0.1:6379"}, ErrPrivateTarget},
+		{"private", mapping.Options{DialAddress: "10.0.0.1:443"}, ErrPrivateTarget},
+		{"linkLocal", mapping.Options{DialAddress: "169.254.169.254:80"}, ErrPrivateTarget},
+		{"ipv6Loopback", mapping.Options{DialAddress: "[::1]:443"}, ErrPrivateTarget},
+		{"fallbackPublic", fallback("https://203.0.113.20/v1"), nil},
+		{"fallbackPrivate", fallback("http://169.254.169.254/latest/meta-data"), ErrPrivateTarget},
+		{"fallbackSelf", fallback("http://203.0.113.8:8000/api"), ErrSelfTarget},
+		{"tokenPublic", mapping.Options{TokenRefresh: &mapping.TokenRefresh{URL: "https://203.0.113.30/token"}}, nil},
+		{"tokenPrivate", mapping.Options{TokenRefresh: &mapping.TokenRefresh{URL: "http://10.0.0.5/token"}}, ErrPrivateTarget},
+		{"tokenSelf", mapping.Options{TokenRefresh: &mapping.TokenRefresh{URL: "http://203.0.113.8:8000/token"}}, ErrSelfTarget},
+		{"upstreamsPublic", mapping.Options{Upstreams: []mapping.Upstream{{URL: "https://203.0.113.40"}, {URL: "https://203.0.113.41"}}}, nil},
+		{"upstreamsPrivate", mapping.Options{Upstreams: []mapping.Upstream{{URL: "https://203.0.113.40"}, {URL: "http://127.0.0.1:6379"}}}, ErrPrivateTarget},
+		{"upstreamsSelf", mapping.Options{Upstreams: []mapping.Upstream{{URL: "http://203.0.113.8:8000"}}}, ErrSelfTarget},
+		{"messageIgnored", mapping.Options{OnUpstream404: &mapping.NotFoundAction{Action: mapping.NotFoundMessage}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mm.checkOptions(tt.opts)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestMappingManager_UnmaskOptions 测试脱敏占位符替换为已存储的敏感内容
+func TestMappingManager_UnmaskOptions(t *testing.T) {
+	mm := &MappingManager{
+		options: map[string]mapping.Options{
+			"/mtls": {ClientCert: &mapping.ClientCert{CertPEM: "cert", KeyPEM: "stored-key"}},
+		},
+	}
+
+	submitted := mapping.Options{ClientCert: &mapping.ClientCert{CertPEM: "new-cert", KeyPEM: mapping.MaskedSecret}}
+	got, err := mm.unmaskOptions("/mtls", submitted)
+	if err != nil {
+		t.Fatalf("unmaskOptions failed: %v", err)
+	}
+	if got.ClientCert.KeyPEM != "stored-key" || got.ClientCert.CertPEM != "new-cert" {
+		t.Errorf("unexpected unmasked cert: %+v", got.ClientCert)
+	}
+	if submitted.ClientCert.KeyPEM != mapping.MaskedSecret {
+		t.Error("unmaskOptions must not modify the submitted options")
+	}
+
+	if _, err := mm.unmaskOptions("/other", submitted); err == nil {
+		t.Error("expected error when no stored key exists")
+	}
+
+	mm.options["/token"] = mapping.Options{TokenRefresh: &mapping.TokenRefresh{URL: "https://auth.example.com/token", ClientSecret: "stored-secret"}}
+	got, err = mm.unmaskOptions("/token", mapping.Options{TokenRefresh: &mapping.TokenRefresh{URL: "https://auth.example.com/v2/token", ClientSecret: mapping.MaskedSecret}})
+	if err != nil {
+		t.Fatalf("unmaskOptions failed: %v", err)
+	}
+	if got.TokenRefresh.ClientSecret != "stored-secret" || got.TokenRefresh.URL != "https://auth.example.com/v2/token" {
+		t.Errorf("unexpected unmasked token config: %+v", got.TokenRefresh)
+	}
+
+	// 请求头: 占位符还原为同名头部的已存储值,新值原样保留
+	mm.options["/headers"] = mapping.Options{RequestHeaders: map[string]string{"Authorization": "Bearer stored"}}
+	got, err = mm.unmaskOptions("/headers", mapping.Options{RequestHeaders: map[string]string{
+		"Authorization": mapping.MaskedSecret,
+		"X-Tenant":      "acme",
+	}})
+	if err != nil {
+		t.Fatalf("unmaskOptions failed: %v", err)
+	}
+	if got.RequestHeaders["Authorization"] != "Bearer stored" || got.RequestHeaders["X-Tenant"] != "acme" {
+		t.Errorf("unexpected unmasked headers: %v", got.RequestHeaders)
+	}
+	if _, err := mm.unmaskOptions("/headers", mapping.Options{RequestHeaders: map[string]string{"X-New": mapping.MaskedSecret}}); err == nil {
+		t.Error("expected error when no stored header value exists")
+	}
+}
+
+// TestValidateMapping_Pattern 测试正则映射与目标模板校验
+func TestValidateMapping_Pattern(t *testing.T) {
+	pattern := "~^/t/(?P<tenant>[^/]+)/api"
+
+	if err := validateMapping(pattern, "https://backend.invalid/$tenant"); err != nil {
+		t.Errorf("expected valid pattern mapping, got %v", err)
+	}
+	if err := validateMapping(pattern, "https://backend.invalid/$region"); err == nil {
+		t.Error("expected error for template variable without capture")
+	}
+	if err := validateMapping(pattern, "https://$tenant.backend.invalid"); err != nil {
+		t.Errorf("expected captures in host labels to be valid, got %v", err)
+	}
+	if err := validateMapping(pattern, "https://$tenant.invalid"); !errors.Is(err, ErrInvalidPrefix) {
+		t.Errorf("expected host template without a fixed domain to be rejected, got %v", err)
+	}
+	if err := validateMapping("~^/bad(", "https://backend.invalid"); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestMappingManager_Diff(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	client.HSet(ctx, KeyMappings, "/same", "http://same.example.com", "/a", "http://a.example.com")
+	client.Set(ctx, KeyMappingsVersion, "5", 0)
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+
+	diff, err := mm.Diff(ctx)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if !diff.InSync() {
+		t.Fatalf("expected in-sync after reload, got %+v", diff)
+	}
+
+	// 其他实例直接修改Redis,本实例缓存过期
+	client.HSet(ctx, KeyMappings, "/a", "http://a2.example.com", "/new", "http://new.example.com")
+	client.HDel(ctx, KeyMappings, "/same")
+	client.Set(ctx, KeyMappingsVersion, "7", 0)
+
+	diff, err = mm.Diff(ctx)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if diff.Added["/new"] != "http://new.example.com" {
+		t.Errorf("expected /new as added, got %+v", diff.Added)
+	}
+	if diff.Removed["/same"] != "http://same.example.com" {
+		t.Errorf("expected /same as removed, got %+v", diff.Removed)
+	}
+	if c := diff.Changed["/a"]; c.Local != "http://a.example.com" || c.Remote != "http://a2.example.com" {
+		t.Errorf("expected /a as changed, got %+v", diff.Changed)
+	}
+	if diff.LocalVersion != 5 || diff.RemoteVersion != 7 || diff.VersionSkew != 2 {
+		t.Errorf("expected version skew 2 (5 -> 7), got %+v", diff)
+	}
+
+	// 只读比较不应修改缓存
+	if mm.cache["/a"] != "http://a.example.com" {
+		t.Error("Diff should not modify the local cache")
+	}
+}
+
+func TestValidateMapping_ReportsAllProblems(t *testing.T) {
+	err := validateMapping("", "ftp://")
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	var problems ValidationErrors
+	if !errors.As(err, &problems) || len(problems) != 3 {
+		t.Fatalf("expected 3 problems, got %v", err)
+	}
+	for _, kind := range []error{ErrInvalidPrefix, ErrInvalidScheme, ErrInvalidHost} {
+		if !errors.Is(err, kind) {
+			t.Errorf("expected %v to be reported, got %v", kind, err)
+		}
+	}
+	if errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("unexpected ErrInvalidTarget in %v", err)
+	}
+
+	err = validateMapping("/has space", "")
+	if !errors.Is(err, ErrInvalidPrefix) || !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected prefix and target problems, got %v", err)
+	}
+}
+
+func TestMappingManager_RejectsSelfTarget(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+		self:     mapping.NewSelfAddresses([]string{"203.0.113.8:8000"}),
+	}
+	ctx := context.Background()
+
+	if err := mm.AddMapping(ctx, "/loop", "http://203.0.113.8:8000", nil); !errors.Is(err, ErrSelfTarget) {
+		t.Fatalf("expected ErrSelfTarget, got %v", err)
+	}
+	if _, err := mm.ApplyMappings(ctx, []mapping.Entry{{Prefix: "/loop", Target: "http://203.0.113.8:8000/v1"}}, false); !errors.Is(err, ErrSelfTarget) {
+		t.Fatalf("expected ErrSelfTarget from ApplyMappings, got %v", err)
+	}
+	// 其他端口不是代理自身
+	if err := mm.AddMapping(ctx, "/ok", "http://203.0.113.8:9000", nil); err != nil {
+		t.Fatalf("expected other port to be accepted, got %v", err)
+	}
+}
+
+// TestMappingManager_ReloadOptionsOnly 仅修改扩展配置(超时)后重载应更新生效配置
+func TestMappingManager_ReloadOptionsOnly(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	client.HSet(ctx, KeyMappings, "/api", "http://203.0.113.10")
+	client.HSet(ctx, KeyMappingOptions, "/api", `{"timeout_ms":1000}`)
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+	if got := mm.GetOptions("/api").RequestTimeout(); got != time.Second {
+		t.Fatalf("expected 1s timeout, got %s", got)
+	}
+
+	// 其他实例只修改了超时配置(映射本身不变)并递增版本号
+	client.HSet(ctx, KeyMappingOptions, "/api", `{"timeout_ms":2500}`)
+	client.Incr(ctx, KeyMappingsVersion)
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+	if got := mm.GetOptions("/api").RequestTimeout(); got != 2500*time.Millisecond {
+		t.Errorf("expected background reload to pick up 2.5s timeout, got %s", got)
+	}
+	if mm.GetVersion() != 2 {
+		t.Errorf("expected version 2, got %d", mm.GetVersion())
+	}
+
+	// 未递增版本号的直接修改需要强制重载
+	client.HSet(ctx, KeyMappingOptions, "/api", `{"timeout_ms":500}`)
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+	if got := mm.GetOptions("/api").RequestTimeout(); got != 2500*time.Millisecond {
+		t.Errorf("unchanged version should skip reload, got %s", got)
+	}
+	if err := mm.ForceReload(ctx); err != nil {
+		t.Fatalf("ForceReload failed: %v", err)
+	}
+	if got := mm.GetOptions("/api").RequestTimeout(); got != 500*time.Millisecond {
+		t.Errorf("expected force reload to pick up 500ms timeout, got %s", got)
+	}
+}
+
+// TestMappingManager_MatchPrefix 路由树在映射变更后重建
+func TestMappingManager_MatchPrefix(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	client.HSet(ctx, KeyMappings, "/api", "http://203.0.113.10", "/api/v1", "http://203.0.113.11")
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+	}
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+
+	if prefix, ok := mm.MatchPrefix("/api/v1/chat"); !ok || prefix != "/api/v1" {
+		t.Fatalf("expected /api/v1, got %q", prefix)
+	}
+	if _, ok := mm.MatchPrefix("/other"); ok {
+		t.Fatal("expected no match for /other")
+	}
+
+	if err := mm.AddMapping(ctx, "/other", "http://203.0.113.12", nil); err != nil {
+		t.Fatal(err)
+	}
+	if prefix, ok := mm.MatchPrefix("/other/x"); !ok || prefix != "/other" {
+		t.Errorf("expected router rebuilt after add, got %q", prefix)
+	}
+
+	if err := mm.DeleteMapping(ctx, "/api/v1"); err != nil {
+		t.Fatal(err)
+	}
+	if prefix, _ := mm.MatchPrefix("/api/v1/chat"); prefix != "/api" {
+		t.Errorf("expected router rebuilt after delete, got %q", prefix)
+	}
+}
+
+// TestMappingManager_BumpVersion 手动递增版本号后所有实例经 Pub/Sub 重新加载
+func TestMappingManager_BumpVersion(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	client.HSet(ctx, KeyMappings, "/api", "http://203.0.113.10")
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	// 定时重载间隔足够长,确保重载只可能由 Pub/Sub 通知触发
+	t.Setenv("API_PROXY_REDIS_URL", "redis://"+mr.Addr())
+	t.Setenv("MAPPING_RELOAD_INTERVAL", "1h")
+	instances := make([]*MappingManager, 2)
+	for i := range instances {
+		mm, err := NewMappingManager(ctx)
+		if err != nil {
+			t.Fatalf("NewMappingManager failed: %v", err)
+		}
+		defer mm.Close()
+		instances[i] = mm
+	}
+
+	// 绕过管理接口直接修改Redis(版本号不变),实例不会自行发现
+	client.HSet(ctx, KeyMappings, "/api", "http://203.0.113.20")
+
+	version, err := instances[0].BumpVersion(ctx)
+	if err != nil {
+		t.Fatalf("BumpVersion failed: %v", err)
+	}
+	if version != 2 {
+		t.Errorf("expected version 2, got %d", version)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for i, mm := range instances {
+		for mm.GetAllMappings()["/api"] != "http://203.0.113.20" || mm.GetVersion() != 2 {
+			if time.Now().After(deadline) {
+				t.Fatalf("instance %d did not reload: target=%s version=%d", i, mm.GetAllMappings()["/api"], mm.GetVersion())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if got := client.HGet(ctx, KeyMappings, "/api").Val(); got != "http://203.0.113.20" {
+		t.Errorf("bump must not change mappings, got %s", got)
+	}
+}
+
+func TestMappingManager_TargetEnvInterpolation(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+	t.Setenv("APIPROXY_TARGET_TEST_REGION", "eu-west-1")
+
+	ctx := context.Background()
+	client.HSet(ctx, KeyMappings, "/api", "https://${APIPROXY_TARGET_TEST_REGION}.api.example.com")
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	mm := &MappingManager{
+		client:         client,
+		cache:          make(map[string]string),
+		stopChan:       make(chan struct{}),
+		interpolateEnv: true,
+	}
+	mm.initialized.Store(true)
+
+	// 加载时代入,Redis 中保留原始模板
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reloadMappings failed: %v", err)
+	}
+	if target, _ := mm.GetMapping(ctx, "/api"); target != "https://eu-west-1.api.example.com" {
+		t.Errorf("expected substituted target, got %s", target)
+	}
+	if diff, err := mm.Diff(ctx); err != nil || len(diff.Changed) != 0 {
+		t.Errorf("substituted targets should not show up in diff: %+v %v", diff, err)
+	}
+
+	if err := mm.AddMapping(ctx, "/eu", "https://${APIPROXY_TARGET_TEST_REGION}.example.com/v1", nil); err != nil {
+		t.Fatalf("AddMapping failed: %v", err)
+	}
+	if raw, _ := client.HGet(ctx, KeyMappings, "/eu").Result(); raw != "https://${APIPROXY_TARGET_TEST_REGION}.example.com/v1" {
+		t.Errorf("expected template stored in Redis, got %s", raw)
+	}
+	if target, _ := mm.GetMapping(ctx, "/eu"); target != "https://eu-west-1.example.com/v1" {
+		t.Errorf("expected substituted target in cache, got %s", target)
+	}
+
+	// 管理接口输出原始模板,不暴露变量值
+	templates := mm.GetAllTemplates()
+	if templates["/eu"] != "https://${APIPROXY_TARGET_TEST_REGION}.example.com/v1" || templates["/api"] != "https://${APIPROXY_TARGET_TEST_REGION}.api.example.com" {
+		t.Errorf("expected unresolved templates, got %v", templates)
+	}
+	if err := mm.UpdateMapping(ctx, "/eu", "https://eu.example.com", nil); err != nil || mm.GetAllTemplates()["/eu"] != "https://eu.example.com" {
+		t.Errorf("template should be dropped once the target no longer references variables: %v", err)
+	}
+
+	// 前缀之外的环境变量不可引用
+	t.Setenv("ADMIN_TOKEN", "secret")
+	if err := mm.AddMapping(ctx, "/leak", "https://api.example.com/?t=${ADMIN_TOKEN}", nil); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected non-allowlisted variable to be rejected, got %v", err)
+	}
+
+	// 未设置的变量: 写入被拒绝并指出变量名
+	err := mm.AddMapping(ctx, "/missing", "https://${APIPROXY_TARGET_TEST_UNSET}.example.com", nil)
+	if !errors.Is(err, ErrInvalidTarget) || !strings.Contains(err.Error(), "APIPROXY_TARGET_TEST_UNSET") {
+		t.Fatalf("expected invalid target error naming the variable, got %v", err)
+	}
+
+	// 变量为空导致主机为空
+	t.Setenv("APIPROXY_TARGET_TEST_EMPTY", "")
+	if err := mm.AddMapping(ctx, "/empty", "https://${APIPROXY_TARGET_TEST_EMPTY}", nil); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected invalid target for empty host, got %v", err)
+	}
+
+	// Redis 中的模板引用了未设置的变量: 仅跳过该映射,其他映射的更新照常生效
+	client.HSet(ctx, KeyMappings, "/broken", "https://${APIPROXY_TARGET_TEST_UNSET}.example.com")
+	client.HSet(ctx, KeyMappings, "/fresh", "https://fresh.example.com")
+	client.Incr(ctx, KeyMappingsVersion)
+	if err := mm.reloadMappings(ctx); err != nil {
+		t.Fatalf("reload should not fail because of one mapping: %v", err)
+	}
+	if target, _ := mm.GetMapping(ctx, "/fresh"); target != "https://fresh.example.com" {
+		t.Errorf("other mappings should be reloaded, got %q", target)
+	}
+	if _, ok := mm.GetAllMappings()["/broken"]; ok {
+		t.Error("mapping with unresolved variable should be skipped")
+	}
+	if target, _ := mm.GetMapping(ctx, "/api"); target != "https://eu-west-1.api.example.com" {
+		t.Errorf("valid mappings should stay loaded, got %s", target)
+	}
+	if diff, err := mm.Diff(ctx); err != nil || diff.Added["/broken"] == "" {
+		t.Errorf("skipped mapping should show up in diff, got %+v %v", diff, err)
+	}
+}
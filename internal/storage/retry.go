@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// transientReplyPrefixes 表示服务端暂时不可用的错误回复(主从切换、加载数据等)
+var transientReplyPrefixes = []string{"LOADING", "READONLY", "MASTERDOWN", "TRYAGAIN", "CLUSTERDOWN"}
+
+// retryPolicy Redis 瞬时故障重试策略(零值表示不重试)
+type retryPolicy struct {
+	attempts int           // 最大尝试次数(含首次)
+	backoff  time.Duration // 首次重试间隔,之后逐次翻倍
+}
+
+// withRetry 执行 Redis 操作,瞬时故障(连接拒绝、超时等)时按退避间隔重试
+// 逻辑错误(如 redis.Nil、命令错误)直接返回,不重试
+func withRetry[T any](ctx context.Context, p retryPolicy, op func() (T, error)) (T, error) {
+	backoff := p.backoff
+	for attempt := 1; ; attempt++ {
+		result, err := op()
+		if err == nil || attempt >= p.attempts || !isTransient(err) {
+			return result, err
+		}
+
+		log.Printf("⚠️  Redis transient error (attempt %d/%d), retrying in %s: %v", attempt, p.attempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient 判断 Redis 错误是否为可重试的瞬时故障
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// 服务端错误回复: 仅主从切换/加载等状态可重试
+	var reply redis.Error
+	if errors.As(err, &reply) {
+		msg := reply.Error()
+		for _, prefix := range transientReplyPrefixes {
+			if strings.HasPrefix(msg, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, redis.ErrPoolTimeout) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// exec 执行写操作(瞬时故障自动重试)
+func (m *MappingManager) exec(ctx context.Context, op func() error) error {
+	_, err := withRetry(ctx, m.retry, func() (struct{}, error) {
+		return struct{}{}, op()
+	})
+	return err
+}
+
+// mappingExists 检查映射是否存在(瞬时故障自动重试)
+func (m *MappingManager) mappingExists(ctx context.Context, prefix string) (bool, error) {
+	return withRetry(ctx, m.retry, func() (bool, error) {
+		return m.client.HExists(ctx, KeyMappings, prefix).Result()
+	})
+}
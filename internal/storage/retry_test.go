@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// replyError 模拟 Redis 服务端错误回复
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+func (replyError) RedisError()     {}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"redisNil", redis.Nil, false},
+		{"canceled", context.Canceled, false},
+		{"commandError", replyError("ERR wrong number of arguments"), false},
+		{"wrongType", replyError("WRONGTYPE Operation against a key"), false},
+		{"loading", replyError("LOADING Redis is loading the dataset in memory"), true},
+		{"readonly", replyError("READONLY You can't write against a read only replica."), true},
+		{"eof", io.EOF, true},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"wrappedReset", fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{"poolTimeout", redis.ErrPoolTimeout, true},
+		{"logical", errors.New("mapping not found"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("%s: isTransient(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	policy := retryPolicy{attempts: 3, backoff: time.Millisecond}
+
+	// 瞬时故障重试后成功
+	calls := 0
+	got, err := withRetry(ctx, policy, func() (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, io.EOF
+		}
+		return 42, nil
+	})
+	if err != nil || got != 42 || calls != 3 {
+		t.Fatalf("expected success on 3rd attempt, got %d %v (calls=%d)", got, err, calls)
+	}
+
+	// 逻辑错误不重试
+	calls = 0
+	_, err = withRetry(ctx, policy, func() (int, error) {
+		calls++
+		return 0, redis.Nil
+	})
+	if !errors.Is(err, redis.Nil) || calls != 1 {
+		t.Fatalf("expected logical error without retry, got %v (calls=%d)", err, calls)
+	}
+
+	// 超过次数后返回最后的错误
+	calls = 0
+	_, err = withRetry(ctx, policy, func() (int, error) {
+		calls++
+		return 0, io.EOF
+	})
+	if !errors.Is(err, io.EOF) || calls != 3 {
+		t.Fatalf("expected 3 attempts, got %v (calls=%d)", err, calls)
+	}
+
+	// 零值策略不重试
+	calls = 0
+	withRetry(ctx, retryPolicy{}, func() (int, error) {
+		calls++
+		return 0, io.EOF
+	})
+	if calls != 1 {
+		t.Fatalf("expected single attempt with zero policy, got %d", calls)
+	}
+}
+
+func TestMappingManager_RetriesTransientRedisErrors(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	ctx := context.Background()
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+		retry:    retryPolicy{attempts: 5, backoff: 20 * time.Millisecond},
+	}
+
+	// 服务端暂时不可用(LOADING),恢复后写入成功
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		mr.SetError("")
+	}()
+	if err := mm.AddMapping(ctx, "/a", "http://203.0.113.1", nil); err != nil {
+		t.Fatalf("expected AddMapping to succeed after transient error, got %v", err)
+	}
+
+	// 连接中断(故障切换),重启后写入成功
+	mr.Close()
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		mr.Restart()
+	}()
+	if err := mm.UpdateMapping(ctx, "/a", "http://203.0.113.2", nil); err != nil {
+		t.Fatalf("expected UpdateMapping to succeed after reconnect, got %v", err)
+	}
+	if target := mr.HGet(KeyMappings, "/a"); target != "http://203.0.113.2" {
+		t.Errorf("expected updated target in redis, got %q", target)
+	}
+
+	// 删除映射时扩展配置和维护配置在同一事务内删除,瞬时故障后整体重试
+	mr.HSet(KeyMappingOptions, "/a", `{"timeout_ms":1000}`)
+	mr.HSet(KeyMaintenance, "/a", `{"enabled":true}`)
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		mr.SetError("")
+	}()
+	if err := mm.DeleteMapping(ctx, "/a"); err != nil {
+		t.Fatalf("expected DeleteMapping to succeed after transient error, got %v", err)
+	}
+	for _, key := range []string{KeyMappings, KeyMappingOptions, KeyMaintenance} {
+		if fields, _ := mr.HKeys(key); len(fields) != 0 {
+			t.Errorf("expected %s to be cleared, got %v", key, fields)
+		}
+	}
+
+	// 逻辑错误立即返回
+	mr.SetError("ERR induced failure")
+	defer mr.SetError("")
+	mm.retry.backoff = time.Second
+	start := time.Now()
+	if err := mm.AddMapping(ctx, "/b", "http://203.0.113.3", nil); err == nil {
+		t.Fatal("expected logical error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("logical error should not be retried, took %v", elapsed)
+	}
+}
+
+func TestMappingManager_DoesNotRetryVersionIncrement(t *testing.T) {
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	// 统计发出的 INCR 命令数
+	incrs := &commandCounter{name: "incr"}
+	client.AddHook(incrs)
+
+	mm := &MappingManager{
+		client:   client,
+		cache:    make(map[string]string),
+		stopChan: make(chan struct{}),
+		retry:    retryPolicy{attempts: 5, backoff: time.Millisecond},
+	}
+
+	// INCR 非幂等: 瞬时故障直接返回,不重试
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	defer mr.SetError("")
+	if _, err := mm.BumpVersion(context.Background()); err == nil {
+		t.Fatal("expected BumpVersion to fail")
+	}
+	mm.commitChange(context.Background(), "options_updated")
+	if n := incrs.count.Load(); n != 2 {
+		t.Errorf("version increment should not be retried, got %d INCR commands", n)
+	}
+}
+
+// commandCounter 统计指定命令的调用次数
+type commandCounter struct {
+	name  string
+	count atomic.Int32
+}
+
+func (h *commandCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *commandCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == h.name {
+			h.count.Add(1)
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *commandCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
@@ -0,0 +1,21 @@
+package storage
+
+// SyncStats 多实例缓存同步计数,用于确认各实例的 Pub/Sub 同步是否正常
+type SyncStats struct {
+	PubSubMessages int64 `json:"pubsub_messages"` // 收到的 Pub/Sub 通知数(含本实例发出的)
+	Reloads        int64 `json:"reloads"`         // 实际重新加载缓存的次数(版本号变化或强制重载)
+	ReloadFailures int64 `json:"reload_failures"` // 后台或通知触发的重载失败次数
+	Version        int64 `json:"version"`         // 当前缓存的映射版本号
+	LastReload     int64 `json:"last_reload"`     // 最近一次检查/加载的时间(Unix秒)
+}
+
+// SyncStats 返回缓存同步计数
+func (m *MappingManager) SyncStats() SyncStats {
+	return SyncStats{
+		PubSubMessages: m.pubsubMessages.Load(),
+		Reloads:        m.reloads.Load(),
+		ReloadFailures: m.reloadFailures.Load(),
+		Version:        m.version.Load(),
+		LastReload:     m.lastReload.Load(),
+	}
+}
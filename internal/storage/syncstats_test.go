@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMappingManager_SyncStats(t *testing.T) {
+	ctx := context.Background()
+	mr, client := setupTestRedis(t)
+	defer mr.Close()
+	defer client.Close()
+
+	client.HSet(ctx, KeyMappings, "/api", "http://203.0.113.10")
+	client.Set(ctx, KeyMappingsVersion, "1", 0)
+
+	t.Setenv("API_PROXY_REDIS_URL", "redis://"+mr.Addr())
+	t.Setenv("MAPPING_RELOAD_INTERVAL", "1h")
+	writer, err := NewMappingManager(ctx)
+	if err != nil {
+		t.Fatalf("NewMappingManager failed: %v", err)
+	}
+	defer writer.Close()
+	reader, err := NewMappingManager(ctx)
+	if err != nil {
+		t.Fatalf("NewMappingManager failed: %v", err)
+	}
+	defer reader.Close()
+
+	before := reader.SyncStats()
+	if before.Reloads != 1 || before.PubSubMessages != 0 || before.Version != 1 {
+		t.Fatalf("unexpected initial stats %+v", before)
+	}
+
+	if err := writer.AddMapping(ctx, "/new", "http://203.0.113.20", nil); err != nil {
+		t.Fatalf("AddMapping failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := reader.SyncStats()
+		if got.PubSubMessages == 1 && got.Reloads == 2 && got.Version == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("reader did not record the sync: %+v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 写入方同样收到自己的通知,但版本号已是最新,不重复加载
+	for writer.SyncStats().PubSubMessages != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("writer did not receive its own notification: %+v", writer.SyncStats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := writer.SyncStats(); got.Reloads != 1 || got.ReloadFailures != 0 {
+		t.Errorf("writer should not reload its own change: %+v", got)
+	}
+}
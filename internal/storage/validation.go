@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+)
+
+// 映射校验错误类别(可用 errors.Is 判断)
+var (
+	ErrInvalidPrefix = errors.New("invalid prefix")
+	ErrInvalidTarget = errors.New("invalid target")
+	ErrInvalidScheme = errors.New("invalid target scheme")
+	ErrInvalidHost   = errors.New("invalid target host")
+	ErrPrivateTarget = errors.New("target resolves to private address")
+	ErrSelfTarget    = errors.New("target points back at the proxy")
+)
+
+// ValidationError 单项校验问题
+type ValidationError struct {
+	Kind    error // 类别哨兵错误
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Kind
+}
+
+// ValidationErrors 一次校验发现的全部问题
+type ValidationErrors []error
+
+func (v *ValidationErrors) add(kind error, message string) {
+	*v = append(*v, &ValidationError{Kind: kind, Message: message})
+}
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, err := range v {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap 支持 errors.Is/As 匹配任一问题
+func (v ValidationErrors) Unwrap() []error {
+	return v
+}
@@ -3,21 +3,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 
 	"api-proxy/internal/admin"
+	"api-proxy/internal/analytics"
+	"api-proxy/internal/certs"
+	"api-proxy/internal/config"
+	"api-proxy/internal/health"
+	"api-proxy/internal/importer"
+	"api-proxy/internal/mapping"
 	"api-proxy/internal/middleware"
+	"api-proxy/internal/pages"
+	"api-proxy/internal/plugin"
 	"api-proxy/internal/proxy"
+	"api-proxy/internal/redact"
 	"api-proxy/internal/stats"
 	"api-proxy/internal/storage"
 )
@@ -49,8 +62,37 @@ func main() {
 	}
 	defer mappingManager.Close()
 
-	// 创建统计收集器
-	statsCollector := stats.NewCollector(mappingManager.GetClient())
+	// 可选: 多实例部署时通过Redis锁选举领导者,仅领导者执行单例后台任务(周期保存统计、周期导入映射)
+	var leader *storage.LeaderElection
+	var isLeader func() bool
+	if config.Bool("LEADER_ELECTION", false) {
+		leader = storage.NewLeaderElection(mappingManager.GetClient(), "background", config.Duration("LEADER_LOCK_TTL", 15*time.Second))
+		leader.Start()
+		defer leader.Stop()
+		isLeader = leader.IsLeader
+	}
+
+	// 可选: 从 MAPPINGS_URL 导入映射(启动时同步一次,按 MAPPINGS_URL_INTERVAL 周期刷新)
+	stopImporter := make(chan struct{})
+	var importerWG sync.WaitGroup
+	if url := config.String("MAPPINGS_URL", ""); url != "" {
+		replace := config.String("MAPPINGS_URL_MODE", "merge") == "replace"
+		imp := importer.New(url, mappingManager, replace)
+		imp.SyncAndLog()
+		if interval := config.Duration("MAPPINGS_URL_INTERVAL", 0); interval > 0 {
+			jitter := config.Duration("MAPPINGS_URL_JITTER", 0)
+			importerWG.Go(func() {
+				imp.Run(stopImporter, interval, jitter, isLeader)
+			})
+		}
+	}
+
+	// 创建统计收集器(可通过 STATS_REDIS_URL 使用独立的Redis持久化统计)
+	statsRedis := statsRedisClient(ctx, config.String("STATS_REDIS_URL", ""), mappingManager.GetClient())
+	if statsRedis != mappingManager.GetClient() {
+		defer statsRedis.Close()
+	}
+	statsCollector := stats.NewCollector(statsRedis)
 	defer statsCollector.Close()
 
 	// 从Redis恢复历史统计数据
@@ -58,42 +100,153 @@ func main() {
 		log.Printf("⚠️  从Redis加载历史数据失败: %v", err)
 	}
 
+	// 可选: 周期保存统计到Redis(间隔从上一轮结束时计算,不会重叠执行)
+	if interval := config.Duration("STATS_SAVE_INTERVAL", 0); interval > 0 {
+		if leader != nil {
+			statsCollector.SetLeader(leader)
+		}
+		statsCollector.StartAutoSave(interval, config.Duration("STATS_SAVE_JITTER", 0))
+	}
+
+	// 可选: 以 StatsD/DogStatsD 格式周期发送关键指标
+	if addr := config.String("STATSD_ADDR", ""); addr != "" {
+		exporter, err := stats.NewStatsdExporter(statsCollector, addr)
+		if err != nil {
+			log.Printf("⚠️  StatsD 导出未启用: %v", err)
+		} else {
+			exporter.Start()
+			defer exporter.Stop()
+			log.Printf("📈 StatsD 指标发送到 %s", addr)
+		}
+	}
+
 	// 创建透明代理（传入统计收集器，只记录代理请求）
+	statsEnabled := config.Bool("ENABLE_STATS", true)
 	var collector proxy.MetricsCollector
-	if os.Getenv("ENABLE_STATS") != "false" {
+	if statsEnabled {
 		collector = statsCollector
 	}
 	transparentProxy := proxy.NewTransparentProxy(mappingManager, collector)
+	transparentProxy.SetResponseStore(storage.NewIdempotencyStore(mappingManager.GetClient()))
+	transparentProxy.SetMaintenanceSource(mappingManager)
+	transparentProxy.SetQuotaStore(storage.NewQuotaStore(mappingManager.GetClient()))
+
+	// 可选: 主动探测多目标映射的各个目标,连续失败的目标暂停转发直至恢复
+	upstreamChecker := health.NewChecker(mappingManager, nil, health.CheckerConfig{
+		Interval:  config.Duration("HEALTH_CHECK_INTERVAL", 0),
+		Path:      config.String("HEALTH_CHECK_PATH", health.DefaultCheckPath),
+		Threshold: config.Int("HEALTH_CHECK_THRESHOLD", health.DefaultCheckThreshold),
+		Timeout:   config.Duration("HEALTH_CHECK_TIMEOUT", health.DefaultCheckTimeout),
+	})
+	if upstreamChecker != nil {
+		upstreamChecker.Start()
+		defer upstreamChecker.Stop()
+		transparentProxy.SetHealthSource(upstreamChecker)
+		log.Println("💓 多目标主动健康检查已启用")
+	}
+
+	// 可选: 将请求元数据(不含请求/响应体)批量POST到分析webhook,缓冲区满时丢弃并计数
+	var analyticsExporter *analytics.Exporter
+	if url := config.String("ANALYTICS_WEBHOOK_URL", ""); url != "" {
+		analyticsExporter = analytics.NewExporter(
+			analytics.NewWebhookSink(url),
+			config.Int("ANALYTICS_BUFFER_SIZE", 10000),
+			config.Int("ANALYTICS_BATCH_SIZE", 100),
+			config.Duration("ANALYTICS_FLUSH_INTERVAL", 5*time.Second),
+		)
+		defer analyticsExporter.Close()
+		transparentProxy.SetExporter(analyticsExporter)
+		log.Println("📤 请求元数据导出已启用")
+	}
+
+	// 开启 audit_log 的映射将请求元数据写入 Redis Stream(apiproxy:audit:<前缀>,按 AUDIT_STREAM_MAXLEN 截断)
+	auditExporter := analytics.NewExporter(
+		storage.NewAuditStreamSink(mappingManager.GetClient(), int64(config.Int("AUDIT_STREAM_MAXLEN", storage.DefaultAuditStreamMaxLen))),
+		config.Int("ANALYTICS_BUFFER_SIZE", 10000),
+		config.Int("ANALYTICS_BATCH_SIZE", 100),
+		config.Duration("AUDIT_FLUSH_INTERVAL", time.Second),
+	)
+	defer auditExporter.Close()
+	transparentProxy.SetAuditLog(auditExporter)
+
+	// 配置了 plugin 的映射在转发前由 WASM 插件转换请求(沙箱运行,按 PLUGIN_TIMEOUT 限时)
+	pluginRuntime := plugin.NewRuntime(context.Background(), plugin.Config{
+		DefaultTimeout: config.Duration("PLUGIN_TIMEOUT", plugin.DefaultTimeout),
+		MemoryLimitMB:  config.Int("PLUGIN_MEMORY_LIMIT_MB", plugin.DefaultMemoryLimitMB),
+	})
+	defer pluginRuntime.Close(context.Background())
+	transparentProxy.SetTransformer(pluginRuntime)
 
 	// 创建路由
 	r := gin.New()
 
-	// 添加日志中间件
-	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("[%s] %s - \"%s %s %s\" %d %s %d %s \"%s\"\n",
-			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
-			param.ClientIP,
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.BodySize,
-			param.ErrorMessage,
-			param.Request.UserAgent(),
-		)
-	}))
+	// 客户端IP: 仅信任 TRUSTED_PROXIES(IP或CIDR)转发的 X-Forwarded-For/X-Real-IP,未配置时使用连接地址
+	if err := r.SetTrustedProxies(config.List("TRUSTED_PROXIES")); err != nil {
+		log.Fatalf("❌ TRUSTED_PROXIES 无效: %v", err)
+	}
+	// 解析后的客户端IP写入请求上下文(按 API Key 分桶在未携带 Key 时回退为该IP)
+	r.Use(middleware.ClientIP())
+
+	// 请求ID: 沿用或生成,随请求转发到上游并在响应头中回显(头名称由 REQUEST_ID_HEADER 配置)
+	r.Use(middleware.RequestID(config.String("REQUEST_ID_HEADER", middleware.DefaultRequestIDHeader)))
+
+	// 添加日志中间件（查询参数中的密钥脱敏后输出）
+	redactor := redact.Default(config.List("LOG_REDACT_PARAMS"))
+	r.Use(gin.LoggerWithFormatter(accessLogFormatter(redactor)))
+
+	// 可选: 启动时探测所有映射目标的可达性(后台执行,不阻塞启动)
+	if config.Bool("PROBE_TARGETS_ON_START", false) {
+		timeout := config.Duration("PROBE_TARGETS_TIMEOUT", 3*time.Second)
+		go logTargetProbe(mappingManager.GetAllMappings(), timeout, redactor)
+	}
+
+	// 可选: 启动时预热与映射目标的连接(后台执行,WARMUP_CONCURRENCY 限制同时进行的预热请求数)
+	if config.Bool("WARMUP_ON_START", false) {
+		concurrency := config.Int("WARMUP_CONCURRENCY", 4)
+		timeout := config.Duration("WARMUP_TIMEOUT", 5*time.Second)
+		go func() {
+			result := transparentProxy.Warmup(context.Background(), concurrency, timeout)
+			log.Printf("🔥 连接预热完成: %d 个目标已建立连接, %d 个失败, %d 个正则映射跳过",
+				result.Warmed, result.Failed, result.Skipped)
+		}()
+	}
 
 	// 添加恢复中间件
 	r.Use(gin.Recovery())
 
-	// 添加速率限制中间件（1000 req/s）
-	rateLimiter := middleware.NewRateLimiter(1000)
+	// 可选的按客户端IP限流(在全局限流之前,单个客户端超限的请求不消耗全局配额)
+	// 客户端IP仅信任 TRUSTED_PROXIES 转发的 X-Forwarded-For/X-Real-IP
+	// RATE_LIMIT_DISTRIBUTED=true 时按客户端限流改用Redis令牌桶,多实例共用配额
+	distributedLimit := config.Bool("RATE_LIMIT_DISTRIBUTED", false)
+	if perIP := config.Int("RATE_LIMIT_PER_IP", 0); perIP > 0 {
+		burst := config.Int("RATE_LIMIT_PER_IP_BURST", perIP*2)
+		if distributedLimit {
+			r.Use(middleware.NewRedisRateLimiter(mappingManager.GetClient(), perIP, burst).Middleware())
+		} else {
+			r.Use(middleware.NewPerIPRateLimiter(perIP, burst).Middleware())
+		}
+	}
+
+	// 添加速率限制中间件（1000 req/s，突发容量默认为速率的2倍）
+	rateLimiter := middleware.NewRateLimiterWithBurst(1000, config.Int("RATE_LIMIT_BURST", 2000))
 	r.Use(rateLimiter.Middleware())
 
+	// 可选的按客户端限流: 按 API Key(API_KEY_SOURCE)分桶,未携带时按客户端IP
+	if perKey := config.Int("RATE_LIMIT_PER_KEY", 0); perKey > 0 {
+		burst := config.Int("RATE_LIMIT_PER_KEY_BURST", perKey*2)
+		if distributedLimit {
+			keyedLimiter := middleware.NewRedisRateLimiter(mappingManager.GetClient(), perKey, burst)
+			keyedLimiter.SetKey(transparentProxy.ClientBucket)
+			r.Use(keyedLimiter.Middleware())
+		} else {
+			r.Use(middleware.NewKeyedRateLimiter(perKey, burst, transparentProxy.ClientBucket).Middleware())
+		}
+	}
+
 	// 基础路由
-	r.GET("/", handleIndex)
-	r.GET("/index.html", handleIndex)
+	basePath := middleware.NormalizeBasePath(config.String("BASE_PATH", ""))
+	r.GET("/", indexHandler(basePath))
+	r.GET("/index.html", indexHandler(basePath))
 	r.GET("/robots.txt", handleRobotsTxt)
 	r.GET("/favicon.ico", func(c *gin.Context) {
 		c.File("web/static/images/favicon.svg")
@@ -102,45 +255,126 @@ func main() {
 	// 静态文件服务
 	r.Static("/static", "./web/static")
 
+	// 进行中的代理请求(关闭时优雅排空)
+	drainer := middleware.NewDrainer()
+
 	// 统计API路由
 	r.GET("/stats", func(c *gin.Context) {
-		stats := statsCollector.GetStats()
-		requests := statsCollector.GetRequests()
+		query, err := parseRequestQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		endpoints := statsCollector.GetStats()
+		requests, requestsTotal := stats.QueryRequests(statsCollector.GetRequests(), query)
 		performance := statsCollector.GetPerformanceMetrics()
 
-		c.JSON(200, gin.H{
+		response := gin.H{
 			"total":          statsCollector.GetRequestCount(),
 			"errors":         statsCollector.GetErrorCount(),
 			"dropped_events": statsCollector.GetDroppedEvents(),
 			"avg_response":   statsCollector.GetAverageResponseTime().String(),
-			"endpoints":      stats,
-			"requests":       requests,    // 新增:时间序列数据
+			"status_classes": statsCollector.GetStatusClassCounts(),
+			"events":         statsCollector.GetEventCounts(),
+			"slo":            statsCollector.GetSLOStats(),
+			"request_sizes":  statsCollector.GetRequestSizeStats(),
+			"size_sample":    statsCollector.SizeSampleRate(), // request_sizes 的计数按此采样率记录
+			"endpoints":      endpoints,
+			"requests":       requests, // 新增:时间序列数据(可按 since/until/offset/limit 过滤分页)
+			"requests_total": requestsTotal,
 			"performance":    performance, // 新增:性能指标
-		})
+			"drain":          drainer.Stats(),
+			"sync":           mappingManager.SyncStats(), // 多实例缓存同步(Pub/Sub 通知与重载次数)
+		}
+		if analyticsExporter != nil {
+			response["analytics"] = analyticsExporter.Stats()
+		}
+		c.JSON(200, response)
 	})
 
+	// Prometheus 指标（请求计数与请求/响应大小分布）
+	r.GET("/metrics", func(c *gin.Context) {
+		c.Header("Content-Type", stats.PrometheusContentType)
+		if err := statsCollector.WritePrometheus(c.Writer); err != nil {
+			log.Printf("⚠️  输出指标失败: %v", err)
+		}
+	})
+
+	// 就绪检查（各项依赖检查可通过 READYZ_DISABLED_CHECKS 关闭）
+	readiness := health.NewRegistry(config.Duration("READYZ_CHECK_TIMEOUT", 2*time.Second))
+	readiness.Register("redis", func(ctx context.Context) error {
+		return mappingManager.GetClient().Ping(ctx).Err()
+	})
+	readiness.Register("mappings", health.MappingsCheck(mappingManager.Count))
+	readiness.Register("draining", func(ctx context.Context) error {
+		if drainer.Draining() {
+			return errors.New("shutting down")
+		}
+		return nil
+	})
+	if probeURL := config.String("READYZ_UPSTREAM_URL", ""); probeURL != "" {
+		readiness.Register("upstream", health.HTTPCheck(http.DefaultClient, probeURL))
+	}
+	for _, name := range config.List("READYZ_DISABLED_CHECKS") {
+		readiness.SetEnabled(name, false)
+	}
+	r.GET("/readyz", readiness.Handler())
+
 	// 管理路由（依赖注入，无全局变量）
 	adminHandler := admin.NewHandler(mappingManager)
+	adminHandler.SetMaintenance(mappingManager)
+	if upstreamChecker != nil {
+		adminHandler.SetUpstreamHealth(upstreamChecker)
+	}
+	if statsEnabled {
+		adminHandler.SetTrafficStats(statsCollector)
+	}
 	adminHandler.SetupRoutes(r)
 
 	// API代理路由 - 使用通配符动态匹配所有路径
 	// 注意: 必须放在最后,避免覆盖其他路由
-	r.NoRoute(func(c *gin.Context) {
+
+	// 可选的HTML错误页模板(浏览器客户端),API客户端仍返回JSON
+	var errorPages *pages.ErrorPages
+	if dir := config.String("ERROR_PAGES_DIR", ""); dir != "" {
+		errorPages, err = pages.LoadErrorPages(dir)
+		if err != nil {
+			log.Fatalf("❌ 加载错误页模板失败: %v", err)
+		}
+		log.Printf("📄 已加载 %d 个错误页模板: %s", errorPages.Len(), dir)
+	}
+	pathLimits := pathLimits{
+		maxLength:   config.Int("MAX_PATH_LENGTH", 0),
+		maxSegments: config.Int("MAX_PATH_SEGMENTS", 0),
+	}
+	r.NoRoute(drainer.Handler(), func(c *gin.Context) {
 		path := c.Request.URL.Path
 
-		prefixes := mappingManager.GetPrefixes()
-		if prefix, ok := findMatchingPrefix(path, prefixes); ok {
+		if !pathLimits.check(c) {
+			return
+		}
+
+		if prefix, ok := mappingManager.MatchPrefix(path); ok {
+			if statsEnabled && !mappingManager.GetOptions(prefix).DisableStats {
+				defer trackClient(c, statsCollector, prefix)()
+				statsCollector.RecordAPIKey(transparentProxy.APIKey(c.Request))
+			}
 			remainingPath := remainingPathAfterPrefix(path, prefix)
-			if err := transparentProxy.ProxyRequest(c.Writer, c.Request, prefix, remainingPath); err != nil {
-				log.Printf("Proxy error for %s: %v", path, err)
-				c.JSON(500, gin.H{"error": err.Error()})
+			// 上游各阶段耗时写入访问日志
+			ctx, upstreamTiming := proxy.WithTimingRecorder(c.Request.Context())
+			c.Request = c.Request.WithContext(ctx)
+			err := transparentProxy.ProxyRequest(c.Writer, c.Request, prefix, remainingPath)
+			c.Set(upstreamTimingKey, upstreamTiming())
+			if err != nil {
+				log.Printf("Proxy error for %s [%s]: %s", path, middleware.GetRequestID(c), redactor.Error(err))
+				writeProxyError(c, err, errorPages)
 				return
 			}
 			return
 		}
 
 		// 没有匹配的映射
-		c.JSON(404, gin.H{
+		errorPages.Render(c, http.StatusNotFound, gin.H{
 			"error":   "No mapping found for this path",
 			"path":    path,
 			"hint":    "Use POST /api/mappings to add a mapping",
@@ -149,28 +383,58 @@ func main() {
 	})
 
 	// 启动服务器
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
-	}
+	port := config.String("PORT", "8000")
 
 	log.Printf("🚀 API代理服务器已启动 端口:%s", port)
 	log.Printf("📊 访问 http://localhost:%s 查看统计信息", port)
 	log.Printf("🔧 访问 http://localhost:%s/admin 管理API映射", port)
 
-	if os.Getenv("ENABLE_STATS") != "false" {
+	if statsEnabled {
 		log.Printf("📈 统计功能: 已启用 (可通过 ENABLE_STATS=false 禁用)")
 	}
 
 	// 使用自定义HTTP服务器
+	// 部署在路径前缀下时(BASE_PATH),路由前去掉前缀
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: r,
+		Handler: middleware.StripBasePath(basePath, r),
+	}
+
+	// 配置证书时直接提供HTTPS(SIGHUP或文件变化时热加载证书)
+	certFile, keyFile := config.String("TLS_CERT_FILE", ""), config.String("TLS_KEY_FILE", "")
+	var reloader *certs.Reloader
+	if certFile != "" && keyFile != "" {
+		reloader, err = certs.NewReloader(certFile, keyFile)
+		if err != nil {
+			log.Fatalf("❌ 加载TLS证书失败: %v", err)
+		}
+		defer reloader.Stop()
+		reloader.Watch(config.Duration("TLS_WATCH_INTERVAL", 0))
+		srv.TLSConfig = reloader.TLSConfig()
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reloader.Reload(); err != nil {
+					log.Printf("⚠️  证书重载失败,继续使用旧证书: %v", err)
+				} else {
+					log.Println("🔐 已通过SIGHUP重新加载证书")
+				}
+			}
+		}()
+		log.Println("🔐 HTTPS 已启用")
 	}
 
 	// 启动服务器
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if reloader != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("服务器启动失败: %v", err)
 		}
 	}()
@@ -182,26 +446,46 @@ func main() {
 
 	log.Println("Shutting down...")
 
-	// 5 秒内完成所有关闭操作
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// 各关闭阶段使用独立超时,排空耗尽时限后HTTP服务器仍有完整的关闭时间
+	// 优雅排空: 就绪检查失败以摘除实例,等待进行中的代理请求完成(期间 /stats 可查看进度)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), config.Duration("DRAIN_TIMEOUT", 5*time.Second))
+	drainer.Drain(drainCtx, config.Duration("DRAIN_LOG_INTERVAL", time.Second))
+	cancelDrain()
 
 	// 优雅关闭HTTP服务器
-	if err := srv.Shutdown(ctx); err != nil {
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), config.Duration("SHUTDOWN_TIMEOUT", 5*time.Second))
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
+	cancelShutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 输出本次运行的统计摘要
+	if config.Bool("LOG_SHUTDOWN_SUMMARY", true) {
+		log.Printf("📊 Run summary: %s", statsCollector.Summary())
+	}
+
+	close(stopImporter)
+	importerWG.Wait()
 
-	// 保存统计（best effort，不影响关闭）
-	if err := statsCollector.SaveToRedis(ctx); err != nil {
-		log.Printf("Stats save error: %v", err)
+	// 保存统计（best effort，不影响关闭；先等待进行中的周期保存结束；启用领导者选举时仅领导者保存）
+	statsCollector.Close()
+	if leader == nil || leader.IsLeader() {
+		if err := statsCollector.SaveToRedis(ctx); err != nil {
+			log.Printf("Stats save error: %v", err)
+		}
 	}
 
 	log.Println("Shutdown complete")
 }
 
-// handleIndex 处理首页
-func handleIndex(c *gin.Context) {
-	c.File("web/templates/index.html")
+// indexHandler 处理首页(页面链接基于部署前缀)
+func indexHandler(basePath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pages.Serve(c, "web/templates/index.html", basePath)
+	}
 }
 
 // handleRobotsTxt 处理robots.txt
@@ -210,36 +494,173 @@ func handleRobotsTxt(c *gin.Context) {
 	c.String(200, "User-agent: *\nDisallow: /\n")
 }
 
-// findMatchingPrefix 返回最先匹配 path 的前缀(假设传入按长度排序)
-func findMatchingPrefix(path string, prefixes []string) (string, bool) {
-	for _, prefix := range prefixes {
-		if matchesPrefix(path, prefix) {
-			return prefix, true
+// upstreamTimingKey gin上下文中本次请求上游各阶段耗时的键(访问日志使用)
+const upstreamTimingKey = "upstream_timing"
+
+// accessLogFormatter 访问日志格式(查询参数按规则脱敏,上游各阶段耗时在请求ID之前,末尾为请求ID)
+func accessLogFormatter(redactor *redact.Redactor) gin.LogFormatter {
+	return func(param gin.LogFormatterParams) string {
+		requestID, _ := param.Keys[middleware.RequestIDKey].(string)
+		if requestID == "" {
+			requestID = "-"
 		}
+		timing, _ := param.Keys[upstreamTimingKey].(*analytics.UpstreamTiming)
+		return fmt.Sprintf("[%s] %s - \"%s %s %s\" %d %s %d %s \"%s\" %s %s\n",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.ClientIP,
+			param.Method,
+			redactor.URL(param.Path),
+			param.Request.Proto,
+			param.StatusCode,
+			param.Latency,
+			param.BodySize,
+			param.ErrorMessage,
+			param.Request.UserAgent(),
+			formatUpstreamTiming(timing),
+			requestID,
+		)
 	}
-	return "", false
 }
 
-func matchesPrefix(path, prefix string) bool {
-	if prefix == "" {
-		return false
+// formatUpstreamTiming 以 dns=…ms connect=…ms tls=…ms ttfb=…ms total=…ms 输出上游耗时
+// 复用连接时省略 DNS/连接/TLS 阶段,未发出上游请求时为 "-"
+func formatUpstreamTiming(t *analytics.UpstreamTiming) string {
+	if t == nil {
+		return "-"
 	}
-	if prefix == "/" {
-		return true
+	var b strings.Builder
+	for _, phase := range []struct {
+		name string
+		ms   float64
+	}{{"dns", t.DNSMs}, {"connect", t.ConnectMs}, {"tls", t.TLSMs}, {"ttfb", t.TTFBMs}} {
+		if phase.ms > 0 {
+			fmt.Fprintf(&b, "%s=%.1fms ", phase.name, phase.ms)
+		}
+	}
+	fmt.Fprintf(&b, "total=%.1fms", t.TotalMs)
+	return b.String()
+}
+
+// trackClient 记录客户端请求(客户端IP按 TRUSTED_PROXIES 解析),
+// 返回的函数在响应完成后调用,4xx/5xx 计入该客户端的错误数
+func trackClient(c *gin.Context, collector *stats.Collector, prefix string) func() {
+	ip := c.ClientIP()
+	collector.RecordClient(ip, prefix)
+	return func() {
+		if c.Writer.Status() >= 400 {
+			collector.RecordClientError(ip, prefix)
+		}
 	}
-	if !strings.HasPrefix(path, prefix) {
+}
+
+// pathLimits 代理路径长度与层级限制(0表示不限制)
+type pathLimits struct {
+	maxLength   int
+	maxSegments int
+}
+
+// allow 判断路径是否在限制范围内
+func (l pathLimits) allow(path string) bool {
+	if l.maxLength > 0 && len(path) > l.maxLength {
 		return false
 	}
-	if len(path) == len(prefix) {
-		return true
+	if l.maxSegments > 0 && strings.Count(strings.Trim(path, "/"), "/")+1 > l.maxSegments {
+		return false
 	}
-	if strings.HasSuffix(prefix, "/") {
+	return true
+}
+
+// check 超出限制时返回 414 URI Too Long
+func (l pathLimits) check(c *gin.Context) bool {
+	if l.allow(c.Request.URL.Path) {
 		return true
 	}
-	return path[len(prefix)] == '/'
+	c.JSON(http.StatusRequestURITooLong, gin.H{"error": "Request path exceeds configured limits"})
+	return false
+}
+
+// logTargetProbe 探测映射目标并输出可达性汇总(不可达的目标逐条列出)
+func logTargetProbe(mappings map[string]string, timeout time.Duration, redactor *redact.Redactor) {
+	client := &http.Client{
+		// 只关心目标能否响应,不跟随重定向
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	summary := health.ProbeTargets(context.Background(), client, mappings, timeout)
+	log.Printf("🔍 目标可达性探测: %s", summary)
+	for _, probe := range summary.Unreachable {
+		log.Printf("⚠️  目标不可达: %s -> %s (%s)", probe.Prefix, redactor.URL(probe.Target), probe.Error)
+	}
+}
+
+// statsRedisClient 返回统计持久化使用的Redis客户端
+// 未配置或连接失败时复用映射存储的客户端
+func statsRedisClient(ctx context.Context, redisURL string, fallback *redis.Client) *redis.Client {
+	if redisURL == "" {
+		return fallback
+	}
+	client, err := storage.NewRedisClient(ctx, redisURL)
+	if err != nil {
+		log.Printf("⚠️  统计Redis连接失败,复用映射存储: %v", err)
+		return fallback
+	}
+	log.Println("📊 统计数据使用独立Redis持久化")
+	return client
+}
+
+// parseRequestQuery 解析 /stats 时间序列的过滤与分页参数
+// since/until 为Unix秒(含),offset/limit 为非负整数,未提供时不限制
+func parseRequestQuery(c *gin.Context) (stats.RequestQuery, error) {
+	var query stats.RequestQuery
+	for _, param := range []struct {
+		name  string
+		value *int64
+	}{{"since", &query.Since}, {"until", &query.Until}} {
+		if raw := c.Query(param.name); raw != "" {
+			v, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || v < 0 {
+				return query, fmt.Errorf("invalid %s: must be a non-negative unix timestamp", param.name)
+			}
+			*param.value = v
+		}
+	}
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"offset", &query.Offset}, {"limit", &query.Limit}} {
+		if raw := c.Query(param.name); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil || v < 0 {
+				return query, fmt.Errorf("invalid %s: must be a non-negative integer", param.name)
+			}
+			*param.value = v
+		}
+	}
+	return query, nil
+}
+
+// writeProxyError 将代理错误转换为客户端响应
+// proxy.Error 携带状态码和 Retry-After,其余错误统一返回500
+// 配置了错误页模板时浏览器客户端获得HTML页面
+func writeProxyError(c *gin.Context, err error, errorPages *pages.ErrorPages) {
+	status := http.StatusInternalServerError
+	var proxyErr *proxy.Error
+	if errors.As(err, &proxyErr) {
+		status = proxyErr.StatusCode
+		if seconds := proxyErr.RetryAfterSeconds(); seconds > 0 {
+			c.Header("Retry-After", strconv.Itoa(seconds))
+		}
+	}
+	errorPages.Render(c, status, gin.H{"error": err.Error()})
 }
 
 func remainingPathAfterPrefix(path, prefix string) string {
+	if mapping.IsPattern(prefix) {
+		end, ok := mapping.MatchPattern(prefix, path)
+		if !ok {
+			return ""
+		}
+		prefix = path[:end]
+	}
 	if len(path) < len(prefix) {
 		return ""
 	}
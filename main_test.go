@@ -1,41 +1,25 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
 
-func TestFindMatchingPrefixPrefersLongest(t *testing.T) {
-	path := "/openai/v1/chat"
-	prefixes := []string{"/openai/v1", "/openai"}
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 
-	match, ok := findMatchingPrefix(path, prefixes)
-	if !ok {
-		t.Fatal("expected to find matching prefix")
-	}
-	if match != "/openai/v1" {
-		t.Fatalf("expected /openai/v1, got %s", match)
-	}
-}
-
-func TestMatchesPrefix(t *testing.T) {
-	tests := []struct {
-		name    string
-		path    string
-		prefix  string
-		expects bool
-	}{
-		{"exact", "/api", "/api", true},
-		{"nested", "/api/v1", "/api", true},
-		{"boundary", "/api2", "/api", false},
-		{"trailingSlash", "/api/v1", "/api/", true},
-		{"root", "/anything", "/", true},
-		{"noMatch", "/foo", "/bar", false},
-	}
-
-	for _, tt := range tests {
-		if got := matchesPrefix(tt.path, tt.prefix); got != tt.expects {
-			t.Fatalf("%s: expected %v got %v", tt.name, tt.expects, got)
-		}
-	}
-}
+	"api-proxy/internal/analytics"
+	"api-proxy/internal/middleware"
+	"api-proxy/internal/proxy"
+	"api-proxy/internal/redact"
+	"api-proxy/internal/stats"
+)
 
 func TestRemainingPathAfterPrefix(t *testing.T) {
 	tests := []struct {
@@ -48,6 +32,7 @@ func TestRemainingPathAfterPrefix(t *testing.T) {
 		{"root", "/foo/bar", "/", "/foo/bar"},
 		{"trailingSlash", "/api/v1", "/api/", "/v1"},
 		{"exact", "/api", "/api", ""},
+		{"pattern", "/t/acme/api/x", "~^/t/(?P<tenant>[^/]+)/api", "/x"},
 	}
 
 	for _, tt := range tests {
@@ -56,3 +41,265 @@ func TestRemainingPathAfterPrefix(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteProxyError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		retryAfter string
+	}{
+		{"generic", errors.New("boom"), http.StatusInternalServerError, ""},
+		{"circuitOpen", &proxy.Error{StatusCode: http.StatusServiceUnavailable, RetryAfter: 1500 * time.Millisecond, Err: proxy.ErrCircuitOpen}, http.StatusServiceUnavailable, "2"},
+		{"wrapped", fmt.Errorf("wrapped: %w", &proxy.Error{StatusCode: http.StatusBadGateway, Err: errors.New("bad")}), http.StatusBadGateway, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		writeProxyError(c, tt.err, nil)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s: expected status %d got %d", tt.name, tt.status, w.Code)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+			t.Fatalf("%s: expected Retry-After %q got %q", tt.name, tt.retryAfter, got)
+		}
+	}
+}
+
+func TestStatsRedisClient_Separate(t *testing.T) {
+	mappingRedis := miniredis.RunT(t)
+	statsRedis := miniredis.RunT(t)
+
+	mappingClient := redis.NewClient(&redis.Options{Addr: mappingRedis.Addr()})
+	defer mappingClient.Close()
+
+	ctx := context.Background()
+	client := statsRedisClient(ctx, "redis://"+statsRedis.Addr()+"/0", mappingClient)
+	if client == mappingClient {
+		t.Fatal("expected a dedicated stats client")
+	}
+	defer client.Close()
+
+	collector := stats.NewCollector(client)
+	collector.RecordRequest("/api")
+	if err := collector.SaveToRedis(ctx); err != nil {
+		t.Fatalf("SaveToRedis failed: %v", err)
+	}
+
+	if !statsRedis.Exists("stats:request_count") {
+		t.Error("stats should be persisted to the dedicated Redis")
+	}
+	if mappingRedis.Exists("stats:request_count") {
+		t.Error("stats should not be written to the mapping Redis")
+	}
+}
+
+func TestStatsRedisClient_Fallback(t *testing.T) {
+	fallback := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+	defer fallback.Close()
+
+	ctx := context.Background()
+	if statsRedisClient(ctx, "", fallback) != fallback {
+		t.Error("empty URL should reuse the mapping client")
+	}
+	if statsRedisClient(ctx, "http://invalid", fallback) != fallback {
+		t.Error("invalid URL should reuse the mapping client")
+	}
+}
+
+func TestPathLimits(t *testing.T) {
+	limits := pathLimits{maxLength: 20, maxSegments: 3}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api/v1/chat", true},
+		{"/", true},
+		{"/a/b/c/", true},
+		{"/a/b/c/d", false},
+		{"/api/" + strings.Repeat("x", 20), false},
+	}
+	for _, tt := range tests {
+		if got := limits.allow(tt.path); got != tt.want {
+			t.Errorf("allow(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if !(pathLimits{}).allow("/" + strings.Repeat("a/", 1000)) {
+		t.Error("zero limits should not restrict paths")
+	}
+}
+
+func TestPathLimits_Check(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limits := pathLimits{maxSegments: 2}
+
+	r := gin.New()
+	r.NoRoute(func(c *gin.Context) {
+		if !limits.check(c) {
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 within limits, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/too/deep", nil))
+	if w.Code != http.StatusRequestURITooLong {
+		t.Fatalf("expected 414 over limits, got %d", w.Code)
+	}
+}
+
+func TestAccessLogFormatter_Redacts(t *testing.T) {
+	formatter := accessLogFormatter(redact.Default(nil))
+	req := httptest.NewRequest("GET", "/v1/chat?api_key=secret&model=x", nil)
+
+	line := formatter(gin.LogFormatterParams{
+		Request:    req,
+		TimeStamp:  time.Now(),
+		Method:     "GET",
+		Path:       "/v1/chat?api_key=secret&model=x",
+		StatusCode: 200,
+		Keys:       map[any]any{middleware.RequestIDKey: "req-42"},
+	})
+	if strings.Contains(line, "secret") || !strings.Contains(line, "/v1/chat?api_key=***&model=x") {
+		t.Fatalf("expected redacted access log, got %q", line)
+	}
+	if !strings.HasSuffix(line, " req-42\n") {
+		t.Errorf("expected request id at end of access log, got %q", line)
+	}
+}
+
+func TestAccessLogFormatter_UpstreamTiming(t *testing.T) {
+	formatter := accessLogFormatter(redact.Default(nil))
+	params := gin.LogFormatterParams{
+		Request:   httptest.NewRequest("GET", "/v1/chat", nil),
+		TimeStamp: time.Now(),
+		Keys: map[any]any{
+			middleware.RequestIDKey: "req-42",
+			upstreamTimingKey:       &analytics.UpstreamTiming{DNSMs: 1.5, ConnectMs: 3, TTFBMs: 20.25, TotalMs: 42},
+		},
+	}
+	line := formatter(params)
+	if !strings.HasSuffix(line, " dns=1.5ms connect=3.0ms ttfb=20.2ms total=42.0ms req-42\n") {
+		t.Errorf("expected upstream timings before request id, got %q", line)
+	}
+
+	// 未发出上游请求时输出占位符
+	delete(params.Keys, upstreamTimingKey)
+	if line := formatter(params); !strings.HasSuffix(line, " - req-42\n") {
+		t.Errorf("expected placeholder without upstream timing, got %q", line)
+	}
+}
+
+func TestBasePathRouting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, base := range []string{"", "/proxy-service"} {
+		r := gin.New()
+		r.GET("/stats", func(c *gin.Context) { c.String(http.StatusOK, "stats") })
+		r.GET("/api/mappings", func(c *gin.Context) { c.String(http.StatusOK, "mappings") })
+		r.NoRoute(func(c *gin.Context) { c.String(http.StatusOK, "proxy "+c.Request.URL.Path) })
+		handler := middleware.StripBasePath(base, r)
+
+		tests := map[string]string{
+			base + "/stats":        "stats",
+			base + "/api/mappings": "mappings",
+			base + "/openai/v1":    "proxy /openai/v1",
+		}
+		for path, want := range tests {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+			if w.Code != http.StatusOK || w.Body.String() != want {
+				t.Errorf("base=%q %s: got %d %q, want %q", base, path, w.Code, w.Body.String(), want)
+			}
+		}
+
+		if base != "" {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/stats", nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("requests outside base path should 404, got %d", w.Code)
+			}
+		}
+	}
+}
+
+func TestParseRequestQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	parse := func(rawQuery string) (stats.RequestQuery, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/stats?"+rawQuery, nil)
+		return parseRequestQuery(c)
+	}
+
+	query, err := parse("since=100&until=200&offset=5&limit=50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != (stats.RequestQuery{Since: 100, Until: 200, Offset: 5, Limit: 50}) {
+		t.Errorf("unexpected query: %+v", query)
+	}
+	if query, err := parse(""); err != nil || query != (stats.RequestQuery{}) {
+		t.Errorf("expected zero query, got %+v %v", query, err)
+	}
+	for _, bad := range []string{"limit=-1", "offset=x", "since=yesterday", "until=-5"} {
+		if _, err := parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestTrackClient_TrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	collector := stats.NewCollector(nil)
+	r := gin.New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	r.NoRoute(func(c *gin.Context) {
+		defer trackClient(c, collector, "/api")()
+		c.Status(http.StatusBadGateway)
+	})
+
+	request := func(remoteAddr, xff string) {
+		req := httptest.NewRequest("GET", "/api/x", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", xff)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// 来自受信负载均衡的请求按 X-Forwarded-For 中的真实客户端统计
+	request("10.0.0.5:4321", "203.0.113.7")
+	request("10.0.0.5:4321", "203.0.113.7")
+	// 不受信来源伪造的 X-Forwarded-For 被忽略
+	request("198.51.100.9:1234", "203.0.113.99")
+
+	clients := map[string]stats.TopNEntry{}
+	for _, entry := range collector.TopClients(0) {
+		clients[entry.Key] = entry
+	}
+	if got := clients["203.0.113.7"]; got.Count != 2 || got.Errors != 2 {
+		t.Errorf("expected real client IP with 2 requests and 2 errors, got %+v", got)
+	}
+	if _, ok := clients["10.0.0.5"]; ok {
+		t.Error("load balancer IP should not be recorded as a client")
+	}
+	if _, ok := clients["203.0.113.99"]; ok {
+		t.Error("spoofed X-Forwarded-For from an untrusted source should be ignored")
+	}
+	if got := clients["198.51.100.9"]; got.Count != 1 {
+		t.Errorf("expected untrusted peer to be recorded by its address, got %+v", got)
+	}
+}